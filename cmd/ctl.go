@@ -0,0 +1,504 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/prober"
+)
+
+// ctlUsage db-probe ctl 的用法说明
+const ctlUsage = `用法: db-probe ctl [选项] <命令> [参数]
+
+查询和操作运行中的探针（通过 HTTP 端口或 management_socket）。
+
+命令:
+  status              目标总数和各状态的数量，列出不正常的目标
+  targets             列出所有目标及其最近一次探测结果
+  probe-now <name>    立即探测目标（name 也可以是目标 ID），目标不可用时退出码为 1
+  pause <name>        暂停目标的探测（计划维护），可以配合 -for、-reason、-hide-up
+  resume <name>       恢复目标的探测（解除暂停），-clear-lockout 同时解除账号锁定保护
+  loglevel [level]    查看日志级别，指定 level（debug、info、warn、error）时调整日志级别
+  support-bundle      下载支持包（脱敏配置、最近的日志、目标状态、指标等，tar.gz），排障时附在 issue 中
+
+选项:
+`
+
+// ctlOptions db-probe ctl 的全局选项
+type ctlOptions struct {
+	addr     string
+	socket   string
+	output   string
+	timeout  time.Duration
+	token    string
+	user     string
+	insecure bool
+	file     string
+	pauseFor time.Duration
+	reason   string
+	hideUp   bool
+	// clearLockout resume 时同时解除账号锁定保护
+	clearLockout bool
+}
+
+// ctlClient 访问探针管理接口的客户端
+type ctlClient struct {
+	base   string
+	client *http.Client
+	// token、user 访问配置了认证的 HTTP 端口或管理端口时使用（user 为 username:password），token 也用于 control_token
+	token string
+	user  string
+}
+
+// runCtl 执行 db-probe ctl 子命令，返回进程退出码：0 成功，1 目标不可用，2 用法或请求错误
+func runCtl(args []string) int {
+	opts := ctlOptions{}
+	fs := flag.NewFlagSet("db-probe ctl", flag.ContinueOnError)
+	fs.StringVar(&opts.addr, "addr", envOr("DB_PROBE_CTL_ADDR", "http://127.0.0.1:9100"), "探针 HTTP 地址（环境变量 DB_PROBE_CTL_ADDR）")
+	fs.StringVar(&opts.socket, "socket", os.Getenv("DB_PROBE_CTL_SOCKET"), "探针 management_socket 路径，配置后优先于 --addr（环境变量 DB_PROBE_CTL_SOCKET）")
+	fs.StringVar(&opts.output, "o", "table", "输出格式：table 或 json")
+	fs.DurationVar(&opts.timeout, "timeout", 30*time.Second, "请求超时时间")
+	fs.StringVar(&opts.token, "token", os.Getenv("DB_PROBE_CTL_TOKEN"), "Bearer token，端口配置了 bearer_token 认证时使用，端口没有认证时为 pause、resume、probe-now、loglevel 使用的 control_token（环境变量 DB_PROBE_CTL_TOKEN）")
+	fs.StringVar(&opts.user, "user", os.Getenv("DB_PROBE_CTL_USER"), "username:password，端口配置了 basic_auth 认证时使用（环境变量 DB_PROBE_CTL_USER）")
+	fs.BoolVar(&opts.insecure, "insecure", false, "HTTPS 地址不校验服务端证书")
+	fs.DurationVar(&opts.pauseFor, "for", 0, "pause 的持续时间，到期后自动恢复探测（默认直到 resume）")
+	fs.StringVar(&opts.reason, "reason", "", "pause 的原因，显示在 /targets 中")
+	fs.BoolVar(&opts.hideUp, "hide-up", false, "pause 期间不导出目标的 db_probe_up")
+	fs.BoolVar(&opts.clearLockout, "clear-lockout", false, "resume 时同时解除账号锁定保护（确认凭据已修复后使用）")
+	fs.StringVar(&opts.file, "f", "", "support-bundle 的保存路径，- 表示输出到标准输出（默认当前目录下的 db-probe-support-<主机名>-<时间>.tar.gz）")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), ctlUsage)
+		fs.PrintDefaults()
+	}
+	// 选项可以写在命令和参数之后（如 db-probe ctl probe-now mysql-prod -o json）
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) == 0 {
+		fs.Usage()
+		return 2
+	}
+	command, arg := positional[0], ""
+	if len(positional) > 1 {
+		arg = positional[1]
+	}
+	if opts.output != "table" && opts.output != "json" {
+		fmt.Fprintf(os.Stderr, "不支持的输出格式: %s（支持 table、json）\n", opts.output)
+		return 2
+	}
+
+	c := newCtlClient(opts)
+	var err error
+	code := 0
+	switch command {
+	case "status":
+		err = c.status(opts.output)
+	case "targets":
+		err = c.targets(opts.output)
+	case "probe-now":
+		code, err = c.probeNow(arg, opts.output)
+	case "pause":
+		err = c.pause(arg, opts)
+	case "resume":
+		err = c.resume(arg, opts)
+	case "loglevel":
+		err = c.logLevel(arg, opts.output)
+	case "support-bundle":
+		err = c.supportBundle(opts.file, opts.output)
+	case "silences":
+		err = fmt.Errorf("探针没有提供 %s 接口", command)
+	default:
+		fs.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "db-probe ctl %s: %v\n", command, err)
+		return 2
+	}
+	return code
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// newCtlClient 创建客户端，配置了 socket 时通过 unix socket 访问，请求地址中的主机名不起作用
+func newCtlClient(opts ctlOptions) *ctlClient {
+	c := &ctlClient{
+		base:   strings.TrimSuffix(opts.addr, "/"),
+		client: &http.Client{Timeout: opts.timeout},
+		token:  opts.token,
+		user:   opts.user,
+	}
+	if opts.insecure {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		c.client.Transport = transport
+	}
+	if opts.socket != "" {
+		socket := opts.socket
+		c.base = "http://db-probe"
+		c.client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}
+	}
+	return c
+}
+
+// newRequest 创建请求，配置了 token 或 user 时附加认证信息
+func (c *ctlClient) newRequest(method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, c.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else if user, password, ok := strings.Cut(c.user, ":"); ok {
+		req.SetBasicAuth(user, password)
+	}
+	return req, nil
+}
+
+// do 发送请求（body 为 JSON 请求体，可以为 nil）并把 JSON 响应解码到 out；accept 为允许的状态码（2xx 之外，如立即探测不可用时的 503）
+func (c *ctlClient) do(method, path string, body io.Reader, out interface{}, accept ...int) (int, error) {
+	req, err := c.newRequest(method, path, body)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("请求探针失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	ok := resp.StatusCode/100 == 2
+	for _, code := range accept {
+		ok = ok || resp.StatusCode == code
+	}
+	if !ok {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return resp.StatusCode, fmt.Errorf("%s %s 返回 %s: %s", method, path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp.StatusCode, fmt.Errorf("解析响应失败: %w", err)
+	}
+	return resp.StatusCode, nil
+}
+
+// ctlStatus status 命令的输出
+type ctlStatus struct {
+	Total     int                 `json:"total"`
+	Up        int                 `json:"up"`
+	Down      int                 `json:"down"`
+	Pending   int                 `json:"pending"` // 尚未完成首次探测
+	Stale     int                 `json:"stale"`
+	Protected int                 `json:"auth_lockout_protected"`
+	TestFire  int                 `json:"test_fire"`
+	Paused    int                 `json:"paused"`
+	Unhealthy []prober.TargetInfo `json:"unhealthy"`
+}
+
+func (c *ctlClient) status(output string) error {
+	var infos []prober.TargetInfo
+	if _, err := c.do(http.MethodGet, "/targets", nil, &infos); err != nil {
+		return err
+	}
+	s := ctlStatus{Total: len(infos), Unhealthy: []prober.TargetInfo{}}
+	for _, info := range infos {
+		switch {
+		case info.LastProbeTime == nil:
+			s.Pending++
+		case info.Up:
+			s.Up++
+		default:
+			s.Down++
+		}
+		if info.Stale {
+			s.Stale++
+		}
+		if info.AuthLockoutProtected {
+			s.Protected++
+		}
+		if info.TestFireUntil != nil {
+			s.TestFire++
+		}
+		if info.Paused {
+			s.Paused++
+		}
+		if info.LastProbeTime != nil && (!info.Up || info.Stale || info.AuthLockoutProtected) {
+			s.Unhealthy = append(s.Unhealthy, info)
+		}
+	}
+
+	if output == "json" {
+		return printJSON(s)
+	}
+	fmt.Printf("目标: %d  正常: %d  故障: %d  未探测: %d  过期: %d  锁定保护: %d  演练: %d  暂停: %d\n",
+		s.Total, s.Up, s.Down, s.Pending, s.Stale, s.Protected, s.TestFire, s.Paused)
+	if len(s.Unhealthy) > 0 {
+		fmt.Println()
+		printTargets(s.Unhealthy)
+	}
+	return nil
+}
+
+func (c *ctlClient) targets(output string) error {
+	var infos []prober.TargetInfo
+	if _, err := c.do(http.MethodGet, "/targets", nil, &infos); err != nil {
+		return err
+	}
+	if output == "json" {
+		return printJSON(infos)
+	}
+	printTargets(infos)
+	return nil
+}
+
+// ctlProbeResponse 立即探测接口的响应
+type ctlProbeResponse struct {
+	Up      bool                     `json:"up"`
+	Results []prober.ImmediateResult `json:"results"`
+}
+
+func (c *ctlClient) probeNow(name, output string) (int, error) {
+	if name == "" {
+		return 0, fmt.Errorf("缺少目标名称")
+	}
+	var resp ctlProbeResponse
+	if _, err := c.do(http.MethodPost, "/api/v1/probe/"+url.PathEscape(name), nil, &resp, http.StatusServiceUnavailable); err != nil {
+		return 0, err
+	}
+	if output == "json" {
+		if err := printJSON(resp); err != nil {
+			return 0, err
+		}
+	} else {
+		infos := make([]prober.TargetInfo, 0, len(resp.Results))
+		for _, result := range resp.Results {
+			infos = append(infos, result.TargetInfo)
+		}
+		printTargets(infos)
+	}
+	if !resp.Up {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (c *ctlClient) pause(name string, opts ctlOptions) error {
+	if name == "" {
+		return fmt.Errorf("缺少目标名称")
+	}
+	req := map[string]interface{}{"hide_up": opts.hideUp, "reason": opts.reason}
+	if opts.pauseFor > 0 {
+		req["duration"] = opts.pauseFor.String()
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	var resp prober.TargetPause
+	if _, err := c.do(http.MethodPost, "/api/v1/targets/"+url.PathEscape(name)+"/pause", bytes.NewReader(body), &resp); err != nil {
+		return err
+	}
+	if opts.output == "json" {
+		return printJSON(resp)
+	}
+	if resp.Until != nil {
+		fmt.Printf("%s: 已暂停探测，%s 自动恢复\n", resp.Target, resp.Until.Local().Format(time.DateTime))
+	} else {
+		fmt.Printf("%s: 已暂停探测，直到执行 resume\n", resp.Target)
+	}
+	return nil
+}
+
+func (c *ctlClient) resume(name string, opts ctlOptions) error {
+	if name == "" {
+		return fmt.Errorf("缺少目标名称")
+	}
+	path := "/api/v1/targets/" + url.PathEscape(name) + "/resume"
+	if opts.clearLockout {
+		path += "?clear_auth_lockout=true"
+	}
+	var resp prober.ResumeResult
+	if _, err := c.do(http.MethodPost, path, nil, &resp); err != nil {
+		return err
+	}
+	if opts.output == "json" {
+		return printJSON(resp)
+	}
+	if resp.Unpaused {
+		fmt.Printf("%s: 已恢复探测\n", resp.Name)
+	}
+	if resp.AuthLockoutCleared {
+		fmt.Printf("%s: 已解除账号锁定保护，下一个探测周期恢复正常探测\n", resp.Name)
+	}
+	if !resp.Resumed {
+		if opts.clearLockout {
+			fmt.Printf("%s: 目标没有暂停，也不处于账号锁定保护\n", resp.Name)
+		} else {
+			fmt.Printf("%s: 目标没有暂停（解除账号锁定保护需要 -clear-lockout）\n", resp.Name)
+		}
+	}
+	return nil
+}
+
+// logLevel 查看日志级别，level 不为空时调整日志级别（PUT 需要认证，与 pause 相同）
+func (c *ctlClient) logLevel(level, output string) error {
+	var resp struct {
+		Level string `json:"level"`
+	}
+	if level == "" {
+		if _, err := c.do(http.MethodGet, "/api/v1/loglevel", nil, &resp); err != nil {
+			return err
+		}
+	} else {
+		body, err := json.Marshal(map[string]string{"level": level})
+		if err != nil {
+			return err
+		}
+		if _, err := c.do(http.MethodPut, "/api/v1/loglevel", bytes.NewReader(body), &resp); err != nil {
+			return err
+		}
+	}
+	if output == "json" {
+		return printJSON(resp)
+	}
+	fmt.Println(resp.Level)
+	return nil
+}
+
+// supportBundle 下载支持包并保存到 file，未指定时使用探针返回的文件名
+// 支持包包含脱敏后的配置，文件权限为 0600
+func (c *ctlClient) supportBundle(file, output string) error {
+	req, err := c.newRequest(http.MethodGet, "/api/v1/support-bundle", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求探针失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("探针没有提供支持包接口：HTTP 端口（或独立管理端口）需要配置认证，或者通过 --socket 访问管理 socket")
+	}
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("GET /api/v1/support-bundle 返回 %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	if file == "-" {
+		_, err := io.Copy(os.Stdout, resp.Body)
+		return err
+	}
+	if file == "" {
+		file = fmt.Sprintf("db-probe-support-%s.tar.gz", time.Now().Format("20060102-150405"))
+		if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+			file = filepath.Base(params["filename"])
+		}
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file)
+		return fmt.Errorf("保存支持包失败: %w", err)
+	}
+
+	if output == "json" {
+		return printJSON(map[string]interface{}{"file": file, "bytes": n})
+	}
+	fmt.Printf("支持包已保存到 %s（%d 字节），其中的配置已脱敏，附到 issue 前请再检查一遍\n", file, n)
+	return nil
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printTargets 以表格输出目标，错误信息截断为一行
+func printTargets(infos []prober.TargetInfo) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTYPE\tENV\tIP\tSTATE\tDURATION\tLAST PROBE\tERROR")
+	for _, info := range infos {
+		lastProbe, duration := "-", "-"
+		if info.LastProbeTime != nil {
+			lastProbe = time.Since(*info.LastProbeTime).Truncate(time.Second).String() + " ago"
+			duration = fmt.Sprintf("%.1fms", info.DurationSeconds*1000)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			info.Name, info.Type, info.Env, info.IP, targetState(info), duration, lastProbe, truncate(info.LastError, 80))
+	}
+	tw.Flush()
+}
+
+// targetState 目标状态：pending（尚未探测）、up、down，附加 stale、locked（账号锁定保护）、test（故障演练）
+func targetState(info prober.TargetInfo) string {
+	state := "pending"
+	if info.LastProbeTime != nil {
+		state = "down"
+		if info.Up {
+			state = "up"
+		}
+	}
+	if info.Stale {
+		state += ",stale"
+	}
+	if info.AuthLockoutProtected {
+		state += ",locked"
+	}
+	if info.TestFireUntil != nil {
+		state += ",test"
+	}
+	if info.Paused {
+		state += ",paused"
+	}
+	return state
+}
+
+func truncate(s string, n int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
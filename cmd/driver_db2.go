@@ -0,0 +1,6 @@
+//go:build db2
+
+package main
+
+// DB2 驱动依赖 cgo 和 IBM CLI Driver（clidriver），不在默认构建中，使用 go build -tags db2（或 make build TAGS=db2）时注册
+import _ "github.com/ibmdb/go_ibm_db" // IBM DB2 驱动（注册为 go_ibm_db）
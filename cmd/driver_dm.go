@@ -0,0 +1,6 @@
+//go:build dm
+
+package main
+
+// 达梦驱动不在默认构建中，使用 go build -tags dm（或 make build TAGS=dm）时注册
+import _ "gitee.com/chunanyong/dm" // 达梦 DM 驱动（注册为 dm）
@@ -0,0 +1,6 @@
+//go:build snowflake
+
+package main
+
+// Snowflake 驱动依赖较多，不在默认构建中，使用 go build -tags snowflake（或 make build TAGS=snowflake）时注册
+import _ "github.com/snowflakedb/gosnowflake" // Snowflake 驱动（注册为 snowflake）
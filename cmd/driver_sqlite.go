@@ -0,0 +1,7 @@
+//go:build sqlite
+
+package main
+
+// SQLite 驱动只用于边缘设备上的本地数据库文件，不在默认构建中，使用 go build -tags sqlite（或 make build TAGS=sqlite）时注册
+// modernc.org/sqlite 为纯 Go 实现，不需要 cgo，可以与默认的 CGO_ENABLED=0 构建一起使用
+import _ "modernc.org/sqlite" // SQLite 驱动（注册为 sqlite）
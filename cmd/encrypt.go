@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/imkerbos/db-probe/internal/config"
+)
+
+// encryptUsage db-probe encrypt 的用法说明
+const encryptUsage = `用法: db-probe encrypt [选项] [明文]
+
+用主密钥加密密码等配置值，输出可以直接写入配置文件的 ENC(...)。
+没有指定明文时从标准输入读取第一行，避免明文留在 shell 历史中。
+主密钥取自 --key-file，未指定时依次取环境变量 DB_PROBE_MASTER_KEY、DB_PROBE_MASTER_KEY_FILE。
+
+  db-probe encrypt --gen-key > master.key      生成新的主密钥
+  db-probe encrypt --key-file master.key       从标准输入读取明文并加密
+
+选项:
+`
+
+// runEncrypt 执行 db-probe encrypt 子命令，返回进程退出码：0 成功，2 用法或加密错误
+func runEncrypt(args []string) int {
+	fs := flag.NewFlagSet("db-probe encrypt", flag.ContinueOnError)
+	keyFile := fs.String("key-file", "", "主密钥文件路径")
+	genKey := fs.Bool("gen-key", false, "生成新的主密钥（base64 编码）并输出")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), encryptUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *genKey {
+		key, err := config.GenerateMasterKey()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "生成主密钥失败: %v\n", err)
+			return 2
+		}
+		fmt.Println(key)
+		return 0
+	}
+
+	key, err := config.LoadMasterKey(*keyFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	var plaintext string
+	switch fs.NArg() {
+	case 0:
+		if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice != 0 {
+			fmt.Fprint(os.Stderr, "明文: ")
+		}
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Fprintf(os.Stderr, "读取明文失败: %v\n", err)
+			return 2
+		}
+		plaintext = strings.TrimRight(line, "\r\n")
+	case 1:
+		plaintext = fs.Arg(0)
+	default:
+		fs.Usage()
+		return 2
+	}
+
+	value, err := config.Encrypt(plaintext, key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加密失败: %v\n", err)
+		return 2
+	}
+	fmt.Println(value)
+	return 0
+}
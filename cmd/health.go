@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/internal/notify"
+	"github.com/imkerbos/db-probe/internal/prober"
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+// healthChecker 处理 /health 请求
+// 默认返回 HTTP 200 和 "OK"，用于 Kubernetes/Docker 健康检查；
+// 开启 health.checks 时检查探测调度和通知队列，任一项异常时返回 503，响应体为各项检查结果（JSON）
+type healthChecker struct {
+	cfg      config.HealthConfig
+	probe    *prober.Prober
+	notifier *notify.Notifier // 未配置通知时为 nil
+	reload   *reloader
+
+	// unhealthy 上一次检查的结果，只在状态变化时输出日志，避免每次健康检查请求都输出
+	unhealthy atomic.Bool
+}
+
+// healthReport /health 的响应体
+type healthReport struct {
+	Status string       `json:"status"` // ok 或 unhealthy
+	Checks healthChecks `json:"checks"`
+}
+
+type healthChecks struct {
+	Config    configStatus           `json:"config"`
+	Scheduler prober.SchedulerStatus `json:"scheduler"`
+	Notify    *notifyStatus          `json:"notify,omitempty"`
+}
+
+// notifyStatus 通知队列状态，任一渠道卡住时为异常
+type notifyStatus struct {
+	OK       bool                   `json:"ok"`
+	Channels []notify.ChannelStatus `json:"channels"`
+}
+
+func (h *healthChecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.cfg.Checks {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+		return
+	}
+
+	report := h.check(time.Now())
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// check 执行各项检查，状态变化时输出日志
+func (h *healthChecker) check(now time.Time) healthReport {
+	report := healthReport{Status: "ok"}
+	report.Checks.Config = h.reload.configStatus()
+	report.Checks.Scheduler = h.probe.SchedulerHealth(now, h.cfg.StallIntervals)
+	healthy := report.Checks.Config.OK && report.Checks.Scheduler.OK
+	if h.notifier != nil {
+		status := &notifyStatus{OK: true, Channels: h.notifier.Health(now, h.cfg.NotifyStallTimeout)}
+		for _, channel := range status.Channels {
+			status.OK = status.OK && channel.OK
+		}
+		report.Checks.Notify = status
+		healthy = healthy && status.OK
+	}
+	if !healthy {
+		report.Status = "unhealthy"
+	}
+
+	if h.unhealthy.Swap(!healthy) != !healthy {
+		if healthy {
+			logger.L().Infow("健康检查恢复正常")
+		} else {
+			logger.L().Errorw("健康检查异常，/health 返回 503", "checks", report.Checks)
+		}
+	}
+	return report
+}
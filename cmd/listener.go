@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+// authExemptPaths 配置了认证时仍然不需要认证的接口：/health 供 Kubernetes/Docker 健康检查，webhook 自带 HMAC 签名校验
+var authExemptPaths = map[string]bool{
+	"/health":         true,
+	"/api/v1/webhook": true,
+}
+
+// startHTTPServer 在 addr 上启动 HTTP 服务，配置了证书时使用 HTTPS，配置了认证时请求需要认证
+// 监听失败时直接返回错误，避免端口被占用时静默退出
+func startHTTPServer(name, addr string, handler http.Handler, tlsCfg config.ListenerTLSConfig, auth config.ListenerAuthConfig) (*http.Server, error) {
+	server := &http.Server{Addr: addr, Handler: requireAuth(auth, handler)}
+	if tlsCfg.CertFile != "" {
+		serverTLS, err := newServerTLSConfig(tlsCfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		server.TLSConfig = serverTLS
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("%s 监听 %s 失败: %w", name, addr, err)
+	}
+
+	go func() {
+		var err error
+		if server.TLSConfig != nil {
+			err = server.ServeTLS(ln, "", "")
+		} else {
+			err = server.Serve(ln)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			logger.L().Fatalw("HTTP 服务器异常退出", "server", name, "error", err)
+		}
+	}()
+	return server, nil
+}
+
+// requireAuth 按 basic_auth 或 bearer_token 校验请求，未配置认证时原样返回 handler
+func requireAuth(auth config.ListenerAuthConfig, next http.Handler) http.Handler {
+	if auth.BasicAuth.Username == "" && auth.BearerToken == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authExemptPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if auth.BearerToken != "" {
+			if !secureEqual(r.Header.Get("Authorization"), "Bearer "+auth.BearerToken) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="db-probe"`)
+				http.Error(w, "需要认证", http.StatusUnauthorized)
+				return
+			}
+		} else {
+			user, password, ok := r.BasicAuth()
+			// 用户名和密码都比较完，避免按耗时区分用户名是否正确
+			userOK := secureEqual(user, auth.BasicAuth.Username)
+			passwordOK := secureEqual(password, auth.BasicAuth.Password)
+			if !ok || !userOK || !passwordOK {
+				w.Header().Set("WWW-Authenticate", `Basic realm="db-probe"`)
+				http.Error(w, "需要认证", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// secureEqual 以固定耗时比较字符串（先取摘要，长度不同也不提前返回）
+func secureEqual(a, b string) bool {
+	ha, hb := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+// newServerTLSConfig 创建 HTTPS 配置，证书在握手时按文件修改时间重新加载；配置 client_ca_file 时要求并校验客户端证书
+func newServerTLSConfig(cfg config.ListenerTLSConfig) (*tls.Config, error) {
+	certs := &certReloader{certFile: cfg.CertFile, keyFile: cfg.KeyFile}
+	if _, err := certs.get(); err != nil {
+		return nil, err
+	}
+	tlsCfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return certs.get()
+		},
+	}
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取客户端 CA 证书失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("客户端 CA 证书文件中没有合法的证书: %s", cfg.ClientCAFile)
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsCfg, nil
+}
+
+// certReloader 证书文件变化后重新加载证书，加载失败时继续使用上一次加载的证书
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func (c *certReloader) get() (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		if c.cert != nil {
+			return c.cert, nil
+		}
+		return nil, fmt.Errorf("读取证书失败: %w", err)
+	}
+	modTime := certInfo.ModTime()
+	if keyInfo, err := os.Stat(c.keyFile); err == nil && keyInfo.ModTime().After(modTime) {
+		modTime = keyInfo.ModTime()
+	}
+	if c.cert != nil && modTime.Equal(c.modTime) {
+		return c.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		if c.cert != nil {
+			logger.L().Warnw("重新加载 TLS 证书失败，继续使用当前证书", "cert_file", c.certFile, "error", err)
+			c.modTime = modTime
+			return c.cert, nil
+		}
+		return nil, fmt.Errorf("加载证书失败: %w", err)
+	}
+	if c.cert != nil {
+		logger.L().Infow("TLS 证书已重新加载", "cert_file", c.certFile)
+	}
+	c.cert, c.modTime = &cert, modTime
+	return c.cert, nil
+}
@@ -1,5 +1,5 @@
 // Package main 是 db-probe 程序的入口点
-// db-probe 是一个数据库可用性探针，支持监控 MySQL、TiDB 和 Oracle 数据库
+// db-probe 是一个数据库可用性探针，支持监控 MySQL、TiDB、Oracle、SQL Server、CockroachDB 等数据库
 // 通过周期性执行轻量级 SQL 查询来检测数据库可用性和延迟
 // 并通过 Prometheus 指标暴露监控数据
 package main
@@ -12,23 +12,47 @@ import (
 	"os/signal"
 	"syscall"
 
-	_ "github.com/go-sql-driver/mysql" // MySQL/TiDB 驱动
-	_ "github.com/sijms/go-ora/v2"     // Oracle 驱动 v2（纯 Go 实现，推荐用于 Oracle 10.2+）
+	_ "github.com/go-sql-driver/mysql"  // MySQL/TiDB 驱动
+	_ "github.com/lib/pq"               // PostgreSQL 协议驱动（CockroachDB，注册为 postgres）
+	_ "github.com/microsoft/go-mssqldb" // SQL Server 驱动（注册为 sqlserver）
+	_ "github.com/sijms/go-ora/v2"      // Oracle 驱动 v2（纯 Go 实现，推荐用于 Oracle 10.2+）
 
+	"github.com/imkerbos/db-probe/internal/api"
+	"github.com/imkerbos/db-probe/internal/changefeed"
 	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/internal/discovery"
+	"github.com/imkerbos/db-probe/internal/localstore"
+	"github.com/imkerbos/db-probe/internal/metrics"
+	"github.com/imkerbos/db-probe/internal/notify"
 	"github.com/imkerbos/db-probe/internal/prober"
+	"github.com/imkerbos/db-probe/internal/remotewrite"
+	"github.com/imkerbos/db-probe/internal/secrets"
 	"github.com/imkerbos/db-probe/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
+	// db-probe ctl 查询运行中的探针，不启动探针
+	if len(os.Args) > 1 && os.Args[1] == "ctl" {
+		os.Exit(runCtl(os.Args[2:]))
+	}
+	// db-probe support-bundle 等同于 db-probe ctl support-bundle，从运行中的探针下载支持包
+	if len(os.Args) > 1 && os.Args[1] == "support-bundle" {
+		os.Exit(runCtl(append([]string{"support-bundle"}, os.Args[2:]...)))
+	}
+	// db-probe encrypt 加密配置中的密码，不启动探针
+	if len(os.Args) > 1 && os.Args[1] == "encrypt" {
+		os.Exit(runEncrypt(os.Args[2:]))
+	}
+
 	// 初始化 logger（JSON 格式输出）
 	if err := logger.InitLogger(); err != nil {
 		panic(fmt.Sprintf("初始化 logger 失败: %v", err))
 	}
 	defer logger.Sync()
 
-	// 加载配置（固定从 configs/config.yaml 读取）
+	// 加载配置（从 configs/config.yaml 读取，也可以是 config.json、config.toml）
 	cfg, err := config.Load()
 	if err != nil {
 		logger.L().Fatalw("加载配置失败", "error", err)
@@ -39,7 +63,19 @@ func main() {
 		"probe_interval", cfg.ProbeInterval,
 		"probe_timeout", cfg.ProbeTimeout,
 		"databases_count", len(cfg.Databases),
+		"low_memory", cfg.LowMemory,
 	)
+	metrics.SetConfigGeneration(1)
+	metrics.RecordConfigReload(true)
+	metrics.ConfigureRuntimeCollectors(cfg.RuntimeMetrics)
+	// 需要在创建目标之前设置，目标的 same_zone label 依赖探针所在区域
+	metrics.SetProbeZone(cfg.Zone)
+	metrics.SetLowMemory(cfg.LowMemory)
+	metrics.SetFeatures(cfg.FeatureStates())
+	logger.L().Infow("实验功能", "enabled", cfg.EnabledFeatures())
+	if unsupported := cfg.UnsupportedFeatures(); len(unsupported) > 0 {
+		logger.L().Warnw("features 中开启了当前版本不支持的功能，已忽略", "features", unsupported)
+	}
 
 	// 初始化探针
 	probe, err := prober.NewProber(cfg)
@@ -47,50 +83,246 @@ func main() {
 		logger.L().Fatalw("初始化探针失败", "error", err)
 	}
 
+	// 记录状态变化（可选），需要在探针启动前订阅，才能记录到首次探测结果
+	// defer 顺序保证探针先停止，changefeed 再写完剩余事件
+	if cfg.Changefeed.Path != "" {
+		feed, err := changefeed.New(cfg.Changefeed)
+		if err != nil {
+			logger.L().Fatalw("初始化 changefeed 失败", "error", err)
+		}
+		probe.Subscribe(feed.Handle)
+		feed.Start()
+		defer feed.Stop()
+	}
+
+	// 状态变化通知（可选），通知先写入磁盘队列再发送，探针停止后队列中未发送的通知在下次启动后继续发送
+	var notifier *notify.Notifier
+	if len(cfg.Notify.Webhooks) > 0 {
+		notifier, err = notify.New(cfg.Notify)
+		if err != nil {
+			logger.L().Fatalw("初始化状态变化通知失败", "error", err)
+		}
+		probe.Subscribe(notifier.Handle)
+		notifier.Start()
+		defer notifier.Stop()
+	}
+
 	// 启动探针
 	probe.Start()
 	defer probe.Stop()
 
+	// 从 SQL 清单库发现目标（可选），defer 顺序保证发现先停止，探针再停止
+	if cfg.Discovery.SQL.DSN != "" {
+		inventory, err := discovery.NewSQL(cfg.Discovery.SQL, probe)
+		if err != nil {
+			logger.L().Fatalw("初始化目标发现失败", "error", err)
+		}
+		inventory.Start()
+		defer inventory.Stop()
+	}
+	// 从 etcd、Consul KV 前缀发现目标（可选），键变化后立即同步
+	if cfg.Discovery.KV.Backend != "" {
+		kvInventory, err := discovery.NewKV(cfg.Discovery.KV, probe)
+		if err != nil {
+			logger.L().Fatalw("初始化 KV 目标发现失败", "error", err)
+		}
+		kvInventory.Start()
+		defer kvInventory.Stop()
+	}
+
+	// 从 Kubernetes Secret 读取凭据（可选），配置了 secret_ref 的目标读取到凭据后才开始探测
+	// defer 顺序保证先停止读取，探针再停止
+	var secretTargets, storeTargets []config.DBConfig
+	for _, dbCfg := range cfg.Databases {
+		if dbCfg.SecretRef != nil {
+			secretTargets = append(secretTargets, dbCfg)
+		}
+		if dbCfg.HasCredentialRefs() {
+			storeTargets = append(storeTargets, dbCfg)
+		}
+	}
+	if len(secretTargets) > 0 {
+		kubeSecrets, err := secrets.NewKubernetes(cfg.Kubernetes, secretTargets, probe)
+		if err != nil {
+			logger.L().Fatalw("初始化 Kubernetes Secret 凭据读取失败", "error", err)
+		}
+		kubeSecrets.Start()
+		defer kubeSecrets.Stop()
+	}
+
+	// 从外部密钥存储（Vault、AWS Secrets Manager）读取凭据（可选），配置了 user_ref、password_ref、dsn_ref 的目标读取到凭据后才开始探测
+	if len(storeTargets) > 0 {
+		store, err := secrets.NewStore(cfg.Secrets, storeTargets, probe)
+		if err != nil {
+			logger.L().Fatalw("初始化外部密钥存储凭据读取失败", "error", err)
+		}
+		// 目标认证失败时立即重新读取凭据（密码可能已经轮换）
+		probe.Subscribe(store.Handle)
+		store.Start()
+		defer store.Stop()
+	}
+
+	// 所有指标追加 global_labels（可选），/metrics、remote write 和本地时序存储使用同一个 gatherer
+	gatherer, err := metrics.WithGlobalLabels(prometheus.DefaultGatherer, cfg.GlobalLabels)
+	if err != nil {
+		logger.L().Fatalw("初始化全局 label 失败", "error", err)
+	}
+	metricsHandler := promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+
+	// 启动 remote write 推送（可选）
+	if cfg.RemoteWrite.URL != "" {
+		writer, err := remotewrite.New(cfg.RemoteWrite, gatherer)
+		if err != nil {
+			logger.L().Fatalw("初始化 remote write 失败", "error", err)
+		}
+		writer.Start()
+		defer writer.Stop()
+	}
+
+	// 管理接口（/targets、/api/v1）的路由，未配置独立管理端口时与 /metrics 共用 HTTP 端口
+	mgmtMux := http.NewServeMux()
+
+	// 启动本地时序存储（可选），没有 Prometheus 的站点通过 /api/v1/query_range 查询历史，导出接口按时间窗口计算可用率
+	var history api.History
+	if cfg.LocalStorage.Path != "" {
+		store, err := localstore.New(cfg.LocalStorage, gatherer)
+		if err != nil {
+			logger.L().Fatalw("初始化本地时序存储失败", "error", err)
+		}
+		store.Start()
+		defer store.Stop()
+		mgmtMux.HandleFunc("/api/v1/query_range", store.QueryRangeHandler)
+		history = store
+	}
+
+	// SIGHUP、配置文件变化时重新加载配置，/health 读取最近一次重新加载的结果
+	reload := &reloader{probe: probe, current: cfg, generation: 1, status: configStatus{Generation: 1}}
+	health := &healthChecker{cfg: cfg.Health, probe: probe, notifier: notifier, reload: reload}
+
 	// 设置 HTTP 路由
-	http.HandleFunc("/health", healthHandler)
-	http.HandleFunc("/targets", func(w http.ResponseWriter, r *http.Request) {
+	mgmtMux.Handle("/health", health)
+	mgmtMux.HandleFunc("/targets", func(w http.ResponseWriter, r *http.Request) {
 		targetsHandler(w, r, probe)
 	})
-	http.Handle("/metrics", promhttp.Handler())
+	api.Register(mgmtMux, probe, cfg, history)
 
-	// 启动 HTTP 服务器
-	server := &http.Server{
-		Addr:    cfg.ListenAddress,
-		Handler: nil,
-	}
+	// 启动 HTTP 服务器；配置了独立管理端口时 HTTP 端口只提供 /metrics 和 /health
+	separate := cfg.Management.ListenAddress != ""
 
-	go func() {
-		logger.L().Infow("HTTP 服务器启动",
-			"listen_address", cfg.ListenAddress,
-			"metrics_endpoint", "/metrics",
-			"health_endpoint", "/health",
+	// 支持包包含脱敏后的配置和日志，只在提供管理接口的端口配置了认证时通过 HTTP 提供（管理 socket 上始终提供）；
+	// 端口没有认证时暂停和恢复探测、立即探测、调整日志级别需要 control_token
+	mgmtAuth := cfg.ListenAuth
+	if separate {
+		mgmtAuth = cfg.Management.Auth
+	}
+	mgmtAuthenticated := mgmtAuth.BasicAuth.Username != "" || mgmtAuth.BearerToken != ""
+	if mgmtAuthenticated {
+		api.RegisterSupportBundle(mgmtMux, probe, cfg, metricsHandler)
+	}
+	if !cfg.ManagementSocketOnly {
+		api.RegisterControl(mgmtMux, probe, mgmtAuthenticated, cfg.ControlToken)
+	}
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/health", health)
+	metricsMux.Handle("/metrics", metricsHandler)
+	if cfg.ProbeEndpoint {
+		api.RegisterScrapeProbe(metricsMux, probe, cfg.GlobalLabels)
+	}
+	if !separate {
+		metricsMux.Handle("/", mgmtMux)
+	}
+	server, err := startHTTPServer("http", cfg.ListenAddress, metricsMux, cfg.ListenTLS, cfg.ListenAuth)
+	if err != nil {
+		logger.L().Fatalw("HTTP 服务器启动失败", "error", err)
+	}
+	defer server.Close()
+	logger.L().Infow("HTTP 服务器启动",
+		"listen_address", cfg.ListenAddress,
+		"tls", cfg.ListenTLS.CertFile != "",
+		"metrics_endpoint", "/metrics",
+		"health_endpoint", "/health",
+		"probe_endpoint", cfg.ProbeEndpoint,
+		"management_endpoints", !separate,
+	)
+	if separate {
+		mgmtServer, err := startHTTPServer("management", cfg.Management.ListenAddress, mgmtMux, cfg.Management.TLS, cfg.Management.Auth)
+		if err != nil {
+			logger.L().Fatalw("管理端口启动失败", "error", err)
+		}
+		defer mgmtServer.Close()
+		logger.L().Infow("管理端口启动",
+			"listen_address", cfg.Management.ListenAddress,
+			"tls", cfg.Management.TLS.CertFile != "",
 			"targets_endpoint", "/targets",
+			"api_endpoint", "/api/v1",
 		)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.L().Fatalw("HTTP 服务器启动失败", "error", err)
+	}
+
+	// 管理 socket（可选），本机的 db-probe ctl 不经过 HTTP 端口即可访问，访问权限由文件权限控制，不需要认证
+	// socket 上始终提供运维操作接口，其余请求与 HTTP 端口、管理端口相同
+	if cfg.ManagementSocket != "" {
+		socketMux := http.NewServeMux()
+		api.RegisterControl(socketMux, probe, true, "")
+		api.RegisterSupportBundle(socketMux, probe, cfg, metricsHandler)
+		socketMux.Handle("/metrics", metricsHandler)
+		socketMux.Handle("/", mgmtMux)
+		socketServer, err := serveManagementSocket(cfg, socketMux)
+		if err != nil {
+			logger.L().Fatalw("启动管理 socket 失败", "error", err)
 		}
-	}()
+		defer socketServer.Close()
+	}
+
+	// 监听凭据文件（user_file、password_file）变化，如 Kubernetes Secret 轮换后使用新凭据重建连接，无需重启
+	credWatcher, err := config.NewCredentialWatcher(reload.credentialFiles(), cfg.WatchConfigDebounce, reload.reloadCredentials)
+	if err != nil {
+		logger.L().Fatalw("初始化凭据文件监听失败", "error", err)
+	}
+	reload.credentials = credWatcher
+	credWatcher.Start()
+	defer credWatcher.Stop()
 
-	// 等待中断信号
+	// 监听配置文件变化（可选），如 Kubernetes 中 ConfigMap 更新后自动重新加载
+	if cfg.WatchConfig {
+		watcher, err := config.NewWatcher(cfg.ConfigFiles(), cfg.WatchConfigDebounce, reload.reload)
+		if err != nil {
+			logger.L().Fatalw("初始化配置文件监听失败", "error", err)
+		}
+		if cfg.DatabasesDir != "" {
+			if err := watcher.WatchDatabasesDir(cfg.DatabasesDir); err != nil {
+				logger.L().Fatalw("初始化配置文件监听失败", "error", err)
+			}
+		}
+		reload.watcher = watcher
+		watcher.Start()
+		defer watcher.Stop()
+	}
+
+	// 轮询远端配置（可选），配置变化后自动重新加载，集中管理的探针无需重新部署
+	if cfg.ConfigURL != "" {
+		poller := newRemoteConfigPoller(cfg.RemoteConfig.Interval, reload.reload)
+		poller.Start()
+		defer poller.Stop()
+	}
+
+	// 等待中断信号，SIGHUP 重新加载配置文件中的目标，SIGUSR1、SIGUSR2 调低、调高日志级别
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2)
+	for sig := <-sigChan; sig != os.Interrupt && sig != syscall.SIGTERM; sig = <-sigChan {
+		switch sig {
+		case syscall.SIGHUP:
+			logger.L().Info("收到 SIGHUP，重新加载配置")
+			reload.reload()
+		case syscall.SIGUSR1:
+			logger.IncreaseVerbosity("收到 SIGUSR1，日志级别已调整")
+		case syscall.SIGUSR2:
+			logger.DecreaseVerbosity("收到 SIGUSR2，日志级别已调整")
+		}
+	}
 
 	logger.L().Info("收到停止信号，正在关闭...")
 }
 
-// healthHandler 处理健康检查请求
-// 返回 HTTP 200 状态码和 "OK" 文本，用于 Kubernetes/Docker 健康检查
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
-}
-
 // targetsHandler 处理目标信息查询请求
 // 返回所有数据库目标的详细信息（名称、类型、主机、IP、最后错误等）
 // 以 JSON 格式返回，用于调试和监控
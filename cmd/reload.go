@@ -0,0 +1,220 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/internal/metrics"
+	"github.com/imkerbos/db-probe/internal/prober"
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+// reloader 收到 SIGHUP 或（开启 watch_config 时）检测到配置文件变化时重新加载配置文件，把配置文件中的目标对齐到新配置
+// 新增的目标开始探测，删除的目标停止探测并删除指标，字段变化的目标重建；未变化的目标不受影响，计数器保持连续
+// 全局配置项（监听地址、探测间隔、通知等）和配置了 secret_ref、*_ref 的目标在启动时生效，变更后需要重启
+type reloader struct {
+	// mu 保证 SIGHUP 和文件监听触发的重新加载依次执行
+	mu    sync.Mutex
+	probe *prober.Prober
+	// current 当前生效的配置：全局配置项为启动时的值，目标为最近一次成功加载的配置
+	// 与新配置比较得出的差异因此会持续提示尚未生效的全局配置项
+	current    *config.Config
+	generation uint64
+	// credentials 凭据文件监听，重新加载配置后更新监听的文件
+	credentials *config.Watcher
+	// watcher 配置文件监听（watch_config），重新加载配置后按新的 includes 更新监听的文件
+	watcher *config.Watcher
+
+	// status 最近一次重新加载的结果，由 statusMu 单独保护，/health 读取时不等待正在进行的重新加载
+	statusMu sync.Mutex
+	status   configStatus
+}
+
+// configStatus 配置加载状态，供 /health 使用
+// 重新加载失败时探针继续使用当前配置，不判定为异常：重启后会加载同样有问题的配置文件而无法启动
+type configStatus struct {
+	OK              bool       `json:"ok"`
+	Generation      uint64     `json:"generation"`
+	LastReloadTime  *time.Time `json:"last_reload_time,omitempty"`
+	LastReloadError string     `json:"last_reload_error,omitempty"`
+}
+
+// record 记录重新加载的结果，err 为 nil 表示成功
+func (r *reloader) record(err error) {
+	now := time.Now()
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	r.status.Generation = r.generation
+	r.status.LastReloadTime = &now
+	r.status.LastReloadError = ""
+	if err != nil {
+		r.status.LastReloadError = err.Error()
+	}
+}
+
+// configStatus 返回配置加载状态
+func (r *reloader) configStatus() configStatus {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	status := r.status
+	status.OK = true
+	return status
+}
+
+// reload 重新加载配置，加载或校验失败时继续使用当前配置
+func (r *reloader) reload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	newCfg, err := config.Load()
+	if err != nil {
+		metrics.RecordConfigReload(false)
+		logger.L().Errorw("重新加载配置失败，继续使用当前配置", "error", err)
+		r.record(err)
+		return
+	}
+	metrics.RecordConfigReload(true)
+	defer r.record(nil)
+	if r.watcher != nil {
+		if err := r.watcher.SetPaths(newCfg.ConfigFiles()); err != nil {
+			logger.L().Warnw("更新配置文件监听失败，新增的 includes 文件变化后需要手动重新加载配置", "error", err)
+		}
+	}
+
+	diff := config.DiffConfigs(r.current, newCfg)
+	if diff.Empty() {
+		logger.L().Infow("重新加载配置：配置没有变化")
+		return
+	}
+	if len(diff.Global) > 0 {
+		fields := make([]string, 0, len(diff.Global))
+		for _, change := range diff.Global {
+			fields = append(fields, change.Field)
+		}
+		logger.L().Warnw("全局配置项变更需要重启探针才能生效", "fields", fields)
+	}
+
+	// 配置了 secret_ref、*_ref 的目标由 Kubernetes Secret 或外部密钥存储读取凭据后加入探测，保持启动时的配置
+	secretTargets := make(map[string]bool)
+	var databases []config.DBConfig
+	for _, dbCfg := range r.current.Databases {
+		if dbCfg.ExternalCredentials() {
+			secretTargets[dbCfg.Name] = true
+			databases = append(databases, dbCfg)
+		}
+	}
+	var fileTargets []config.DBConfig
+	var pending []string
+	for _, dbCfg := range newCfg.Databases {
+		if dbCfg.ExternalCredentials() || secretTargets[dbCfg.Name] {
+			pending = append(pending, dbCfg.Name)
+			continue
+		}
+		fileTargets = append(fileTargets, dbCfg)
+	}
+	databases = append(databases, fileTargets...)
+	if changed := changedSecretTargets(diff, secretTargets, pending); len(changed) > 0 {
+		logger.L().Warnw("配置了 secret_ref、*_ref 的目标变更需要重启探针才能生效", "targets", changed)
+	}
+
+	result := r.probe.SyncTargets("", fileTargets)
+
+	effective := *r.current
+	effective.Databases = databases
+	r.current = &effective
+	if r.credentials != nil {
+		if err := r.credentials.SetPaths(r.credentialFiles()); err != nil {
+			logger.L().Warnw("更新凭据文件监听失败，新增的凭据文件变化后需要重新加载配置", "error", err)
+		}
+	}
+	r.generation++
+	metrics.SetConfigGeneration(r.generation)
+	logger.L().Infow("重新加载配置成功",
+		"generation", r.generation,
+		"diff", diff,
+		"added", result.Added,
+		"updated", result.Updated,
+		"removed", result.Removed,
+		"skipped", result.Skipped,
+	)
+}
+
+// reloadCredentials 凭据文件（user_file、password_file）变化后重新读取，凭据变化的目标使用新凭据重建连接
+// 只更新当前生效配置中的凭据，配置文件中尚未重新加载的其他改动不会因此生效；
+// 文件读取失败（如轮换过程中文件暂时为空）时继续使用当前凭据，下一次文件变化时再读取
+func (r *reloader) reloadCredentials() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	databases := make([]config.DBConfig, 0, len(r.current.Databases))
+	var fileTargets []config.DBConfig
+	var rotated []string
+	for _, dbCfg := range r.current.Databases {
+		// 配置了 secret_ref、*_ref 的目标由 Kubernetes Secret 或外部密钥存储管理，保持启动时的配置
+		if !dbCfg.ExternalCredentials() {
+			if len(dbCfg.CredentialFiles()) > 0 {
+				changed, err := config.RefreshCredentialFiles(&dbCfg)
+				if err != nil {
+					logger.L().Warnw("重新读取凭据文件失败，继续使用当前凭据", "db_name", dbCfg.Name, "error", err)
+				} else if changed {
+					rotated = append(rotated, dbCfg.Name)
+				}
+			}
+			fileTargets = append(fileTargets, dbCfg)
+		}
+		databases = append(databases, dbCfg)
+	}
+	if len(rotated) == 0 {
+		logger.L().Infow("凭据文件内容没有变化")
+		return
+	}
+
+	result := r.probe.SyncTargets("", fileTargets)
+	for _, name := range result.Updated {
+		metrics.RecordCredentialRotation(name, "file")
+	}
+	effective := *r.current
+	effective.Databases = databases
+	r.current = &effective
+	logger.L().Infow("凭据文件已变化，已使用新凭据重建连接", "targets", rotated, "updated", result.Updated)
+}
+
+// credentialFiles 返回当前生效配置中各目标引用的凭据文件，调用方需持有 mu（启动阶段除外）
+func (r *reloader) credentialFiles() []string {
+	var paths []string
+	for _, dbCfg := range r.current.Databases {
+		if !dbCfg.ExternalCredentials() {
+			paths = append(paths, dbCfg.CredentialFiles()...)
+		}
+	}
+	return paths
+}
+
+// changedSecretTargets 返回差异中涉及外部凭据的目标：原来配置了 secret_ref、*_ref，或新配置中配置了 secret_ref、*_ref
+func changedSecretTargets(diff *config.Diff, secretTargets map[string]bool, pending []string) []string {
+	involved := make(map[string]bool, len(secretTargets)+len(pending))
+	for name := range secretTargets {
+		involved[name] = true
+	}
+	for _, name := range pending {
+		involved[name] = true
+	}
+	var changed []string
+	for _, name := range diff.Added {
+		if involved[name] {
+			changed = append(changed, name)
+		}
+	}
+	for _, name := range diff.Removed {
+		if involved[name] {
+			changed = append(changed, name)
+		}
+	}
+	for _, change := range diff.Changed {
+		if involved[change.Name] {
+			changed = append(changed, change.Name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/internal/metrics"
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+// remoteConfigPoller 按 remote_config.interval 轮询 config_url，配置变化后与 SIGHUP 一样重新加载
+// 请求失败时继续使用当前配置，只在开始失败和恢复时各输出一条日志
+type remoteConfigPoller struct {
+	interval time.Duration
+	onChange func()
+	done     chan struct{}
+	stopped  chan struct{}
+}
+
+func newRemoteConfigPoller(interval time.Duration, onChange func()) *remoteConfigPoller {
+	return &remoteConfigPoller{
+		interval: interval,
+		onChange: onChange,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+// Start 启动轮询
+func (p *remoteConfigPoller) Start() {
+	logger.L().Infow("远端配置轮询已启用", "interval", p.interval)
+	go p.run()
+}
+
+// Stop 停止轮询，等待正在进行的重新加载完成
+func (p *remoteConfigPoller) Stop() {
+	close(p.done)
+	<-p.stopped
+}
+
+func (p *remoteConfigPoller) run() {
+	defer close(p.stopped)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	failing := false
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := config.PollRemoteConfig()
+		if err != nil {
+			metrics.RecordRemoteConfigFetch("error")
+			if !failing {
+				logger.L().Warnw("拉取远端配置失败，继续使用当前配置", "error", err)
+				failing = true
+			}
+			continue
+		}
+		if failing {
+			logger.L().Infow("拉取远端配置恢复正常")
+			failing = false
+		}
+		if !changed {
+			metrics.RecordRemoteConfigFetch("not_modified")
+			continue
+		}
+		metrics.RecordRemoteConfigFetch("updated")
+		logger.L().Infow("远端配置已变化，重新加载配置")
+		p.onChange()
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"strconv"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+// serveManagementSocket 在 unix socket 上提供管理接口，供本机的 db-probe ctl 使用
+// 访问权限由 socket 文件的权限（management_socket_mode）和属组（management_socket_group）控制；上次异常退出遗留的 socket 文件会先删除
+func serveManagementSocket(cfg *config.Config, handler http.Handler) (*http.Server, error) {
+	path := cfg.ManagementSocket
+	mode, err := config.ParseFileMode(cfg.ManagementSocketMode)
+	if err != nil {
+		return nil, err
+	}
+	gid := -1
+	if cfg.ManagementSocketGroup != "" {
+		if gid, err = lookupGroup(cfg.ManagementSocketGroup); err != nil {
+			return nil, err
+		}
+	}
+
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s 已存在且不是 socket 文件", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("删除遗留的 socket 文件失败: %w", err)
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("监听 unix socket 失败: %w", err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("设置 socket 文件权限失败: %w", err)
+	}
+	if gid >= 0 {
+		if err := os.Chown(path, -1, gid); err != nil {
+			ln.Close()
+			return nil, fmt.Errorf("设置 socket 文件属组失败: %w", err)
+		}
+	}
+
+	server := &http.Server{Handler: handler}
+	go func() {
+		if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logger.L().Errorw("管理 socket 服务异常退出", "path", path, "error", err)
+		}
+	}()
+	logger.L().Infow("管理 socket 已启动",
+		"path", path,
+		"mode", fmt.Sprintf("%04o", mode),
+		"group", cfg.ManagementSocketGroup,
+		"control_only_on_socket", cfg.ManagementSocketOnly,
+	)
+	return server, nil
+}
+
+// lookupGroup 按组名或 GID 查找属组
+func lookupGroup(group string) (int, error) {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, fmt.Errorf("查找 management_socket_group 失败: %w", err)
+	}
+	return strconv.Atoi(g.Gid)
+}
@@ -0,0 +1,304 @@
+// Package api 提供 /api/v1 下的 HTTP 接口
+// 包括目标状态导出、凭据指纹等面向运维和报表的查询接口，以及解除账号锁定保护、立即探测、故障演练、调整日志级别等运维操作
+// 所有接口都基于 prober 暴露的目标信息，不直接访问数据库
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/internal/prober"
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+// Register 在 mux 上注册 /api/v1 下的查询接口和带认证的接口，没有认证的运维操作接口由 RegisterControl 注册
+// 配置了 webhook.secret 时才注册 POST /api/v1/webhook，配置了 test_fire.token 时才注册 /api/v1/test/fire，
+// 配置了 chatops 的密钥时才注册对应平台的 /api/v1/chatops 接口；
+// history 为本地时序存储（未开启 local_storage 时为 nil），导出时按时间窗口计算可用率
+func Register(mux *http.ServeMux, probe *prober.Prober, cfg *config.Config, history History) {
+	mux.HandleFunc("GET /api/v1/export", func(w http.ResponseWriter, r *http.Request) {
+		exportHandler(w, r, probe, history)
+	})
+	mux.HandleFunc("GET /api/v1/results", func(w http.ResponseWriter, r *http.Request) {
+		resultsHandler(w, r, probe)
+	})
+	mux.HandleFunc("GET /api/v1/credentials", func(w http.ResponseWriter, r *http.Request) {
+		credentialsHandler(w, r, probe)
+	})
+	mux.HandleFunc("GET /api/v1/targets/{name}/errors", func(w http.ResponseWriter, r *http.Request) {
+		errorsHandler(w, r, probe)
+	})
+	mux.HandleFunc("GET /api/v1/summary", func(w http.ResponseWriter, r *http.Request) {
+		summaryHandler(w, r, probe)
+	})
+	mux.HandleFunc("GET /api/v1/loglevel", getLogLevelHandler)
+	if secret := cfg.Webhook.Secret; secret != "" {
+		mux.HandleFunc("POST /api/v1/webhook", func(w http.ResponseWriter, r *http.Request) {
+			webhookHandler(w, r, probe, secret)
+		})
+	}
+	if cfg.TestFire.Token != "" {
+		registerTestFire(mux, probe, cfg.TestFire)
+	}
+	registerChatOps(mux, probe, cfg.ChatOps)
+}
+
+// RegisterControl 注册运维操作接口（暂停和恢复探测、解除账号锁定保护、立即探测、调整日志级别）
+// 配置 management_socket_only 时只注册到管理 socket，HTTP 端口不提供；
+// trusted 表示 mux 所在的端口已经认证访问者（管理 socket 或配置了认证的端口），否则这些接口都需要 token（control_token），
+// 此时 token 为空则不注册这些接口：hide_up 会删除 db_probe_up，不能允许能访问指标端口的任何人屏蔽目标的告警；
+// 立即探测每次都会登录数据库，与 webhook 的 HMAC 签名一样需要认证；调整为 debug 会输出大量细节，调整为 error 会隐藏告警依赖的 Warn 日志
+func RegisterControl(mux *http.ServeMux, probe *prober.Prober, trusted bool, token string) {
+	if trusted || token != "" {
+		guard := func(next http.HandlerFunc) http.HandlerFunc {
+			if trusted {
+				return next
+			}
+			return requireToken(token, next)
+		}
+		mux.HandleFunc("POST /api/v1/targets/{name}/pause", guard(func(w http.ResponseWriter, r *http.Request) {
+			pauseHandler(w, r, probe)
+		}))
+		mux.HandleFunc("POST /api/v1/targets/{name}/resume", guard(func(w http.ResponseWriter, r *http.Request) {
+			resumeHandler(w, r, probe)
+		}))
+		mux.HandleFunc("POST /api/v1/probe/{name}", guard(func(w http.ResponseWriter, r *http.Request) {
+			probeHandler(w, r, probe)
+		}))
+		mux.HandleFunc("PUT /api/v1/loglevel", guard(putLogLevelHandler))
+	}
+}
+
+// pauseRequest 暂停探测的请求体（可选），duration 为 Go duration 格式（如 2h），为空时直到手动恢复
+type pauseRequest struct {
+	Duration string `json:"duration"`
+	HideUp   bool   `json:"hide_up"`
+	Reason   string `json:"reason"`
+}
+
+// pauseHandler 暂停目标的探测，用于计划维护期间屏蔽探测和告警，不需要修改配置
+// 请求体可以为空；对已暂停的目标重复请求会按新的请求重新设置
+func pauseHandler(w http.ResponseWriter, r *http.Request, probe *prober.Prober) {
+	var req pauseRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxWebhookBody)).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, fmt.Sprintf("请求体不是合法的 JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	var duration time.Duration
+	if req.Duration != "" {
+		var err error
+		duration, err = time.ParseDuration(req.Duration)
+		if err != nil || duration <= 0 {
+			http.Error(w, fmt.Sprintf("duration 必须是大于 0 的时长（如 2h）: %q", req.Duration), http.StatusBadRequest)
+			return
+		}
+	}
+
+	tp, err := probe.Pause(r.PathValue("name"), duration, req.HideUp, req.Reason)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tp)
+}
+
+// resumeHandler 恢复目标的探测：维护结束后解除暂停；
+// clear_auth_lockout=true 时同时解除账号锁定保护，运维确认凭据已修复（数据库侧已解锁账号）后调用
+func resumeHandler(w http.ResponseWriter, r *http.Request, probe *prober.Prober) {
+	var clearAuthLockout bool
+	if s := r.URL.Query().Get("clear_auth_lockout"); s != "" {
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("clear_auth_lockout 必须是 true 或 false: %q", s), http.StatusBadRequest)
+			return
+		}
+		clearAuthLockout = v
+	}
+	result, err := probe.Resume(r.PathValue("name"), clearAuthLockout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// errorsHandler 返回目标最近出现过的不同错误（次数、首次和最近一次出现时间），最近出现的在前
+func errorsHandler(w http.ResponseWriter, r *http.Request, probe *prober.Prober) {
+	samples, err := probe.ErrorSamples(r.PathValue("name"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(samples)
+}
+
+// History 历史数据来源（本地时序存储），Availability 返回各目标（按名称）在 [start, end] 内的可用率
+type History interface {
+	Availability(start, end time.Time) (map[string]float64, error)
+}
+
+// exportColumns 导出文件的列
+var exportColumns = []string{
+	"name", "type", "project", "env", "host", "ip", "role", "effective_role",
+	"up", "last_probe_time", "duration_seconds",
+	"last_error", "last_error_count", "last_error_first_seen",
+	"owner", "team", "oncall", "id",
+}
+
+// exportWindowColumns 指定时间窗口时追加的列
+var exportWindowColumns = []string{"availability", "window_start", "window_end"}
+
+// exportHandler 导出所有目标的当前状态
+// format=csv 输出标准 CSV；format=excel 输出带 UTF-8 BOM 的 CSV，Excel 打开时中文不会乱码，
+// 以 =、+、-、@ 开头的文本单元格前加 '，避免被 Excel 当作公式执行（目标名称、错误信息等可能来自服务发现或数据库返回）
+// window（如 24h，截止到当前时间）或 start、end（RFC3339 或 Unix 秒，end 默认为当前时间）指定时间窗口时，
+// 从本地时序存储计算每个目标在窗口内的可用率（availability 为 0~1，窗口内没有样本时为空）；未开启 local_storage 时返回 400
+func exportHandler(w http.ResponseWriter, r *http.Request, probe *prober.Prober, history History) {
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "excel" {
+		http.Error(w, fmt.Sprintf("不支持的导出格式: %s (支持: csv, excel)", format), http.StatusBadRequest)
+		return
+	}
+	start, end, windowed, err := exportWindow(query, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var availability map[string]float64
+	if windowed {
+		if history == nil {
+			http.Error(w, "未开启 local_storage，不支持按时间窗口导出可用率", http.StatusBadRequest)
+			return
+		}
+		if availability, err = history.Availability(start, end); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	text := func(s string) string { return s }
+	if format == "excel" {
+		text = escapeFormula
+	}
+
+	filename := fmt.Sprintf("db-probe-status-%s.csv", time.Now().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if format == "excel" {
+		w.Write([]byte("\xEF\xBB\xBF"))
+	}
+
+	cw := csv.NewWriter(w)
+	if windowed {
+		cw.Write(append(exportColumns[:len(exportColumns):len(exportColumns)], exportWindowColumns...))
+	} else {
+		cw.Write(exportColumns)
+	}
+	for _, info := range probe.GetTargetsInfo() {
+		row := []string{
+			text(info.Name),
+			text(info.Type),
+			text(info.Project),
+			text(info.Env),
+			text(info.Host),
+			text(info.IP),
+			text(info.Role),
+			text(info.EffectiveRole),
+			strconv.FormatBool(info.Up),
+			formatTime(info.LastProbeTime),
+			strconv.FormatFloat(info.DurationSeconds, 'f', 6, 64),
+			text(info.LastError),
+			strconv.Itoa(info.LastErrorCount),
+			formatTime(info.LastErrorFirstSeen),
+			text(info.Owner),
+			text(info.Team),
+			text(info.Oncall),
+			text(info.ID),
+		}
+		if windowed {
+			ratio := ""
+			if v, ok := availability[info.Name]; ok {
+				ratio = strconv.FormatFloat(v, 'f', 6, 64)
+			}
+			row = append(row, ratio, start.Format(time.RFC3339), end.Format(time.RFC3339))
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		logger.L().Warnw("导出目标状态失败", "format", format, "error", err)
+	}
+}
+
+// formatTime 以 RFC3339 格式输出时间，nil 输出空字符串
+// exportWindow 解析导出的时间窗口参数：window 与 start 不能同时指定，都没有时 windowed 为 false
+func exportWindow(query url.Values, now time.Time) (start, end time.Time, windowed bool, err error) {
+	window, startValue, endValue := query.Get("window"), query.Get("start"), query.Get("end")
+	if window == "" && startValue == "" && endValue == "" {
+		return time.Time{}, time.Time{}, false, nil
+	}
+	end = now
+	if endValue != "" {
+		if end, err = parseExportTime(endValue); err != nil {
+			return time.Time{}, time.Time{}, false, fmt.Errorf("end 参数不合法: %q", endValue)
+		}
+	}
+	switch {
+	case window != "" && startValue != "":
+		return time.Time{}, time.Time{}, false, fmt.Errorf("window 和 start 不能同时指定")
+	case window != "":
+		d, err := time.ParseDuration(window)
+		if err != nil || d <= 0 {
+			return time.Time{}, time.Time{}, false, fmt.Errorf("window 必须是大于 0 的时长（如 24h）: %q", window)
+		}
+		start = end.Add(-d)
+	case startValue != "":
+		if start, err = parseExportTime(startValue); err != nil {
+			return time.Time{}, time.Time{}, false, fmt.Errorf("start 参数不合法: %q", startValue)
+		}
+	default:
+		return time.Time{}, time.Time{}, false, fmt.Errorf("指定 end 时需要同时指定 start 或 window")
+	}
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, false, fmt.Errorf("start 需要早于 end")
+	}
+	return start, end, true, nil
+}
+
+// parseExportTime 解析 RFC3339 时间或 Unix 时间戳（秒）
+func parseExportTime(s string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// escapeFormula 以 =、+、-、@（以及制表符、回车）开头的单元格前加 '，Excel 显示为文本而不是执行公式
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
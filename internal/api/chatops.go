@@ -0,0 +1,317 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/internal/prober"
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+const (
+	// slackMaxSkew Slack 请求时间戳允许的最大偏差，超过视为重放
+	slackMaxSkew = 5 * time.Minute
+	// dingtalkMaxSkew 钉钉请求时间戳允许的最大偏差（钉钉要求 1 小时内）
+	dingtalkMaxSkew = time.Hour
+	// chatopsMaxListed 回复中最多列出的故障、最慢目标数
+	chatopsMaxListed = 10
+	// chatopsMaxError 回复中错误信息的最大长度（字符数）
+	chatopsMaxError = 200
+)
+
+// chatopsHelp 查询机器人的用法
+const chatopsHelp = `用法:
+status                所有目标的状态汇总
+status <env>          指定环境的状态汇总
+status <name>         指定目标的状态、耗时和最近的错误
+status <env> <name>   指定环境中的目标`
+
+// registerChatOps 按配置的密钥注册各平台的查询接口
+func registerChatOps(mux *http.ServeMux, probe *prober.Prober, cfg config.ChatOpsConfig) {
+	if secret := cfg.SlackSigningSecret; secret != "" {
+		mux.HandleFunc("POST /api/v1/chatops/slack", func(w http.ResponseWriter, r *http.Request) {
+			slackHandler(w, r, probe, secret)
+		})
+	}
+	if secret := cfg.DingTalkAppSecret; secret != "" {
+		mux.HandleFunc("POST /api/v1/chatops/dingtalk", func(w http.ResponseWriter, r *http.Request) {
+			dingtalkHandler(w, r, probe, secret)
+		})
+	}
+}
+
+// slackHandler Slack 斜杠命令（如 /dbprobe status prod payment-db）的请求地址
+// 签名为 v0=hex(HMAC-SHA256(signing_secret, "v0:<timestamp>:<body>"))，回复只对发起查询的用户可见
+func slackHandler(w http.ResponseWriter, r *http.Request, probe *prober.Prober, secret string) {
+	body, ok := readChatOpsBody(w, r)
+	if !ok {
+		return
+	}
+	timestamp := r.Header.Get("X-Slack-Request-Timestamp")
+	if !validSlackSignature(body, timestamp, r.Header.Get("X-Slack-Signature"), secret, time.Now()) {
+		logger.L().Warnw("ChatOps 签名校验失败", "platform", "slack", "remote_addr", r.RemoteAddr)
+		http.Error(w, "签名校验失败", http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("请求体不是合法的表单: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	text := form.Get("text")
+	logger.L().Infow("收到 ChatOps 查询", "platform", "slack", "user", form.Get("user_name"), "text", text)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"response_type": "ephemeral",
+		"text":          chatopsReply(text, probe, time.Now()),
+	})
+}
+
+// dingtalkRequest 钉钉机器人转发的消息（只使用其中的文本和发送者）
+type dingtalkRequest struct {
+	Text struct {
+		Content string `json:"content"`
+	} `json:"text"`
+	SenderNick string `json:"senderNick"`
+}
+
+// dingtalkHandler 钉钉企业内部机器人（在群里 @机器人 status prod payment-db）的消息接收地址
+// 签名为 base64(HMAC-SHA256(app_secret, "<timestamp>\n<app_secret>"))，timestamp 为毫秒
+func dingtalkHandler(w http.ResponseWriter, r *http.Request, probe *prober.Prober, secret string) {
+	body, ok := readChatOpsBody(w, r)
+	if !ok {
+		return
+	}
+	if !validDingTalkSignature(r.Header.Get("timestamp"), r.Header.Get("sign"), secret, time.Now()) {
+		logger.L().Warnw("ChatOps 签名校验失败", "platform", "dingtalk", "remote_addr", r.RemoteAddr)
+		http.Error(w, "签名校验失败", http.StatusUnauthorized)
+		return
+	}
+	var req dingtalkRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, fmt.Sprintf("请求体不是合法的 JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	logger.L().Infow("收到 ChatOps 查询", "platform", "dingtalk", "user", req.SenderNick, "text", req.Text.Content)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"msgtype": "text",
+		"text":    map[string]string{"content": chatopsReply(req.Text.Content, probe, time.Now())},
+	})
+}
+
+// readChatOpsBody 读取请求体，超过大小上限时返回 413
+func readChatOpsBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody+1))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("读取请求体失败: %v", err), http.StatusBadRequest)
+		return nil, false
+	}
+	if len(body) > maxWebhookBody {
+		http.Error(w, "请求体过大", http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	return body, true
+}
+
+// validSlackSignature 校验 Slack 请求签名，时间戳（秒）与当前时间相差超过 slackMaxSkew 时视为无效
+func validSlackSignature(body []byte, timestamp, header, secret string, now time.Time) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || now.Sub(time.Unix(ts, 0)).Abs() > slackMaxSkew {
+		return false
+	}
+	sig, ok := strings.CutPrefix(header, "v0=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:", timestamp)
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// validDingTalkSignature 校验钉钉请求签名，时间戳（毫秒）与当前时间相差超过 dingtalkMaxSkew 时视为无效
+func validDingTalkSignature(timestamp, sign, secret string, now time.Time) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || now.Sub(time.UnixMilli(ts)).Abs() > dingtalkMaxSkew {
+		return false
+	}
+	got, err := base64.StdEncoding.DecodeString(sign)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// chatopsReply 按查询命令生成回复文本
+func chatopsReply(text string, probe *prober.Prober, now time.Time) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || fields[0] == "help" {
+		return chatopsHelp
+	}
+	if fields[0] != "status" || len(fields) > 3 {
+		return fmt.Sprintf("不支持的命令: %s\n\n%s", strings.TrimSpace(text), chatopsHelp)
+	}
+
+	infos := probe.GetTargetsInfo()
+	switch args := fields[1:]; len(args) {
+	case 0:
+		return summaryText("所有目标", buildSummary(infos, chatopsMaxListed, now))
+	case 1:
+		if matched := matchTargets(infos, "", args[0]); len(matched) > 0 {
+			return targetsText(matched, now)
+		}
+		if inEnv := matchTargets(infos, args[0], ""); len(inEnv) > 0 {
+			return summaryText("环境 "+args[0], buildSummary(inEnv, chatopsMaxListed, now))
+		}
+		return fmt.Sprintf("没有找到目标或环境: %s", args[0])
+	default:
+		if matched := matchTargets(infos, args[0], args[1]); len(matched) > 0 {
+			return targetsText(matched, now)
+		}
+		return fmt.Sprintf("环境 %s 中没有目标: %s", args[0], args[1])
+	}
+}
+
+// matchTargets 按环境和名称（或 ID）筛选目标，为空的条件不筛选
+// 按 schema、地址展开的目标名称相同，会同时匹配
+func matchTargets(infos []prober.TargetInfo, env, name string) []prober.TargetInfo {
+	var matched []prober.TargetInfo
+	for _, info := range infos {
+		if env != "" && info.Env != env {
+			continue
+		}
+		if name != "" && info.Name != name && info.ID != name {
+			continue
+		}
+		matched = append(matched, info)
+	}
+	return matched
+}
+
+// summaryText 状态汇总的文本描述
+func summaryText(scope string, s summary) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %d  正常: %d  故障: %d  未探测: %d", scope, s.Total, s.Up, s.Down, s.Pending)
+	if s.Stale > 0 || s.AuthLockoutProtected > 0 || s.TestFire > 0 || s.Paused > 0 {
+		fmt.Fprintf(&b, "\n过期: %d  锁定保护: %d  演练: %d  暂停: %d", s.Stale, s.AuthLockoutProtected, s.TestFire, s.Paused)
+	}
+	if len(s.Outages) > 0 {
+		b.WriteString("\n\n故障中:")
+		for i, outage := range s.Outages {
+			if i == chatopsMaxListed {
+				fmt.Fprintf(&b, "\n... 还有 %d 个", len(s.Outages)-i)
+				break
+			}
+			fmt.Fprintf(&b, "\n- %s (%s %s)", outage.Name, outage.Type, outage.Host)
+			if outage.Since != nil {
+				fmt.Fprintf(&b, " 已持续 %s", time.Duration(outage.OutageSeconds*float64(time.Second)).Round(time.Second))
+			}
+			if outage.LastError != "" {
+				fmt.Fprintf(&b, ": %s", truncateRunes(outage.LastError, chatopsMaxError))
+			}
+		}
+	}
+	if len(s.Slowest) > 0 {
+		b.WriteString("\n\n最慢:")
+		for _, target := range s.Slowest {
+			fmt.Fprintf(&b, "\n- %s %s", target.Name, formatSeconds(target.DurationSeconds))
+		}
+	}
+	return b.String()
+}
+
+// targetsText 目标当前状态的文本描述，格式与状态变化通知相近
+func targetsText(infos []prober.TargetInfo, now time.Time) string {
+	parts := make([]string, 0, len(infos))
+	for _, info := range infos {
+		var b strings.Builder
+		switch {
+		case info.LastProbeTime == nil:
+			fmt.Fprintf(&b, "[未探测] %s", info.Name)
+		case info.Up:
+			fmt.Fprintf(&b, "[正常] %s", info.Name)
+		default:
+			fmt.Fprintf(&b, "[故障] %s", info.Name)
+		}
+		if info.Stale {
+			b.WriteString("（指标过期）")
+		}
+		if info.AuthLockoutProtected {
+			b.WriteString("（账号锁定保护）")
+		}
+		if info.TestFireUntil != nil {
+			b.WriteString("（演练中）")
+		}
+		if info.Paused {
+			b.WriteString("（已暂停探测）")
+		}
+		fmt.Fprintf(&b, "\n类型: %s\n地址: %s", info.Type, info.Host)
+		if info.IP != "" && info.IP != info.Host {
+			fmt.Fprintf(&b, " (%s)", info.IP)
+		}
+		if info.Schema != "" {
+			fmt.Fprintf(&b, "\nSchema: %s", info.Schema)
+		}
+		if info.Project != "" || info.Env != "" {
+			fmt.Fprintf(&b, "\n项目: %s / %s", info.Project, info.Env)
+		}
+		if info.LastProbeTime != nil {
+			fmt.Fprintf(&b, "\n耗时: %s\n最近探测: %s", formatSeconds(info.DurationSeconds), info.LastProbeTime.Format(time.DateTime))
+		}
+		if info.LastError != "" {
+			fmt.Fprintf(&b, "\n错误: %s", truncateRunes(info.LastError, chatopsMaxError))
+			if info.LastErrorFirstSeen != nil {
+				fmt.Fprintf(&b, "\n连续 %d 次，首次出现: %s（%s 前）", info.LastErrorCount,
+					info.LastErrorFirstSeen.Format(time.DateTime), now.Sub(*info.LastErrorFirstSeen).Round(time.Second))
+			}
+			if runbook := info.LastErrorRunbookURL; runbook != "" {
+				fmt.Fprintf(&b, "\n处理手册: %s", runbook)
+			} else if info.RunbookURL != "" {
+				fmt.Fprintf(&b, "\n处理手册: %s", info.RunbookURL)
+			}
+		}
+		if info.Owner != "" || info.Oncall != "" {
+			fmt.Fprintf(&b, "\n负责人: %s  值班: %s", info.Owner, info.Oncall)
+		}
+		parts = append(parts, b.String())
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// formatSeconds 把秒数格式化为易读的耗时（如 12ms、1.2s）
+func formatSeconds(seconds float64) string {
+	d := time.Duration(seconds * float64(time.Second))
+	if d < time.Second {
+		return d.Round(time.Millisecond / 10).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// truncateRunes 超过 n 个字符时截断并追加省略号
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
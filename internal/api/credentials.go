@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/internal/prober"
+)
+
+// credentialUsage 一个环境中使用同一凭据的目标
+type credentialUsage struct {
+	Fingerprint string   `json:"fingerprint"`
+	User        string   `json:"user,omitempty"`
+	Targets     []string `json:"targets"`
+}
+
+// credentialsHandler 按环境列出各目标凭据的指纹，以及被多个环境使用的凭据
+// 只输出指纹（进程内随机密钥的 HMAC），不输出密码；没有可比较凭据的目标（如只配置了 dsn）不列出
+func credentialsHandler(w http.ResponseWriter, r *http.Request, probe *prober.Prober) {
+	dbCfgs := probe.DatabaseConfigs()
+
+	byEnv := make(map[string][]credentialUsage)
+	for i := range dbCfgs {
+		fingerprint := config.CredentialFingerprint(&dbCfgs[i])
+		if fingerprint == "" {
+			continue
+		}
+		env := dbCfgs[i].Env
+		usages := byEnv[env]
+		found := false
+		for j := range usages {
+			if usages[j].Fingerprint == fingerprint {
+				usages[j].Targets = append(usages[j].Targets, dbCfgs[i].Name)
+				found = true
+				break
+			}
+		}
+		if !found {
+			usages = append(usages, credentialUsage{Fingerprint: fingerprint, User: dbCfgs[i].User, Targets: []string{dbCfgs[i].Name}})
+		}
+		byEnv[env] = usages
+	}
+	for _, usages := range byEnv {
+		sort.Slice(usages, func(i, j int) bool { return usages[i].Fingerprint < usages[j].Fingerprint })
+	}
+
+	reused := config.FindCredentialReuse(dbCfgs)
+	if reused == nil {
+		reused = []config.CredentialReuse{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"environments": byEnv,
+		"reused":       reused,
+	})
+}
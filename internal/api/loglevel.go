@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/imkerbos/db-probe/pkg/logger"
+	"go.uber.org/zap/zapcore"
+)
+
+// logLevel 日志级别接口的请求和响应
+type logLevel struct {
+	Level string `json:"level"`
+}
+
+// getLogLevelHandler 返回当前的日志级别
+func getLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(logLevel{Level: logger.Level().String()})
+}
+
+// putLogLevelHandler 调整日志级别，立即生效，重启后恢复为 info
+func putLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	var req logLevel
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("解析请求失败: %v", err), http.StatusBadRequest)
+		return
+	}
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(req.Level)); err != nil {
+		http.Error(w, fmt.Sprintf("不支持的日志级别: %s（支持 debug、info、warn、error）", req.Level), http.StatusBadRequest)
+		return
+	}
+	if err := logger.SetLevel(level, "日志级别已调整", "remote_addr", r.RemoteAddr); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(logLevel{Level: level.String()})
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/imkerbos/db-probe/internal/prober"
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+// maxWebhookBody webhook 请求体的大小上限
+const maxWebhookBody = 1 << 20
+
+// probeResponse 立即探测接口的响应
+// Up 为所有目标（包括 probe_all_addresses 的各个地址）都完成探测（或为 on_demand_min_interval 内上一次探测的结果）且可用
+type probeResponse struct {
+	Up      bool                     `json:"up"`
+	Results []prober.ImmediateResult `json:"results"`
+}
+
+// probeHandler 立即探测指定目标并同步返回结果，用于发布流水线在主从切换等操作后确认数据库可用
+// 全部可用时返回 200，否则返回 503，流水线可以直接用 curl -f 判断
+func probeHandler(w http.ResponseWriter, r *http.Request, probe *prober.Prober) {
+	name := r.PathValue("name")
+	results, err := probe.ProbeNow(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	logger.L().Infow("收到立即探测请求", "db_name", name, "remote_addr", r.RemoteAddr)
+	writeProbeResponse(w, results)
+}
+
+// webhookRequest webhook 请求体
+type webhookRequest struct {
+	Targets []string `json:"targets"`
+}
+
+// webhookHandler 通用 webhook 接收端，校验 HMAC 签名后立即探测请求体中列出的目标
+// 签名为请求体的 HMAC-SHA256，格式与 GitHub 等平台相同：X-Hub-Signature-256: sha256=<hex>
+func webhookHandler(w http.ResponseWriter, r *http.Request, probe *prober.Prober, secret string) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody+1))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("读取请求体失败: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxWebhookBody {
+		http.Error(w, "请求体过大", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !validSignature(body, r.Header.Get("X-Hub-Signature-256"), secret) {
+		logger.L().Warnw("webhook 签名校验失败", "remote_addr", r.RemoteAddr)
+		http.Error(w, "签名校验失败", http.StatusUnauthorized)
+		return
+	}
+
+	var req webhookRequest
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("请求体不是合法的 JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	if len(req.Targets) == 0 {
+		http.Error(w, "targets 不能为空", http.StatusBadRequest)
+		return
+	}
+
+	// 先确认所有目标都存在，避免探测了一部分才发现名称写错
+	known := make(map[string]bool)
+	for _, info := range probe.GetTargetsInfo() {
+		known[info.Name] = true
+	}
+	var unknown []string
+	for _, name := range req.Targets {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		http.Error(w, fmt.Sprintf("目标不存在: %s", strings.Join(unknown, ", ")), http.StatusNotFound)
+		return
+	}
+
+	logger.L().Infow("收到 webhook 立即探测请求", "targets", req.Targets, "remote_addr", r.RemoteAddr)
+	var results []prober.ImmediateResult
+	for _, name := range req.Targets {
+		targetResults, err := probe.ProbeNow(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		results = append(results, targetResults...)
+	}
+	writeProbeResponse(w, results)
+}
+
+// validSignature 校验 sha256=<hex> 格式的 HMAC-SHA256 签名
+func validSignature(body []byte, header, secret string) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// writeProbeResponse 输出立即探测结果，全部可用时返回 200，否则返回 503
+func writeProbeResponse(w http.ResponseWriter, results []prober.ImmediateResult) {
+	resp := probeResponse{Up: true, Results: results}
+	for _, result := range results {
+		if !result.Fresh() || !result.Up {
+			resp.Up = false
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !resp.Up {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/imkerbos/db-probe/internal/prober"
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+// resultsHandler 返回所有目标最近一次的探测结果（prober.ProbeResult）
+// 默认输出 JSON 数组；Accept 中包含 application/x-protobuf 时输出 protobuf 编码的 ProbeResultList
+func resultsHandler(w http.ResponseWriter, r *http.Request, probe *prober.Prober) {
+	results := probe.LastResults()
+	if strings.Contains(r.Header.Get("Accept"), "application/x-protobuf") {
+		w.Header().Set("Content-Type", prober.ProbeResultContentType)
+		if _, err := w.Write(prober.MarshalProbeResults(results)); err != nil {
+			logger.L().Debugw("输出探测结果失败", "error", err)
+		}
+		return
+	}
+
+	if results == nil {
+		results = []prober.ProbeResult{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
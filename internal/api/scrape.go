@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/metrics"
+	"github.com/imkerbos/db-probe/internal/prober"
+	"github.com/imkerbos/db-probe/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// RegisterScrapeProbe 注册 GET /probe?target=<name>（配置 probe_endpoint: true 时注册在 HTTP 端口上）
+// 与 blackbox_exporter 的 /probe 相同，由 Prometheus 的抓取驱动探测，目标通过 relabel_configs 写在 Prometheus 的抓取配置中；
+// globalLabels 为 global_labels，与 /metrics 相同追加到返回的每条时间序列上
+func RegisterScrapeProbe(mux *http.ServeMux, probe *prober.Prober, globalLabels map[string]string) {
+	mux.HandleFunc("GET /probe", func(w http.ResponseWriter, r *http.Request) {
+		scrapeProbeHandler(w, r, probe, globalLabels)
+	})
+}
+
+// scrapeProbeHandler 立即探测 target 参数指定的目标（名称或 ID），返回该目标的指标以及 blackbox_exporter 兼容的 probe_success、probe_duration_seconds
+// 开启 probe_all_addresses 的目标并发探测所有地址，全部可用时 probe_success 为 1；处于账号锁定保护的目标不探测，probe_success 为 0；
+// 已暂停的目标（计划维护）不探测，也不返回 probe_success、probe_duration_seconds，基于 probe_success 的告警在维护期间不会触发，
+// 只返回目标自身的指标（db_probe_target_paused 为 1）
+// 缺少 target 参数时返回 400，目标不存在时返回 404；探测失败仍返回 200（与 blackbox_exporter 相同，由 probe_success 表示）
+// 不支持 blackbox_exporter 的 module 参数：target 只能是配置中的目标，凭据保存在探针的配置中，探针不会按请求连接任意地址
+func scrapeProbeHandler(w http.ResponseWriter, r *http.Request, probe *prober.Prober, globalLabels map[string]string) {
+	query := r.URL.Query()
+	name := query.Get("target")
+	if name == "" {
+		http.Error(w, "缺少 target 参数", http.StatusBadRequest)
+		return
+	}
+	if query.Has("module") {
+		http.Error(w, "不支持 module 参数，target 为配置中的目标名称或 ID", http.StatusBadRequest)
+		return
+	}
+
+	start := time.Now()
+	results, err := probe.ProbeNow(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	duration := time.Since(start)
+
+	// 暂停按目标名称记录，同名的各个地址一起暂停
+	paused := results[0].Paused
+	success := true
+	for _, result := range results {
+		if !result.Fresh() || !result.Up {
+			success = false
+		}
+	}
+	logger.L().Debugw("抓取触发探测", "db_name", results[0].Name, "success", success, "paused", paused, "duration_seconds", duration.Seconds(), "remote_addr", r.RemoteAddr)
+
+	targetGatherer := metrics.ForTarget(prometheus.DefaultGatherer, results[0].Name)
+	if paused {
+		serveGatherer(w, r, targetGatherer, globalLabels)
+		return
+	}
+
+	registry := prometheus.NewRegistry()
+	probeSuccess := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_success",
+		Help: "本次探测是否成功（1=成功，0=失败），与 blackbox_exporter 相同",
+	})
+	probeDuration := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_duration_seconds",
+		Help: "本次探测的耗时（秒），与 blackbox_exporter 相同",
+	})
+	registry.MustRegister(probeSuccess, probeDuration)
+	if success {
+		probeSuccess.Set(1)
+	}
+	probeDuration.Set(duration.Seconds())
+
+	// 返回结果中的名称为目标名称（target 参数可以是 ID），指标的 db_name 为目标名称
+	serveGatherer(w, r, prometheus.Gatherers{registry, targetGatherer}, globalLabels)
+}
+
+// serveGatherer 追加 global_labels 后以 Prometheus 文本格式输出 gatherer 中的指标
+func serveGatherer(w http.ResponseWriter, r *http.Request, gatherer prometheus.Gatherer, globalLabels map[string]string) {
+	gatherer, err := metrics.WithGlobalLabels(gatherer, globalLabels)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
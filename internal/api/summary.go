@@ -0,0 +1,196 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/prober"
+)
+
+// defaultSummaryTop 汇总中最慢目标的默认数量
+const defaultSummaryTop = 10
+
+// summaryCounts 一组目标的状态计数，Pending 为尚未完成首次探测
+type summaryCounts struct {
+	Total   int `json:"total"`
+	Up      int `json:"up"`
+	Down    int `json:"down"`
+	Pending int `json:"pending"`
+}
+
+// add 按目标最近一次的探测结果计数
+func (c *summaryCounts) add(info *prober.TargetInfo) {
+	c.Total++
+	switch {
+	case info.LastProbeTime == nil:
+		c.Pending++
+	case info.Up:
+		c.Up++
+	default:
+		c.Down++
+	}
+}
+
+// projectEnvCounts 一个项目、环境下的状态计数
+type projectEnvCounts struct {
+	Project string `json:"project"`
+	Env     string `json:"env"`
+	summaryCounts
+}
+
+// summaryTarget 汇总中列出的目标
+type summaryTarget struct {
+	Name            string  `json:"name"`
+	Type            string  `json:"type"`
+	Project         string  `json:"project"`
+	Env             string  `json:"env"`
+	Host            string  `json:"host"`
+	IP              string  `json:"ip,omitempty"`
+	Schema          string  `json:"schema,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// summaryOutage 正在发生的故障，Since 为当前错误首次出现的时间
+type summaryOutage struct {
+	summaryTarget
+	Since          *time.Time `json:"since,omitempty"`
+	OutageSeconds  float64    `json:"outage_seconds"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastErrorCount int        `json:"last_error_count,omitempty"`
+	RunbookURL     string     `json:"runbook_url,omitempty"`
+	Owner          string     `json:"owner,omitempty"`
+	Oncall         string     `json:"oncall,omitempty"`
+	AuthLockout    bool       `json:"auth_lockout_protected,omitempty"`
+	TestFire       bool       `json:"test_fire,omitempty"`
+	Paused         bool       `json:"paused,omitempty"`
+}
+
+// summary /api/v1/summary 的响应
+type summary struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	summaryCounts
+	Stale                int                      `json:"stale"`
+	AuthLockoutProtected int                      `json:"auth_lockout_protected"`
+	TestFire             int                      `json:"test_fire"`
+	Paused               int                      `json:"paused"`
+	ByType               map[string]summaryCounts `json:"by_type"`
+	ByProjectEnv         []projectEnvCounts       `json:"by_project_env"`
+	Slowest              []summaryTarget          `json:"slowest"`
+	Outages              []summaryOutage          `json:"outages"`
+}
+
+// summaryHandler 汇总所有目标的状态：按状态、类型、项目和环境的计数，探测耗时最长的可用目标，以及正在发生的故障
+// top 指定最慢目标的数量（默认 10），故障全部列出，持续时间最长的在前
+func summaryHandler(w http.ResponseWriter, r *http.Request, probe *prober.Prober) {
+	top := defaultSummaryTop
+	if s := r.URL.Query().Get("top"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			http.Error(w, fmt.Sprintf("top 必须是非负整数: %s", s), http.StatusBadRequest)
+			return
+		}
+		top = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buildSummary(probe.GetTargetsInfo(), top, time.Now()))
+}
+
+// buildSummary 汇总一组目标的状态，ChatOps 查询机器人同样使用
+func buildSummary(infos []prober.TargetInfo, top int, now time.Time) summary {
+	s := summary{
+		GeneratedAt:  now,
+		ByType:       make(map[string]summaryCounts),
+		ByProjectEnv: []projectEnvCounts{},
+		Slowest:      []summaryTarget{},
+		Outages:      []summaryOutage{},
+	}
+	byProjectEnv := make(map[[2]string]*projectEnvCounts)
+	for i := range infos {
+		info := &infos[i]
+		s.add(info)
+		counts := s.ByType[info.Type]
+		counts.add(info)
+		s.ByType[info.Type] = counts
+		key := [2]string{info.Project, info.Env}
+		if byProjectEnv[key] == nil {
+			byProjectEnv[key] = &projectEnvCounts{Project: info.Project, Env: info.Env}
+		}
+		byProjectEnv[key].add(info)
+
+		if info.Stale {
+			s.Stale++
+		}
+		if info.AuthLockoutProtected {
+			s.AuthLockoutProtected++
+		}
+		if info.TestFireUntil != nil {
+			s.TestFire++
+		}
+		if info.Paused {
+			s.Paused++
+		}
+		if info.LastProbeTime == nil {
+			continue
+		}
+		target := summaryTarget{
+			Name:            info.Name,
+			Type:            info.Type,
+			Project:         info.Project,
+			Env:             info.Env,
+			Host:            info.Host,
+			IP:              info.IP,
+			Schema:          info.Schema,
+			DurationSeconds: info.DurationSeconds,
+		}
+		// 失败的探测耗时取决于失败的方式（如超时），只比较成功探测的耗时，失败的目标列在 outages 中
+		if info.Up {
+			s.Slowest = append(s.Slowest, target)
+			continue
+		}
+		outage := summaryOutage{
+			summaryTarget:  target,
+			Since:          info.LastErrorFirstSeen,
+			LastError:      info.LastError,
+			LastErrorCount: info.LastErrorCount,
+			RunbookURL:     info.LastErrorRunbookURL,
+			Owner:          info.Owner,
+			Oncall:         info.Oncall,
+			AuthLockout:    info.AuthLockoutProtected,
+			TestFire:       info.TestFireUntil != nil,
+			Paused:         info.Paused,
+		}
+		if outage.RunbookURL == "" {
+			outage.RunbookURL = info.RunbookURL
+		}
+		if outage.Since != nil {
+			outage.OutageSeconds = now.Sub(*outage.Since).Seconds()
+		}
+		s.Outages = append(s.Outages, outage)
+	}
+
+	for _, counts := range byProjectEnv {
+		s.ByProjectEnv = append(s.ByProjectEnv, *counts)
+	}
+	sort.Slice(s.ByProjectEnv, func(i, j int) bool {
+		a, b := s.ByProjectEnv[i], s.ByProjectEnv[j]
+		if a.Project != b.Project {
+			return a.Project < b.Project
+		}
+		return a.Env < b.Env
+	})
+	sort.SliceStable(s.Slowest, func(i, j int) bool {
+		return s.Slowest[i].DurationSeconds > s.Slowest[j].DurationSeconds
+	})
+	if len(s.Slowest) > top {
+		s.Slowest = s.Slowest[:top]
+	}
+	sort.SliceStable(s.Outages, func(i, j int) bool {
+		return s.Outages[i].OutageSeconds > s.Outages[j].OutageSeconds
+	})
+	return s
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/internal/prober"
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+// startTime 探针进程的启动时间（近似为 api 包初始化的时间），写入支持包的 version.json
+var startTime = time.Now()
+
+// buildInfo 支持包中的版本和运行环境信息
+type buildInfo struct {
+	Module      string            `json:"module"`
+	Version     string            `json:"version"`
+	GoVersion   string            `json:"go_version"`
+	OS          string            `json:"os"`
+	Arch        string            `json:"arch"`
+	Settings    map[string]string `json:"settings,omitempty"` // 构建参数（vcs.revision、vcs.time、-tags 等）
+	Hostname    string            `json:"hostname"`
+	PID         int               `json:"pid"`
+	StartTime   time.Time         `json:"start_time"`
+	GeneratedAt time.Time         `json:"generated_at"`
+	Goroutines  int               `json:"goroutines"`
+	LogLevel    string            `json:"log_level"`
+	Targets     int               `json:"targets"`
+}
+
+// RegisterSupportBundle 注册 GET /api/v1/support-bundle，返回排障用的支持包（tar.gz）
+// 支持包包含脱敏后的配置，只注册在配置了认证的端口或按文件权限控制访问的管理 socket 上；
+// metricsHandler 为 /metrics 的 handler，支持包中的指标与抓取到的完全相同（含 global_labels）
+func RegisterSupportBundle(mux *http.ServeMux, probe *prober.Prober, cfg *config.Config, metricsHandler http.Handler) {
+	mux.HandleFunc("GET /api/v1/support-bundle", func(w http.ResponseWriter, r *http.Request) {
+		supportBundleHandler(w, r, probe, cfg, metricsHandler)
+	})
+}
+
+// supportBundleHandler 收集脱敏配置、最近的日志、目标状态、探测结果、错误样本、状态变化事件、指标和版本信息，打包为 tar.gz
+// 排障升级时把支持包附在 issue 中，不需要逐个接口收集
+func supportBundleHandler(w http.ResponseWriter, r *http.Request, probe *prober.Prober, cfg *config.Config, metricsHandler http.Handler) {
+	now := time.Now()
+	hostname, _ := os.Hostname()
+	dir := fmt.Sprintf("db-probe-support-%s", now.Format("20060102-150405"))
+	if hostname != "" {
+		dir = fmt.Sprintf("db-probe-support-%s-%s", hostname, now.Format("20060102-150405"))
+	}
+
+	// 热加载只更新目标，全局配置在重启前保持不变；目标取当前实际探测的（含目标发现得到的）
+	current := *cfg
+	current.Databases = probe.DatabaseConfigs()
+	infos := probe.GetTargetsInfo()
+
+	var samples []prober.TargetErrorSamples
+	seen := make(map[string]bool)
+	for _, info := range infos {
+		if seen[info.Name] {
+			continue
+		}
+		seen[info.Name] = true
+		if s, err := probe.ErrorSamples(info.Name); err == nil {
+			samples = append(samples, s...)
+		}
+	}
+
+	var goroutines bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&goroutines, 1); err != nil {
+		fmt.Fprintf(&goroutines, "收集 goroutine 失败: %v\n", err)
+	}
+
+	metricsReq := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	metricsRec := httptest.NewRecorder()
+	metricsHandler.ServeHTTP(metricsRec, metricsReq)
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"version.json", newBuildInfo(hostname, now, len(infos))},
+		{"config.json", current.Masked()},
+		{"targets.json", infos},
+		{"results.json", probe.LastResults()},
+		{"errors.json", samples},
+		{"events.json", probe.RecentEvents()},
+		{"logs.jsonl", strings.Join(logger.Recent(), "\n") + "\n"},
+		{"metrics.txt", metricsRec.Body.String()},
+		{"goroutines.txt", goroutines.String()},
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", dir+".tar.gz"))
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		data, ok := f.data.(string)
+		if !ok {
+			b, err := json.MarshalIndent(f.data, "", "  ")
+			if err != nil {
+				b = []byte(fmt.Sprintf("{\"error\": %q}", err.Error()))
+			}
+			data = string(b) + "\n"
+		}
+		if err := writeTarFile(tw, dir+"/"+f.name, data, now); err != nil {
+			logger.L().Warnw("生成支持包失败", "file", f.name, "error", err)
+			return
+		}
+	}
+	if err := tw.Close(); err != nil {
+		logger.L().Warnw("生成支持包失败", "error", err)
+		return
+	}
+	if err := gz.Close(); err != nil {
+		logger.L().Warnw("生成支持包失败", "error", err)
+		return
+	}
+	logger.L().Infow("已生成支持包", "file", dir+".tar.gz", "remote_addr", r.RemoteAddr)
+}
+
+// writeTarFile 向 tar 中写入一个普通文件
+func writeTarFile(tw *tar.Writer, name, data string, modTime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o600,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(tw, data)
+	return err
+}
+
+// newBuildInfo 读取二进制中的模块版本和构建参数（go build 自动嵌入的 vcs.revision 等）
+func newBuildInfo(hostname string, now time.Time, targets int) buildInfo {
+	info := buildInfo{
+		GoVersion:   runtime.Version(),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		Hostname:    hostname,
+		PID:         os.Getpid(),
+		StartTime:   startTime,
+		GeneratedAt: now,
+		Goroutines:  runtime.NumGoroutine(),
+		LogLevel:    logger.Level().String(),
+		Targets:     targets,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info.Module = bi.Main.Path
+		info.Version = bi.Main.Version
+		info.Settings = make(map[string]string, len(bi.Settings))
+		for _, s := range bi.Settings {
+			info.Settings[s.Key] = s.Value
+		}
+	}
+	return info
+}
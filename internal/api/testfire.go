@@ -0,0 +1,109 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/internal/prober"
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+// testFireRequest 故障演练请求体，duration 为 Go duration 格式（如 5m）
+type testFireRequest struct {
+	Target   string `json:"target"`
+	Duration string `json:"duration"`
+}
+
+// testFireResponse 开始故障演练的响应，Results 为标记后立即探测的结果
+type testFireResponse struct {
+	Target  string                   `json:"target"`
+	Until   time.Time                `json:"until"`
+	Results []prober.ImmediateResult `json:"results"`
+}
+
+// cancelTestFireResponse 结束故障演练的响应，Cancelled 为目标此前是否处于演练中
+type cancelTestFireResponse struct {
+	Target    string                   `json:"target"`
+	Cancelled bool                     `json:"cancelled"`
+	Results   []prober.ImmediateResult `json:"results"`
+}
+
+// registerTestFire 注册故障演练接口，所有请求都需要携带 Authorization: Bearer <token>
+func registerTestFire(mux *http.ServeMux, probe *prober.Prober, cfg config.TestFireConfig) {
+	mux.HandleFunc("GET /api/v1/test/fire", requireToken(cfg.Token, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"active": probe.ActiveTests()})
+	}))
+	mux.HandleFunc("POST /api/v1/test/fire", requireToken(cfg.Token, func(w http.ResponseWriter, r *http.Request) {
+		testFireHandler(w, r, probe, cfg.MaxDuration)
+	}))
+	mux.HandleFunc("DELETE /api/v1/test/fire/{name}", requireToken(cfg.Token, func(w http.ResponseWriter, r *http.Request) {
+		cancelTestFireHandler(w, r, probe)
+	}))
+}
+
+// requireToken 校验 Authorization: Bearer <token>，不匹配时返回 401
+func requireToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			logger.L().Warnw("接口认证失败", "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+			http.Error(w, "认证失败", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// testFireHandler 把目标临时标记为故障，用于演练告警和通知链路，不影响真实数据库
+// 持续时间不能超过 test_fire.max_duration，到期后自动恢复；对正在演练的目标重复请求会重新设置结束时间
+func testFireHandler(w http.ResponseWriter, r *http.Request, probe *prober.Prober, maxDuration time.Duration) {
+	var req testFireRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxWebhookBody)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("请求体不是合法的 JSON: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Target == "" {
+		http.Error(w, "target 不能为空", http.StatusBadRequest)
+		return
+	}
+	duration, err := time.ParseDuration(req.Duration)
+	if err != nil || duration <= 0 {
+		http.Error(w, fmt.Sprintf("duration 必须是大于 0 的时长（如 5m）: %q", req.Duration), http.StatusBadRequest)
+		return
+	}
+	if duration > maxDuration {
+		http.Error(w, fmt.Sprintf("duration (%v) 不能超过 test_fire.max_duration (%v)", duration, maxDuration), http.StatusBadRequest)
+		return
+	}
+
+	until, results, err := probe.FireTest(req.Target, duration)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	logger.L().Warnw("开始故障演练", "db_name", req.Target, "duration", duration, "until", until, "remote_addr", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(testFireResponse{Target: req.Target, Until: until, Results: results})
+}
+
+// cancelTestFireHandler 提前结束故障演练，立即探测一次以恢复真实状态
+func cancelTestFireHandler(w http.ResponseWriter, r *http.Request, probe *prober.Prober) {
+	name := r.PathValue("name")
+	cancelled, results, err := probe.CancelTest(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	logger.L().Infow("结束故障演练", "db_name", name, "cancelled", cancelled, "remote_addr", r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cancelTestFireResponse{Target: name, Cancelled: cancelled, Results: results})
+}
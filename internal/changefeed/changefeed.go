@@ -0,0 +1,186 @@
+// Package changefeed 把目标状态变化以 JSON Lines 追加到本地文件
+// 每个状态变化事件（prober.StateEvent）写一行 JSON，供容量、可用性分析等离线处理，
+// 与通知渠道互相独立：通知失败或未配置通知都不影响记录
+// 文件按大小轮转：path → path.1 → path.2 …，最多保留 max_backups 个旧文件
+package changefeed
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/internal/prober"
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+// queueSize 待写入事件的缓冲大小
+// 状态变化频率很低，缓冲只用于吸收磁盘短暂卡顿，避免阻塞探测 goroutine
+const queueSize = 1024
+
+// Writer 状态变化记录器
+type Writer struct {
+	cfg     config.ChangefeedConfig
+	maxSize int64
+
+	file *os.File
+	size int64
+
+	events chan prober.StateEvent
+	wg     sync.WaitGroup
+	// dropMu 保护 dropped，Handle 在多个探测 goroutine 中并发调用
+	dropMu  sync.Mutex
+	dropped int
+}
+
+// New 打开（或创建）记录文件，新事件追加到已有内容之后
+func New(cfg config.ChangefeedConfig) (*Writer, error) {
+	if dir := filepath.Dir(cfg.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("创建 changefeed 目录失败: %w", err)
+		}
+	}
+	w := &Writer{
+		cfg:     cfg,
+		maxSize: int64(cfg.MaxSizeMB) * 1024 * 1024,
+		events:  make(chan prober.StateEvent, queueSize),
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Start 启动写入循环
+func (w *Writer) Start() {
+	logger.L().Infow("changefeed 已启用",
+		"path", w.cfg.Path,
+		"max_size_mb", w.cfg.MaxSizeMB,
+		"max_backups", w.cfg.MaxBackups,
+	)
+	w.wg.Add(1)
+	go w.run()
+}
+
+// Stop 写完缓冲中的事件后关闭文件，必须在探针停止之后调用
+func (w *Writer) Stop() {
+	close(w.events)
+	w.wg.Wait()
+	if err := w.file.Close(); err != nil {
+		logger.L().Warnw("关闭 changefeed 文件失败", "path", w.cfg.Path, "error", err)
+	}
+}
+
+// Handle 状态变化事件回调，通过 prober.Subscribe 注册
+// 不阻塞探测 goroutine：缓冲已满时丢弃事件并记录告警日志
+func (w *Writer) Handle(ev prober.StateEvent) {
+	select {
+	case w.events <- ev:
+	default:
+		w.dropMu.Lock()
+		w.dropped++
+		dropped := w.dropped
+		w.dropMu.Unlock()
+		logger.L().Warnw("changefeed 写入缓冲已满，丢弃状态变化事件",
+			"target", ev.Target,
+			"up", ev.Up,
+			"dropped_total", dropped,
+		)
+	}
+}
+
+func (w *Writer) run() {
+	defer w.wg.Done()
+	for ev := range w.events {
+		if err := w.write(ev); err != nil {
+			logger.L().Errorw("写入 changefeed 失败",
+				"path", w.cfg.Path,
+				"target", ev.Target,
+				"error", err,
+			)
+		}
+	}
+}
+
+// write 追加一行 JSON，写入后超过大小上限时先轮转
+// 每行在一次 Write 调用中写完，外部 tail 读取时不会看到半行
+func (w *Writer) write(ev prober.StateEvent) error {
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if w.size > 0 && w.size+int64(len(line)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			// 轮转失败不丢弃事件，继续追加到当前文件
+			logger.L().Warnw("changefeed 文件轮转失败", "path", w.cfg.Path, "error", err)
+		}
+	}
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	return err
+}
+
+// open 以追加方式打开记录文件，并以已有大小作为轮转计数的起点
+func (w *Writer) open() error {
+	file, err := os.OpenFile(w.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("打开 changefeed 文件失败: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("读取 changefeed 文件信息失败: %w", err)
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// rotate 关闭当前文件，依次重命名旧文件（path.N-1 → path.N），最旧的超出 max_backups 后删除
+// 重命名失败时仍重新打开记录文件继续追加，下次写入时再尝试轮转
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		logger.L().Warnw("关闭 changefeed 文件失败", "path", w.cfg.Path, "error", err)
+	}
+	err := w.shiftBackups()
+	if oerr := w.open(); oerr != nil {
+		return oerr
+	}
+	if err == nil {
+		logger.L().Infow("changefeed 文件已轮转", "path", w.cfg.Path, "max_backups", w.cfg.MaxBackups)
+	}
+	return err
+}
+
+// shiftBackups 腾出 path，max_backups 为 0 时直接删除当前文件
+func (w *Writer) shiftBackups() error {
+	if w.cfg.MaxBackups == 0 {
+		if err := os.Remove(w.cfg.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("删除 changefeed 文件失败: %w", err)
+		}
+		return nil
+	}
+
+	oldest := backupName(w.cfg.Path, w.cfg.MaxBackups)
+	if err := os.Remove(oldest); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除最旧的 changefeed 文件失败: %w", err)
+	}
+	for i := w.cfg.MaxBackups - 1; i >= 1; i-- {
+		err := os.Rename(backupName(w.cfg.Path, i), backupName(w.cfg.Path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("轮转 changefeed 文件失败: %w", err)
+		}
+	}
+	if err := os.Rename(w.cfg.Path, backupName(w.cfg.Path, 1)); err != nil {
+		return fmt.Errorf("轮转 changefeed 文件失败: %w", err)
+	}
+	return nil
+}
+
+func backupName(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
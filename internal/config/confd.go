@@ -0,0 +1,166 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// loadDatabasesDir 读取 databases_dir 中的 *.yaml、*.yml、*.json、*.toml 文件，按文件名顺序把其中的 databases 追加到主配置的目标之后
+// 每个文件只能包含 databases，全局配置项只能写在主配置文件中；文件中同样可以引用环境变量（${NAME}）
+// 以 . 开头的文件和目录（如 Kubernetes ConfigMap 挂载目录中的 ..data）被忽略
+func loadDatabasesDir(cfg *Config) error {
+	if cfg.DatabasesDir == "" {
+		return nil
+	}
+	files, err := DatabaseFiles(cfg.DatabasesDir)
+	if err != nil {
+		return err
+	}
+
+	cfg.databaseFields = make([]string, len(cfg.Databases), len(cfg.Databases)+len(files))
+	for _, path := range files {
+		dbCfgs, err := readDatabasesFile(path, cfg.Defaults, cfg.Modules)
+		if err != nil {
+			return err
+		}
+		for i := range dbCfgs {
+			cfg.databaseFields = append(cfg.databaseFields, fmt.Sprintf("%s: databases[%d]", path, i))
+		}
+		cfg.Databases = append(cfg.Databases, dbCfgs...)
+	}
+	return nil
+}
+
+// DatabaseFiles 返回目录中参与合并的目标文件，按文件名排序
+func DatabaseFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取 databases_dir 失败: %w", err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if !isDatabasesFile(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		// 跟随符号链接判断是否为普通文件（ConfigMap 挂载的文件是指向 ..data 的符号链接）
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取 %s 失败: %w", path, err)
+		}
+		if info.Mode().IsRegular() {
+			files = append(files, path)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// isDatabasesFile 文件名是否为参与合并的目标文件
+func isDatabasesFile(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	return isConfigExt(filepath.Ext(name))
+}
+
+// readDatabasesFile 读取单个目标文件中的 databases，各项同样合并主配置文件中的 defaults 和引用的 modules
+func readDatabasesFile(path string, defaults, modules map[string]interface{}) ([]DBConfig, error) {
+	data, format, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	v := viper.New()
+	v.SetConfigType(format)
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", path, err)
+	}
+	for _, key := range v.AllKeys() {
+		if top, _, _ := strings.Cut(key, "."); top != "databases" {
+			return nil, fmt.Errorf("%s 只能包含 databases，全局配置项 %s 需要写在主配置文件中", path, top)
+		}
+	}
+	if len(defaults) > 0 || len(modules) > 0 {
+		dbCfgs, err := decodeWithDefaults(v.Get("databases"), defaults, modules)
+		if err != nil {
+			return nil, fmt.Errorf("解析 %s 失败: %w", path, err)
+		}
+		return dbCfgs, nil
+	}
+	var dbCfgs []DBConfig
+	if err := v.UnmarshalKey("databases", &dbCfgs); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", path, err)
+	}
+	return dbCfgs, nil
+}
+
+// DecodeDatabase 解析单个目标的配置（YAML 或 JSON，与 databases 中的一项相同），值中未配置的字段取自 template
+// name 为内容的来源（如 KV 的键名），按扩展名识别格式，没有扩展名时以 { 开头的内容按 JSON 解析；值中同样可以引用环境变量（${NAME}）
+// 返回的配置尚未校验，调用方需要调用 ValidateDatabase
+func DecodeDatabase(name string, data []byte, template DBConfig) (DBConfig, error) {
+	data, format, err := decodeConfig(name, data, remoteFormat(name, "", data))
+	if err != nil {
+		return DBConfig{}, err
+	}
+	v := viper.New()
+	v.SetConfigType(format)
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return DBConfig{}, fmt.Errorf("解析 %s 失败: %w", name, err)
+	}
+
+	// mapstructure 解码到已有的 map、指针时直接写入，模板中的 map 和指针各目标之间不能共用；
+	// 解码到已有的列表时按下标覆盖，值中出现的列表（如 session_init）整体替换模板中的值
+	dbCfg := cloneDatabase(template)
+	rv := reflect.ValueOf(&dbCfg).Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		if rv.Field(i).Kind() == reflect.Slice && v.IsSet(rv.Type().Field(i).Tag.Get("mapstructure")) {
+			rv.Field(i).SetZero()
+		}
+	}
+	if err := v.Unmarshal(&dbCfg); err != nil {
+		return DBConfig{}, fmt.Errorf("解析 %s 失败: %w", name, err)
+	}
+	return dbCfg, nil
+}
+
+// cloneDatabase 复制目标配置，其中的 map、指针和列表也复制一份，修改副本不影响原配置
+func cloneDatabase(db DBConfig) DBConfig {
+	rv := reflect.ValueOf(&db).Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Field(i)
+		if field.IsZero() {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.Map:
+			clone := reflect.MakeMapWithSize(field.Type(), field.Len())
+			for iter := field.MapRange(); iter.Next(); {
+				clone.SetMapIndex(iter.Key(), iter.Value())
+			}
+			field.Set(clone)
+		case reflect.Ptr:
+			clone := reflect.New(field.Type().Elem())
+			clone.Elem().Set(field.Elem())
+			field.Set(clone)
+		case reflect.Slice:
+			field.Set(reflect.AppendSlice(reflect.MakeSlice(field.Type(), 0, field.Len()), field))
+		}
+	}
+	return db
+}
+
+// databaseField 第 i 个目标在校验错误信息中的位置
+func (cfg *Config) databaseField(i int) string {
+	if i < len(cfg.databaseFields) && cfg.databaseFields[i] != "" {
+		return cfg.databaseFields[i]
+	}
+	return fmt.Sprintf("databases[%d]", i)
+}
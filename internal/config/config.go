@@ -1,10 +1,17 @@
 // Package config 提供配置管理功能
-// 支持从 YAML 配置文件加载配置，并支持环境变量覆盖
+// 支持从 YAML 配置文件加载配置，值中可以引用环境变量（${NAME}），并支持环境变量覆盖
 // 配置包括监听地址、探测间隔、超时时间以及数据库实例列表
 package config
 
 import (
+	"bytes"
 	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/imkerbos/db-probe/pkg/logger"
@@ -13,52 +20,638 @@ import (
 
 // Config 主配置结构
 type Config struct {
-	ListenAddress string        `mapstructure:"listen_address"`
-	ProbeInterval time.Duration `mapstructure:"probe_interval"`
-	ProbeTimeout  time.Duration `mapstructure:"probe_timeout"`
-	Databases     []DBConfig    `mapstructure:"databases"`
+	ListenAddress        string        `mapstructure:"listen_address"`
+	ProbeInterval        time.Duration `mapstructure:"probe_interval"`
+	ProbeTimeout         time.Duration `mapstructure:"probe_timeout"`
+	ErrorDetailInterval  time.Duration `mapstructure:"error_detail_interval"`  // 相同错误重复出现时，完整详情的最小输出间隔（0 表示每次都输出）
+	ProbeRetries         int           `mapstructure:"probe_retries"`          // 单轮探测内瞬时错误的最大重试次数（默认 0，不重试）
+	RetryDelay           time.Duration `mapstructure:"retry_delay"`            // 单轮探测内首次重试前的等待时间，之后每次翻倍（默认 0，立即重试）
+	ProbeSplay           bool          `mapstructure:"probe_splay"`            // 按目标 ID 把各目标的探测时间点分散到探测间隔内（默认 true）
+	ProbeJitter          time.Duration `mapstructure:"probe_jitter"`           // 每轮探测时间点额外的随机延迟上限（默认 0，不超过探测间隔的一半）
+	OnDemandMinInterval  time.Duration `mapstructure:"on_demand_min_interval"` // 立即探测同一目标的最小间隔，间隔内的请求返回上一次探测的结果（默认 1s，0 表示不限制）
+	AuthFailureThreshold int           `mapstructure:"auth_failure_threshold"` // 连续认证失败多少次后进入账号锁定保护（默认 3，0 表示不启用）
+	AuthFailureBackoff   time.Duration `mapstructure:"auth_failure_backoff"`   // 账号锁定保护期间的探测间隔（默认 10m，0 表示停止探测直到手动恢复）
+	StaleAfterIntervals  int           `mapstructure:"stale_after_intervals"`  // 目标超过多少个探测间隔没有完成探测时判定指标过期（默认 3，0 表示不检查）
+	StaleBehavior        string        `mapstructure:"stale_behavior"`         // 指标过期时 db_probe_up 的处理：mark（默认，保持不变）、down（置 0）、nan（置 NaN，表示未知）
+	UptimeInterval       time.Duration `mapstructure:"uptime_interval"`        // 查询数据库实例运行时长的间隔（默认 1m，0 表示不查询）
+	StatementBudget      int           `mapstructure:"statement_budget"`       // 每个目标每小时执行语句数的上限，达到后跳过可选检查（默认 0，不限制）
+	ClusterCheckInterval time.Duration `mapstructure:"cluster_check_interval"` // 开启 cluster_check 的目标查询集群节点存活情况的间隔（默认 1m）
+	RuntimeMetrics       string        `mapstructure:"runtime_metrics"`        // 探针进程自身的运行时指标：off、basic（默认）、full
+	Zone                 string        `mapstructure:"zone"`                   // 可选，探针所在的区域（如可用区），与目标的 zone 比较得出 same_zone label
+	ErrorRules           []ErrorRule   `mapstructure:"error_rules"`            // 自定义错误分类规则，优先于内置分析
+	CredentialReuseCheck string        `mapstructure:"credential_reuse_check"` // 检查不同 env 的目标是否使用相同凭据：off（默认）、warn、error
+	WatchConfig          bool          `mapstructure:"watch_config"`           // 监听配置文件变化，自动重新加载（默认 false，SIGHUP 始终可用）
+	WatchConfigDebounce  time.Duration `mapstructure:"watch_config_debounce"`  // 配置文件最后一次变化后等待多久再重新加载（默认 2s）
+	ManagementSocket     string        `mapstructure:"management_socket"`      // 可选，在 unix socket 上提供与 HTTP 端口相同的接口，供 db-probe ctl 使用
+	Databases            []DBConfig    `mapstructure:"databases"`
+	DatabasesDir         string        `mapstructure:"databases_dir"` // 可选，目录中每个 *.yaml 文件的 databases 合并到 databases 之后（如每个应用一个文件）
+
+	// 开启 probe_splay 时，启动、目标新增或修改后首次探测前的最长等待（默认 10s，0 表示立即探测，见 probeSchedule.firstDelay）
+	ProbeSplayInitialDelay time.Duration `mapstructure:"probe_splay_initial_delay"`
+
+	// 可选，合并到主配置的其他配置文件（相对路径相对于主配置文件所在的目录），如把凭据、目标清单放在权限不同的文件中
+	// 主配置文件中的配置项优先，databases 拼接在主配置文件的目标之后；被包含的文件同样可以使用 includes（见 includeSettings）
+	Includes []string `mapstructure:"includes"`
+
+	// 可选，databases（包括 databases_dir 中的目标）中各项的默认配置：顶层的配置项适用于所有目标，
+	// types.<type> 下的配置项适用于该类型的目标并优先于顶层，目标自身的配置项优先于两者（见 applyDefaults）
+	Defaults map[string]interface{} `mapstructure:"defaults"`
+
+	// 可选，命名的探测模块（如 mysql-fast、oracle-deep）：每个模块是一组目标配置项（探测 SQL、超时、TLS 等），
+	// 目标通过 module 引用，优先于 defaults，目标自身的配置项优先于模块（见 decodeWithDefaults）
+	Modules map[string]interface{} `mapstructure:"modules"`
+
+	// databaseFields 各目标在校验错误信息中的位置，databases_dir 中的目标带有文件名（见 databaseField）
+	databaseFields []string
+
+	// configFiles 主配置文件及其 includes 包含的文件（见 ConfigFiles）
+	configFiles []string
+
+	// 可选，management_socket 的文件权限（默认 0660）和属组（组名或 GID，默认为进程的属组）
+	// management_socket_only 为 true 时没有认证的运维操作接口（立即探测、解除账号锁定保护）只在 socket 上提供，HTTP 端口不再提供
+	ManagementSocketMode  string `mapstructure:"management_socket_mode"`
+	ManagementSocketGroup string `mapstructure:"management_socket_group"`
+	ManagementSocketOnly  bool   `mapstructure:"management_socket_only"`
+
+	// 可选，提供管理接口的端口没有配置认证时，暂停和恢复探测、立即探测、调整日志级别接口需要的访问令牌（Authorization: Bearer）
+	// 端口未配置认证且未配置 control_token 时，这些接口只在管理 socket 上提供
+	ControlToken string `mapstructure:"control_token"`
+
+	// 可选，HTTP 端口（listen_address）的 TLS 和认证，认证对 /health 和自带签名校验的 webhook 以外的所有接口生效
+	ListenTLS  ListenerTLSConfig  `mapstructure:"listen_tls"`
+	ListenAuth ListenerAuthConfig `mapstructure:"listen_auth"`
+
+	// 可选，在 HTTP 端口提供 /probe?target=<name>（默认 false）：与 blackbox_exporter 相同，Prometheus 抓取时同步探测目标并只返回该目标的指标
+	ProbeEndpoint bool `mapstructure:"probe_endpoint"`
+
+	// 可选，独立的管理端口：配置 listen_address 后 /targets、/api/v1 只在管理端口提供，HTTP 端口只提供 /metrics 和 /health
+	Management ManagementConfig `mapstructure:"management"`
+
+	// 可选，/health 检查探针内部状态（探测调度、通知队列），异常时返回 503，Kubernetes 据此重启探针（默认只返回 200）
+	Health HealthConfig `mapstructure:"health"`
+
+	// 可选，连接串和会话初始化语句完全相同的目标（如同一实例上只有探测 SQL 不同的多个逻辑目标）共用一个连接池（默认 true），
+	// 减少对整合实例的连接数，各目标的指标仍然独立；目标可以通过 share_connection: false 单独退出
+	ShareConnections bool `mapstructure:"share_connections"`
+
+	// 可选，低内存模式，面向 ARMv7 等资源受限、只探测少量本地数据库的边缘网关（默认 false）
+	// 开启后不创建延迟分布（Histogram）指标，没有显式配置的探测间隔、remote write 缓冲等使用更节省资源的默认值（见 lowMemoryDefaults）
+	LowMemory bool `mapstructure:"low_memory"`
+
+	// 可选，每个目标保留最近出现过的不同错误的种数（默认 10，0 表示不保留），以及没有再出现的错误保留多久（默认 24h，0 表示不按时间丢弃）
+	// 通过 /api/v1/targets/{name}/errors 查询，偶发的认证失败、超时、DNS 错误在恢复后仍然可以查到
+	ErrorSamples          int           `mapstructure:"error_samples"`
+	ErrorSamplesRetention time.Duration `mapstructure:"error_samples_retention"`
+
+	// 可选，附加到所有指标的静态 label（如 region、datacenter、probe_instance），多个地域的探针探测同一个数据库时用于区分
+	// /metrics、remote write 和本地时序存储中都带有这些 label，变更需要重启才能生效
+	GlobalLabels map[string]string `mapstructure:"global_labels"`
+
+	// 可选，开启实验功能（如 write_canary: true），默认全部关闭；环境变量 DB_PROBE_FEATURE_<NAME>=true/false 覆盖同名功能
+	// 当前版本不支持的功能只输出告警，变更需要重启才能生效
+	Features map[string]bool `mapstructure:"features"`
+
+	// 可选，检查探测 SQL 与数据库当前版本的兼容性（默认 false）：首次连接成功及检测到实例重启、版本变化后，
+	// 用 EXPLAIN（不支持时在回滚的事务中试执行）检查探测 SQL，并记录服务端版本，结果导出为 db_probe_query_compatible
+	QueryCompatCheck bool `mapstructure:"query_compat_check"`
+
+	// 可选，从 HTTP(S) 地址拉取配置，覆盖本地配置文件中的同名配置项，按 remote_config.interval 轮询（ETag），变化后自动重新加载
+	// 用于集中管理大量探针：本地配置文件只保留 config_url 和每个探针自己的配置（如 zone、listen_address）
+	ConfigURL    string             `mapstructure:"config_url"`
+	RemoteConfig RemoteConfigConfig `mapstructure:"remote_config"`
+
+	// 可选，通过 Prometheus remote write 协议主动推送指标（未配置 url 时不启用）
+	RemoteWrite RemoteWriteConfig `mapstructure:"remote_write"`
+
+	// 可选，把目标状态变化以 JSON Lines 追加到文件（未配置 path 时不启用）
+	Changefeed ChangefeedConfig `mapstructure:"changefeed"`
+
+	// 可选，内置本地时序存储，没有 Prometheus 的站点通过 /api/v1/query_range 查询历史（未配置 path 时不启用）
+	LocalStorage LocalStorageConfig `mapstructure:"local_storage"`
+
+	// 可选，触发立即探测的 webhook（未配置 secret 时不启用）
+	Webhook WebhookConfig `mapstructure:"webhook"`
+
+	// 可选，故障演练接口，把目标临时标记为故障以演练告警链路（未配置 token 时不启用）
+	TestFire TestFireConfig `mapstructure:"test_fire"`
+
+	// 可选，ChatOps 查询机器人，在 Slack 斜杠命令、钉钉机器人中查询目标状态（未配置密钥时不启用）
+	ChatOps ChatOpsConfig `mapstructure:"chatops"`
+
+	// 可选，把目标状态变化发送到 webhook/chat 机器人（未配置 webhooks 时不启用）
+	Notify NotifyConfig `mapstructure:"notify"`
+
+	// 可选，从外部清单（如 CMDB）定期发现探测目标，与 databases 中的目标一起探测
+	Discovery DiscoveryConfig `mapstructure:"discovery"`
+
+	// 可选，访问 Kubernetes API 的参数，供配置了 secret_ref 的目标读取 Secret（在集群内运行时无需配置）
+	Kubernetes KubernetesConfig `mapstructure:"kubernetes"`
+
+	// 可选，外部密钥存储（如 Vault）的访问参数，供配置了 user_ref、password_ref、dsn_ref 的目标读取凭据
+	Secrets SecretsConfig `mapstructure:"secrets"`
+}
+
+// KubernetesConfig Kubernetes API 访问配置
+// 未配置时使用 Pod 的 ServiceAccount：KUBERNETES_SERVICE_HOST/PORT 环境变量、挂载的 token 和 CA 证书
+type KubernetesConfig struct {
+	APIServer string `mapstructure:"api_server"` // API Server 地址（如 https://10.0.0.1:6443，或 kubectl proxy 的 http://127.0.0.1:8001）
+	TokenFile string `mapstructure:"token_file"` // Bearer token 文件，每次请求时重新读取，支持 token 轮换
+	CAFile    string `mapstructure:"ca_file"`    // 校验 API Server 证书的 CA 文件
+}
+
+// SecretRef 目标凭据所在的 Kubernetes Secret
+// key、user_key、dsn_key 分别指定密码、用户名、完整 DSN 所在的 key，至少配置一个；读取到的值覆盖目标中的对应字段
+type SecretRef struct {
+	Namespace string `mapstructure:"namespace"` // Secret 所在的命名空间（默认为探针所在的命名空间）
+	Name      string `mapstructure:"name"`      // Secret 名称
+	Key       string `mapstructure:"key"`       // 密码所在的 key
+	UserKey   string `mapstructure:"user_key"`  // 可选，用户名所在的 key
+	DSNKey    string `mapstructure:"dsn_key"`   // 可选，完整 DSN 所在的 key（MongoDB 为连接串，Elasticsearch 为集群 URL）
+}
+
+// SecretsConfig 外部密钥存储配置
+type SecretsConfig struct {
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"` // 没有租约的凭据（如 KV 中的静态密码）的重新读取间隔（默认 5m）
+	Vault           VaultConfig   `mapstructure:"vault"`
+	AWS             AWSConfig     `mapstructure:"aws"`
+}
+
+// AWSConfig AWS Secrets Manager 访问配置，访问凭证使用 AWS 的标准来源（环境变量、EKS IRSA、ECS 任务角色、EC2 实例角色）
+type AWSConfig struct {
+	Region   string `mapstructure:"region"`   // 默认区域（默认 AWS_REGION、AWS_DEFAULT_REGION），引用 ARN 时使用 ARN 中的区域
+	Endpoint string `mapstructure:"endpoint"` // 可选，Secrets Manager 地址（如 VPC 终端节点），默认 https://secretsmanager.<region>.amazonaws.com
+}
+
+// VaultConfig HashiCorp Vault 访问配置，未配置的字段使用 Vault 客户端的标准环境变量（VAULT_ADDR、VAULT_TOKEN 等）
+// 认证方式：配置 kubernetes_role 时使用 Kubernetes 认证（ServiceAccount token 登录），否则使用 token
+type VaultConfig struct {
+	Address             string `mapstructure:"address"`               // Vault 地址（默认 VAULT_ADDR）
+	Namespace           string `mapstructure:"namespace"`             // Vault Enterprise 命名空间（默认 VAULT_NAMESPACE）
+	TokenFile           string `mapstructure:"token_file"`            // token 文件（如 Vault Agent 写入的 sink），每次请求时重新读取；未配置时使用 VAULT_TOKEN
+	KubernetesRole      string `mapstructure:"kubernetes_role"`       // Kubernetes 认证的角色
+	KubernetesMount     string `mapstructure:"kubernetes_mount"`      // Kubernetes 认证的挂载路径（默认 kubernetes）
+	KubernetesTokenFile string `mapstructure:"kubernetes_token_file"` // 登录使用的 ServiceAccount token 文件（默认为 Pod 中挂载的 token）
+	CAFile              string `mapstructure:"ca_file"`               // 校验 Vault 证书的 CA 文件（默认 VAULT_CACERT）
+}
+
+// PoolConfig 独立连接池的大小
+type PoolConfig struct {
+	MaxOpenConns int `mapstructure:"max_open_conns"` // 最大连接数（默认 1）
+	MaxIdleConns int `mapstructure:"max_idle_conns"` // 最大空闲连接数（默认 1，超过 max_open_conns 时按 max_open_conns）
+}
+
+// CheckPoolNames 可以使用独立连接池的检查：运行时长查询、集群节点查询、实际角色识别
+var CheckPoolNames = []string{"uptime", "cluster", "role"}
+
+// DiscoveryConfig 目标发现配置
+type DiscoveryConfig struct {
+	SQL SQLDiscoveryConfig `mapstructure:"sql"` // 从 SQL 清单库发现目标（未配置 dsn 时不启用）
+	KV  KVDiscoveryConfig  `mapstructure:"kv"`  // 从 etcd、Consul KV 前缀发现目标（未配置 backend 时不启用）
+}
+
+// SQLDiscoveryConfig SQL 清单库目标发现配置
+// query 返回的每一行生成一个目标：name、type、host、port 列必需，labels（JSON 对象）、project、env 列可选，
+// 其余字段（账号、密码、project、env 等）取自 template，行中的值覆盖 template
+type SQLDiscoveryConfig struct {
+	Driver   string        `mapstructure:"driver"`   // 清单库的驱动：mysql（默认）、postgres、sqlserver、oracle
+	DSN      string        `mapstructure:"dsn"`      // 清单库连接串（驱动原生格式）
+	Query    string        `mapstructure:"query"`    // 查询目标清单的 SQL
+	Interval time.Duration `mapstructure:"interval"` // 同步间隔（默认 5m）
+	Timeout  time.Duration `mapstructure:"timeout"`  // 单次查询的超时时间（默认 10s）
+	Template DBConfig      `mapstructure:"template"` // 目标模板，name、type、host、port 由查询结果提供
+}
+
+// KVDiscoveryConfig etcd、Consul KV 目标发现配置
+// prefix 下的每个键是一个目标，值为 YAML 或 JSON 格式的目标配置（与 databases 中的一项相同），未配置 name 时使用去掉前缀的键名；
+// 值中未配置的字段取自 template，前缀下的键变化后立即同步（etcd watch、Consul blocking query）
+type KVDiscoveryConfig struct {
+	Backend       string   `mapstructure:"backend"`         // etcd 或 consul
+	Address       string   `mapstructure:"address"`         // 服务地址，如 http://127.0.0.1:2379（etcd）、http://127.0.0.1:8500（Consul）
+	Prefix        string   `mapstructure:"prefix"`          // 目标所在的键前缀，如 db-probe/databases/
+	Token         string   `mapstructure:"token"`           // Consul 专用：ACL token
+	Username      string   `mapstructure:"username"`        // etcd 专用：开启认证时的用户名
+	Password      string   `mapstructure:"password"`        // etcd 专用：开启认证时的密码
+	CAFile        string   `mapstructure:"ca_file"`         // 可选，校验服务端证书的 CA 证书
+	TLSSkipVerify bool     `mapstructure:"tls_skip_verify"` // 可选，跳过服务端证书校验
+	Template      DBConfig `mapstructure:"template"`        // 目标模板，值中未配置的字段取自这里
+}
+
+// NotifyConfig 状态变化通知配置
+// 通知先写入磁盘队列再发送，发送失败按指数退避重试，webhook/chat 服务故障期间不丢失故障事件；
+// 超过 max_attempts 或被对端明确拒绝（4xx）的通知移入死信目录
+type NotifyConfig struct {
+	Webhooks      []NotifyWebhook `mapstructure:"webhooks"`       // 通知渠道，每个渠道有独立的队列，互不阻塞
+	QueueDir      string          `mapstructure:"queue_dir"`      // 队列目录（默认 data/notify），每个渠道一个子目录，死信在其 dead 子目录
+	MaxAttempts   int             `mapstructure:"max_attempts"`   // 单条通知的最大发送次数（默认 50），超过后移入死信目录
+	RetryInterval time.Duration   `mapstructure:"retry_interval"` // 首次重试的间隔（默认 5s），之后每次翻倍，最长 5m
+	Timeout       time.Duration   `mapstructure:"timeout"`        // 单次发送的超时时间（默认 10s）
+}
+
+// NotifyWebhook 通知渠道
+type NotifyWebhook struct {
+	Name    string            `mapstructure:"name"`    // 渠道名称，用作队列子目录和指标的 channel label
+	URL     string            `mapstructure:"url"`     // 发送地址（企业微信、钉钉机器人的 key/access_token 包含在地址中）
+	Format  string            `mapstructure:"format"`  // 消息格式：json（默认，原始状态变化事件）、slack、wecom、dingtalk
+	Headers map[string]string `mapstructure:"headers"` // 可选，附加的请求头（如 Authorization）
+}
+
+// WebhookConfig 立即探测 webhook 配置
+// 请求体需要用 secret 做 HMAC-SHA256 签名，签名放在 X-Hub-Signature-256 请求头中
+type WebhookConfig struct {
+	Secret string `mapstructure:"secret"` // HMAC 密钥，未配置时不注册 POST /api/v1/webhook
+}
+
+// TestFireConfig 故障演练接口配置
+// 请求需要在 Authorization 请求头中携带 Bearer token
+type TestFireConfig struct {
+	Token       string        `mapstructure:"token"`        // 访问令牌，未配置时不注册 /api/v1/test/fire
+	MaxDuration time.Duration `mapstructure:"max_duration"` // 单次演练的最长持续时间（默认 1h）
+}
+
+// ChatOpsConfig ChatOps 查询机器人配置
+// 请求需要按各平台的方式签名，签名校验失败或时间戳过期的请求直接拒绝
+type ChatOpsConfig struct {
+	SlackSigningSecret string `mapstructure:"slack_signing_secret"` // Slack 应用的 Signing Secret，未配置时不注册 POST /api/v1/chatops/slack
+	DingTalkAppSecret  string `mapstructure:"dingtalk_app_secret"`  // 钉钉企业内部机器人的 AppSecret，未配置时不注册 POST /api/v1/chatops/dingtalk
+}
+
+// ChangefeedConfig 状态变化记录文件配置
+// 每次状态变化写入一行 JSON，供离线分析使用，与通知渠道无关
+type ChangefeedConfig struct {
+	Path       string `mapstructure:"path"`        // 文件路径，目录不存在时自动创建
+	MaxSizeMB  int    `mapstructure:"max_size_mb"` // 单个文件的最大大小（MB，默认 100），超过后轮转
+	MaxBackups int    `mapstructure:"max_backups"` // 保留的轮转文件数（默认 5），0 表示轮转时直接删除旧文件
+}
+
+// LocalStorageConfig 内置本地时序存储配置
+// 适用于没有任何 Prometheus 的离线站点，按采样间隔把指定指标追加到本地按天切分的文件，保留 retention 时长
+type LocalStorageConfig struct {
+	Path      string        `mapstructure:"path"`      // 存储目录，目录不存在时自动创建
+	Retention time.Duration `mapstructure:"retention"` // 保留时长（默认 168h，即 7 天），按天删除过期文件
+	Interval  time.Duration `mapstructure:"interval"`  // 采样间隔（默认 15s）
+	Metrics   []string      `mapstructure:"metrics"`   // 存储的指标名（默认 db_probe_up 和三个耗时指标），只支持 Gauge 和 Counter
+}
+
+// RemoteWriteConfig remote write 推送配置
+// 适用于没有 Prometheus 抓取的边缘站点，直接推送到 Grafana Cloud、Mimir、VictoriaMetrics 等
+type RemoteWriteConfig struct {
+	URL               string            `mapstructure:"url"`                 // 推送地址，如 https://.../api/prom/push
+	Interval          time.Duration     `mapstructure:"interval"`            // 采集并推送的间隔（默认 30s）
+	Timeout           time.Duration     `mapstructure:"timeout"`             // 单次推送的超时时间（默认 10s）
+	BasicAuth         BasicAuthConfig   `mapstructure:"basic_auth"`          // 可选，Basic 认证（Grafana Cloud 用户名为实例 ID，密码为 API Token）
+	BearerToken       string            `mapstructure:"bearer_token"`        // 可选，Bearer Token 认证，与 basic_auth 二选一
+	TenantID          string            `mapstructure:"tenant_id"`           // 可选，多租户 ID，作为 X-Scope-OrgID 请求头（Mimir/Cortex）
+	Headers           map[string]string `mapstructure:"headers"`             // 可选，附加的请求头
+	ExternalLabels    map[string]string `mapstructure:"external_labels"`     // 可选，附加到所有时间序列的 label（默认带 job、instance）
+	TLSSkipVerify     bool              `mapstructure:"tls_skip_verify"`     // 跳过 TLS 证书校验
+	CAFile            string            `mapstructure:"ca_file"`             // 可选，校验服务端证书的 CA 文件
+	MaxPendingBatches int               `mapstructure:"max_pending_batches"` // 远端不可用时最多缓冲的批次数（默认 20），超过后丢弃最旧的批次
+}
+
+// RemoteConfigConfig 远端配置（config_url）的拉取配置，只能写在本地配置文件中
+type RemoteConfigConfig struct {
+	Interval      time.Duration     `mapstructure:"interval"`        // 轮询间隔（默认 1m）
+	Timeout       time.Duration     `mapstructure:"timeout"`         // 单次请求的超时时间（默认 10s）
+	BasicAuth     BasicAuthConfig   `mapstructure:"basic_auth"`      // 可选，Basic 认证
+	BearerToken   string            `mapstructure:"bearer_token"`    // 可选，Bearer Token 认证，与 basic_auth 二选一
+	Headers       map[string]string `mapstructure:"headers"`         // 可选，附加的请求头
+	TLSSkipVerify bool              `mapstructure:"tls_skip_verify"` // 跳过 TLS 证书校验
+	CAFile        string            `mapstructure:"ca_file"`         // 可选，校验服务端证书的 CA 文件
+	CacheFile     string            `mapstructure:"cache_file"`      // 可选，保存最近一次拉取到的配置，启动时远端不可用则使用该文件
+}
+
+// ListenerTLSConfig HTTP 监听端口的 TLS 配置，配置 cert_file、key_file 后使用 HTTPS
+// 证书文件变化（如 cert-manager 续期）后，新的连接自动使用新证书
+type ListenerTLSConfig struct {
+	CertFile     string `mapstructure:"cert_file"`      // 服务端证书
+	KeyFile      string `mapstructure:"key_file"`       // 服务端私钥
+	ClientCAFile string `mapstructure:"client_ca_file"` // 可选，要求客户端证书并使用该 CA 校验（mTLS）
+}
+
+// ListenerAuthConfig HTTP 监听端口的认证，basic_auth 与 bearer_token 二选一，都未配置时不认证
+type ListenerAuthConfig struct {
+	BasicAuth   BasicAuthConfig `mapstructure:"basic_auth"`
+	BearerToken string          `mapstructure:"bearer_token"`
+}
+
+// ManagementConfig 独立管理端口配置
+type ManagementConfig struct {
+	ListenAddress string             `mapstructure:"listen_address"` // 管理端口的监听地址（如 127.0.0.1:9101），未配置时管理接口与 /metrics 共用 listen_address
+	TLS           ListenerTLSConfig  `mapstructure:"tls"`
+	Auth          ListenerAuthConfig `mapstructure:"auth"`
+}
+
+// HealthConfig /health 内部状态检查配置
+type HealthConfig struct {
+	Checks             bool          `mapstructure:"checks"`               // 开启内部状态检查，/health 返回 JSON 格式的检查结果
+	StallIntervals     int           `mapstructure:"stall_intervals"`      // 所有目标都超过多少个探测间隔没有完成探测时判定探测调度停止（默认 5）
+	NotifyStallTimeout time.Duration `mapstructure:"notify_stall_timeout"` // 通知队列有待发送的通知、发送循环超过预定时间多久仍没有发送时判定卡住（默认 10m）
+}
+
+// BasicAuthConfig Basic 认证配置
+type BasicAuthConfig struct {
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+}
+
+// ErrorRule 自定义错误分类规则
+// 错误信息匹配 Pattern 时，失败阶段和严重级别使用规则中的配置
+type ErrorRule struct {
+	Pattern    string   `mapstructure:"pattern"`     // 匹配错误信息的正则表达式
+	Stage      string   `mapstructure:"stage"`       // 失败阶段（如 service_not_registered）
+	Severity   string   `mapstructure:"severity"`    // 严重级别（默认 error）
+	Types      []string `mapstructure:"types"`       // 可选，只对指定的数据库类型生效
+	RunbookURL string   `mapstructure:"runbook_url"` // 可选，该类错误的处理手册链接
+	Retryable  *bool    `mapstructure:"retryable"`   // 可选，是否允许重试（默认按失败阶段判断）
 }
 
 // DBConfig 数据库配置
 type DBConfig struct {
 	Name        string            `mapstructure:"name"`
-	Type        string            `mapstructure:"type"` // mysql, tidb, oracle
+	ID          string            `mapstructure:"id"`   // 可选，目标的稳定标识，改名后保持不变；未配置时由类型和地址推导（见 deriveTargetID）
+	Type        string            `mapstructure:"type"` // mysql, tidb, mariadb-galera, oceanbase, doris, oracle, dm, kingbase, db2, mssql, cockroachdb, snowflake, aurora-mysql, aurora-postgres, sqlite, redis, mongodb, cassandra, elasticsearch, trino, tcp
 	Host        string            `mapstructure:"host"`
 	Port        int               `mapstructure:"port"`
 	User        string            `mapstructure:"user"`
 	Password    string            `mapstructure:"password"`
-	DSN         string            `mapstructure:"dsn"`          // 可选，如果提供则优先使用
-	Query       string            `mapstructure:"query"`        // 可选，自定义探测 SQL
+	DSN         string            `mapstructure:"dsn"`          // 可选，如果提供则优先使用（MongoDB 为连接串，可以是副本集 URI）
+	Query       string            `mapstructure:"query"`        // 可选，自定义探测 SQL（Redis 为探测命令，如 GET __probe__；MongoDB 为命令名，如 ping）
+	Module      string            `mapstructure:"module"`       // 可选，引用 modules 中的探测模块，加载时合并到目标，之后为空
 	ServiceName string            `mapstructure:"service_name"` // Oracle 专用：服务名称（默认 "ORCL"）
 	Project     string            `mapstructure:"project"`      // 项目名称
 	Env         string            `mapstructure:"env"`          // 环境标识
 	Labels      map[string]string `mapstructure:"labels"`       // 额外的 label 维度
+	RunbookURL  string            `mapstructure:"runbook_url"`  // 可选，该目标的处理手册链接
+	Owner       string            `mapstructure:"owner"`        // 可选，负责人
+	Team        string            `mapstructure:"team"`         // 可选，所属团队
+	Oncall      string            `mapstructure:"oncall"`       // 可选，值班/升级联系方式（如值班组、电话）
+	Zone        string            `mapstructure:"zone"`         // 可选，目标所在的区域（如可用区），导出为 zone label
+	SessionInit []string          `mapstructure:"session_init"` // 可选，每条新建物理连接上执行一次的会话初始化语句
+	LockSafety  *bool             `mapstructure:"lock_safety"`  // 可选，是否启用只读、短锁等待的会话安全设置（默认启用）
+
+	// 可选，从 Kubernetes Secret 读取密码、用户名或完整 DSN，读取到凭据后才开始探测，Secret 变化时重建连接
+	SecretRef *SecretRef `mapstructure:"secret_ref"`
+
+	// 可选，从文件读取用户名、密码（如 Docker secrets、Kubernetes 挂载的 Secret 文件），配置后覆盖 user、password
+	// 加载配置时读取，重新加载配置时重新读取，文件内容变化的目标会重建连接
+	UserFile     string `mapstructure:"user_file"`
+	PasswordFile string `mapstructure:"password_file"`
+
+	// 可选，从外部密钥存储读取用户名、密码或完整 DSN，格式为 <provider>:<path>#<key>（如 vault:secret/data/db/orders#password）
+	// 读取到凭据后才开始探测，定期重新读取（有租约的凭据按租约续期），凭据轮换后重建连接
+	UserRef     string `mapstructure:"user_ref"`
+	PasswordRef string `mapstructure:"password_ref"`
+	DSNRef      string `mapstructure:"dsn_ref"`
+
+	// 可选，为可选检查（uptime、cluster、role）使用独立的连接池，key 为检查名称
+	// 检查的查询卡住时只占用自己的连接池，不会让探测 SQL 等待连接；未列出的检查与探测 SQL 共用连接池
+	CheckPools map[string]PoolConfig `mapstructure:"check_pools"`
+
+	// 可选，每小时执行语句数的上限，覆盖全局 statement_budget（0 表示不限制）
+	StatementBudget *int `mapstructure:"statement_budget"`
+
+	// 可选，覆盖全局 share_connections，false 表示即使与其他目标的连接相同也使用独立的连接池
+	ShareConnection *bool `mapstructure:"share_connection"`
+
+	// 可选，覆盖全局 probe_interval、probe_timeout（如跨广域网的目标需要更长的超时），未配置时使用全局值
+	ProbeInterval time.Duration `mapstructure:"probe_interval"`
+	ProbeTimeout  time.Duration `mapstructure:"probe_timeout"`
+
+	// 可选，探测间隔较长（分钟级）时在两轮探测之间按该间隔对空闲连接执行一次 Ping（如 MySQL 的 COM_PING），
+	// 保持 NAT、防火墙的连接状态，避免空闲超时后每轮探测都要重新建连；0 表示不发送
+	KeepaliveInterval time.Duration `mapstructure:"keepalive_interval"`
+
+	// 可选，每轮探测前关闭已有的连接，每轮都重新建连，探测延迟稳定地包含建连耗时（与 keepalive_interval 二选一）
+	AlwaysReconnect bool `mapstructure:"always_reconnect"`
+
+	// 可选，只在 Prometheus 抓取 /probe 时探测：不执行周期探测和保活，也不参与过期（stale）和调度健康检查，需要开启 probe_endpoint
+	ScrapeOnly bool `mapstructure:"scrape_only"`
+
+	// Oracle 专用：新建连接后切换到的 PDB 容器（ALTER SESSION SET CONTAINER）和默认 schema（CURRENT_SCHEMA）
+	// 配置任意一项且未自定义 query 时，默认探测 SQL 改为 SELECT 1 FROM SYS.DUAL
+	Container     string `mapstructure:"container"`
+	DefaultSchema string `mapstructure:"default_schema"`
+
+	// Oracle 专用：连接 listener 时请求的服务器类型：dedicated（专用服务器）、shared（共享服务器）、pooled（DRCP 连接池）
+	// 未配置时不指定，由 listener 决定（配置了 dispatcher 的实例默认使用共享服务器）
+	ServerType string `mapstructure:"server_type"`
+
+	// OceanBase 专用：租户名和集群名，连接时用户名拼接为 user@tenant#cluster
+	// 直连 OBServer 时只需 tenant，经 OBProxy 连接多集群时还需要 cluster
+	Tenant  string `mapstructure:"tenant"`
+	Cluster string `mapstructure:"cluster"`
+
+	// MySQL 协议类型（mysql、tidb、mariadb-galera、oceanbase、doris）专用，未提供 dsn 时追加到连接参数中
+	// compress 开启协议压缩（适合跨广域网的目标），charset 为连接字符集（可用逗号分隔多个，依次尝试），collation 为连接排序规则
+	Compress  bool   `mapstructure:"compress"`
+	Charset   string `mapstructure:"charset"`
+	Collation string `mapstructure:"collation"`
+
+	// KingbaseES、DB2、Aurora PostgreSQL 专用：连接的数据库名（KingbaseES 默认 test，Aurora PostgreSQL 默认 postgres，DB2 未提供 dsn 时必填）
+	Database string `mapstructure:"database"`
+
+	// Cassandra/ScyllaDB 专用：host 之外的其他 contact points（可带端口，未带时使用 port），
+	// 以及本地数据中心名称（配置后只连接该数据中心的节点）
+	ContactPoints []string `mapstructure:"contact_points"`
+	Datacenter    string   `mapstructure:"datacenter"`
+
+	// Snowflake 专用：账号标识（如 myorg-myaccount，必填）、仓库和角色（可选，未配置时使用用户的默认值）
+	// 未配置 host 时连接 <account>.snowflakecomputing.com:443；配置 private_key_file 时使用 key-pair 认证，不需要 password
+	Account        string `mapstructure:"account"`
+	Warehouse      string `mapstructure:"warehouse"`
+	Role           string `mapstructure:"role"`
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+
+	// Elasticsearch/OpenSearch 专用：API Key 认证（Authorization: ApiKey <api_key>），与 user/password 二选一
+	APIKey string `mapstructure:"api_key"`
+
+	// Aurora 专用：集群的 reader 端点（如 xxx.cluster-ro-xxx.rds.amazonaws.com），配置后与 host（writer 端点）分别探测
+	// 两个端点的 role label 都由探测自动识别（writer/reader），故障切换后自动更新
+	ReaderHost string `mapstructure:"reader_host"`
+
+	// SQLite 专用：本地数据库文件路径，以只读方式打开（文件不存在时探测失败，不会创建新文件）
+	Path string `mapstructure:"path"`
+
+	// CockroachDB、Doris 专用：按 cluster_check_interval 查询集群节点存活情况
+	// （CockroachDB 需要 VIEWCLUSTERMETADATA 权限，Doris 需要 ADMIN 或 NODE 权限）
+	ClusterCheck bool `mapstructure:"cluster_check"`
+
+	// MySQL、Oracle、达梦、MongoDB 专用：每轮探测识别节点实际角色，导出为 db_probe_effective_role 并与配置的 role 对比
+	// （MySQL 读取 read_only，Oracle 读取 v$database.database_role，达梦读取 V$INSTANCE.MODE$，MongoDB 使用 hello 的结果）
+	RoleDetection bool `mapstructure:"role_detection"`
+
+	// TCP、Redis、MongoDB、Cassandra、Elasticsearch、Trino、CockroachDB、KingbaseES、Aurora PostgreSQL 类型专用
+	// banner 仅 TCP；MongoDB、Elasticsearch、Trino、CockroachDB、KingbaseES、Aurora PostgreSQL 配置 dsn 时由连接串控制 TLS（Elasticsearch、Trino 为 https 地址）
+	TLS           bool   `mapstructure:"tls"`             // 连接后进行 TLS 握手
+	TLSSkipVerify bool   `mapstructure:"tls_skip_verify"` // 跳过 TLS 证书校验
+	Banner        string `mapstructure:"banner"`          // 可选，期望的 banner 正则，连接后读取并匹配
+
+	// 可选，host 为域名且解析出多个地址（如双栈、anycast、VIP 成员）时分别探测每个地址，用 db_ip label 区分
+	// 最多探测 max_addresses 个地址（默认 8）；不适用于配置了 dsn 的目标
+	ProbeAllAddresses bool `mapstructure:"probe_all_addresses"`
+	MaxAddresses      int  `mapstructure:"max_addresses"`
+
+	// MySQL 协议类型专用：每轮探测比较连接实际连接的对端地址与 host 当前的 DNS 解析结果，导出为 db_probe_peer_mismatch
+	// 用于发现基于 DNS 的故障切换后连接池仍连着旧后端的情况
+	PeerCheck bool `mapstructure:"peer_check"`
+
+	// 可选，按该间隔重新解析 host，解析出的地址变化时更新 db_ip label（如 RDS 故障切换后域名指向新的实例）；0 表示只在创建目标时解析一次
+	DNSRefreshInterval time.Duration `mapstructure:"dns_refresh_interval"`
+	// 可选，探测失败后立即重新解析 host，不等待 dns_refresh_interval
+	DNSRefreshOnFailure bool `mapstructure:"dns_refresh_on_failure"`
+
+	// TiDB 专用：状态端口（通常为 10080），配置后每轮探测同时请求 /status，导出状态端口可用性、连接数和版本
+	// 状态端口不经过 SQL 层，用于区分 SQL 层过载（SQL 探测失败、状态端口正常）和进程退出（两者都失败）
+	StatusPort int `mapstructure:"status_port"`
+
+	// MySQL 协议类型专用：按 schema 展开为多个目标，每个目标探测前执行 USE <schema> 再执行探测 SQL，用 schema label 区分
+	// 展开出的目标共用同一个连接池，用于一台实例上有大量租户库、需要分别知道各租户库是否可用的场景
+	Schemas []string `mapstructure:"schemas"`
+
+	// 可选，按主机展开为多个目标（如一组只读副本），每项为主机或 host:port，其余字段相同
+	// 展开出的目标名称追加主机（和端口），只能在配置文件中使用，不能与 host、dsn 同时配置
+	Hosts []string `mapstructure:"hosts"`
 }
 
 var (
 	globalConfig *Config
 )
 
-// Load 加载配置（固定从 configs/config.yaml 读取）
+// Load 加载配置（从 configs/config.yaml 读取，也可以是 config.yml、config.json、config.toml，见 ConfigFile）
+// 设置了 DB_PROBE_TYPE 时为单目标模式，不读取配置文件，全局配置项和唯一的目标都来自环境变量（见 envTarget）
+// 合并了新拉取到的远端配置时，加载和校验成功后才提交远端配置并写入 cache_file
 func Load() (*Config, error) {
-	configPath := "configs/config.yaml"
+	cfg, err := load()
+	remote.settle(err == nil)
+	return cfg, err
+}
 
-	viper.SetConfigFile(configPath)
-	viper.SetConfigType("yaml")
+func load() (*Config, error) {
+	envMode := EnvTargetMode()
+	path, err := ConfigFile()
+	if err != nil && !envMode {
+		return nil, err
+	}
+	if envMode && path != "" {
+		logger.L().Warnw("已设置 DB_PROBE_TYPE，按单目标模式运行，忽略配置文件", "config_file", path)
+	}
 
 	// 支持环境变量覆盖（前缀 DB_PROBE_）
 	viper.SetEnvPrefix("DB_PROBE")
 	viper.AutomaticEnv()
 
-	// 读取配置文件
-	if err := viper.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	// 默认值
+	viper.SetDefault("error_detail_interval", "5m")
+	viper.SetDefault("error_samples", 10)
+	viper.SetDefault("error_samples_retention", "24h")
+	viper.SetDefault("auth_failure_threshold", 3)
+	viper.SetDefault("auth_failure_backoff", "10m")
+	viper.SetDefault("stale_after_intervals", 3)
+	viper.SetDefault("stale_behavior", "mark")
+	viper.SetDefault("uptime_interval", "1m")
+	viper.SetDefault("cluster_check_interval", "1m")
+	viper.SetDefault("runtime_metrics", "basic")
+	viper.SetDefault("watch_config_debounce", "2s")
+	viper.SetDefault("share_connections", true)
+	viper.SetDefault("probe_splay", true)
+	viper.SetDefault("probe_splay_initial_delay", "10s")
+	viper.SetDefault("on_demand_min_interval", "1s")
+	viper.SetDefault("management_socket_mode", "0660")
+	viper.SetDefault("health.stall_intervals", 5)
+	viper.SetDefault("health.notify_stall_timeout", "10m")
+	viper.SetDefault("remote_config.interval", "1m")
+	viper.SetDefault("remote_config.timeout", "10s")
+	viper.SetDefault("remote_write.interval", "30s")
+	viper.SetDefault("remote_write.timeout", "10s")
+	viper.SetDefault("remote_write.max_pending_batches", 20)
+	viper.SetDefault("changefeed.max_size_mb", 100)
+	viper.SetDefault("changefeed.max_backups", 5)
+	viper.SetDefault("local_storage.retention", "168h")
+	viper.SetDefault("local_storage.interval", "15s")
+	viper.SetDefault("local_storage.metrics", []string{
+		"db_probe_up",
+		"db_probe_duration_seconds",
+		"db_probe_ping_duration_seconds",
+		"db_probe_query_duration_seconds",
+	})
+	viper.SetDefault("test_fire.max_duration", "1h")
+	viper.SetDefault("notify.queue_dir", "data/notify")
+	viper.SetDefault("notify.max_attempts", 50)
+	viper.SetDefault("notify.retry_interval", "5s")
+	viper.SetDefault("notify.timeout", "10s")
+	viper.SetDefault("discovery.sql.driver", "mysql")
+	viper.SetDefault("discovery.sql.interval", "5m")
+	viper.SetDefault("discovery.sql.timeout", "10s")
+	viper.SetDefault("secrets.refresh_interval", "5m")
+	viper.SetDefault("secrets.vault.kubernetes_mount", "kubernetes")
+
+	var configFiles []string
+	if envMode {
+		bindGlobalEnv()
+		viper.SetConfigType("yaml")
+		if err := viper.ReadConfig(strings.NewReader(envTargetBase)); err != nil {
+			return nil, fmt.Errorf("读取配置失败: %w", err)
+		}
+	} else {
+		// 读取配置文件，替换值中引用的环境变量（${NAME}），密码等敏感信息不必写入配置文件
+		viper.SetConfigFile(path)
+		data, format, err := readConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		viper.SetConfigType(format)
+		if err := viper.ReadConfig(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("读取配置文件失败: %w", err)
+		}
+		includes, err := mergeIncludes(path, data, format)
+		if err != nil {
+			return nil, err
+		}
+		configFiles = append([]string{path}, includes...)
 	}
 
 	var cfg Config
 	if err := viper.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("解析配置文件失败: %w", err)
 	}
+	// 合并远端配置后重新解析，远端配置中的配置项覆盖本地配置文件
+	if cfg.ConfigURL != "" {
+		if err := mergeRemoteConfig(cfg.ConfigURL, cfg.RemoteConfig); err != nil {
+			return nil, err
+		}
+		cfg = Config{}
+		if err := viper.Unmarshal(&cfg); err != nil {
+			return nil, fmt.Errorf("解析远端配置失败: %w", err)
+		}
+	}
+	cfg.configFiles = configFiles
+	if err := applyDefaults(&cfg); err != nil {
+		return nil, err
+	}
+	if err := loadDatabasesDir(&cfg); err != nil {
+		return nil, err
+	}
+	if envMode {
+		if cfg.WatchConfig {
+			return nil, fmt.Errorf("单目标模式（DB_PROBE_TYPE）没有配置文件，不能开启 watch_config")
+		}
+		dbCfg, err := envTarget(cfg.Defaults, cfg.Modules)
+		if err != nil {
+			return nil, err
+		}
+		for len(cfg.databaseFields) < len(cfg.Databases) {
+			cfg.databaseFields = append(cfg.databaseFields, "")
+		}
+		cfg.Databases = append(cfg.Databases, dbCfg)
+		cfg.databaseFields = append(cfg.databaseFields, envTargetField)
+	}
+	if err := expandHosts(&cfg); err != nil {
+		return nil, err
+	}
+	applyLowMemoryDefaults(&cfg)
+	if err := applyFeatureEnv(&cfg); err != nil {
+		return nil, err
+	}
 
 	// 校验配置
 	if err := Validate(&cfg); err != nil {
@@ -66,10 +659,25 @@ func Load() (*Config, error) {
 	}
 
 	globalConfig = &cfg
-	logger.L().Infof("配置加载成功: %s", viper.ConfigFileUsed())
+	if envMode {
+		logger.L().Infof("配置加载成功: 单目标模式（%s）", envTargetField)
+	} else {
+		logger.L().Infof("配置加载成功: %s", viper.ConfigFileUsed())
+	}
 	return &cfg, nil
 }
 
+// ConfigFiles 返回主配置文件及其 includes 包含的文件，watch_config 监听这些文件；单目标模式没有配置文件，返回空
+func (c *Config) ConfigFiles() []string {
+	return c.configFiles
+}
+
+// oracleIdentifier 不带引号的 Oracle 标识符（如 APP、CDB$ROOT）
+var oracleIdentifier = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_$#]{0,127}$`)
+
+// oracleServiceName 可以拼接到连接描述符中的 Oracle 服务名
+var oracleServiceName = regexp.MustCompile(`^[A-Za-z0-9_.\-]+$`)
+
 // Validate 校验配置
 func Validate(cfg *Config) error {
 	// 校验探测间隔和超时时间
@@ -79,6 +687,111 @@ func Validate(cfg *Config) error {
 	if cfg.ProbeTimeout <= 0 {
 		return fmt.Errorf("probe_timeout 必须大于 0")
 	}
+	if cfg.ErrorDetailInterval < 0 {
+		return fmt.Errorf("error_detail_interval 不能为负数")
+	}
+	if cfg.ErrorSamples < 0 || cfg.ErrorSamplesRetention < 0 {
+		return fmt.Errorf("error_samples、error_samples_retention 不能为负数")
+	}
+	if cfg.ProbeRetries < 0 {
+		return fmt.Errorf("probe_retries 不能为负数")
+	}
+	if cfg.RetryDelay < 0 {
+		return fmt.Errorf("retry_delay 不能为负数")
+	}
+	if cfg.ProbeJitter < 0 {
+		return fmt.Errorf("probe_jitter 不能为负数")
+	}
+	if cfg.ProbeJitter > cfg.ProbeInterval/2 {
+		return fmt.Errorf("probe_jitter (%v) 不能超过 probe_interval (%v) 的一半", cfg.ProbeJitter, cfg.ProbeInterval)
+	}
+	if cfg.ProbeSplayInitialDelay < 0 {
+		return fmt.Errorf("probe_splay_initial_delay 不能为负数")
+	}
+	if cfg.OnDemandMinInterval < 0 {
+		return fmt.Errorf("on_demand_min_interval 不能为负数")
+	}
+	if cfg.RetryDelay > 0 && cfg.RetryDelay >= cfg.ProbeTimeout {
+		return fmt.Errorf("retry_delay (%v) 需要小于 probe_timeout (%v)，重试在本轮探测的超时预算内进行", cfg.RetryDelay, cfg.ProbeTimeout)
+	}
+	if cfg.AuthFailureThreshold < 0 {
+		return fmt.Errorf("auth_failure_threshold 不能为负数")
+	}
+	if cfg.AuthFailureBackoff < 0 {
+		return fmt.Errorf("auth_failure_backoff 不能为负数")
+	}
+	if cfg.StaleAfterIntervals < 0 {
+		return fmt.Errorf("stale_after_intervals 不能为负数")
+	}
+	switch cfg.StaleBehavior {
+	case "", "mark", "down", "nan":
+	default:
+		return fmt.Errorf("stale_behavior 只能是 mark、down 或 nan: %s", cfg.StaleBehavior)
+	}
+	if cfg.UptimeInterval < 0 {
+		return fmt.Errorf("uptime_interval 不能为负数")
+	}
+	if cfg.StatementBudget < 0 {
+		return fmt.Errorf("statement_budget 不能为负数")
+	}
+	if cfg.ClusterCheckInterval < 0 {
+		return fmt.Errorf("cluster_check_interval 不能为负数")
+	}
+	switch cfg.RuntimeMetrics {
+	case "", "off", "basic", "full":
+	default:
+		return fmt.Errorf("runtime_metrics 只能是 off、basic 或 full: %s", cfg.RuntimeMetrics)
+	}
+	if err := validateManagementSocket(cfg); err != nil {
+		return err
+	}
+	if err := validateListeners(cfg); err != nil {
+		return err
+	}
+	if cfg.Health.Checks {
+		if cfg.Health.StallIntervals <= 0 {
+			return fmt.Errorf("health.stall_intervals 必须大于 0")
+		}
+		if cfg.Health.NotifyStallTimeout <= 0 {
+			return fmt.Errorf("health.notify_stall_timeout 必须大于 0")
+		}
+	}
+	if cfg.WatchConfig && cfg.WatchConfigDebounce <= 0 {
+		return fmt.Errorf("watch_config_debounce 必须大于 0")
+	}
+	switch cfg.CredentialReuseCheck {
+	case "", "off", "warn", "error":
+	default:
+		return fmt.Errorf("credential_reuse_check 只能是 off、warn 或 error: %s", cfg.CredentialReuseCheck)
+	}
+	if err := validateRemoteConfig(cfg.ConfigURL, &cfg.RemoteConfig); err != nil {
+		return err
+	}
+	for name := range cfg.GlobalLabels {
+		if !labelName.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("global_labels 中的 label 名称不合法: %s", name)
+		}
+	}
+	if err := validateFeatures(cfg.Features); err != nil {
+		return err
+	}
+	if err := validateRemoteWrite(&cfg.RemoteWrite); err != nil {
+		return err
+	}
+	if cfg.Changefeed.Path != "" {
+		if cfg.Changefeed.MaxSizeMB <= 0 {
+			return fmt.Errorf("changefeed.max_size_mb 必须大于 0")
+		}
+		if cfg.Changefeed.MaxBackups < 0 {
+			return fmt.Errorf("changefeed.max_backups 不能为负数")
+		}
+	}
+	if err := validateLocalStorage(&cfg.LocalStorage); err != nil {
+		return err
+	}
+	if cfg.TestFire.Token != "" && cfg.TestFire.MaxDuration <= 0 {
+		return fmt.Errorf("test_fire.max_duration 必须大于 0")
+	}
 	// 超时时间不应该超过探测间隔，避免连接被占用影响下一次探测
 	// 允许 timeout 等于 interval（100%），但超过则报错
 	if cfg.ProbeTimeout > cfg.ProbeInterval {
@@ -110,56 +823,888 @@ func Validate(cfg *Config) error {
 		}
 	}
 
-	if len(cfg.Databases) == 0 {
+	// 校验自定义错误分类规则
+	for i, rule := range cfg.ErrorRules {
+		if rule.Pattern == "" {
+			return fmt.Errorf("error_rules[%d].pattern 不能为空", i)
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("error_rules[%d].pattern 不是合法的正则表达式: %w", i, err)
+		}
+		if rule.Stage == "" {
+			return fmt.Errorf("error_rules[%d].stage 不能为空", i)
+		}
+	}
+
+	if err := validateNotify(&cfg.Notify); err != nil {
+		return err
+	}
+	if err := validateSQLDiscovery(&cfg.Discovery.SQL); err != nil {
+		return err
+	}
+	if err := validateKVDiscovery(&cfg.Discovery.KV); err != nil {
+		return err
+	}
+	if err := validateSecrets(&cfg.Secrets); err != nil {
+		return err
+	}
+
+	// 启用目标发现时 databases 可以为空，所有目标都来自清单
+	if len(cfg.Databases) == 0 && cfg.Discovery.SQL.DSN == "" && cfg.Discovery.KV.Backend == "" {
 		return fmt.Errorf("配置项 databases 不能为空")
 	}
 
 	// 检查数据库名称唯一性
 	nameMap := make(map[string]bool)
-	for i, db := range cfg.Databases {
+	configuredIDs := make([]bool, len(cfg.Databases))
+	for i := range cfg.Databases {
+		db := &cfg.Databases[i]
+		field := cfg.databaseField(i)
 		if db.Name == "" {
-			return fmt.Errorf("databases[%d].name 不能为空", i)
+			return fmt.Errorf("%s.name 不能为空", field)
 		}
 		if nameMap[db.Name] {
-			return fmt.Errorf("数据库名称重复: %s", db.Name)
+			return fmt.Errorf("数据库名称重复: %s（%s）", db.Name, field)
 		}
 		nameMap[db.Name] = true
+		configuredIDs[i] = db.ID != ""
 
-		// 校验项目和环境
-		if db.Project == "" {
-			return fmt.Errorf("databases[%d].project 不能为空", i)
+		// 校验时补全的默认值（如 snowflake 的 host、port）需要保留在配置中
+		if err := ValidateDatabase(field, db); err != nil {
+			return err
+		}
+		if err := validateTargetTiming(field, cfg, db); err != nil {
+			return err
 		}
-		if db.Env == "" {
-			return fmt.Errorf("databases[%d].env 不能为空", i)
+		if db.ScrapeOnly && !cfg.ProbeEndpoint {
+			return fmt.Errorf("%s.scrape_only 需要开启 probe_endpoint", field)
 		}
+	}
+	if err := resolveTargetIDs(cfg, configuredIDs); err != nil {
+		return err
+	}
+
+	return checkCredentialReuse(cfg)
+}
+
+// validateManagementSocket 校验 management_socket 的文件权限，management_socket_only 需要配置 management_socket
+func validateManagementSocket(cfg *Config) error {
+	if cfg.ManagementSocketOnly && cfg.ManagementSocket == "" {
+		return fmt.Errorf("management_socket_only 需要同时配置 management_socket")
+	}
+	if cfg.ManagementSocket == "" {
+		return nil
+	}
+	if _, err := ParseFileMode(cfg.ManagementSocketMode); err != nil {
+		return fmt.Errorf("management_socket_mode %w", err)
+	}
+	return nil
+}
+
+// validateListeners 校验 HTTP 端口和管理端口的 TLS、认证配置
+func validateListeners(cfg *Config) error {
+	if err := validateListenerTLS("listen_tls", &cfg.ListenTLS); err != nil {
+		return err
+	}
+	if err := validateListenerAuth("listen_auth", &cfg.ListenAuth); err != nil {
+		return err
+	}
+	m := &cfg.Management
+	if m.ListenAddress == "" {
+		if m.TLS != (ListenerTLSConfig{}) || m.Auth != (ListenerAuthConfig{}) {
+			return fmt.Errorf("management.tls、management.auth 需要同时配置 management.listen_address")
+		}
+		return nil
+	}
+	if m.ListenAddress == cfg.ListenAddress {
+		return fmt.Errorf("management.listen_address 不能与 listen_address 相同: %s", m.ListenAddress)
+	}
+	if err := validateListenerTLS("management.tls", &m.TLS); err != nil {
+		return err
+	}
+	return validateListenerAuth("management.auth", &m.Auth)
+}
+
+func validateListenerTLS(field string, t *ListenerTLSConfig) error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("%s.cert_file 和 key_file 需要同时配置", field)
+	}
+	if t.ClientCAFile != "" && t.CertFile == "" {
+		return fmt.Errorf("%s.client_ca_file 需要同时配置 cert_file、key_file", field)
+	}
+	return nil
+}
 
-		// 校验数据库类型
-		validTypes := map[string]bool{
-			"mysql":  true,
-			"tidb":   true,
-			"oracle": true,
+func validateListenerAuth(field string, a *ListenerAuthConfig) error {
+	if a.BasicAuth.Username != "" && a.BearerToken != "" {
+		return fmt.Errorf("%s.basic_auth 和 %s.bearer_token 只能配置一个", field, field)
+	}
+	if (a.BasicAuth.Username == "") != (a.BasicAuth.Password == "") {
+		return fmt.Errorf("%s.basic_auth 的 username 和 password 需要同时配置", field)
+	}
+	return nil
+}
+
+// ParseFileMode 解析八进制的文件权限（如 0660），为空时返回 0660
+func ParseFileMode(s string) (os.FileMode, error) {
+	if s == "" {
+		return 0o660, nil
+	}
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode == 0 || mode > 0o777 {
+		return 0, fmt.Errorf("不是合法的文件权限（如 0660）: %s", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+// validateTargetTiming 校验目标覆盖的 probe_interval、probe_timeout：与全局值合并后超时不能超过探测间隔，
+// keepalive_interval 需要小于探测间隔（否则两轮探测之间不会发送），全局 probe_jitter 不能超过探测间隔的一半
+func validateTargetTiming(field string, cfg *Config, db *DBConfig) error {
+	if db.ProbeInterval == 0 && db.ProbeTimeout == 0 && db.KeepaliveInterval == 0 {
+		return nil
+	}
+	interval, timeout := cfg.ProbeInterval, cfg.ProbeTimeout
+	if db.ProbeInterval > 0 {
+		interval = db.ProbeInterval
+	}
+	if db.ProbeTimeout > 0 {
+		timeout = db.ProbeTimeout
+	}
+	if timeout > interval {
+		return fmt.Errorf("%s 的 probe_timeout (%v) 不应超过 probe_interval (%v)", field, timeout, interval)
+	}
+	if db.KeepaliveInterval >= interval {
+		return fmt.Errorf("%s 的 keepalive_interval (%v) 需要小于 probe_interval (%v)", field, db.KeepaliveInterval, interval)
+	}
+	if cfg.ProbeJitter > interval/2 {
+		return fmt.Errorf("probe_jitter (%v) 不能超过 %s 的 probe_interval (%v) 的一半", cfg.ProbeJitter, field, interval)
+	}
+	return nil
+}
+
+// keepaliveMaxInterval keepalive_interval 的上限：连接池关闭空闲超过 2 分钟的连接，间隔更长时保活的连接已经被关闭
+const keepaliveMaxInterval = 2 * time.Minute
+
+// validateKeepalive 校验 keepalive_interval、always_reconnect
+// tcp 每轮探测都新建连接，cassandra 驱动自带心跳，mongodb、elasticsearch、trino 的连接由客户端库管理，都不适用
+func validateKeepalive(field string, db *DBConfig) error {
+	if db.KeepaliveInterval == 0 && !db.AlwaysReconnect {
+		return nil
+	}
+	if db.KeepaliveInterval < 0 {
+		return fmt.Errorf("%s.keepalive_interval 不能为负数", field)
+	}
+	if db.KeepaliveInterval >= keepaliveMaxInterval {
+		return fmt.Errorf("%s.keepalive_interval (%v) 需要小于连接池的空闲连接超时 %v", field, db.KeepaliveInterval, keepaliveMaxInterval)
+	}
+	if db.KeepaliveInterval > 0 && db.ScrapeOnly {
+		return fmt.Errorf("%s.scrape_only 的目标不执行周期探测，不能配置 keepalive_interval", field)
+	}
+	if db.KeepaliveInterval > 0 && db.AlwaysReconnect {
+		return fmt.Errorf("%s.keepalive_interval 与 always_reconnect 不能同时配置", field)
+	}
+	switch db.Type {
+	case "tcp", "mongodb", "cassandra", "elasticsearch", "trino":
+		return fmt.Errorf("%s.keepalive_interval、always_reconnect 不适用于 %s 类型", field, db.Type)
+	}
+	if db.AlwaysReconnect {
+		// 关闭连接会影响共用连接池的其他目标
+		if db.ShareConnection != nil && *db.ShareConnection {
+			return fmt.Errorf("%s.always_reconnect 不能与 share_connection: true 同时配置", field)
 		}
-		if !validTypes[db.Type] {
-			return fmt.Errorf("databases[%d].type 必须是 mysql、tidb 或 oracle，当前值: %s", i, db.Type)
+		if len(db.Schemas) > 0 {
+			return fmt.Errorf("%s.always_reconnect 不能与 schemas 同时配置", field)
 		}
+	}
+	return nil
+}
+
+// databaseTypes 支持的数据库类型
+var databaseTypes = map[string]bool{
+	"mysql":           true,
+	"tidb":            true,
+	"mariadb-galera":  true,
+	"oceanbase":       true,
+	"doris":           true,
+	"oracle":          true,
+	"dm":              true,
+	"kingbase":        true,
+	"db2":             true,
+	"mssql":           true,
+	"cockroachdb":     true,
+	"snowflake":       true,
+	"aurora-mysql":    true,
+	"aurora-postgres": true,
+	"sqlite":          true,
+	"redis":           true,
+	"mongodb":         true,
+	"cassandra":       true,
+	"elasticsearch":   true,
+	"trino":           true,
+	"tcp":             true,
+}
+
+// ValidateDatabase 校验单个数据库目标的配置，field 为错误信息中的字段路径前缀（如 databases[0]）
+// 目标发现得到的目标同样使用该函数校验；未配置 id 时由地址推导稳定 ID 并写入 db.ID
+func ValidateDatabase(field string, db *DBConfig) error {
+	if err := validateDatabaseFields(field, db); err != nil {
+		return err
+	}
+	// 地址字段（如 snowflake 的 host）补全默认值之后再推导 ID
+	return validateTargetID(field, db)
+}
+
+// validateDatabaseFields 校验单个数据库目标的各个字段，并补全默认值
+func validateDatabaseFields(field string, db *DBConfig) error {
+	// 先读取凭据文件，后面的必填校验（如 password）使用文件中的值
+	if err := loadCredentialFiles(field, db); err != nil {
+		return err
+	}
+	if db.StatementBudget != nil && *db.StatementBudget < 0 {
+		return fmt.Errorf("%s.statement_budget 不能为负数", field)
+	}
+	if db.ProbeInterval < 0 || db.ProbeTimeout < 0 {
+		return fmt.Errorf("%s.probe_interval、probe_timeout 不能为负数", field)
+	}
+	if err := validateKeepalive(field, db); err != nil {
+		return err
+	}
+	if db.ClusterCheck && db.Type != "cockroachdb" && db.Type != "doris" {
+		return fmt.Errorf("%s.cluster_check 仅适用于 cockroachdb、doris 类型", field)
+	}
+	if db.RoleDetection && db.Type != "mysql" && db.Type != "oracle" && db.Type != "dm" && db.Type != "mongodb" {
+		return fmt.Errorf("%s.role_detection 仅适用于 mysql、oracle、dm、mongodb 类型", field)
+	}
+	if db.MaxAddresses < 0 {
+		return fmt.Errorf("%s.max_addresses 不能为负数", field)
+	}
+	if db.DNSRefreshInterval < 0 {
+		return fmt.Errorf("%s.dns_refresh_interval 不能为负数", field)
+	}
+	// probe_all_addresses 展开的目标各自探测一个固定的地址
+	if db.ProbeAllAddresses && (db.DNSRefreshInterval > 0 || db.DNSRefreshOnFailure) {
+		return fmt.Errorf("%s.dns_refresh_interval、dns_refresh_on_failure 不能与 probe_all_addresses 同时配置", field)
+	}
+	// 配置文件中的 hosts 在加载时已经展开，目标发现得到的目标不支持
+	if len(db.Hosts) > 0 {
+		return fmt.Errorf("%s.hosts 只能在配置文件中使用", field)
+	}
+	// 引用的模块在加载时已经合并，仍有 module 说明没有配置 modules 或为目标发现得到的目标
+	if db.Module != "" {
+		return fmt.Errorf("%s.module: 探测模块 %s 不存在（modules 需要在主配置文件中定义，目标发现得到的目标不支持 module）", field, db.Module)
+	}
+	if err := validateSecretRef(field, db); err != nil {
+		return err
+	}
+	if err := validateCredentialRefs(field, db); err != nil {
+		return err
+	}
+	if err := validateCheckPools(field, db); err != nil {
+		return err
+	}
+	// dsn 中的地址由驱动解析，无法替换为各个解析出的地址
+	if db.ProbeAllAddresses && db.DSN != "" {
+		return fmt.Errorf("%s.probe_all_addresses 不适用于配置了 dsn 的目标", field)
+	}
+	if err := validateMySQLOptions(field, db); err != nil {
+		return err
+	}
+	if err := validatePeerCheck(field, db); err != nil {
+		return err
+	}
+	if err := validateSchemas(field, db); err != nil {
+		return err
+	}
+	if db.StatusPort != 0 {
+		if db.Type != "tidb" {
+			return fmt.Errorf("%s.status_port 仅适用于 tidb 类型", field)
+		}
+		if db.StatusPort < 0 || db.StatusPort > 65535 {
+			return fmt.Errorf("%s.status_port 不是合法的端口，当前值: %d", field, db.StatusPort)
+		}
+		// 状态端口与 host 组合，dsn 中的地址无法取出
+		if db.DSN != "" {
+			return fmt.Errorf("%s.status_port 不适用于配置了 dsn 的目标", field)
+		}
+	}
+	if db.Database != "" && db.Type != "kingbase" && db.Type != "db2" && db.Type != "aurora-postgres" {
+		return fmt.Errorf("%s.database 仅适用于 kingbase、db2、aurora-postgres 类型", field)
+	}
+	if err := validateAurora(field, db); err != nil {
+		return err
+	}
+	if db.Path != "" && db.Type != "sqlite" {
+		return fmt.Errorf("%s.path 仅适用于 sqlite 类型", field)
+	}
+	// DB2 DSN 使用分号分隔的 key=value，数据库名中出现分号会拼出错误的连接串
+	if db.Type == "db2" && strings.Contains(db.Database, ";") {
+		return fmt.Errorf("%s.database 不能包含分号，当前值: %s", field, db.Database)
+	}
+	if (len(db.ContactPoints) > 0 || db.Datacenter != "") && db.Type != "cassandra" {
+		return fmt.Errorf("%s.contact_points、datacenter 仅适用于 cassandra 类型", field)
+	}
+	// 按地址探测时每个目标只连接一个地址，其他 contact points 会让会话连到别的节点
+	if db.ProbeAllAddresses && len(db.ContactPoints) > 0 {
+		return fmt.Errorf("%s.probe_all_addresses 不能与 contact_points 同时配置", field)
+	}
+	if (db.Account != "" || db.Warehouse != "" || db.Role != "" || db.PrivateKeyFile != "") && db.Type != "snowflake" {
+		return fmt.Errorf("%s.account、warehouse、role、private_key_file 仅适用于 snowflake 类型（role label 请配置在 labels 中）", field)
+	}
+	if db.APIKey != "" && db.Type != "elasticsearch" {
+		return fmt.Errorf("%s.api_key 仅适用于 elasticsearch 类型", field)
+	}
+	if (db.Tenant != "" || db.Cluster != "") && db.Type != "oceanbase" {
+		return fmt.Errorf("%s.tenant、cluster 仅适用于 oceanbase 类型", field)
+	}
+	if db.Cluster != "" && db.Tenant == "" {
+		return fmt.Errorf("%s.配置 cluster 时 tenant 不能为空", field)
+	}
+	// 租户信息由 tenant、cluster 拼接，user 中不能再带 @、#，避免拼出歧义的用户名
+	if db.Tenant != "" && strings.ContainsAny(db.User, "@#") {
+		return fmt.Errorf("%s.user 已配置 tenant 时不能包含 @ 或 #，当前值: %s", field, db.User)
+	}
+	for _, opt := range []struct{ key, value string }{
+		{"container", db.Container},
+		{"default_schema", db.DefaultSchema},
+	} {
+		if opt.value == "" {
+			continue
+		}
+		if db.Type != "oracle" {
+			return fmt.Errorf("%s.%s 仅适用于 oracle 类型", field, opt.key)
+		}
+		// 值会直接拼接到 ALTER SESSION 语句中，只允许不带引号的 Oracle 标识符
+		if !oracleIdentifier.MatchString(opt.value) {
+			return fmt.Errorf("%s.%s 不是合法的 Oracle 标识符: %s", field, opt.key, opt.value)
+		}
+	}
+
+	if db.ServerType != "" {
+		if db.Type != "oracle" {
+			return fmt.Errorf("%s.server_type 仅适用于 oracle 类型", field)
+		}
+		if db.DSN != "" {
+			return fmt.Errorf("%s.server_type 不能与 dsn 同时配置，请在 dsn 的连接描述符中指定 SERVER", field)
+		}
+		switch db.ServerType {
+		case "dedicated", "shared", "pooled":
+		default:
+			return fmt.Errorf("%s.server_type 必须是 dedicated、shared 或 pooled，当前值: %s", field, db.ServerType)
+		}
+		// 服务名会拼接到连接描述符中
+		if db.ServiceName != "" && !oracleServiceName.MatchString(db.ServiceName) {
+			return fmt.Errorf("%s.service_name 只能包含字母、数字、下划线、点和连字符，当前值: %s", field, db.ServiceName)
+		}
+	}
+
+	// 校验项目和环境
+	if db.Project == "" {
+		return fmt.Errorf("%s.project 不能为空", field)
+	}
+	if db.Env == "" {
+		return fmt.Errorf("%s.env 不能为空", field)
+	}
+
+	// 校验数据库类型
+	if !databaseTypes[db.Type] {
+		return fmt.Errorf("%s.type 必须是 mysql、tidb、mariadb-galera、oceanbase、doris、oracle、dm、kingbase、db2、mssql、cockroachdb、snowflake、aurora-mysql、aurora-postgres、sqlite、redis、mongodb、cassandra、elasticsearch、trino 或 tcp，当前值: %s", field, db.Type)
+	}
+
+	// Snowflake 通过 HTTPS 访问账号对应的地址，未配置 host、port 时按账号标识补全
+	if db.Type == "snowflake" && db.DSN == "" {
+		if db.Account == "" {
+			return fmt.Errorf("%s.account 不能为空（当 dsn 未提供时，snowflake 类型需要指定账号标识）", field)
+		}
+		if db.Host == "" {
+			db.Host = db.Account + ".snowflakecomputing.com"
+		}
+		if db.Port == 0 {
+			db.Port = 443
+		}
+		if db.Password != "" && db.PrivateKeyFile != "" {
+			return fmt.Errorf("%s.password 和 private_key_file 只能配置其一", field)
+		}
+	}
 
-		// 如果 DSN 为空，则必须提供 host、port、user、password
-		if db.DSN == "" {
+	// SQLite 类型打开本地数据库文件，只需要 path，没有网络地址和账号
+	if db.Type == "sqlite" {
+		if db.Path == "" {
+			return fmt.Errorf("%s.path 不能为空（sqlite 类型需要指定数据库文件路径）", field)
+		}
+		if db.DSN != "" || db.Host != "" || db.Port != 0 || db.User != "" || db.Password != "" || db.SecretRef != nil {
+			return fmt.Errorf("%s.dsn、host、port、user、password、secret_ref 不适用于 sqlite 类型，请使用 path", field)
+		}
+		if db.ProbeAllAddresses {
+			return fmt.Errorf("%s.probe_all_addresses 不适用于 sqlite 类型", field)
+		}
+		for j, stmt := range db.SessionInit {
+			if strings.TrimSpace(stmt) == "" {
+				return fmt.Errorf("%s.session_init[%d] 不能为空", field, j)
+			}
+		}
+		return nil
+	}
+
+	// TCP、Redis 类型只需要 host、port，账号密码可选（Redis 未开启认证时不需要）
+	if db.Type == "tcp" || db.Type == "redis" {
+		if db.Host == "" {
+			return fmt.Errorf("%s.host 不能为空", field)
+		}
+		if db.Port == 0 {
+			return fmt.Errorf("%s.port 不能为空", field)
+		}
+		if db.Banner != "" {
+			if _, err := regexp.Compile(db.Banner); err != nil {
+				return fmt.Errorf("%s.banner 不是合法的正则表达式: %w", field, err)
+			}
+		}
+		if len(db.SessionInit) > 0 {
+			return fmt.Errorf("%s.session_init 不适用于 %s 类型", field, db.Type)
+		}
+		return nil
+	}
+
+	// MongoDB 类型使用 dsn（可以是副本集 URI）或 host、port，账号密码可选
+	if db.Type == "mongodb" {
+		if db.DSN == "" && !dsnFromSecret(db) {
+			if db.Host == "" {
+				return fmt.Errorf("%s.host 不能为空（当 dsn 未提供时）", field)
+			}
+			if db.Port == 0 {
+				return fmt.Errorf("%s.port 不能为空（当 dsn 未提供时）", field)
+			}
+		}
+		if strings.ContainsAny(strings.TrimSpace(db.Query), " \t") {
+			return fmt.Errorf("%s.query 对 mongodb 类型应为单个命令名（如 ping），当前值: %s", field, db.Query)
+		}
+		if len(db.SessionInit) > 0 {
+			return fmt.Errorf("%s.session_init 不适用于 %s 类型", field, db.Type)
+		}
+		return nil
+	}
+
+	// Elasticsearch 类型使用 dsn（集群地址）或 host、port，认证可选：user/password 或 api_key
+	if db.Type == "elasticsearch" {
+		if db.DSN != "" {
+			u, err := url.Parse(db.DSN)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("%s.dsn 对 elasticsearch 类型应为 http 或 https 地址，当前值: %s", field, db.DSN)
+			}
+		} else {
 			if db.Host == "" {
-				return fmt.Errorf("databases[%d].host 不能为空（当 dsn 未提供时）", i)
+				return fmt.Errorf("%s.host 不能为空（当 dsn 未提供时）", field)
 			}
 			if db.Port == 0 {
-				return fmt.Errorf("databases[%d].port 不能为空（当 dsn 未提供时）", i)
+				return fmt.Errorf("%s.port 不能为空（当 dsn 未提供时）", field)
 			}
-			if db.User == "" {
-				return fmt.Errorf("databases[%d].user 不能为空（当 dsn 未提供时）", i)
+		}
+		if db.APIKey != "" && db.User != "" {
+			return fmt.Errorf("%s.api_key 和 user 只能配置一个", field)
+		}
+		if len(db.SessionInit) > 0 {
+			return fmt.Errorf("%s.session_init 不适用于 %s 类型", field, db.Type)
+		}
+		return nil
+	}
+
+	// Trino/Presto 类型使用 dsn（coordinator 地址）或 host、port，user 必填（Trino 要求每个查询带用户名），password 可选
+	if db.Type == "trino" {
+		if db.DSN != "" {
+			u, err := url.Parse(db.DSN)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("%s.dsn 对 trino 类型应为 http 或 https 地址，当前值: %s", field, db.DSN)
+			}
+		} else {
+			if db.Host == "" {
+				return fmt.Errorf("%s.host 不能为空（当 dsn 未提供时）", field)
 			}
-			if db.Password == "" {
-				return fmt.Errorf("databases[%d].password 不能为空（当 dsn 未提供时）", i)
+			if db.Port == 0 {
+				return fmt.Errorf("%s.port 不能为空（当 dsn 未提供时）", field)
 			}
 		}
+		if db.User == "" && !userFromSecret(db) {
+			return fmt.Errorf("%s.user 不能为空", field)
+		}
+		if len(db.SessionInit) > 0 {
+			return fmt.Errorf("%s.session_init 不适用于 %s 类型", field, db.Type)
+		}
+		return nil
+	}
+
+	// Cassandra 类型使用 host、port 及可选的 contact_points，账号密码可选（未开启认证时不需要）
+	if db.Type == "cassandra" {
+		if db.DSN != "" {
+			return fmt.Errorf("%s.dsn 不适用于 cassandra 类型，请使用 host、port、contact_points", field)
+		}
+		if db.Host == "" {
+			return fmt.Errorf("%s.host 不能为空", field)
+		}
+		if db.Port == 0 {
+			return fmt.Errorf("%s.port 不能为空", field)
+		}
+		for j, cp := range db.ContactPoints {
+			if strings.TrimSpace(cp) == "" {
+				return fmt.Errorf("%s.contact_points[%d] 不能为空", field, j)
+			}
+		}
+		if len(db.SessionInit) > 0 {
+			return fmt.Errorf("%s.session_init 不适用于 %s 类型", field, db.Type)
+		}
+		return nil
+	}
+
+	for j, stmt := range db.SessionInit {
+		if strings.TrimSpace(stmt) == "" {
+			return fmt.Errorf("%s.session_init[%d] 不能为空", field, j)
+		}
+	}
+
+	// 如果 DSN 为空，则必须提供 host、port、user、password（cockroachdb、doris 的 password 可选，snowflake 可以用 private_key_file 代替）
+	// 配置了 secret_ref、*_ref 的目标在读取到凭据并填入后再完整校验一次，此处不要求由 Secret 提供的字段
+	if db.DSN == "" && !dsnFromSecret(db) {
+		if db.Host == "" {
+			return fmt.Errorf("%s.host 不能为空（当 dsn 未提供时）", field)
+		}
+		if db.Port == 0 {
+			return fmt.Errorf("%s.port 不能为空（当 dsn 未提供时）", field)
+		}
+		if db.User == "" && !userFromSecret(db) {
+			return fmt.Errorf("%s.user 不能为空（当 dsn 未提供时）", field)
+		}
+		if db.Type == "db2" && db.Database == "" {
+			return fmt.Errorf("%s.database 不能为空（当 dsn 未提供时，db2 类型需要指定数据库名）", field)
+		}
+		// CockroachDB 以 --insecure 启动时不校验密码；Doris/StarRocks 的账号（包括 root）默认没有密码，允许为空；
+		// Snowflake 使用 key-pair 认证时不需要密码
+		if db.Password == "" && db.Type != "cockroachdb" && db.Type != "doris" && !(db.Type == "snowflake" && db.PrivateKeyFile != "") &&
+			!passwordFromSecret(db) {
+			return fmt.Errorf("%s.password 不能为空（当 dsn 未提供时）", field)
+		}
+	}
+	return nil
+}
+
+// labelName 合法的 Prometheus label 名称
+var labelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// metricName Prometheus 指标名
+var metricName = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// validateLocalStorage 校验本地时序存储配置，未配置 path 时不启用，不做校验
+func validateLocalStorage(ls *LocalStorageConfig) error {
+	if ls.Path == "" {
+		return nil
+	}
+	// 文件按天切分，保留时长不足一天时当天的文件无法删除
+	if ls.Retention < 24*time.Hour {
+		return fmt.Errorf("local_storage.retention 不能小于 24h，当前值: %v", ls.Retention)
+	}
+	if ls.Interval < time.Second {
+		return fmt.Errorf("local_storage.interval 不能小于 1s，当前值: %v", ls.Interval)
+	}
+	if len(ls.Metrics) == 0 {
+		return fmt.Errorf("local_storage.metrics 不能为空")
+	}
+	for i, name := range ls.Metrics {
+		if !metricName.MatchString(name) {
+			return fmt.Errorf("local_storage.metrics[%d] 不是合法的指标名: %s", i, name)
+		}
+	}
+	return nil
+}
+
+// validateRemoteWrite 校验 remote write 配置，未配置 url 时不启用，不做校验
+func validateRemoteWrite(rw *RemoteWriteConfig) error {
+	if rw.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(rw.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("remote_write.url 必须是 http 或 https 地址，当前值: %s", rw.URL)
+	}
+	if rw.Interval <= 0 {
+		return fmt.Errorf("remote_write.interval 必须大于 0")
+	}
+	if rw.Timeout <= 0 {
+		return fmt.Errorf("remote_write.timeout 必须大于 0")
+	}
+	if rw.MaxPendingBatches <= 0 {
+		return fmt.Errorf("remote_write.max_pending_batches 必须大于 0")
+	}
+	if rw.BasicAuth.Username != "" && rw.BearerToken != "" {
+		return fmt.Errorf("remote_write.basic_auth 和 remote_write.bearer_token 只能配置一个")
+	}
+	for name := range rw.ExternalLabels {
+		if !labelName.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("remote_write.external_labels 中的 label 名称不合法: %s", name)
+		}
+	}
+	return nil
+}
+
+// validateRemoteConfig 校验远端配置的拉取配置，未配置 config_url 时不启用，不做校验
+func validateRemoteConfig(configURL string, rc *RemoteConfigConfig) error {
+	if configURL == "" {
+		return nil
+	}
+	u, err := url.Parse(configURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("config_url 必须是 http 或 https 地址，当前值: %s", configURL)
+	}
+	if rc.Interval <= 0 {
+		return fmt.Errorf("remote_config.interval 必须大于 0")
+	}
+	if rc.Timeout <= 0 {
+		return fmt.Errorf("remote_config.timeout 必须大于 0")
+	}
+	if rc.BasicAuth.Username != "" && rc.BearerToken != "" {
+		return fmt.Errorf("remote_config.basic_auth 和 remote_config.bearer_token 只能配置一个")
+	}
+	return nil
+}
+
+// notifyChannelName 通知渠道名称，同时用作队列子目录名
+var notifyChannelName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// validateNotify 校验通知配置，未配置 webhooks 时不启用，不做校验
+func validateNotify(n *NotifyConfig) error {
+	if len(n.Webhooks) == 0 {
+		return nil
+	}
+	if n.QueueDir == "" {
+		return fmt.Errorf("notify.queue_dir 不能为空")
+	}
+	if n.MaxAttempts <= 0 {
+		return fmt.Errorf("notify.max_attempts 必须大于 0")
+	}
+	if n.RetryInterval <= 0 {
+		return fmt.Errorf("notify.retry_interval 必须大于 0")
+	}
+	if n.Timeout <= 0 {
+		return fmt.Errorf("notify.timeout 必须大于 0")
+	}
+	names := make(map[string]bool, len(n.Webhooks))
+	for i, wh := range n.Webhooks {
+		if !notifyChannelName.MatchString(wh.Name) {
+			return fmt.Errorf("notify.webhooks[%d].name 只能包含字母、数字、_ 和 -，当前值: %q", i, wh.Name)
+		}
+		if names[wh.Name] {
+			return fmt.Errorf("notify.webhooks[%d].name 重复: %s", i, wh.Name)
+		}
+		names[wh.Name] = true
+		u, err := url.Parse(wh.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("notify.webhooks[%d].url 必须是 http 或 https 地址", i)
+		}
+		switch wh.Format {
+		case "", "json", "slack", "wecom", "dingtalk":
+		default:
+			return fmt.Errorf("notify.webhooks[%d].format 只能是 json、slack、wecom 或 dingtalk，当前值: %s", i, wh.Format)
+		}
+	}
+	return nil
+}
+
+// mysqlCharsetPattern/mysqlCollationPattern charset、collation 的合法取值（字符集名可用逗号分隔多个）
+var (
+	mysqlCharsetPattern   = regexp.MustCompile(`^[A-Za-z0-9_]+(,[A-Za-z0-9_]+)*$`)
+	mysqlCollationPattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+)
+
+// validateMySQLOptions 校验 MySQL 协议类型的 compress、charset、collation
+// 这些选项拼接到自动构造的 DSN 中，配置了 dsn 时应直接写在 dsn 里
+func validateMySQLOptions(field string, db *DBConfig) error {
+	if !db.Compress && db.Charset == "" && db.Collation == "" {
+		return nil
+	}
+	switch db.Type {
+	case "mysql", "tidb", "mariadb-galera", "oceanbase", "doris", "aurora-mysql":
+	default:
+		return fmt.Errorf("%s.compress、charset、collation 仅适用于 mysql、tidb、mariadb-galera、oceanbase、doris、aurora-mysql 类型", field)
+	}
+	if db.DSN != "" {
+		return fmt.Errorf("%s.compress、charset、collation 不能与 dsn 同时配置，请直接在 dsn 中指定", field)
+	}
+	if db.Charset != "" && !mysqlCharsetPattern.MatchString(db.Charset) {
+		return fmt.Errorf("%s.charset 不合法（如 utf8mb4，多个字符集用逗号分隔），当前值: %s", field, db.Charset)
+	}
+	if db.Collation != "" && !mysqlCollationPattern.MatchString(db.Collation) {
+		return fmt.Errorf("%s.collation 不合法（如 utf8mb4_general_ci），当前值: %s", field, db.Collation)
+	}
+	return nil
+}
+
+// validatePeerCheck 校验 peer_check：对端地址在驱动拨号时记录，目前只支持 MySQL 协议驱动
+// dsn 中的地址不经过记录对端地址的拨号函数；按地址探测时每个目标直接连接一个 IP，比较没有意义
+func validatePeerCheck(field string, db *DBConfig) error {
+	if !db.PeerCheck {
+		return nil
+	}
+	switch db.Type {
+	case "mysql", "tidb", "mariadb-galera", "oceanbase", "doris", "aurora-mysql":
+	default:
+		return fmt.Errorf("%s.peer_check 仅适用于 mysql、tidb、mariadb-galera、oceanbase、doris、aurora-mysql 类型", field)
+	}
+	if db.DSN != "" {
+		return fmt.Errorf("%s.peer_check 不适用于配置了 dsn 的目标", field)
+	}
+	if db.ProbeAllAddresses {
+		return fmt.Errorf("%s.peer_check 不能与 probe_all_addresses 同时配置", field)
+	}
+	return nil
+}
+
+// mysqlSchemaName schemas 中合法的库名，库名会拼接到 USE 语句中
+var mysqlSchemaName = regexp.MustCompile(`^[A-Za-z0-9_$-]{1,64}$`)
+
+// validateSchemas 校验 schemas：只支持 MySQL 协议类型，库名不能重复
+func validateSchemas(field string, db *DBConfig) error {
+	if len(db.Schemas) == 0 {
+		return nil
+	}
+	switch db.Type {
+	case "mysql", "tidb", "mariadb-galera", "oceanbase", "doris", "aurora-mysql":
+	default:
+		return fmt.Errorf("%s.schemas 仅适用于 mysql、tidb、mariadb-galera、oceanbase、doris、aurora-mysql 类型", field)
+	}
+	seen := make(map[string]bool, len(db.Schemas))
+	for j, schema := range db.Schemas {
+		if !mysqlSchemaName.MatchString(schema) {
+			return fmt.Errorf("%s.schemas[%d] 只能包含字母、数字、下划线、$ 和连字符，且不超过 64 个字符，当前值: %s", field, j, schema)
+		}
+		if seen[schema] {
+			return fmt.Errorf("%s.schemas[%d] 重复: %s", field, j, schema)
+		}
+		seen[schema] = true
+	}
+	return nil
+}
+
+// validateAurora 校验 Aurora 类型的 reader_host 和 role
+// Aurora 目标的 role label 由探测自动识别，故障切换后 writer、reader 会互换，不能在 labels 中固定
+func validateAurora(field string, db *DBConfig) error {
+	aurora := db.Type == "aurora-mysql" || db.Type == "aurora-postgres"
+	if db.ReaderHost != "" && !aurora {
+		return fmt.Errorf("%s.reader_host 仅适用于 aurora-mysql、aurora-postgres 类型", field)
+	}
+	if !aurora {
+		return nil
+	}
+	if _, ok := db.Labels["role"]; ok {
+		return fmt.Errorf("%s.labels.role 不适用于 %s 类型，角色由探测自动识别（writer/reader）", field, db.Type)
+	}
+	if db.ReaderHost != "" {
+		if db.DSN != "" {
+			return fmt.Errorf("%s.reader_host 不能与 dsn 同时配置", field)
+		}
+		if db.ReaderHost == db.Host {
+			return fmt.Errorf("%s.reader_host 不能与 host 相同", field)
+		}
+	}
+	return nil
+}
+
+// validateCheckPools 校验目标的 check_pools，只适用于 database/sql 类型的目标
+func validateCheckPools(field string, db *DBConfig) error {
+	if len(db.CheckPools) == 0 {
+		return nil
+	}
+	switch db.Type {
+	case "tcp", "redis", "mongodb", "cassandra", "elasticsearch", "trino":
+		return fmt.Errorf("%s.check_pools 不适用于 %s 类型", field, db.Type)
+	}
+	for name, pool := range db.CheckPools {
+		if !slices.Contains(CheckPoolNames, name) {
+			return fmt.Errorf("%s.check_pools 不支持检查 %s，可选值: %s", field, name, strings.Join(CheckPoolNames, ", "))
+		}
+		if pool.MaxOpenConns < 0 || pool.MaxIdleConns < 0 {
+			return fmt.Errorf("%s.check_pools.%s 的 max_open_conns、max_idle_conns 不能为负数", field, name)
+		}
 	}
+	return nil
+}
 
+// validateSecretRef 校验目标的 secret_ref，Secret 中的值与目标中直接配置的同一字段不能同时存在
+func validateSecretRef(field string, db *DBConfig) error {
+	ref := db.SecretRef
+	if ref == nil {
+		return nil
+	}
+	if db.Type == "tcp" {
+		return fmt.Errorf("%s.secret_ref 不适用于 tcp 类型", field)
+	}
+	if ref.Name == "" {
+		return fmt.Errorf("%s.secret_ref.name 不能为空", field)
+	}
+	if ref.Key == "" && ref.UserKey == "" && ref.DSNKey == "" {
+		return fmt.Errorf("%s.secret_ref 至少需要配置 key、user_key、dsn_key 其中之一", field)
+	}
+	if ref.Key != "" && db.Password != "" {
+		return fmt.Errorf("%s.password 和 secret_ref.key 只能配置其一", field)
+	}
+	if ref.UserKey != "" && db.User != "" {
+		return fmt.Errorf("%s.user 和 secret_ref.user_key 只能配置其一", field)
+	}
+	if ref.DSNKey != "" && db.DSN != "" {
+		return fmt.Errorf("%s.dsn 和 secret_ref.dsn_key 只能配置其一", field)
+	}
+	return nil
+}
+
+// validateSQLDiscovery 校验 SQL 清单目标发现配置，未配置 dsn 时不启用，不做校验
+// template 中的 name、type、host、port 由查询结果提供，其余字段在每一行生成目标后随目标一起校验
+func validateSQLDiscovery(sd *SQLDiscoveryConfig) error {
+	if sd.DSN == "" {
+		return nil
+	}
+	switch sd.Driver {
+	case "mysql", "postgres", "sqlserver", "oracle":
+	default:
+		return fmt.Errorf("discovery.sql.driver 只能是 mysql、postgres、sqlserver 或 oracle，当前值: %s", sd.Driver)
+	}
+	if strings.TrimSpace(sd.Query) == "" {
+		return fmt.Errorf("discovery.sql.query 不能为空")
+	}
+	if sd.Interval <= 0 {
+		return fmt.Errorf("discovery.sql.interval 必须大于 0")
+	}
+	if sd.Timeout <= 0 {
+		return fmt.Errorf("discovery.sql.timeout 必须大于 0")
+	}
+	if sd.Template.Name != "" || sd.Template.Type != "" || sd.Template.Host != "" || sd.Template.Port != 0 {
+		return fmt.Errorf("discovery.sql.template 不能配置 name、type、host、port，这些字段由查询结果提供")
+	}
+	return nil
+}
+
+// validateKVDiscovery 校验 etcd、Consul KV 目标发现配置，未配置 backend 时不启用，不做校验
+// 每个键生成的目标在同步时按 databases 的规则校验
+func validateKVDiscovery(kd *KVDiscoveryConfig) error {
+	if kd.Backend == "" {
+		return nil
+	}
+	if kd.Backend != "etcd" && kd.Backend != "consul" {
+		return fmt.Errorf("discovery.kv.backend 只能是 etcd 或 consul，当前值: %s", kd.Backend)
+	}
+	u, err := url.Parse(kd.Address)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("discovery.kv.address 必须是 http:// 或 https:// 地址，当前值: %s", kd.Address)
+	}
+	if strings.Trim(kd.Prefix, "/") == "" {
+		return fmt.Errorf("discovery.kv.prefix 不能为空")
+	}
+	if kd.Token != "" && kd.Backend != "consul" {
+		return fmt.Errorf("discovery.kv.token 仅适用于 consul")
+	}
+	if (kd.Username != "" || kd.Password != "") && kd.Backend != "etcd" {
+		return fmt.Errorf("discovery.kv.username、password 仅适用于 etcd（consul 请使用 token）")
+	}
+	if kd.Password != "" && kd.Username == "" {
+		return fmt.Errorf("discovery.kv.配置 password 时 username 不能为空")
+	}
+	if kd.Template.Name != "" {
+		return fmt.Errorf("discovery.kv.template 不能配置 name，目标名称取自值中的 name 或键名")
+	}
 	return nil
 }
 
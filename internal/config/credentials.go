@@ -0,0 +1,99 @@
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+// credentialKey 计算凭据指纹的密钥，进程启动时随机生成
+// 指纹只用于在同一进程内比较凭据是否相同，不能用于离线猜测密码；重启后指纹会变化
+var credentialKey = func() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}()
+
+// CredentialFingerprint 返回目标凭据的指纹（HMAC-SHA256 的前 12 个十六进制字符），没有可比较的凭据时返回空字符串
+// 凭据为 user + password，Elasticsearch 使用 api_key 时为 api_key；只配置了 dsn 或没有密码的目标不计算
+func CredentialFingerprint(db *DBConfig) string {
+	var material string
+	switch {
+	case db.Password != "":
+		material = "password\x00" + db.User + "\x00" + db.Password
+	case db.APIKey != "":
+		material = "api_key\x00" + db.APIKey
+	default:
+		return ""
+	}
+	mac := hmac.New(sha256.New, credentialKey)
+	mac.Write([]byte(material))
+	return hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
+// CredentialReuse 同一凭据被不同 env 的目标使用
+type CredentialReuse struct {
+	Fingerprint string   `json:"fingerprint"`
+	User        string   `json:"user,omitempty"`
+	Envs        []string `json:"envs"`
+	Targets     []string `json:"targets"`
+}
+
+// FindCredentialReuse 找出被不同 env 的目标使用的相同凭据，按指纹排序
+// 同一 env 内多个目标使用同一个监控账号是常见做法，不算复用
+func FindCredentialReuse(dbs []DBConfig) []CredentialReuse {
+	byFingerprint := make(map[string]*CredentialReuse)
+	for i := range dbs {
+		fingerprint := CredentialFingerprint(&dbs[i])
+		if fingerprint == "" {
+			continue
+		}
+		reuse := byFingerprint[fingerprint]
+		if reuse == nil {
+			reuse = &CredentialReuse{Fingerprint: fingerprint, User: dbs[i].User}
+			byFingerprint[fingerprint] = reuse
+		}
+		if !slices.Contains(reuse.Envs, dbs[i].Env) {
+			reuse.Envs = append(reuse.Envs, dbs[i].Env)
+		}
+		reuse.Targets = append(reuse.Targets, dbs[i].Name)
+	}
+
+	var result []CredentialReuse
+	for _, reuse := range byFingerprint {
+		if len(reuse.Envs) > 1 {
+			sort.Strings(reuse.Envs)
+			result = append(result, *reuse)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Fingerprint < result[j].Fingerprint })
+	return result
+}
+
+// checkCredentialReuse 按 credential_reuse_check 检查配置文件中的目标是否在不同 env 间复用凭据
+// warn 只输出 Warn 日志，error 拒绝启动；配置了 secret_ref 的目标此时还没有凭据，不参与检查
+func checkCredentialReuse(cfg *Config) error {
+	if cfg.CredentialReuseCheck == "" || cfg.CredentialReuseCheck == "off" {
+		return nil
+	}
+	for _, reuse := range FindCredentialReuse(cfg.Databases) {
+		if cfg.CredentialReuseCheck == "error" {
+			return fmt.Errorf("相同的凭据（user: %s）被不同环境 %s 的目标使用: %s，请确认没有把一个环境的凭据复制到另一个环境",
+				reuse.User, strings.Join(reuse.Envs, "、"), strings.Join(reuse.Targets, ", "))
+		}
+		logger.L().Warnw("相同的凭据被不同环境的目标使用，请确认没有把一个环境的凭据复制到另一个环境",
+			"user", reuse.User,
+			"fingerprint", reuse.Fingerprint,
+			"envs", reuse.Envs,
+			"targets", reuse.Targets,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// maxCredentialFileSize 凭据文件的大小上限，避免误配置成大文件时整个读入内存
+const maxCredentialFileSize = 64 << 10
+
+// loadCredentialFiles 读取 user_file、password_file，覆盖 user、password
+// 文件末尾的换行符（echo 或编辑器写入的）会被去掉，其余内容原样使用
+func loadCredentialFiles(field string, db *DBConfig) error {
+	if db.UserFile == "" && db.PasswordFile == "" {
+		return nil
+	}
+	if db.Type == "tcp" || db.Type == "sqlite" {
+		return fmt.Errorf("%s.user_file、password_file 不适用于 %s 类型", field, db.Type)
+	}
+	if ref := db.SecretRef; ref != nil {
+		if ref.Key != "" && db.PasswordFile != "" {
+			return fmt.Errorf("%s.password_file 和 secret_ref.key 只能配置其一", field)
+		}
+		if ref.UserKey != "" && db.UserFile != "" {
+			return fmt.Errorf("%s.user_file 和 secret_ref.user_key 只能配置其一", field)
+		}
+	}
+	if db.UserFile != "" {
+		user, err := readCredentialFile(db.UserFile)
+		if err != nil {
+			return fmt.Errorf("%s.user_file: %w", field, err)
+		}
+		db.User = user
+	}
+	if db.PasswordFile != "" {
+		password, err := readCredentialFile(db.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("%s.password_file: %w", field, err)
+		}
+		db.Password = password
+	}
+	return nil
+}
+
+// CredentialFiles 返回目标配置的 user_file、password_file
+func (db *DBConfig) CredentialFiles() []string {
+	var paths []string
+	for _, path := range []string{db.UserFile, db.PasswordFile} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// RefreshCredentialFiles 重新读取目标的 user_file、password_file，返回凭据是否变化；读取失败时 db 保持不变
+// 用于凭据文件变化（如 Kubernetes Secret 轮换）后只更新凭据，不重新加载配置文件
+func RefreshCredentialFiles(db *DBConfig) (bool, error) {
+	refreshed := *db
+	if err := loadCredentialFiles("databases."+db.Name, &refreshed); err != nil {
+		return false, err
+	}
+	changed := refreshed.User != db.User || refreshed.Password != db.Password
+	*db = refreshed
+	return changed, nil
+}
+
+// readCredentialFile 读取凭据文件，错误信息中只包含路径，不包含文件内容
+func readCredentialFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("读取凭据文件失败: %w", err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s 是目录，不是凭据文件", path)
+	}
+	if info.Size() > maxCredentialFileSize {
+		return "", fmt.Errorf("凭据文件 %s 过大（%d 字节）", path, info.Size())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("读取凭据文件失败: %w", err)
+	}
+	value := strings.TrimRight(string(data), "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("凭据文件 %s 为空", path)
+	}
+	return value, nil
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+// identityKeys defaults 中不能配置的字段，目标的名称、类型和 ID 只能写在各个目标中
+var identityKeys = []string{"name", "id", "type"}
+
+// applyDefaults 按 defaults 和 modules 重新解析 databases，两者都未配置时直接返回
+func applyDefaults(cfg *Config) error {
+	if len(cfg.Defaults) == 0 && len(cfg.Modules) == 0 {
+		return nil
+	}
+	if err := validateDefaults(cfg.Defaults); err != nil {
+		return err
+	}
+	if err := validateModules(cfg.Modules); err != nil {
+		return err
+	}
+	dbCfgs, err := decodeWithDefaults(viper.Get("databases"), cfg.Defaults, cfg.Modules)
+	if err != nil {
+		return fmt.Errorf("解析 databases 失败: %w", err)
+	}
+	cfg.Databases = dbCfgs
+	return nil
+}
+
+// validateDefaults 校验 defaults：不能配置目标的标识字段，types 下只能是支持的数据库类型
+func validateDefaults(defaults map[string]interface{}) error {
+	for _, key := range identityKeys {
+		if _, ok := defaults[key]; ok {
+			return fmt.Errorf("defaults 不能配置 %s，只能写在各个目标中", key)
+		}
+	}
+	raw, ok := defaults["types"]
+	if !ok {
+		return nil
+	}
+	types, ok := toSettings(raw)
+	if !ok {
+		return fmt.Errorf("defaults.types 必须是按数据库类型分组的配置（如 types.mysql.port）")
+	}
+	for typ, value := range types {
+		if !databaseTypes[typ] {
+			return fmt.Errorf("defaults.types.%s 不是支持的数据库类型", typ)
+		}
+		settings, ok := toSettings(value)
+		if !ok {
+			return fmt.Errorf("defaults.types.%s 必须是目标的配置项", typ)
+		}
+		for _, key := range identityKeys {
+			if _, ok := settings[key]; ok {
+				return fmt.Errorf("defaults.types.%s 不能配置 %s，只能写在各个目标中", typ, key)
+			}
+		}
+	}
+	return nil
+}
+
+// validateModules 校验 modules：每个模块是一组目标配置项，不能配置目标的标识字段，也不能再引用其他模块
+func validateModules(modules map[string]interface{}) error {
+	for name, value := range modules {
+		settings, ok := toSettings(value)
+		if !ok {
+			return fmt.Errorf("modules.%s 必须是目标的配置项", name)
+		}
+		for _, key := range identityKeys {
+			if _, ok := settings[key]; ok {
+				return fmt.Errorf("modules.%s 不能配置 %s，只能写在各个目标中", name, key)
+			}
+		}
+		if _, ok := settings["module"]; ok {
+			return fmt.Errorf("modules.%s 不能再引用其他模块", name)
+		}
+	}
+	return nil
+}
+
+// decodeWithDefaults 解析 databases 列表，每一项依次合并 defaults 顶层、defaults.types.<type>、引用的模块（modules.<module>）和目标自身的配置项，后者优先
+// labels、check_pools 等按 key 合并，其他配置项（包括列表）整体覆盖；合并后目标的 module 为空，引用不存在的模块时返回错误
+func decodeWithDefaults(raw interface{}, defaults, modules map[string]interface{}) ([]DBConfig, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("databases 必须是列表")
+	}
+	base := make(map[string]interface{}, len(defaults))
+	for key, value := range defaults {
+		if key != "types" {
+			base[key] = value
+		}
+	}
+	types, _ := toSettings(defaults["types"])
+
+	merged := make([]interface{}, len(items))
+	for i, item := range items {
+		entry, ok := toSettings(item)
+		if !ok {
+			return nil, fmt.Errorf("databases[%d] 必须是目标的配置项", i)
+		}
+		settings := mergeSettings(nil, base)
+		if typ, ok := entry["type"].(string); ok {
+			if typeDefaults, ok := toSettings(types[typ]); ok {
+				settings = mergeSettings(settings, typeDefaults)
+			}
+		}
+		// module 可以写在目标中，也可以写在 defaults 中（如 defaults.types.oracle.module），模块的配置项优先于 defaults
+		settings = mergeSettings(settings, entry)
+		if module, ok := settings["module"]; ok {
+			name := fmt.Sprint(module)
+			moduleSettings, ok := toSettings(modules[name])
+			if !ok {
+				return nil, fmt.Errorf("databases[%d] 引用的探测模块 %s 不存在", i, name)
+			}
+			settings = mergeSettings(mergeSettings(settings, moduleSettings), entry)
+			delete(settings, "module")
+		}
+		merged[i] = settings
+	}
+
+	v := viper.New()
+	v.Set("databases", merged)
+	var dbCfgs []DBConfig
+	if err := v.UnmarshalKey("databases", &dbCfgs); err != nil {
+		return nil, err
+	}
+	return dbCfgs, nil
+}
+
+// mergeSettings 返回 dst 与 src 合并后的副本，src 优先；两边都是 map 的配置项按 key 递归合并
+func mergeSettings(dst, src map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(dst)+len(src))
+	for key, value := range dst {
+		out[key] = value
+	}
+	for key, value := range src {
+		if srcMap, ok := toSettings(value); ok {
+			if dstMap, ok := toSettings(out[key]); ok {
+				out[key] = mergeSettings(dstMap, srcMap)
+				continue
+			}
+		}
+		out[key] = value
+	}
+	return out
+}
+
+// toSettings 把解析配置文件得到的 map 转换为 map[string]interface{}（YAML 的嵌套 map 可能是 map[interface{}]interface{}）
+func toSettings(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(m))
+		for key, value := range m {
+			out[fmt.Sprint(key)] = value
+		}
+		return out, true
+	}
+	return nil, false
+}
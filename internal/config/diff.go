@@ -0,0 +1,147 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// secretFields 差异中只标记为已修改、不输出内容的字段（可能包含密码）
+var secretFields = map[string]bool{
+	"password":      true,
+	"api_key":       true,
+	"dsn":           true,
+	"remote_write":  true, // 包含认证信息，整体只标记为已修改
+	"webhook":       true, // 包含 HMAC 密钥
+	"test_fire":     true, // 包含访问令牌
+	"control_token": true, // 运维操作接口的访问令牌
+	"chatops":       true, // 包含签名密钥
+	"notify":        true, // 机器人地址中包含 key/access_token，请求头可能包含认证信息
+	"discovery":     true, // 包含清单库连接串和目标模板中的密码
+	"listen_auth":   true, // 包含 HTTP 端口的认证信息
+	"management":    true, // 包含管理端口的认证信息
+
+	// 包含拉取远端配置（config_url）的认证信息
+	"remote_config": true,
+}
+
+// maskedValue 敏感字段在差异中的占位值
+const maskedValue = "***"
+
+// FieldChange 单个字段的变更，字段名使用配置文件中的 key
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// TargetChange 单个数据库目标的字段变更
+type TargetChange struct {
+	Name   string        `json:"name"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// TargetRename 改名的目标：新旧配置中 ID 相同、name 不同
+type TargetRename struct {
+	ID   string `json:"id"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Diff 两份配置之间的结构化差异，用于热加载时记录和审计配置变更
+// 目标按 name 匹配，改名的目标同时出现在 Added 和 Removed 中，并按 ID 列在 Renamed 中；敏感字段（password、dsn、remote_write、webhook、test_fire、chatops、notify、discovery）只标记为已修改
+type Diff struct {
+	Global  []FieldChange  `json:"global,omitempty"`  // 全局配置项变更
+	Added   []string       `json:"added,omitempty"`   // 新增的目标
+	Removed []string       `json:"removed,omitempty"` // 删除的目标
+	Changed []TargetChange `json:"changed,omitempty"` // 字段发生变化的目标
+	Renamed []TargetRename `json:"renamed,omitempty"` // 改名的目标
+}
+
+// Empty 两份配置是否没有任何差异
+func (d *Diff) Empty() bool {
+	return len(d.Global) == 0 && len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffConfigs 计算从 oldCfg 到 newCfg 的配置差异
+func DiffConfigs(oldCfg, newCfg *Config) *Diff {
+	d := &Diff{
+		// defaults 的变化体现在各目标的字段变更中，重新加载即可生效
+		Global: diffFields(reflect.ValueOf(*oldCfg), reflect.ValueOf(*newCfg), "databases", "defaults"),
+	}
+
+	oldTargets := make(map[string]*DBConfig, len(oldCfg.Databases))
+	for i := range oldCfg.Databases {
+		oldTargets[oldCfg.Databases[i].Name] = &oldCfg.Databases[i]
+	}
+	newTargets := make(map[string]bool, len(newCfg.Databases))
+	addedIDs := make(map[string]string)
+	for i := range newCfg.Databases {
+		newDB := &newCfg.Databases[i]
+		newTargets[newDB.Name] = true
+		oldDB, ok := oldTargets[newDB.Name]
+		if !ok {
+			d.Added = append(d.Added, newDB.Name)
+			addedIDs[newDB.ID] = newDB.Name
+			continue
+		}
+		if fields := diffFields(reflect.ValueOf(*oldDB), reflect.ValueOf(*newDB)); len(fields) > 0 {
+			d.Changed = append(d.Changed, TargetChange{Name: newDB.Name, Fields: fields})
+		}
+	}
+	for name, oldDB := range oldTargets {
+		if !newTargets[name] {
+			d.Removed = append(d.Removed, name)
+			if to, ok := addedIDs[oldDB.ID]; ok && oldDB.ID != "" {
+				d.Renamed = append(d.Renamed, TargetRename{ID: oldDB.ID, From: name, To: to})
+			}
+		}
+	}
+	sort.Strings(d.Removed)
+	sort.Slice(d.Renamed, func(i, j int) bool { return d.Renamed[i].From < d.Renamed[j].From })
+	return d
+}
+
+// diffFields 逐个比较结构体字段，skip 为不参与比较的字段（配置文件中的 key）
+func diffFields(oldVal, newVal reflect.Value, skip ...string) []FieldChange {
+	var changes []FieldChange
+	typ := oldVal.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		key := typ.Field(i).Tag.Get("mapstructure")
+		if key == "" || contains(skip, key) {
+			continue
+		}
+		oldField, newField := oldVal.Field(i).Interface(), newVal.Field(i).Interface()
+		if reflect.DeepEqual(oldField, newField) {
+			continue
+		}
+		change := FieldChange{Field: key, Old: formatValue(oldField), New: formatValue(newField)}
+		if secretFields[key] {
+			change.Old, change.New = maskedValue, maskedValue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// formatValue 格式化字段值，指针取值、nil 输出空字符串
+func formatValue(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return ""
+		}
+		return fmt.Sprint(rv.Elem().Interface())
+	}
+	return fmt.Sprint(v)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
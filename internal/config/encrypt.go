@@ -0,0 +1,218 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	// MasterKeyEnv 解密配置中 ENC(...) 值的主密钥（32 字节，base64 或 hex 编码）
+	MasterKeyEnv = "DB_PROBE_MASTER_KEY"
+	// MasterKeyFileEnv 保存主密钥的文件路径，未设置 DB_PROBE_MASTER_KEY 时读取
+	MasterKeyFileEnv = "DB_PROBE_MASTER_KEY_FILE"
+	// masterKeySize 主密钥长度（AES-256）
+	masterKeySize = 32
+)
+
+// encRefPattern 配置内容中是否可能有加密的值，用于跳过不需要解析的配置
+var encRefPattern = regexp.MustCompile(`ENC\(`)
+
+// encValuePattern 加密的值：整个值为 ENC(<base64(nonce + 密文)>)
+var encValuePattern = regexp.MustCompile(`^ENC\(([A-Za-z0-9+/]+={0,2})\)$`)
+
+// masterKey 按需读取的主密钥，配置中没有加密的值时不要求设置
+type masterKey struct {
+	key    []byte
+	err    error
+	loaded bool
+}
+
+// get 返回主密钥，同一次加载只读取一次
+func (k *masterKey) get() ([]byte, error) {
+	if !k.loaded {
+		k.key, k.err = LoadMasterKey("")
+		k.loaded = true
+	}
+	return k.key, k.err
+}
+
+// LoadMasterKey 读取主密钥：file 非空时读取该文件，否则依次读取环境变量 DB_PROBE_MASTER_KEY 和 DB_PROBE_MASTER_KEY_FILE 指向的文件
+func LoadMasterKey(file string) ([]byte, error) {
+	if file == "" {
+		if value, ok := os.LookupEnv(MasterKeyEnv); ok {
+			key, err := ParseMasterKey(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s 无效: %w", MasterKeyEnv, err)
+			}
+			return key, nil
+		}
+		file = os.Getenv(MasterKeyFileEnv)
+	}
+	if file == "" {
+		return nil, fmt.Errorf("未设置主密钥（环境变量 %s 或 %s）", MasterKeyEnv, MasterKeyFileEnv)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("读取主密钥文件失败: %w", err)
+	}
+	key, err := ParseMasterKey(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("主密钥文件 %s 无效: %w", file, err)
+	}
+	return key, nil
+}
+
+// ParseMasterKey 解析 base64 或 hex 编码的 32 字节主密钥，忽略首尾空白
+func ParseMasterKey(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if len(value) == hex.EncodedLen(masterKeySize) {
+		if key, err := hex.DecodeString(value); err == nil {
+			return key, nil
+		}
+	}
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(key) != masterKeySize {
+		return nil, fmt.Errorf("需要 base64 或 hex 编码的 %d 字节密钥", masterKeySize)
+	}
+	return key, nil
+}
+
+// GenerateMasterKey 生成随机的主密钥，返回 base64 编码
+func GenerateMasterKey() (string, error) {
+	key := make([]byte, masterKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Encrypt 用主密钥加密 plaintext，返回可以直接写入配置的 ENC(...)；每次加密使用随机 nonce，相同的明文得到不同的结果
+func Encrypt(plaintext string, key []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return "ENC(" + base64.StdEncoding.EncodeToString(sealed) + ")", nil
+}
+
+// decryptValue 解密 ENC(...) 形式的值，不是加密的值时原样返回
+func decryptValue(value string, key *masterKey) (string, error) {
+	m := encValuePattern.FindStringSubmatch(value)
+	if m == nil {
+		return value, nil
+	}
+	k, err := key.get()
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(k)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(m[1])
+	if err != nil || len(sealed) < gcm.NonceSize()+gcm.Overhead() {
+		return "", errors.New("加密的值格式错误")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", errors.New("解密失败，主密钥不匹配或值已损坏")
+	}
+	return string(plaintext), nil
+}
+
+// newGCM 创建 AES-256-GCM
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != masterKeySize {
+		return nil, fmt.Errorf("主密钥需要 %d 字节", masterKeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decryptNode 递归解密节点中 ENC(...) 形式的标量值，mapping 的 key 不处理；返回第一个失败的值及所在行
+func decryptNode(node *yaml.Node, key *masterKey) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := decryptNode(child, key); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := decryptNode(node.Content[i], key); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if !encValuePattern.MatchString(node.Value) {
+			return nil
+		}
+		value, err := decryptValue(node.Value, key)
+		if err != nil {
+			return fmt.Errorf("第 %d 行的加密值: %w", node.Line, err)
+		}
+		node.Value = value
+		node.Tag = "!!str"
+		node.Style = yaml.DoubleQuotedStyle
+	}
+	return nil
+}
+
+// decryptSettings 递归解密 JSON、TOML 配置中 ENC(...) 形式的字符串值，错误信息带所在的配置项（如 databases[0].password）
+func decryptSettings(value any, path string, key *masterKey) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		// 按 key 排序，多个值解密失败时报告的位置稳定
+		sort.Strings(keys)
+		for _, k := range keys {
+			decrypted, err := decryptSettings(v[k], joinKey(path, k), key)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = decrypted
+		}
+		return v, nil
+	case []any:
+		for i := range v {
+			decrypted, err := decryptSettings(v[i], fmt.Sprintf("%s[%d]", path, i), key)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = decrypted
+		}
+		return v, nil
+	case string:
+		decrypted, err := decryptValue(v, key)
+		if err != nil {
+			return nil, fmt.Errorf("%s 的加密值: %w", path, err)
+		}
+		return decrypted, nil
+	default:
+		return value, nil
+	}
+}
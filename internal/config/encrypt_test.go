@@ -0,0 +1,253 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// testMasterKey 固定的 32 字节测试主密钥
+var testMasterKey = bytes.Repeat([]byte{0x42}, masterKeySize)
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	tests := []string{"", "s3cret", "p@ss:w/o+rd=中文", strings.Repeat("x", 4096)}
+	for _, plaintext := range tests {
+		encrypted, err := Encrypt(plaintext, testMasterKey)
+		if err != nil {
+			t.Fatalf("Encrypt(%q): %v", plaintext, err)
+		}
+		if !encValuePattern.MatchString(encrypted) {
+			t.Fatalf("Encrypt(%q) = %q, not an ENC(...) value", plaintext, encrypted)
+		}
+		got, err := decryptValue(encrypted, &masterKey{key: testMasterKey, loaded: true})
+		if err != nil {
+			t.Fatalf("decryptValue(%q): %v", encrypted, err)
+		}
+		if got != plaintext {
+			t.Errorf("round trip = %q, want %q", got, plaintext)
+		}
+	}
+
+	// 每次加密使用随机 nonce，相同的明文得到不同的结果
+	a, _ := Encrypt("same", testMasterKey)
+	b, _ := Encrypt("same", testMasterKey)
+	if a == b {
+		t.Errorf("Encrypt produced identical output for the same plaintext: %s", a)
+	}
+}
+
+func TestDecryptValueErrors(t *testing.T) {
+	encrypted, err := Encrypt("s3cret", testMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, _ := base64.StdEncoding.DecodeString(strings.TrimSuffix(strings.TrimPrefix(encrypted, "ENC("), ")"))
+	tamper := func(i int) string {
+		b := append([]byte(nil), sealed...)
+		b[i] ^= 0x01
+		return "ENC(" + base64.StdEncoding.EncodeToString(b) + ")"
+	}
+	wrongKey := bytes.Repeat([]byte{0x24}, masterKeySize)
+
+	tests := []struct {
+		name  string
+		value string
+		key   []byte
+	}{
+		{name: "wrong key", value: encrypted, key: wrongKey},
+		{name: "tampered nonce", value: tamper(0), key: testMasterKey},
+		{name: "tampered ciphertext", value: tamper(len(sealed) - 20), key: testMasterKey},
+		{name: "tampered tag", value: tamper(len(sealed) - 1), key: testMasterKey},
+		{name: "truncated", value: "ENC(" + base64.StdEncoding.EncodeToString(sealed[:10]) + ")", key: testMasterKey},
+		{name: "short key", value: encrypted, key: testMasterKey[:16]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decryptValue(tt.value, &masterKey{key: tt.key, loaded: true})
+			if err == nil {
+				t.Fatalf("decryptValue succeeded with %q, want error", got)
+			}
+		})
+	}
+
+	t.Run("missing key", func(t *testing.T) {
+		if _, err := decryptValue(encrypted, &masterKey{err: os.ErrNotExist, loaded: true}); err == nil {
+			t.Fatal("expected error without master key")
+		}
+	})
+	t.Run("plain value untouched", func(t *testing.T) {
+		for _, value := range []string{"plain", "ENC(not base64!)", "prefix ENC(abc=)", ""} {
+			got, err := decryptValue(value, &masterKey{err: os.ErrNotExist, loaded: true})
+			if err != nil || got != value {
+				t.Errorf("decryptValue(%q) = %q, %v; want unchanged", value, got, err)
+			}
+		}
+	})
+}
+
+func TestParseMasterKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		ok    bool
+	}{
+		{name: "base64", value: base64.StdEncoding.EncodeToString(testMasterKey), ok: true},
+		{name: "hex", value: hex.EncodeToString(testMasterKey), ok: true},
+		{name: "hex upper case", value: strings.ToUpper(hex.EncodeToString(testMasterKey)), ok: true},
+		{name: "surrounding whitespace", value: "  " + base64.StdEncoding.EncodeToString(testMasterKey) + "\n", ok: true},
+		{name: "base64 too short", value: base64.StdEncoding.EncodeToString(testMasterKey[:16])},
+		{name: "hex too short", value: hex.EncodeToString(testMasterKey[:16])},
+		{name: "invalid", value: "not a key"},
+		{name: "empty", value: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParseMasterKey(tt.value)
+			if (err == nil) != tt.ok {
+				t.Fatalf("ParseMasterKey(%q) error = %v, want ok %v", tt.value, err, tt.ok)
+			}
+			if tt.ok && !bytes.Equal(key, testMasterKey) {
+				t.Errorf("ParseMasterKey(%q) = %x, want %x", tt.value, key, testMasterKey)
+			}
+		})
+	}
+
+	// 64 个字符但不是合法 hex 时按 base64 解析（48 字节，长度不对）
+	if _, err := ParseMasterKey(strings.Repeat("z", 64)); err == nil {
+		t.Error("expected error for 64-character non-hex key")
+	}
+}
+
+func TestLoadMasterKey(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "master.key")
+	if err := os.WriteFile(file, []byte(hex.EncodeToString(testMasterKey)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("env preferred", func(t *testing.T) {
+		t.Setenv(MasterKeyEnv, base64.StdEncoding.EncodeToString(testMasterKey))
+		t.Setenv(MasterKeyFileEnv, filepath.Join(t.TempDir(), "missing"))
+		if key, err := LoadMasterKey(""); err != nil || !bytes.Equal(key, testMasterKey) {
+			t.Fatalf("LoadMasterKey = %x, %v", key, err)
+		}
+	})
+	t.Run("file env", func(t *testing.T) {
+		t.Setenv(MasterKeyEnv, "")
+		os.Unsetenv(MasterKeyEnv)
+		t.Setenv(MasterKeyFileEnv, file)
+		if key, err := LoadMasterKey(""); err != nil || !bytes.Equal(key, testMasterKey) {
+			t.Fatalf("LoadMasterKey = %x, %v", key, err)
+		}
+	})
+	t.Run("explicit file", func(t *testing.T) {
+		if key, err := LoadMasterKey(file); err != nil || !bytes.Equal(key, testMasterKey) {
+			t.Fatalf("LoadMasterKey = %x, %v", key, err)
+		}
+	})
+	t.Run("invalid env", func(t *testing.T) {
+		t.Setenv(MasterKeyEnv, "short")
+		if _, err := LoadMasterKey(""); err == nil {
+			t.Fatal("expected error for invalid key")
+		}
+	})
+}
+
+func TestDecodeConfigDecryptsValues(t *testing.T) {
+	password, err := Encrypt("db-secret", testMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := Encrypt("api-token", testMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(MasterKeyEnv, base64.StdEncoding.EncodeToString(testMasterKey))
+	t.Setenv("TEST_ENC_TOKEN", token)
+
+	tests := []struct {
+		format string
+		data   string
+	}{
+		{"yaml", "remote_config:\n  bearer_token: \"${TEST_ENC_TOKEN}\"\ndatabases:\n  - name: a\n    password: " + password + "\n    user: plain\n"},
+		{"yml", "remote_config:\n  bearer_token: ${TEST_ENC_TOKEN}\ndatabases:\n  - name: a\n    password: \"" + password + "\"\n    user: plain\n"},
+		{"json", `{"remote_config": {"bearer_token": "${TEST_ENC_TOKEN}"}, "databases": [{"name": "a", "password": "` + password + `", "user": "plain"}]}`},
+		{"toml", "[remote_config]\nbearer_token = \"${TEST_ENC_TOKEN}\"\n\n[[databases]]\nname = \"a\"\npassword = \"" + password + "\"\nuser = \"plain\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			data, format, err := decodeConfig("config."+tt.format, []byte(tt.data), tt.format)
+			if err != nil {
+				t.Fatalf("decodeConfig: %v", err)
+			}
+			v := viper.New()
+			v.SetConfigType(format)
+			if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+				t.Fatalf("parse decoded config: %v\n%s", err, data)
+			}
+			if got := v.GetString("remote_config.bearer_token"); got != "api-token" {
+				t.Errorf("bearer_token = %q, want api-token", got)
+			}
+			databases, _ := v.Get("databases").([]any)
+			if len(databases) != 1 {
+				t.Fatalf("databases = %v", v.Get("databases"))
+			}
+			db, _ := databases[0].(map[string]any)
+			if db["password"] != "db-secret" || db["user"] != "plain" {
+				t.Errorf("database = %v, want decrypted password and untouched user", db)
+			}
+		})
+	}
+}
+
+func TestDecodeConfigDecryptErrors(t *testing.T) {
+	password, err := Encrypt("db-secret", testMasterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		key    string
+		format string
+		data   string
+		want   string
+	}{
+		{name: "yaml wrong key", key: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, masterKeySize)), format: "yaml",
+			data: "databases:\n  - name: a\n    password: " + password + "\n", want: "第 3 行"},
+		{name: "json wrong key", key: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, masterKeySize)), format: "json",
+			data: `{"databases": [{"name": "a", "password": "` + password + `"}]}`, want: "databases[0].password"},
+		{name: "toml missing key", format: "toml",
+			data: "[[databases]]\nname = \"a\"\npassword = \"" + password + "\"\n", want: "databases[0].password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.key != "" {
+				t.Setenv(MasterKeyEnv, tt.key)
+			} else {
+				t.Setenv(MasterKeyEnv, "")
+				os.Unsetenv(MasterKeyEnv)
+				t.Setenv(MasterKeyFileEnv, "")
+			}
+			_, _, err := decodeConfig("config."+tt.format, []byte(tt.data), tt.format)
+			if err == nil {
+				t.Fatal("expected decrypt error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not mention %q", err, tt.want)
+			}
+		})
+	}
+
+	// 没有加密的值时不要求主密钥
+	t.Setenv(MasterKeyEnv, "")
+	os.Unsetenv(MasterKeyEnv)
+	t.Setenv(MasterKeyFileEnv, "")
+	if _, _, err := decodeConfig("config.yaml", []byte("databases:\n  - name: a\n    password: plain\n"), "yaml"); err != nil {
+		t.Errorf("plain config without master key: %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// envRefPattern 配置值中的环境变量引用：${NAME}
+var envRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv 将配置文件中各个值里的 ${NAME} 替换为环境变量的值，引用的环境变量未设置时返回错误（列出所有缺失的变量及所在行）
+// 只处理 YAML 的值，不处理 key 和注释，注释中的示例引用不会因变量未设置而报错；没有引用的值保持原样
+// 替换后的值一律作为字符串，端口、时长等字段由解析配置时的类型转换处理；替换后整个值为 ENC(...) 时用主密钥解密
+func expandEnv(data []byte) ([]byte, error) {
+	if !envRefPattern.Match(data) && !encRefPattern.Match(data) {
+		return data, nil
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+	var missing []string
+	expandNode(&root, &missing)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("配置文件引用的环境变量未设置: %s", strings.Join(missing, ", "))
+	}
+	if err := decryptNode(&root, &masterKey{}); err != nil {
+		return nil, err
+	}
+
+	out, err := yaml.Marshal(&root)
+	if err != nil {
+		return nil, fmt.Errorf("替换环境变量后重新生成配置失败: %w", err)
+	}
+	return out, nil
+}
+
+// expandNode 递归替换节点中标量值的环境变量引用，mapping 的 key 不替换
+func expandNode(node *yaml.Node, missing *[]string) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			expandNode(child, missing)
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			expandNode(node.Content[i], missing)
+		}
+	case yaml.ScalarNode:
+		if !envRefPattern.MatchString(node.Value) {
+			return
+		}
+		node.Value = envRefPattern.ReplaceAllStringFunc(node.Value, func(ref string) string {
+			name := envRefPattern.FindStringSubmatch(ref)[1]
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				*missing = append(*missing, fmt.Sprintf("%s (第 %d 行)", name, node.Line))
+			}
+			return value
+		})
+		node.Tag = "!!str"
+		node.Style = yaml.DoubleQuotedStyle
+	}
+}
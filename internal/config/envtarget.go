@@ -0,0 +1,126 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// envTargetType 单目标模式的开关：设置了 DB_PROBE_TYPE 时不读取配置文件，唯一的目标完全由环境变量 DB_PROBE_<字段> 描述
+// 用于通过 Helm values 把探针作为 sidecar 注入到已有的应用 Pod 中，不需要再挂载配置文件
+const envTargetType = "DB_PROBE_TYPE"
+
+// envTargetField 单目标模式下错误信息中的目标位置
+const envTargetField = "环境变量 DB_PROBE_*"
+
+// EnvTargetMode 是否为单目标模式（设置了 DB_PROBE_TYPE）
+func EnvTargetMode() bool {
+	return os.Getenv(envTargetType) != ""
+}
+
+// envTarget 按 DBConfig 的字段读取环境变量 DB_PROBE_<字段>（如 DB_PROBE_HOST、DB_PROBE_SERVICE_NAME），合并 defaults 后解析为目标
+// 与全局配置项同名的字段（如 probe_interval、zone）按全局配置项处理，目标继承全局值；
+// 列表用逗号分隔（如 DB_PROBE_SESSION_INIT），labels 写成 k=v,k2=v2，secret_ref、check_pools 等嵌套字段不支持；值可以是 ENC(...)
+func envTarget(defaults, modules map[string]interface{}) (DBConfig, error) {
+	key := &masterKey{}
+	globals := make(map[string]bool)
+	ct := reflect.TypeOf(Config{})
+	for i := 0; i < ct.NumField(); i++ {
+		globals[ct.Field(i).Tag.Get("mapstructure")] = true
+	}
+
+	settings := make(map[string]interface{})
+	dt := reflect.TypeOf(DBConfig{})
+	for i := 0; i < dt.NumField(); i++ {
+		name := dt.Field(i).Tag.Get("mapstructure")
+		if name == "" || globals[name] {
+			continue
+		}
+		env := "DB_PROBE_" + strings.ToUpper(name)
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		value, err := decryptValue(value, key)
+		if err != nil {
+			return DBConfig{}, fmt.Errorf("%s 的加密值: %w", env, err)
+		}
+		switch kind := envFieldKind(dt.Field(i).Type); kind {
+		case reflect.Invalid:
+			return DBConfig{}, fmt.Errorf("单目标模式不支持 %s，%s 需要写在配置文件中", env, name)
+		case reflect.Map:
+			labels, err := parseEnvLabels(value)
+			if err != nil {
+				return DBConfig{}, fmt.Errorf("%s: %w", env, err)
+			}
+			settings[name] = labels
+		default:
+			settings[name] = value
+		}
+	}
+
+	dbCfgs, err := decodeWithDefaults([]interface{}{settings}, defaults, modules)
+	if err != nil {
+		return DBConfig{}, fmt.Errorf("解析%s失败: %w", envTargetField, err)
+	}
+	return dbCfgs[0], nil
+}
+
+// envFieldKind 字段能否从环境变量的字符串读取：标量（及其指针）、字符串列表、字符串 map，其余返回 reflect.Invalid
+func envFieldKind(t reflect.Type) reflect.Kind {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64:
+		return t.Kind()
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.String {
+			return reflect.Slice
+		}
+	case reflect.Map:
+		if t.Key().Kind() == reflect.String && t.Elem().Kind() == reflect.String {
+			return reflect.Map
+		}
+	}
+	return reflect.Invalid
+}
+
+// parseEnvLabels 解析 k=v,k2=v2 形式的 labels
+func parseEnvLabels(value string) (map[string]interface{}, error) {
+	labels := make(map[string]interface{})
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("labels 需要写成 k=v,k2=v2，当前值: %s", item)
+		}
+		labels[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return labels, nil
+}
+
+// envTargetBase 单目标模式下代替配置文件的全局配置，与 configs/config.yaml 示例相同，可以用 DB_PROBE_<配置项> 覆盖
+const envTargetBase = `
+listen_address: ":9100"
+probe_interval: 2s
+probe_timeout: 1s
+`
+
+// bindGlobalEnv 单目标模式没有配置文件，viper 只会按已知的配置项读取环境变量，需要逐个绑定全局的标量配置项（如 DB_PROBE_ZONE）
+func bindGlobalEnv() {
+	ct := reflect.TypeOf(Config{})
+	for i := 0; i < ct.NumField(); i++ {
+		key := ct.Field(i).Tag.Get("mapstructure")
+		if kind := envFieldKind(ct.Field(i).Type); key == "" || kind == reflect.Invalid || kind == reflect.Map {
+			continue
+		}
+		_ = viper.BindEnv(key)
+	}
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// featureEnvPrefix 按功能覆盖 features 的环境变量前缀，如 DB_PROBE_FEATURE_WRITE_CANARY=true
+const featureEnvPrefix = "DB_PROBE_FEATURE_"
+
+// experimentalFeatures 当前版本支持的实验功能及说明，默认关闭，通过 features 开启
+// 实验功能的代码通过 cfg.FeatureEnabled 判断是否启用，功能稳定后从这里移除并改为默认开启
+var experimentalFeatures = map[string]string{}
+
+// featureName 功能名称：小写字母开头，由小写字母、数字和下划线组成
+var featureName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// applyFeatureEnv 用环境变量 DB_PROBE_FEATURE_<NAME>（true/false）覆盖 features 中的同名功能
+// viper 的环境变量覆盖不支持 map 中的 key，只能单独处理
+func applyFeatureEnv(cfg *Config) error {
+	for _, env := range os.Environ() {
+		key, value, _ := strings.Cut(env, "=")
+		if !strings.HasPrefix(key, featureEnvPrefix) {
+			continue
+		}
+		name := strings.ToLower(strings.TrimPrefix(key, featureEnvPrefix))
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("环境变量 %s 只能是 true 或 false: %s", key, value)
+		}
+		if cfg.Features == nil {
+			cfg.Features = make(map[string]bool)
+		}
+		cfg.Features[name] = enabled
+	}
+	return nil
+}
+
+// validateFeatures 校验 features 中的功能名称；不认识的功能不报错，先下发配置再升级探针时可以正常启动
+func validateFeatures(features map[string]bool) error {
+	for name := range features {
+		if !featureName.MatchString(name) {
+			return fmt.Errorf("features 中的功能名称不合法: %s", name)
+		}
+	}
+	return nil
+}
+
+// FeatureEnabled 实验功能是否开启：当前版本支持该功能且 features 中开启
+func (cfg *Config) FeatureEnabled(name string) bool {
+	_, ok := experimentalFeatures[name]
+	return ok && cfg.Features[name]
+}
+
+// FeatureStates 返回当前版本支持的和 features 中配置的所有功能是否生效
+func (cfg *Config) FeatureStates() map[string]bool {
+	states := make(map[string]bool, len(experimentalFeatures)+len(cfg.Features))
+	for name := range experimentalFeatures {
+		states[name] = cfg.FeatureEnabled(name)
+	}
+	for name := range cfg.Features {
+		states[name] = cfg.FeatureEnabled(name)
+	}
+	return states
+}
+
+// EnabledFeatures 返回生效的实验功能，按名称排序
+func (cfg *Config) EnabledFeatures() []string {
+	var names []string
+	for name, enabled := range cfg.FeatureStates() {
+		if enabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UnsupportedFeatures 返回 features 中开启、但当前版本不支持的功能，按名称排序
+func (cfg *Config) UnsupportedFeatures() []string {
+	var names []string
+	for name, enabled := range cfg.Features {
+		if _, ok := experimentalFeatures[name]; enabled && !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
+)
+
+// configBase 主配置文件路径（不含扩展名），扩展名为 configExts 之一，按扩展名识别格式
+const configBase = "configs/config"
+
+// configExts 支持的配置文件扩展名，配置管理工具生成 JSON 时可以直接使用 config.json
+var configExts = []string{".yaml", ".yml", ".json", ".toml"}
+
+// ConfigFile 返回主配置文件路径：configs/config.yaml、config.yml、config.json、config.toml 中存在的一个
+// 同时存在多个时返回错误，避免修改了其中一个却加载了另一个
+func ConfigFile() (string, error) {
+	var found []string
+	for _, ext := range configExts {
+		if _, err := os.Stat(configBase + ext); err == nil {
+			found = append(found, configBase+ext)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("读取配置文件失败: 未找到 %s{%s}", configBase, strings.Join(configExts, ","))
+	case 1:
+		return found[0], nil
+	default:
+		return "", fmt.Errorf("同时存在多个配置文件，只能保留一个: %s", strings.Join(found, ", "))
+	}
+}
+
+// isConfigExt 扩展名是否为支持的配置文件格式
+func isConfigExt(ext string) bool {
+	for _, e := range configExts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// readConfigFile 读取配置文件并替换值中引用的环境变量（${NAME}），返回内容和交给 viper 解析的格式
+func readConfigFile(path string) ([]byte, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("读取 %s 失败: %w", path, err)
+	}
+	return decodeConfig(path, data, strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// decodeConfig 替换配置内容中引用的环境变量并解密 ENC(...) 形式的值，name 为错误信息中的来源（文件路径或 config_url）
+// JSON、TOML 引用了环境变量或包含加密的值时按原格式解析后处理，结果统一转换为 YAML
+func decodeConfig(name string, data []byte, format string) ([]byte, string, error) {
+	var err error
+	switch format {
+	case "yaml", "yml":
+		data, err = expandEnv(data)
+		format = "yaml"
+	case "json", "toml":
+		if envRefPattern.Match(data) || encRefPattern.Match(data) {
+			data, err = expandSettings(data, format)
+			format = "yaml"
+		}
+	default:
+		return nil, "", fmt.Errorf("不支持的配置文件格式: %s（只支持 %s）", name, strings.Join(configExts, "、"))
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", name, err)
+	}
+	return data, format, nil
+}
+
+// expandSettings 按 format 解析配置，替换所有字符串值中的环境变量引用后转换为 YAML
+// 缺失的变量按所在的配置项（如 databases[0].password）列出
+func expandSettings(data []byte, format string) ([]byte, error) {
+	v := viper.New()
+	v.SetConfigType(format)
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+	var missing []string
+	settings := expandValue(v.AllSettings(), "", &missing)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("配置文件引用的环境变量未设置: %s", strings.Join(missing, ", "))
+	}
+	settings, err := decryptSettings(settings, "", &masterKey{})
+	if err != nil {
+		return nil, err
+	}
+	out, err := yaml.Marshal(settings)
+	if err != nil {
+		return nil, fmt.Errorf("替换环境变量后重新生成配置失败: %w", err)
+	}
+	return out, nil
+}
+
+// expandValue 递归替换字符串值中的环境变量引用，map 的 key 不替换
+func expandValue(value any, key string, missing *[]string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		// 按 key 排序，缺失变量的列出顺序稳定
+		sort.Strings(keys)
+		for _, k := range keys {
+			v[k] = expandValue(v[k], joinKey(key, k), missing)
+		}
+		return v
+	case []any:
+		for i := range v {
+			v[i] = expandValue(v[i], fmt.Sprintf("%s[%d]", key, i), missing)
+		}
+		return v
+	case string:
+		return envRefPattern.ReplaceAllStringFunc(v, func(ref string) string {
+			name := envRefPattern.FindStringSubmatch(ref)[1]
+			env, ok := os.LookupEnv(name)
+			if !ok {
+				*missing = append(*missing, fmt.Sprintf("%s (%s)", name, key))
+			}
+			return env
+		})
+	default:
+		return value
+	}
+}
+
+// joinKey 拼接配置项路径
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// expandHosts 把配置了 hosts 的目标按主机展开为多个目标，其余字段（凭据、labels 等）相同
+// 展开出的目标名称（以及配置了的 id）追加主机（和端口），如 mysql-replica-10.0.0.2、mysql-replica-10.0.0.3-3307
+func expandHosts(cfg *Config) error {
+	expand := false
+	for i := range cfg.Databases {
+		expand = expand || len(cfg.Databases[i].Hosts) > 0
+	}
+	if !expand {
+		return nil
+	}
+
+	dbCfgs := make([]DBConfig, 0, len(cfg.Databases))
+	fields := make([]string, 0, len(cfg.Databases))
+	for i, db := range cfg.Databases {
+		field := cfg.databaseField(i)
+		if len(db.Hosts) == 0 {
+			dbCfgs = append(dbCfgs, db)
+			fields = append(fields, field)
+			continue
+		}
+		if db.Host != "" {
+			return fmt.Errorf("%s.hosts 不能与 host 同时配置", field)
+		}
+		// dsn 中的地址由驱动解析，无法替换为各个主机
+		if db.DSN != "" {
+			return fmt.Errorf("%s.hosts 不能与 dsn 同时配置", field)
+		}
+		for j, entry := range db.Hosts {
+			host, port, err := splitHostEntry(entry)
+			if err != nil {
+				return fmt.Errorf("%s.hosts[%d] %w", field, j, err)
+			}
+			suffix := host
+			if port != 0 {
+				suffix = fmt.Sprintf("%s-%d", host, port)
+			}
+			expanded := cloneDatabase(db)
+			expanded.Hosts = nil
+			expanded.Host = host
+			if port != 0 {
+				expanded.Port = port
+			}
+			expanded.Name = db.Name + "-" + suffix
+			if db.ID != "" {
+				expanded.ID = db.ID + "-" + suffix
+			}
+			dbCfgs = append(dbCfgs, expanded)
+			fields = append(fields, fmt.Sprintf("%s.hosts[%d]", field, j))
+		}
+	}
+	cfg.Databases = dbCfgs
+	cfg.databaseFields = fields
+	return nil
+}
+
+// splitHostEntry 解析 hosts 中的一项：主机、host:port 或 [IPv6]:port，未带端口时返回的端口为 0
+func splitHostEntry(entry string) (string, int, error) {
+	entry = strings.TrimSpace(entry)
+	if entry == "" {
+		return "", 0, fmt.Errorf("不能为空")
+	}
+	host, portStr, err := net.SplitHostPort(entry)
+	if err != nil {
+		// 没有端口（包括不带方括号的 IPv6 地址）
+		return strings.Trim(entry, "[]"), 0, nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return "", 0, fmt.Errorf("中的端口不合法: %s", entry)
+	}
+	if host == "" {
+		return "", 0, fmt.Errorf("缺少主机: %s", entry)
+	}
+	return host, port, nil
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// targetIDNamespace 由目标地址推导稳定 ID 时使用的 UUID 命名空间（UUID v5）
+var targetIDNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://github.com/imkerbos/db-probe/targets"))
+
+// targetIDPattern 配置的目标 ID 允许的字符
+var targetIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:-]{0,127}$`)
+
+// deriveTargetID 由目标的类型和地址推导稳定 ID（UUID v5），与 name 无关，改名后保持不变
+// 只配置了 dsn、没有任何地址字段的目标无法从地址推导，使用 name 推导；withName 为 true 时同样把 name 计入
+// （多个目标连接同一个地址时用于区分）
+func deriveTargetID(db *DBConfig, withName bool) string {
+	parts := []string{
+		db.Type, db.Host, strconv.Itoa(db.Port), db.ServiceName, db.Database,
+		db.Tenant, db.Cluster, db.Account, db.Path,
+	}
+	hasAddress := db.Host != "" || db.ServiceName != "" || db.Database != "" || db.Account != "" || db.Path != ""
+	if !hasAddress || withName {
+		parts = append(parts, db.Name)
+	}
+	return uuid.NewSHA1(targetIDNamespace, []byte(strings.Join(parts, "\x00"))).String()
+}
+
+// validateTargetID 校验配置的 id，未配置时由地址推导
+func validateTargetID(field string, db *DBConfig) error {
+	if db.ID == "" {
+		db.ID = deriveTargetID(db, false)
+		return nil
+	}
+	if !targetIDPattern.MatchString(db.ID) {
+		return fmt.Errorf("%s.id 只能包含字母、数字和 . _ : -，以字母或数字开头，最长 128 个字符: %q", field, db.ID)
+	}
+	return nil
+}
+
+// resolveTargetIDs 检查配置文件中目标 ID 的唯一性
+// 多个未配置 id 的目标连接同一个地址（如用不同的账号或探测 SQL 探测同一个实例）时推导出的 ID 相同，
+// 这些目标改为把 name 也计入推导，改名后 ID 会变化，需要保持 ID 时应显式配置 id；配置的 id 重复时返回错误
+func resolveTargetIDs(cfg *Config, configured []bool) error {
+	counts := make(map[string]int, len(cfg.Databases))
+	for i := range cfg.Databases {
+		if !configured[i] {
+			counts[cfg.Databases[i].ID]++
+		}
+	}
+	for i := range cfg.Databases {
+		db := &cfg.Databases[i]
+		if !configured[i] && counts[db.ID] > 1 {
+			db.ID = deriveTargetID(db, true)
+		}
+	}
+
+	owners := make(map[string]string, len(cfg.Databases))
+	for i := range cfg.Databases {
+		db := &cfg.Databases[i]
+		if other, ok := owners[db.ID]; ok {
+			return fmt.Errorf("目标 ID 重复: %s（%s、%s）", db.ID, other, db.Name)
+		}
+		owners[db.ID] = db.Name
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// mergeIncludes 把配置文件 includes 中列出的文件合并到 viper，未配置 includes 时直接返回
+// 被包含的文件同样可以使用 includes；data、format 为已经读取（并替换了环境变量）的主配置文件内容
+// 返回被包含的全部文件（包括间接包含的），watch_config 同时监听这些文件
+func mergeIncludes(path string, data []byte, format string) ([]string, error) {
+	if !viper.InConfig("includes") {
+		return nil, nil
+	}
+	var files []string
+	settings, err := includeSettings(path, data, format, nil, &files)
+	if err != nil {
+		return nil, err
+	}
+	return files, viper.MergeConfigMap(settings)
+}
+
+// includeSettings 返回配置文件与其 includes 合并后的配置项
+// 同名配置项按 includes 的顺序合并，后面的文件优先，文件自身的配置项优先于它包含的文件（map 按 key 递归合并）；
+// databases 不合并而是拼接：文件自身的目标在前，之后按 includes 的顺序追加各文件的目标
+// chain 为当前的包含链，用于检测循环包含：路径先解析符号链接，通过不同的链接包含同一个文件也能检测到；files 收集被包含的文件
+func includeSettings(path string, data []byte, format string, chain []string, files *[]string) (map[string]interface{}, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("解析 %s 的路径失败: %w", path, err)
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = real
+	}
+	for i, included := range chain {
+		if included == abs {
+			return nil, fmt.Errorf("配置文件循环包含: %s", strings.Join(append(chain[i:], abs), " -> "))
+		}
+	}
+	chain = append(chain, abs)
+
+	v := viper.New()
+	v.SetConfigType(format)
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", path, err)
+	}
+	settings := v.AllSettings()
+	includes, ok := settings["includes"].([]interface{})
+	if settings["includes"] != nil && !ok {
+		return nil, fmt.Errorf("%s 中的 includes 必须是文件列表", path)
+	}
+	if len(includes) == 0 {
+		return settings, nil
+	}
+
+	databases, err := databaseItems(path, settings)
+	if err != nil {
+		return nil, err
+	}
+	merged := viper.New()
+	for i, item := range includes {
+		file, ok := item.(string)
+		if !ok || file == "" {
+			return nil, fmt.Errorf("%s 中的 includes[%d] 必须是文件路径", path, i)
+		}
+		// 相对路径相对于包含它的文件所在的目录
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(path), file)
+		}
+		includedData, includedFormat, err := readConfigFile(file)
+		if err != nil {
+			return nil, err
+		}
+		*files = append(*files, file)
+		included, err := includeSettings(file, includedData, includedFormat, chain, files)
+		if err != nil {
+			return nil, err
+		}
+		items, err := databaseItems(file, included)
+		if err != nil {
+			return nil, err
+		}
+		databases = append(databases, items...)
+		delete(included, "databases")
+		delete(included, "includes")
+		if err := merged.MergeConfigMap(included); err != nil {
+			return nil, fmt.Errorf("合并 %s 失败: %w", file, err)
+		}
+	}
+	delete(settings, "databases")
+	if err := merged.MergeConfigMap(settings); err != nil {
+		return nil, fmt.Errorf("合并 %s 失败: %w", path, err)
+	}
+	result := merged.AllSettings()
+	if len(databases) > 0 {
+		result["databases"] = databases
+	}
+	return result, nil
+}
+
+// databaseItems 返回配置项中的 databases 列表
+func databaseItems(path string, settings map[string]interface{}) ([]interface{}, error) {
+	raw, ok := settings["databases"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s 中的 databases 必须是列表", path)
+	}
+	return items, nil
+}
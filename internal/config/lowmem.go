@@ -0,0 +1,41 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// lowMemoryDefaults low_memory 模式下使用的默认值，只作用于配置文件和环境变量中都没有配置的项
+// 面向只探测少量本地数据库的边缘网关：拉长探测和可选检查的间隔，缩小 remote write 的内存缓冲
+var lowMemoryDefaults = []struct {
+	key   string
+	apply func(cfg *Config)
+}{
+	{"probe_interval", func(cfg *Config) { cfg.ProbeInterval = 30 * time.Second }},
+	{"probe_timeout", func(cfg *Config) { cfg.ProbeTimeout = 5 * time.Second }},
+	{"uptime_interval", func(cfg *Config) { cfg.UptimeInterval = 10 * time.Minute }},
+	{"cluster_check_interval", func(cfg *Config) { cfg.ClusterCheckInterval = 10 * time.Minute }},
+	{"remote_write.interval", func(cfg *Config) { cfg.RemoteWrite.Interval = time.Minute }},
+	{"remote_write.max_pending_batches", func(cfg *Config) { cfg.RemoteWrite.MaxPendingBatches = 2 }},
+	{"local_storage.interval", func(cfg *Config) { cfg.LocalStorage.Interval = time.Minute }},
+}
+
+// applyLowMemoryDefaults 开启 low_memory 时，把没有显式配置的项替换为 lowMemoryDefaults 中的值
+// 不能通过 viper.SetDefault 实现：默认值需要先读取配置文件才知道是否开启，而 viper 的默认值在重新加载配置时不会被清除
+func applyLowMemoryDefaults(cfg *Config) {
+	if !cfg.LowMemory {
+		return
+	}
+	for _, d := range lowMemoryDefaults {
+		if viper.InConfig(d.key) {
+			continue
+		}
+		if _, ok := os.LookupEnv("DB_PROBE_" + strings.ToUpper(d.key)); ok {
+			continue
+		}
+		d.apply(cfg)
+	}
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maskedKeys 脱敏输出配置时隐藏值的字段（密码、密钥、令牌、可能带密码的 DSN 和请求头）
+var maskedKeys = map[string]bool{
+	"password":             true,
+	"api_key":              true,
+	"dsn":                  true,
+	"secret":               true,
+	"token":                true,
+	"control_token":        true,
+	"bearer_token":         true,
+	"dingtalk_app_secret":  true,
+	"slack_signing_secret": true,
+	"headers":              true,
+}
+
+// maskedURLKeys 值为地址的字段：隐藏其中的密码和查询参数（企业微信、钉钉机器人的 key/access_token 在查询参数中）
+var maskedURLKeys = map[string]bool{
+	"url":        true,
+	"config_url": true,
+	"address":    true,
+	"endpoint":   true,
+}
+
+// Masked 返回脱敏后的配置，key 与配置文件相同，用于支持包等需要把配置交给他人排障的场景
+// 密码、密钥、令牌、DSN 和请求头替换为 ***，地址中的密码和查询参数替换为 ***；未配置的敏感字段保持为空
+func (cfg *Config) Masked() map[string]interface{} {
+	return maskValue(reflect.ValueOf(*cfg), "").(map[string]interface{})
+}
+
+// maskValue 按 mapstructure tag 把配置转换为 map，key 为当前值在配置文件中的 key
+func maskValue(v reflect.Value, key string) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		return time.Duration(v.Int()).String()
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return maskValue(v.Elem(), key)
+	case reflect.Struct:
+		out := make(map[string]interface{})
+		for i := 0; i < v.NumField(); i++ {
+			name := v.Type().Field(i).Tag.Get("mapstructure")
+			if name == "" {
+				continue
+			}
+			out[name] = maskValue(v.Field(i), name)
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		out := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			name := iter.Key().String()
+			if maskedKeys[key] {
+				out[name] = maskedValue
+				continue
+			}
+			// defaults 等 map 中的 key 同样是配置项
+			out[name] = maskValue(iter.Value(), name)
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		out := make([]interface{}, v.Len())
+		for i := range out {
+			out[i] = maskValue(v.Index(i), key)
+		}
+		return out
+	case reflect.String:
+		s := v.String()
+		if s == "" {
+			return s
+		}
+		if maskedKeys[key] {
+			return maskedValue
+		}
+		if maskedURLKeys[key] {
+			return maskURL(s)
+		}
+		return s
+	}
+	return v.Interface()
+}
+
+// maskURL 隐藏地址中的密码和查询参数的值，无法解析的地址整体隐藏
+func maskURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return maskedValue
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), maskedValue)
+	}
+	if u.RawQuery != "" {
+		// 不使用 url.Values.Encode，避免 *** 被转义为 %2A%2A%2A
+		var names []string
+		for name := range u.Query() {
+			names = append(names, url.QueryEscape(name)+"="+maskedValue)
+		}
+		sort.Strings(names)
+		u.RawQuery = strings.Join(names, "&")
+	}
+	return u.String()
+}
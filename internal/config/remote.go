@@ -0,0 +1,262 @@
+package config
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/imkerbos/db-probe/pkg/logger"
+	"github.com/spf13/viper"
+)
+
+// maxRemoteConfigSize 远端配置的最大长度，避免错误的地址返回大文件耗尽内存
+const maxRemoteConfigSize = 16 << 20
+
+// remoteSource 远端配置（config_url）最近一次拉取到的内容
+// Load 合并其中的内容，PollRemoteConfig 按 ETag 轮询更新；SIGHUP 重新加载不会额外请求远端
+// 拉取到的新内容先作为 pending，Load 加载和校验成功后才提交为 data 并写入 cache_file，
+// 失败时记为 rejected，之后的加载继续使用上一次成功的 data，远端内容不变时轮询不再触发重新加载
+type remoteSource struct {
+	mu     sync.Mutex
+	url    string
+	cfg    RemoteConfigConfig
+	client *http.Client
+	data   []byte // 上一次加载成功的原始内容（与 cache_file 一致），环境变量引用在合并时替换
+	format string
+	etag   string // 最近一次响应的 ETag
+
+	pending       []byte // 拉取到、还没有通过加载和校验的内容
+	pendingFormat string
+	pendingUsed   bool   // 本次 Load 合并的是 pending，Load 结束时由 settle 提交或丢弃
+	rejected      []byte // 加载或校验失败的内容
+}
+
+var remote remoteSource
+
+// mergeRemoteConfig 把 config_url 的配置合并到 viper，覆盖本地配置文件中的同名配置项
+// 首次加载时同步拉取，拉取失败且配置了 cache_file 时使用上一次拉取到的配置
+func mergeRemoteConfig(configURL string, rc RemoteConfigConfig) error {
+	if err := validateRemoteConfig(configURL, &rc); err != nil {
+		return err
+	}
+	data, format, err := remote.get(configURL, rc)
+	if err != nil {
+		return err
+	}
+	data, format, err = decodeConfig(configURL, data, format)
+	if err != nil {
+		return err
+	}
+
+	v := viper.New()
+	v.SetConfigType(format)
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("解析远端配置失败: %w", err)
+	}
+	for _, key := range v.AllKeys() {
+		if top, _, _ := strings.Cut(key, "."); top == "config_url" || top == "remote_config" {
+			return fmt.Errorf("远端配置中不能包含 %s，只能写在本地配置文件中", top)
+		}
+	}
+	return viper.MergeConfigMap(v.AllSettings())
+}
+
+// PollRemoteConfig 请求 config_url，返回配置是否变化；未配置 config_url 时直接返回
+// 带上一次响应的 ETag（If-None-Match），远端返回 304 或内容相同时视为没有变化
+func PollRemoteConfig() (bool, error) {
+	remote.mu.Lock()
+	defer remote.mu.Unlock()
+	if remote.client == nil {
+		return false, nil
+	}
+	return remote.fetch()
+}
+
+// get 返回远端配置的内容和格式，还没有拉取过（或 config_url 变化）时同步拉取
+func (s *remoteSource) get(configURL string, rc RemoteConfigConfig) ([]byte, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == nil || s.url != configURL || !reflect.DeepEqual(s.cfg, rc) {
+		client, err := newRemoteClient(rc)
+		if err != nil {
+			return nil, "", err
+		}
+		if s.url != configURL {
+			s.data, s.format, s.etag = nil, "", ""
+			s.pending, s.pendingFormat, s.rejected = nil, "", nil
+		}
+		s.url, s.cfg, s.client = configURL, rc, client
+	}
+
+	if s.pending == nil && s.data == nil {
+		if _, err := s.fetch(); err != nil {
+			if rc.CacheFile == "" {
+				return nil, "", err
+			}
+			data, readErr := os.ReadFile(rc.CacheFile)
+			if readErr != nil {
+				return nil, "", fmt.Errorf("%w（读取 cache_file 失败: %v）", err, readErr)
+			}
+			logger.L().Warnw("拉取远端配置失败，使用 cache_file 中上一次拉取到的配置",
+				"config_url", configURL,
+				"cache_file", rc.CacheFile,
+				"error", err,
+			)
+			s.data, s.format = data, remoteFormat(configURL, "", data)
+		}
+	}
+	switch {
+	case s.pending != nil:
+		s.pendingUsed = true
+		return s.pending, s.pendingFormat, nil
+	case s.data != nil:
+		return s.data, s.format, nil
+	}
+	return nil, "", fmt.Errorf("远端配置加载失败，内容变化后才会重新尝试: %s", configURL)
+}
+
+// settle 在 Load 结束时调用：本次加载合并了新拉取到的远端配置时，成功则提交为 data 并写入 cache_file，
+// 失败则记为 rejected，之后的加载继续使用上一次成功的内容
+func (s *remoteSource) settle(ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.pendingUsed {
+		return
+	}
+	s.pendingUsed = false
+	if !ok {
+		s.rejected = s.pending
+		s.pending, s.pendingFormat = nil, ""
+		return
+	}
+	s.data, s.format = s.pending, s.pendingFormat
+	s.pending, s.pendingFormat, s.rejected = nil, "", nil
+	if s.cfg.CacheFile != "" {
+		if err := writeCacheFile(s.cfg.CacheFile, s.data); err != nil {
+			logger.L().Warnw("写入远端配置 cache_file 失败", "cache_file", s.cfg.CacheFile, "error", err)
+		}
+	}
+}
+
+// fetch 请求远端配置，内容变化时保存为 pending 并返回 true，与 pending、上一次成功或失败的内容相同时视为没有变化，调用方持有 s.mu
+func (s *remoteSource) fetch() (bool, error) {
+	req, err := http.NewRequest(http.MethodGet, s.url, nil)
+	if err != nil {
+		return false, fmt.Errorf("创建远端配置请求失败: %w", err)
+	}
+	for name, value := range s.cfg.Headers {
+		req.Header.Set(name, value)
+	}
+	if s.cfg.BasicAuth.Username != "" {
+		req.SetBasicAuth(s.cfg.BasicAuth.Username, s.cfg.BasicAuth.Password)
+	} else if s.cfg.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.cfg.BearerToken)
+	}
+	seen := s.data != nil || s.pending != nil || s.rejected != nil
+	if seen && s.etag != "" {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("拉取远端配置失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && seen {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("拉取远端配置失败: HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteConfigSize+1))
+	if err != nil {
+		return false, fmt.Errorf("读取远端配置失败: %w", err)
+	}
+	if len(body) > maxRemoteConfigSize {
+		return false, fmt.Errorf("远端配置超过 %d MB", maxRemoteConfigSize>>20)
+	}
+
+	s.etag = resp.Header.Get("ETag")
+	switch {
+	case s.pending != nil && bytes.Equal(body, s.pending):
+		return false, nil
+	case s.rejected != nil && bytes.Equal(body, s.rejected):
+		return false, nil
+	case s.data != nil && bytes.Equal(body, s.data):
+		// 远端恢复为上一次成功的内容，当前配置已经是这份内容
+		s.pending, s.pendingFormat, s.rejected = nil, "", nil
+		return false, nil
+	}
+	s.pending, s.pendingFormat = body, remoteFormat(s.url, resp.Header.Get("Content-Type"), body)
+	return true, nil
+}
+
+// newRemoteClient 按 remote_config 创建 HTTP 客户端
+func newRemoteClient(rc RemoteConfigConfig) (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: rc.TLSSkipVerify}
+	if rc.CAFile != "" {
+		pem, err := os.ReadFile(rc.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 remote_config.ca_file 失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("remote_config.ca_file 中没有有效的证书: %s", rc.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Timeout: rc.Timeout, Transport: transport}, nil
+}
+
+// remoteFormat 识别远端配置的格式：URL 路径的扩展名优先，其次是 Content-Type，都无法识别时以 { 开头的内容按 JSON、其余按 YAML 解析
+func remoteFormat(configURL, contentType string, data []byte) string {
+	if u, err := url.Parse(configURL); err == nil {
+		if ext := path.Ext(u.Path); isConfigExt(ext) {
+			return strings.TrimPrefix(ext, ".")
+		}
+	}
+	switch {
+	case strings.Contains(contentType, "json"):
+		return "json"
+	case strings.Contains(contentType, "toml"):
+		return "toml"
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return "json"
+	}
+	return "yaml"
+}
+
+// writeCacheFile 先写临时文件再重命名，进程中途退出时不会留下写了一半的 cache_file
+// 远端配置可能包含密码，文件权限为 0600
+func writeCacheFile(file string, data []byte) error {
+	dir := filepath.Dir(file)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(file)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SecretProviders user_ref、password_ref、dsn_ref 支持的外部密钥存储，即引用的前缀
+var SecretProviders = []string{"vault", "aws-sm"}
+
+// CredentialRef 外部密钥存储中的一个凭据：<provider>:<path>#<key>
+type CredentialRef struct {
+	Provider string // 密钥存储（如 vault）
+	Path     string // 密钥路径（如 Vault 的 secret/data/db/orders、AWS Secrets Manager 的 ARN 或名称）
+	Key      string // 路径下的 key
+}
+
+func (r CredentialRef) String() string {
+	return r.Provider + ":" + r.Path + "#" + r.Key
+}
+
+// ParseCredentialRef 解析凭据引用，如 vault:secret/data/db/orders#password
+// provider 之后的部分直到 # 均为路径，路径中可以包含冒号（如 aws-sm:arn:aws:secretsmanager:...#password）
+func ParseCredentialRef(ref string) (CredentialRef, error) {
+	provider, rest, ok := strings.Cut(ref, ":")
+	if !ok {
+		return CredentialRef{}, fmt.Errorf("格式应为 <provider>:<path>#<key>: %s", ref)
+	}
+	path, key, ok := strings.Cut(rest, "#")
+	path = strings.Trim(path, "/")
+	if !ok || path == "" || key == "" {
+		return CredentialRef{}, fmt.Errorf("格式应为 <provider>:<path>#<key>: %s", ref)
+	}
+	if !slices.Contains(SecretProviders, provider) {
+		return CredentialRef{}, fmt.Errorf("不支持的密钥存储 %s（支持 %s）", provider, strings.Join(SecretProviders, "、"))
+	}
+	return CredentialRef{Provider: provider, Path: path, Key: key}, nil
+}
+
+// HasCredentialRefs 目标是否配置了 user_ref、password_ref、dsn_ref
+func (db *DBConfig) HasCredentialRefs() bool {
+	return db.UserRef != "" || db.PasswordRef != "" || db.DSNRef != ""
+}
+
+// ExternalCredentials 目标的凭据是否在运行时从外部读取（secret_ref 或 user_ref、password_ref、dsn_ref）
+// 这类目标读取到凭据后才加入探测，不随配置文件创建
+func (db *DBConfig) ExternalCredentials() bool {
+	return db.SecretRef != nil || db.HasCredentialRefs()
+}
+
+// userFromSecret、passwordFromSecret、dsnFromSecret 字段是否由 secret_ref 或外部密钥存储在运行时提供
+func userFromSecret(db *DBConfig) bool {
+	return db.UserRef != "" || db.SecretRef != nil && db.SecretRef.UserKey != ""
+}
+
+func passwordFromSecret(db *DBConfig) bool {
+	return db.PasswordRef != "" || db.SecretRef != nil && db.SecretRef.Key != ""
+}
+
+func dsnFromSecret(db *DBConfig) bool {
+	return db.DSNRef != "" || db.SecretRef != nil && db.SecretRef.DSNKey != ""
+}
+
+// validateCredentialRefs 校验目标的 user_ref、password_ref、dsn_ref，引用的字段不能再直接配置或从其他来源读取
+func validateCredentialRefs(field string, db *DBConfig) error {
+	if !db.HasCredentialRefs() {
+		return nil
+	}
+	if db.Type == "tcp" || db.Type == "sqlite" {
+		return fmt.Errorf("%s.user_ref、password_ref、dsn_ref 不适用于 %s 类型", field, db.Type)
+	}
+	if db.SecretRef != nil {
+		return fmt.Errorf("%s.secret_ref 和 user_ref、password_ref、dsn_ref 只能配置其一", field)
+	}
+	for _, ref := range []struct {
+		name    string
+		value   string
+		direct  string
+		file    string
+		setting string
+	}{
+		{"user_ref", db.UserRef, db.User, db.UserFile, "user"},
+		{"password_ref", db.PasswordRef, db.Password, db.PasswordFile, "password"},
+		{"dsn_ref", db.DSNRef, db.DSN, "", "dsn"},
+	} {
+		if ref.value == "" {
+			continue
+		}
+		if _, err := ParseCredentialRef(ref.value); err != nil {
+			return fmt.Errorf("%s.%s %w", field, ref.name, err)
+		}
+		if ref.file != "" {
+			return fmt.Errorf("%s.%s 和 %s_file 只能配置其一", field, ref.name, ref.setting)
+		}
+		if ref.direct != "" {
+			return fmt.Errorf("%s.%s 和 %s 只能配置其一", field, ref.name, ref.setting)
+		}
+	}
+	return nil
+}
+
+// validateSecrets 校验外部密钥存储配置
+func validateSecrets(s *SecretsConfig) error {
+	if s.RefreshInterval <= 0 {
+		return fmt.Errorf("secrets.refresh_interval 必须大于 0")
+	}
+	if s.Vault.KubernetesRole == "" && s.Vault.KubernetesTokenFile != "" {
+		return fmt.Errorf("secrets.vault.kubernetes_token_file 需要同时配置 kubernetes_role")
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+// kubernetesDataLink Kubernetes 挂载 ConfigMap、Secret 的目录中指向当前版本的符号链接
+// ConfigMap、Secret 更新时 kubelet 原子地替换该链接，文件本身（同样是符号链接）没有任何事件
+const kubernetesDataLink = "..data"
+
+// Watcher 监听文件（配置文件或凭据文件）变化，变化停止 debounce 之后调用 onChange
+// 监听的是文件所在的目录：编辑器保存（写临时文件再重命名）和 Kubernetes ConfigMap、Secret 更新都会替换文件，
+// 直接监听文件会在第一次替换后失效
+type Watcher struct {
+	watcher  *fsnotify.Watcher
+	kind     string // 日志中的文件类型，如"配置文件"
+	debounce time.Duration
+	onChange func()
+	done     chan struct{}
+	stopped  chan struct{}
+
+	// mu 保护 files、dirs：监听的文件和所在目录，凭据文件列表在重新加载配置后更新
+	// databasesDirs 为 databases_dir 目录，其中任意目标文件（*.yaml、*.yml、*.json、*.toml）的变化都触发 onChange
+	mu            sync.Mutex
+	files         map[string]bool
+	dirs          map[string]bool
+	databasesDirs map[string]bool
+}
+
+// NewWatcher 创建配置文件监听，paths 为主配置文件及其 includes 包含的文件（见 Config.ConfigFiles），重新加载配置后通过 SetPaths 更新
+func NewWatcher(paths []string, debounce time.Duration, onChange func()) (*Watcher, error) {
+	return newWatcher("配置文件", paths, debounce, onChange)
+}
+
+// NewCredentialWatcher 创建凭据文件（user_file、password_file）监听，paths 见 CredentialFiles
+func NewCredentialWatcher(paths []string, debounce time.Duration, onChange func()) (*Watcher, error) {
+	return newWatcher("凭据文件", paths, debounce, onChange)
+}
+
+func newWatcher(kind string, paths []string, debounce time.Duration, onChange func()) (*Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("创建文件监听失败: %w", err)
+	}
+	w := &Watcher{
+		watcher:  fw,
+		kind:     kind,
+		debounce: debounce,
+		onChange: onChange,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+		files:    make(map[string]bool),
+		dirs:     make(map[string]bool),
+
+		databasesDirs: make(map[string]bool),
+	}
+	if err := w.SetPaths(paths); err != nil {
+		fw.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// SetPaths 更新监听的文件，新增的目录开始监听；不再引用的目录中的事件不再触发 onChange
+func (w *Watcher) SetPaths(paths []string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	files := make(map[string]bool, len(paths))
+	dirs := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		dir := filepath.Dir(path)
+		if !w.dirs[dir] && !dirs[dir] {
+			if err := w.watcher.Add(dir); err != nil {
+				return fmt.Errorf("监听%s所在目录 %s 失败: %w", w.kind, dir, err)
+			}
+		}
+		files[path] = true
+		dirs[dir] = true
+	}
+	w.files, w.dirs = files, dirs
+	return nil
+}
+
+// WatchDatabasesDir 同时监听 databases_dir，新增、修改、删除目标文件都会重新加载配置
+func (w *Watcher) WatchDatabasesDir(dir string) error {
+	dir = filepath.Clean(dir)
+	if err := w.watcher.Add(dir); err != nil {
+		return fmt.Errorf("监听 databases_dir %s 失败: %w", dir, err)
+	}
+	w.mu.Lock()
+	w.databasesDirs[dir] = true
+	w.mu.Unlock()
+	logger.L().Infow("databases_dir 监听已启动", "path", dir)
+	return nil
+}
+
+// Start 开始监听
+func (w *Watcher) Start() {
+	go w.run()
+	w.mu.Lock()
+	paths := make([]string, 0, len(w.files))
+	for path := range w.files {
+		paths = append(paths, path)
+	}
+	w.mu.Unlock()
+	if len(paths) > 0 {
+		logger.L().Infow(w.kind+"监听已启动", "paths", paths, "debounce", w.debounce)
+	}
+}
+
+// Stop 停止监听，等待正在进行的重新加载完成
+func (w *Watcher) Stop() {
+	close(w.done)
+	w.watcher.Close()
+	<-w.stopped
+}
+
+func (w *Watcher) run() {
+	defer close(w.stopped)
+
+	// 保存一次文件通常产生多个事件（截断、写入、重命名），最后一个事件之后 debounce 才重新加载
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+	for {
+		select {
+		case <-w.done:
+			timer.Stop()
+			return
+		case ev, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if w.relevantEvent(ev) {
+				timer.Reset(w.debounce)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.L().Warnw(w.kind+"监听出错", "error", err)
+		case <-timer.C:
+			logger.L().Infow("检测到" + w.kind + "变化，重新加载")
+			w.onChange()
+		}
+	}
+}
+
+// relevantEvent 事件是否可能改变监听文件的内容：监听的文件本身或所在目录中 Kubernetes 数据链接的写入、创建、重命名、删除
+func (w *Watcher) relevantEvent(ev fsnotify.Event) bool {
+	if !ev.Has(fsnotify.Write) && !ev.Has(fsnotify.Create) && !ev.Has(fsnotify.Rename) && !ev.Has(fsnotify.Remove) {
+		return false
+	}
+	name := filepath.Clean(ev.Name)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	dir, base := filepath.Split(name)
+	dir = filepath.Clean(dir)
+	if base == kubernetesDataLink {
+		return w.dirs[dir] || w.databasesDirs[dir]
+	}
+	return w.files[name] || (w.databasesDirs[dir] && isDatabasesFile(base))
+}
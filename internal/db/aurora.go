@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// AuroraMySQLDriver Amazon Aurora MySQL 驱动实现，协议与 MySQL 相同
+// 集群 writer 端点和 reader 端点背后的实例会随故障切换变化，每轮探测查询当前连接到的实例及其角色
+type AuroraMySQLDriver struct {
+	MySQLDriver
+}
+
+// QueryNodeRole 读取 @@aurora_server_id 和 @@innodb_read_only：Aurora 只有 writer 实例的 innodb_read_only 为 0
+func (d *AuroraMySQLDriver) QueryNodeRole(ctx context.Context, database *sql.DB) (role, instance string, err error) {
+	var readOnly int
+	if err := database.QueryRowContext(ctx, "SELECT @@aurora_server_id, @@innodb_read_only").Scan(&instance, &readOnly); err != nil {
+		return "", "", err
+	}
+	if readOnly != 0 {
+		return "reader", instance, nil
+	}
+	return "writer", instance, nil
+}
+
+// AuroraPostgresDriver Amazon Aurora PostgreSQL 驱动实现，使用 PostgreSQL 协议（lib/pq，注册为 postgres）
+type AuroraPostgresDriver struct{}
+
+func (d *AuroraPostgresDriver) DriverName() string {
+	return "postgres"
+}
+
+func (d *AuroraPostgresDriver) DefaultQuery() string {
+	return "SELECT 1"
+}
+
+// SafetySessionInit 只读事务，锁等待限制为 1 秒
+func (d *AuroraPostgresDriver) SafetySessionInit() []string {
+	return []string{
+		"SET default_transaction_read_only = on",
+		"SET lock_timeout = '1s'",
+	}
+}
+
+// QueryNodeRole 在 aurora_replica_status() 中查找当前实例（aurora_db_instance_identifier()）：
+// session_id 为 MASTER_SESSION_ID 的是 writer 实例，其余为 reader 实例
+func (d *AuroraPostgresDriver) QueryNodeRole(ctx context.Context, database *sql.DB) (role, instance string, err error) {
+	var writer bool
+	err = database.QueryRowContext(ctx,
+		"SELECT server_id, session_id = 'MASTER_SESSION_ID' FROM aurora_replica_status() "+
+			"WHERE server_id = aurora_db_instance_identifier()").Scan(&instance, &writer)
+	if err != nil {
+		return "", "", err
+	}
+	if writer {
+		return "writer", instance, nil
+	}
+	return "reader", instance, nil
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gocql/gocql"
+
+	"github.com/imkerbos/db-probe/internal/config"
+)
+
+// CassandraDriver Cassandra/ScyllaDB 探测驱动（CQL 原生协议）
+// Ping 阶段建立会话（连接 contact points 并发现集群拓扑），Query 阶段执行探测 CQL
+type CassandraDriver struct{}
+
+func (d *CassandraDriver) DriverName() string {
+	return "cassandra"
+}
+
+// DefaultQuery system.local 只包含当前协调节点的信息，不涉及其他副本，适合作为探测语句
+func (d *CassandraDriver) DefaultQuery() string {
+	return "SELECT now() FROM system.local"
+}
+
+// NewClient 创建 Cassandra 客户端
+// contact points 为 host 加上 contact_points 中的其他节点；配置了 datacenter 时只连接本地数据中心的节点
+func (d *CassandraDriver) NewClient(dbCfg *config.DBConfig) (Client, error) {
+	hosts := append([]string{dbCfg.Host}, dbCfg.ContactPoints...)
+	cluster := gocql.NewCluster(hosts...)
+	cluster.Port = dbCfg.Port
+	cluster.ConnectTimeout = 5 * time.Second
+	cluster.Timeout = 5 * time.Second
+	cluster.NumConns = 1
+	// 探测语句只读取协调节点本地的 system 表，一致性级别不影响结果，使用最低的 LOCAL_ONE
+	cluster.Consistency = gocql.LocalOne
+	if dbCfg.Datacenter != "" {
+		cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(gocql.DCAwareRoundRobinPolicy(dbCfg.Datacenter))
+	}
+	if dbCfg.User != "" {
+		cluster.Authenticator = gocql.PasswordAuthenticator{
+			Username: dbCfg.User,
+			Password: dbCfg.Password,
+		}
+	}
+	if dbCfg.TLS {
+		cluster.SslOpts = &gocql.SslOptions{
+			Config:                 &tls.Config{InsecureSkipVerify: dbCfg.TLSSkipVerify},
+			EnableHostVerification: !dbCfg.TLSSkipVerify,
+		}
+	}
+
+	query := dbCfg.Query
+	if query == "" {
+		query = d.DefaultQuery()
+	}
+	return &cassandraClient{cluster: cluster, query: query}, nil
+}
+
+// cassandraClient Cassandra 探测客户端
+// 会话在首次 Ping 时建立，之后由 gocql 维护连接池和节点重连；
+// 所有节点都不可用时关闭会话，下一轮探测在 Ping 阶段重新建立，连接失败计入 ping 而不是 query
+type cassandraClient struct {
+	cluster *gocql.ClusterConfig
+	query   string
+
+	mu      sync.Mutex
+	session *gocql.Session
+}
+
+// sessionResult 后台建立会话的结果
+type sessionResult struct {
+	session *gocql.Session
+	err     error
+}
+
+func (c *cassandraClient) Ping(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session != nil && !c.session.Closed() {
+		return nil
+	}
+
+	// CreateSession 不接受 context，在后台建立会话，超时后由后台 goroutine 负责关闭迟到的会话
+	done := make(chan sessionResult, 1)
+	go func() {
+		session, err := c.cluster.CreateSession()
+		done <- sessionResult{session: session, err: err}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return r.err
+		}
+		c.session = r.session
+		return nil
+	case <-ctx.Done():
+		go func() {
+			if r := <-done; r.session != nil {
+				r.session.Close()
+			}
+		}()
+		return ctx.Err()
+	}
+}
+
+func (c *cassandraClient) Query(ctx context.Context) error {
+	c.mu.Lock()
+	session := c.session
+	c.mu.Unlock()
+	if session == nil {
+		return gocql.ErrNoConnections
+	}
+
+	err := session.Query(c.query).WithContext(ctx).Exec()
+	if errors.Is(err, gocql.ErrNoConnections) {
+		c.mu.Lock()
+		if c.session == session {
+			c.session = nil
+		}
+		c.mu.Unlock()
+		session.Close()
+	}
+	return err
+}
+
+func (c *cassandraClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session != nil {
+		c.session.Close()
+		c.session = nil
+	}
+	return nil
+}
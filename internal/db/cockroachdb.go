@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// CockroachDBDriver CockroachDB 驱动实现，使用 PostgreSQL 协议（lib/pq，注册为 postgres）
+type CockroachDBDriver struct{}
+
+func (d *CockroachDBDriver) DriverName() string {
+	return "postgres"
+}
+
+func (d *CockroachDBDriver) DefaultQuery() string {
+	return "SELECT 1"
+}
+
+// SafetySessionInit 只读事务，锁等待限制为 1 秒（lock_timeout 需要 CockroachDB 21.2+）
+func (d *CockroachDBDriver) SafetySessionInit() []string {
+	return []string{
+		"SET default_transaction_read_only = on",
+		"SET lock_timeout = '1s'",
+	}
+}
+
+// ClusterChecker 支持查询集群节点存活情况的驱动
+type ClusterChecker interface {
+	// QueryClusterNodes 返回集群节点总数和存活节点数
+	QueryClusterNodes(ctx context.Context, database *sql.DB) (total, live int, err error)
+}
+
+// QueryClusterNodes 根据 crdb_internal.gossip_nodes 统计节点存活情况
+// 需要 admin 角色或 VIEWCLUSTERMETADATA 权限；已退役（decommissioned）的节点不计入
+func (d *CockroachDBDriver) QueryClusterNodes(ctx context.Context, database *sql.DB) (total, live int, err error) {
+	err = database.QueryRowContext(ctx,
+		"SELECT count(*), count(*) FILTER (WHERE is_live) FROM crdb_internal.gossip_nodes "+
+			"WHERE membership != 'decommissioned'").Scan(&total, &live)
+	return total, live, err
+}
@@ -0,0 +1,180 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+)
+
+// VersionQuerier 支持查询数据库服务端版本的驱动
+// 检查探测 SQL 的兼容性时一起记录版本，升级后探测 SQL 不兼容时可以对照版本定位
+type VersionQuerier interface {
+	// QueryVersion 返回服务端版本（如 8.0.36、PostgreSQL 15.4 ...）
+	QueryVersion(ctx context.Context, database *sql.DB) (string, error)
+}
+
+// QueryExplainer 可以只编译、不执行探测 SQL 的驱动
+// 不支持或探测 SQL 不能 EXPLAIN（如 SHOW 语句）时由 CheckQueryCompat 在回滚的事务中试执行
+type QueryExplainer interface {
+	// ExplainQuery 返回检查 query 使用的语句，不能 EXPLAIN 时返回空字符串
+	ExplainQuery(query string) string
+}
+
+// CheckQueryCompat 在 conn 上检查探测 SQL 能否被服务端解析和执行
+// 驱动支持 EXPLAIN 时只生成执行计划，否则在事务中执行后回滚；返回的错误来自服务端，说明探测 SQL 与当前版本不兼容
+func CheckQueryCompat(ctx context.Context, conn *sql.Conn, drv ProberDriver, query string) error {
+	if explainer, ok := drv.(QueryExplainer); ok {
+		if stmt := explainer.ExplainQuery(query); stmt != "" {
+			return drainQuery(ctx, conn, stmt)
+		}
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return drainQuery(ctx, tx, query)
+}
+
+// drainQuery 执行查询并读完所有结果行，部分数据库在读取结果时才报告错误
+func drainQuery(ctx context.Context, q interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}, query string) error {
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+// IsConnectionError 错误是否来自连接（超时、连接断开等），而不是服务端对语句的报错
+// 兼容性检查遇到连接错误时无法得出结论，下一轮探测再检查
+func IsConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) ||
+		errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.As(err, &netErr)
+}
+
+// explainable 探测 SQL 是否为可以 EXPLAIN 的查询（SELECT 或 WITH 开头）
+func explainable(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "WITH":
+		return true
+	}
+	return false
+}
+
+// ExplainQuery MySQL 协议的数据库（MySQL、TiDB、Galera、Aurora MySQL）用 EXPLAIN 检查查询
+func (d *MySQLDriver) ExplainQuery(query string) string {
+	if !explainable(query) {
+		return ""
+	}
+	return "EXPLAIN " + query
+}
+
+// ExplainQuery 同 MySQLDriver
+func (d *OceanBaseDriver) ExplainQuery(query string) string {
+	if !explainable(query) {
+		return ""
+	}
+	return "EXPLAIN " + query
+}
+
+// ExplainQuery 同 MySQLDriver
+func (d *DorisDriver) ExplainQuery(query string) string {
+	if !explainable(query) {
+		return ""
+	}
+	return "EXPLAIN " + query
+}
+
+// ExplainQuery PostgreSQL 协议的数据库用 EXPLAIN（不带 ANALYZE，不执行）检查查询
+func (d *AuroraPostgresDriver) ExplainQuery(query string) string {
+	if !explainable(query) {
+		return ""
+	}
+	return "EXPLAIN " + query
+}
+
+// ExplainQuery 同 AuroraPostgresDriver
+func (d *CockroachDBDriver) ExplainQuery(query string) string {
+	if !explainable(query) {
+		return ""
+	}
+	return "EXPLAIN " + query
+}
+
+// ExplainQuery 同 AuroraPostgresDriver
+func (d *KingbaseDriver) ExplainQuery(query string) string {
+	if !explainable(query) {
+		return ""
+	}
+	return "EXPLAIN " + query
+}
+
+// QueryVersion 读取 VERSION()（TiDB 为兼容的 MySQL 版本加 TiDB 版本，如 8.0.11-TiDB-v7.5.0）
+func (d *MySQLDriver) QueryVersion(ctx context.Context, database *sql.DB) (string, error) {
+	return queryString(ctx, database, "SELECT VERSION()")
+}
+
+// QueryVersion 同 MySQLDriver
+func (d *OceanBaseDriver) QueryVersion(ctx context.Context, database *sql.DB) (string, error) {
+	return queryString(ctx, database, "SELECT VERSION()")
+}
+
+// QueryVersion 同 MySQLDriver
+func (d *DorisDriver) QueryVersion(ctx context.Context, database *sql.DB) (string, error) {
+	return queryString(ctx, database, "SELECT VERSION()")
+}
+
+// QueryVersion 读取 version()
+func (d *AuroraPostgresDriver) QueryVersion(ctx context.Context, database *sql.DB) (string, error) {
+	return queryString(ctx, database, "SELECT version()")
+}
+
+// QueryVersion 同 AuroraPostgresDriver
+func (d *CockroachDBDriver) QueryVersion(ctx context.Context, database *sql.DB) (string, error) {
+	return queryString(ctx, database, "SELECT version()")
+}
+
+// QueryVersion 同 AuroraPostgresDriver
+func (d *KingbaseDriver) QueryVersion(ctx context.Context, database *sql.DB) (string, error) {
+	return queryString(ctx, database, "SELECT version()")
+}
+
+// QueryVersion 读取 product_component_version（所有用户都有查询权限，不需要 v$version 的权限）
+func (d *OracleDriver) QueryVersion(ctx context.Context, database *sql.DB) (string, error) {
+	return queryString(ctx, database, "SELECT version FROM product_component_version WHERE product LIKE 'Oracle%' AND ROWNUM = 1")
+}
+
+// QueryVersion 读取 SERVERPROPERTY('ProductVersion')（如 16.0.1000.6）
+func (d *MSSQLDriver) QueryVersion(ctx context.Context, database *sql.DB) (string, error) {
+	return queryString(ctx, database, "SELECT CAST(SERVERPROPERTY('ProductVersion') AS NVARCHAR(128))")
+}
+
+// QueryVersion 读取 CURRENT_VERSION()
+func (d *SnowflakeDriver) QueryVersion(ctx context.Context, database *sql.DB) (string, error) {
+	return queryString(ctx, database, "SELECT CURRENT_VERSION()")
+}
+
+// QueryVersion 读取 sqlite_version()（编译进探针的 SQLite 库版本）
+func (d *SQLiteDriver) QueryVersion(ctx context.Context, database *sql.DB) (string, error) {
+	return queryString(ctx, database, "SELECT sqlite_version()")
+}
+
+// queryString 执行返回单个字符串的查询
+func queryString(ctx context.Context, database *sql.DB, query string) (string, error) {
+	var value string
+	err := database.QueryRowContext(ctx, query).Scan(&value)
+	return value, err
+}
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net"
+	"sync/atomic"
+)
+
+// Connector 包装底层驱动的 driver.Connector
+// 统计新建物理连接的次数：prober 通过前后两次计数判断一次探测是复用了连接池中的连接还是新建了连接
+// 每条新建的物理连接上依次执行会话初始化语句（session_init），任意一条失败则关闭该连接并返回错误
+type Connector struct {
+	base        driver.Connector
+	dials       atomic.Uint64          // 新建物理连接的尝试次数（含失败）
+	initStmts   atomic.Uint64          // 已执行的会话初始化语句数（含失败）
+	peer        atomic.Pointer[string] // 最近一条新建物理连接的对端 IP（仅通过 DialPeer 拨号的连接）
+	driver      driver.Driver
+	sessionInit []string
+}
+
+// NewConnector 根据已注册的驱动名称和 DSN 创建 Connector，用于 sql.OpenDB
+// sessionInit 为每条新建物理连接上执行的初始化语句，可以为空
+func NewConnector(driverName, dsn string, sessionInit []string) (*Connector, error) {
+	// sql.Open 不会建立连接，这里只用来按名称取到已注册的驱动
+	database, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	drv := database.Driver()
+	database.Close()
+
+	var base driver.Connector
+	if dc, ok := drv.(driver.DriverContext); ok {
+		if base, err = dc.OpenConnector(dsn); err != nil {
+			return nil, err
+		}
+	} else {
+		base = dsnConnector{dsn: dsn, driver: drv}
+	}
+	return &Connector{base: base, driver: drv, sessionInit: sessionInit}, nil
+}
+
+// Connect 新建一条物理连接并执行会话初始化语句
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.dials.Add(1)
+	// 拨号函数（DialPeer）通过 context 找到 Connector 并记录对端地址
+	ctx = context.WithValue(ctx, peerKey{}, c)
+	conn, err := c.base.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, stmt := range c.sessionInit {
+		c.initStmts.Add(1)
+		if err := execContext(ctx, conn, stmt); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("执行会话初始化语句失败 (%s): %w", stmt, err)
+		}
+	}
+	return conn, nil
+}
+
+// execContext 在原始驱动连接上执行一条不带参数的语句
+// 优先使用 ExecerContext，驱动不支持时退回到 Prepare + Exec
+func execContext(ctx context.Context, conn driver.Conn, stmt string) error {
+	if execer, ok := conn.(driver.ExecerContext); ok {
+		_, err := execer.ExecContext(ctx, stmt, nil)
+		if err != driver.ErrSkip {
+			return err
+		}
+	}
+
+	var st driver.Stmt
+	var err error
+	if preparer, ok := conn.(driver.ConnPrepareContext); ok {
+		st, err = preparer.PrepareContext(ctx, stmt)
+	} else {
+		st, err = conn.Prepare(stmt)
+	}
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	if execer, ok := st.(driver.StmtExecContext); ok {
+		_, err = execer.ExecContext(ctx, nil)
+		return err
+	}
+	// 驱动未实现 StmtExecContext 时的兼容路径
+	_, err = st.Exec(nil)
+	return err
+}
+
+// Clone 返回使用相同底层连接器和会话初始化语句、但计数独立的 Connector
+// 用于为同一目标创建额外的连接池（如可选检查的独立连接池），不影响原连接池的连接复用判断
+func (c *Connector) Clone() *Connector {
+	return &Connector{base: c.base, driver: c.driver, sessionInit: c.sessionInit}
+}
+
+// Driver 返回底层驱动
+func (c *Connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// Dials 返回累计新建物理连接的尝试次数
+func (c *Connector) Dials() uint64 {
+	return c.dials.Load()
+}
+
+// SessionInitStatements 返回累计执行的会话初始化语句数，用于统计探测对数据库的开销
+func (c *Connector) SessionInitStatements() uint64 {
+	return c.initStmts.Load()
+}
+
+// Peer 返回最近一条新建物理连接实际连接的对端 IP，未通过 DialPeer 拨号或尚未建立连接时返回空字符串
+func (c *Connector) Peer() string {
+	if peer := c.peer.Load(); peer != nil {
+		return *peer
+	}
+	return ""
+}
+
+// setPeer 记录新建物理连接的对端地址
+func (c *Connector) setPeer(addr net.Addr) {
+	host := addr.String()
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	c.peer.Store(&host)
+}
+
+// dsnConnector 未实现 driver.DriverContext 的驱动的 Connector，每次连接都通过 Open(dsn) 建立
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
@@ -0,0 +1,35 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// DB2Driver IBM DB2 LUW 驱动实现
+// 驱动（github.com/ibmdb/go_ibm_db，注册为 go_ibm_db）依赖 cgo 和 IBM CLI Driver，需要使用 -tags db2 构建
+type DB2Driver struct{}
+
+func (d *DB2Driver) DriverName() string {
+	return "go_ibm_db"
+}
+
+func (d *DB2Driver) DefaultQuery() string {
+	return "SELECT 1 FROM SYSIBM.SYSDUMMY1"
+}
+
+// SafetySessionInit DB2 没有会话级只读设置，只把锁等待限制为 1 秒（默认 -1 表示无限等待）
+func (d *DB2Driver) SafetySessionInit() []string {
+	return []string{
+		"SET CURRENT LOCK TIMEOUT 1",
+	}
+}
+
+// QueryUptime 根据 MON_GET_INSTANCE 的 DB2START_TIME 计算运行时长（需要该表函数的 EXECUTE 权限，如 SQLADM、DBADM）
+func (d *DB2Driver) QueryUptime(ctx context.Context, database *sql.DB) (float64, error) {
+	var uptime float64
+	err := database.QueryRowContext(ctx,
+		"SELECT (DAYS(CURRENT TIMESTAMP) - DAYS(DB2START_TIME)) * 86400 + "+
+			"MIDNIGHT_SECONDS(CURRENT TIMESTAMP) - MIDNIGHT_SECONDS(DB2START_TIME) "+
+			"FROM TABLE(MON_GET_INSTANCE(-1))").Scan(&uptime)
+	return uptime, err
+}
@@ -0,0 +1,25 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// DMDriver 达梦（DM）驱动实现
+// 驱动（gitee.com/chunanyong/dm，注册为 dm）不在默认构建中，需要使用 -tags dm 构建
+type DMDriver struct{}
+
+func (d *DMDriver) DriverName() string {
+	return "dm"
+}
+
+func (d *DMDriver) DefaultQuery() string {
+	return "SELECT 1 FROM DUAL"
+}
+
+// QueryUptime 根据 V$INSTANCE.START_TIME 计算运行时长（需要动态视图的查询权限）
+func (d *DMDriver) QueryUptime(ctx context.Context, database *sql.DB) (float64, error) {
+	var uptime float64
+	err := database.QueryRowContext(ctx, "SELECT DATEDIFF(SS, START_TIME, SYSDATE) FROM V$INSTANCE").Scan(&uptime)
+	return uptime, err
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// DorisDriver Apache Doris / StarRocks FE 驱动实现，使用 MySQL 协议（FE 的 query_port，默认 9030）
+// 不复用 MySQLDriver 的会话安全设置和运行时长查询：FE 不支持 innodb_lock_wait_timeout 等 InnoDB 变量，
+// 也没有 SHOW GLOBAL STATUS LIKE 'Uptime'
+type DorisDriver struct{}
+
+func (d *DorisDriver) DriverName() string {
+	return "mysql"
+}
+
+// DefaultQuery 由 FE 直接计算，不下发到 BE，BE 的存活情况通过 cluster_check 检查
+func (d *DorisDriver) DefaultQuery() string {
+	return "SELECT 1"
+}
+
+// DorisChecker 支持查询 FE、BE 节点存活情况的驱动
+type DorisChecker interface {
+	// QueryDorisNodes 返回 FE、BE 的节点数和存活节点数
+	QueryDorisNodes(ctx context.Context, database *sql.DB) (DorisNodes, error)
+}
+
+// DorisNodes FE、BE 的节点数和存活节点数
+type DorisNodes struct {
+	Frontends      int
+	AliveFrontends int
+	Backends       int
+	AliveBackends  int
+}
+
+// QueryDorisNodes 根据 SHOW FRONTENDS、SHOW BACKENDS 的 Alive 列统计节点存活情况
+// 需要 ADMIN 或 NODE 权限（StarRocks 需要 SYSTEM 级 OPERATE 权限）
+func (d *DorisDriver) QueryDorisNodes(ctx context.Context, database *sql.DB) (DorisNodes, error) {
+	var nodes DorisNodes
+	var err error
+	nodes.Frontends, nodes.AliveFrontends, err = countAlive(ctx, database, "SHOW FRONTENDS")
+	if err != nil {
+		return nodes, err
+	}
+	nodes.Backends, nodes.AliveBackends, err = countAlive(ctx, database, "SHOW BACKENDS")
+	return nodes, err
+}
+
+// countAlive 执行 SHOW FRONTENDS/BACKENDS 并按 Alive 列统计节点数
+// 各版本返回的列数不同，按列名定位 Alive 列
+func countAlive(ctx context.Context, database *sql.DB, query string) (total, alive int, err error) {
+	rows, err := database.QueryContext(ctx, query)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, 0, err
+	}
+	aliveIndex := -1
+	for i, column := range columns {
+		if strings.EqualFold(column, "Alive") {
+			aliveIndex = i
+			break
+		}
+	}
+	if aliveIndex < 0 {
+		return 0, 0, fmt.Errorf("doris: %s 的结果中没有 Alive 列", query)
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return 0, 0, err
+		}
+		total++
+		if strings.EqualFold(string(values[aliveIndex]), "true") {
+			alive++
+		}
+	}
+	return total, alive, rows.Err()
+}
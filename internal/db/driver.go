@@ -1,10 +1,15 @@
 // Package db 提供数据库驱动抽象层
-// 定义了统一的数据库驱动接口，支持 MySQL、TiDB 和 Oracle
+// 定义了统一的数据库驱动接口，支持 MySQL、TiDB、MariaDB Galera、OceanBase、Apache Doris/StarRocks、Oracle、达梦（DM）、人大金仓（KingbaseES）、IBM DB2、SQL Server、CockroachDB、Snowflake、Amazon Aurora（MySQL/PostgreSQL）、SQLite、Redis、MongoDB、Cassandra/ScyllaDB、Elasticsearch/OpenSearch、Trino/Presto 以及纯 TCP 端口探测
 // 每种数据库类型都有对应的驱动实现，提供驱动名称和默认探测 SQL
+// 不基于 database/sql 的类型通过 ClientDriver 提供自己的探测客户端
 package db
 
 import (
+	"context"
 	"fmt"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
 )
 
 // ProberDriver 数据库驱动接口
@@ -15,6 +20,60 @@ type ProberDriver interface {
 	DefaultQuery() string
 }
 
+// Client 不基于 database/sql 的探测客户端
+// 与 SQL 目标一样分为两步：Ping 检查连通性，Query 执行探测命令
+type Client interface {
+	// Ping 建立连接并检查连通性
+	Ping(ctx context.Context) error
+	// Query 执行探测命令
+	Query(ctx context.Context) error
+	// Close 释放客户端持有的连接
+	Close() error
+}
+
+// RoleReporter 能够识别节点角色的探测客户端（如 MongoDB 的 primary/secondary）
+// 目标未在 labels 中配置 role 时，prober 使用识别出的角色作为 role label
+type RoleReporter interface {
+	// Role 返回最近一次 Ping 识别出的角色，尚未识别时返回空字符串
+	Role() string
+}
+
+// HealthReporter 能够读取集群健康状态的探测客户端（如 Elasticsearch 的 green/yellow/red）
+// 降级状态（yellow）下探测仍然成功，prober 通过 db_probe_cluster_health 导出状态并在状态变化时输出日志
+type HealthReporter interface {
+	// Health 返回最近一次 Query 读取到的健康状态，尚未读取时返回空字符串
+	Health() string
+}
+
+// QueryPhaseReporter 能够区分查询排队时间和执行时间的探测客户端（如 Trino 查询的 stats）
+// prober 通过 db_probe_query_queued_seconds、db_probe_query_execution_seconds 导出，用于区分过载和不可用
+type QueryPhaseReporter interface {
+	// QueryPhases 返回最近一次 Query 的排队时间和执行时间，尚未读取到时 ok 为 false
+	QueryPhases() (queued, execution time.Duration, ok bool)
+}
+
+// SessionGuard 提供探测会话安全设置的驱动
+// 返回的语句在每条新建物理连接上、用户配置的 session_init 之前执行，
+// 确保探测会话只读并且遇到锁等待时快速失败，而不是卡在 DDL 或长事务后面
+type SessionGuard interface {
+	// SafetySessionInit 返回会话安全设置语句
+	SafetySessionInit() []string
+}
+
+// ResultChecker 需要检查探测 SQL 结果的驱动（如 SQLite 的 PRAGMA integrity_check 返回 ok 才算通过）
+// 查询成功但结果表示异常时返回错误，本轮探测按查询失败处理
+type ResultChecker interface {
+	// CheckQueryResult 检查探测 SQL 返回的第一列，query 为实际执行的探测 SQL
+	CheckQueryResult(query string, result interface{}) error
+}
+
+// ClientDriver 自行创建探测客户端的驱动（如 tcp、redis、mongodb、cassandra、elasticsearch、trino），prober 不再使用 sql.Open
+type ClientDriver interface {
+	ProberDriver
+	// NewClient 根据目标配置创建探测客户端
+	NewClient(dbCfg *config.DBConfig) (Client, error)
+}
+
 // MySQLDriver MySQL/TiDB 驱动实现
 type MySQLDriver struct{}
 
@@ -26,6 +85,27 @@ func (d *MySQLDriver) DefaultQuery() string {
 	return "SELECT 1"
 }
 
+// SafetySessionInit 只读事务，行锁和元数据锁（DDL）等待都限制为 1 秒
+func (d *MySQLDriver) SafetySessionInit() []string {
+	return []string{
+		"SET SESSION TRANSACTION READ ONLY",
+		"SET SESSION innodb_lock_wait_timeout = 1",
+		"SET SESSION lock_wait_timeout = 1",
+	}
+}
+
+// TiDBDriver TiDB 驱动实现，协议与 MySQL 相同，只有会话安全设置不同
+type TiDBDriver struct {
+	MySQLDriver
+}
+
+// SafetySessionInit TiDB 的只读事务是 noop 实现，默认配置下设置会报错，因此只限制锁等待
+func (d *TiDBDriver) SafetySessionInit() []string {
+	return []string{
+		"SET SESSION innodb_lock_wait_timeout = 1",
+	}
+}
+
 // OracleDriver Oracle 驱动实现
 type OracleDriver struct{}
 
@@ -37,15 +117,78 @@ func (d *OracleDriver) DefaultQuery() string {
 	return "SELECT 1 FROM dual"
 }
 
+// SafetySessionInit Oracle 没有会话级只读设置，只限制 DDL 锁等待时间
+func (d *OracleDriver) SafetySessionInit() []string {
+	return []string{
+		"ALTER SESSION SET DDL_LOCK_TIMEOUT = 1",
+	}
+}
+
+// MSSQLDriver SQL Server 驱动实现
+type MSSQLDriver struct{}
+
+func (d *MSSQLDriver) DriverName() string {
+	return "sqlserver"
+}
+
+func (d *MSSQLDriver) DefaultQuery() string {
+	return "SELECT 1"
+}
+
+// SafetySessionInit 锁等待超时 1 秒（默认 -1 表示无限等待）
+func (d *MSSQLDriver) SafetySessionInit() []string {
+	return []string{
+		"SET LOCK_TIMEOUT 1000",
+	}
+}
+
 // GetDriver 根据数据库类型获取驱动
 func GetDriver(dbType string) (ProberDriver, error) {
 	switch dbType {
-	case "mysql", "tidb":
+	case "mysql":
 		return &MySQLDriver{}, nil
+	case "tidb":
+		return &TiDBDriver{}, nil
+	case "mariadb-galera":
+		return &GaleraDriver{}, nil
+	case "oceanbase":
+		return &OceanBaseDriver{}, nil
+	case "doris":
+		return &DorisDriver{}, nil
 	case "oracle":
 		return &OracleDriver{}, nil
+	case "dm":
+		return &DMDriver{}, nil
+	case "snowflake":
+		return &SnowflakeDriver{}, nil
+	case "kingbase":
+		return &KingbaseDriver{}, nil
+	case "db2":
+		return &DB2Driver{}, nil
+	case "mssql":
+		return &MSSQLDriver{}, nil
+	case "cockroachdb":
+		return &CockroachDBDriver{}, nil
+	case "redis":
+		return &RedisDriver{}, nil
+	case "mongodb":
+		return &MongoDBDriver{}, nil
+	case "cassandra":
+		return &CassandraDriver{}, nil
+	case "elasticsearch":
+		return &ElasticsearchDriver{}, nil
+	case "trino":
+		return &TrinoDriver{}, nil
+	case "aurora-mysql":
+		return &AuroraMySQLDriver{}, nil
+	case "aurora-postgres":
+		return &AuroraPostgresDriver{}, nil
+	case "sqlite":
+		return &SQLiteDriver{}, nil
+	case "tcp":
+		return &TCPDriver{}, nil
 	default:
-		return nil, fmt.Errorf("不支持的数据库类型: %s (支持的类型: mysql, tidb, oracle)", dbType)
+		return nil, fmt.Errorf("不支持的数据库类型: %s (支持的类型: mysql, tidb, mariadb-galera, oceanbase, doris, oracle, dm, kingbase, db2, mssql, cockroachdb, snowflake, aurora-mysql, aurora-postgres, sqlite, redis, mongodb, cassandra, elasticsearch, trino, tcp)", dbType)
 	}
 }
 
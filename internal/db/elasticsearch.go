@@ -0,0 +1,158 @@
+package db
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/imkerbos/db-probe/internal/config"
+)
+
+// ElasticsearchDriver Elasticsearch/OpenSearch 探测驱动（REST API）
+// Ping 阶段请求根路径（GET /）检查连通性和认证，Query 阶段请求 _cluster/health 读取集群健康状态
+type ElasticsearchDriver struct{}
+
+func (d *ElasticsearchDriver) DriverName() string {
+	return "elasticsearch"
+}
+
+// DefaultQuery Elasticsearch 的探测语句为 Query 阶段请求的 API 路径
+func (d *ElasticsearchDriver) DefaultQuery() string {
+	return "/_cluster/health"
+}
+
+// NewClient 创建 Elasticsearch 客户端
+// 配置了 dsn 时作为集群地址（如 https://es.example.com:9200），否则使用 host:port，开启 tls 时为 https
+// 认证方式为 user/password（Basic）或 api_key（ApiKey），两者只能配置一个
+func (d *ElasticsearchDriver) NewClient(dbCfg *config.DBConfig) (Client, error) {
+	baseURL := strings.TrimRight(dbCfg.DSN, "/")
+	if baseURL == "" {
+		scheme := "http"
+		if dbCfg.TLS {
+			scheme = "https"
+		}
+		baseURL = scheme + "://" + net.JoinHostPort(dbCfg.Host, strconv.Itoa(dbCfg.Port))
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: dbCfg.TLSSkipVerify}
+	// 探测请求串行执行，保留一条空闲连接即可复用
+	transport.MaxIdleConnsPerHost = 1
+
+	path := dbCfg.Query
+	if path == "" {
+		path = d.DefaultQuery()
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return &elasticsearchClient{
+		http:     &http.Client{Transport: transport},
+		baseURL:  baseURL,
+		path:     path,
+		user:     dbCfg.User,
+		password: dbCfg.Password,
+		apiKey:   dbCfg.APIKey,
+	}, nil
+}
+
+// elasticsearchClient Elasticsearch 探测客户端，超时由探测的 context 控制
+type elasticsearchClient struct {
+	http     *http.Client
+	baseURL  string
+	path     string
+	user     string
+	password string
+	apiKey   string
+
+	mu     sync.RWMutex
+	health string // 最近一次 Query 读取到的集群健康状态
+}
+
+// clusterHealth _cluster/health 返回中用到的字段
+type clusterHealth struct {
+	ClusterName         string  `json:"cluster_name"`
+	Status              string  `json:"status"`
+	NumberOfNodes       int     `json:"number_of_nodes"`
+	UnassignedShards    int     `json:"unassigned_shards"`
+	ActiveShardsPercent float64 `json:"active_shards_percent_as_number"`
+}
+
+func (c *elasticsearchClient) Ping(ctx context.Context) error {
+	_, err := c.get(ctx, "/")
+	return err
+}
+
+// Query 请求探测路径；返回中包含 status 字段时记录集群健康状态，red 视为探测失败
+func (c *elasticsearchClient) Query(ctx context.Context) error {
+	body, err := c.get(ctx, c.path)
+	if err != nil {
+		return err
+	}
+	var health clusterHealth
+	if json.Unmarshal(body, &health) != nil || health.Status == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	c.health = health.Status
+	c.mu.Unlock()
+	if health.Status == "red" {
+		return fmt.Errorf("elasticsearch: 集群状态为 red (cluster_name=%s, number_of_nodes=%d, unassigned_shards=%d, active_shards_percent=%.1f)",
+			health.ClusterName, health.NumberOfNodes, health.UnassignedShards, health.ActiveShardsPercent)
+	}
+	return nil
+}
+
+func (c *elasticsearchClient) Close() error {
+	c.http.CloseIdleConnections()
+	return nil
+}
+
+// Health 返回最近一次 Query 读取到的集群健康状态（green、yellow、red）
+func (c *elasticsearchClient) Health() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.health
+}
+
+// get 发送 GET 请求，非 2xx 响应返回带状态码和响应片段的错误
+func (c *elasticsearchClient) get(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "db-probe")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+c.apiKey)
+	} else if c.user != "" {
+		req.SetBasicAuth(c.user, c.password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// 探测只需要很小的响应，限制读取大小，避免自定义路径返回大量数据
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		msg := strings.TrimSpace(string(body))
+		if len(msg) > 256 {
+			msg = msg[:256]
+		}
+		return nil, fmt.Errorf("elasticsearch: GET %s 返回 HTTP %d: %s", path, resp.StatusCode, msg)
+	}
+	return body, nil
+}
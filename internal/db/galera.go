@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// GaleraDriver MariaDB Galera 集群节点驱动，协议与 MySQL 相同
+// 节点处于非 Primary 分区时仍能响应 SELECT 1，因此探测 SQL 之后还要检查 wsrep 状态
+type GaleraDriver struct {
+	MySQLDriver
+}
+
+// GaleraChecker 支持检查 Galera 集群状态的驱动
+type GaleraChecker interface {
+	// QueryGaleraStatus 读取节点的 wsrep 状态
+	QueryGaleraStatus(ctx context.Context, database *sql.DB) (GaleraStatus, error)
+}
+
+// GaleraStatus 节点的 wsrep 状态
+type GaleraStatus struct {
+	ClusterStatus     string // wsrep_cluster_status：Primary、non-Primary、Disconnected
+	Ready             bool   // wsrep_ready：节点是否接受查询
+	ClusterSize       int    // wsrep_cluster_size：当前组件中的节点数
+	LocalState        int    // wsrep_local_state：1=Joining 2=Donor/Desynced 3=Joined 4=Synced
+	LocalStateComment string // wsrep_local_state_comment
+}
+
+// Healthy 节点处于 Primary 组件并且已就绪
+func (s *GaleraStatus) Healthy() bool {
+	return s.ClusterStatus == "Primary" && s.Ready
+}
+
+// Err 节点不健康时返回描述 wsrep 状态的错误
+func (s *GaleraStatus) Err() error {
+	if s.Healthy() {
+		return nil
+	}
+	ready := "OFF"
+	if s.Ready {
+		ready = "ON"
+	}
+	return fmt.Errorf("galera: 节点不可用 (wsrep_cluster_status=%s, wsrep_ready=%s, wsrep_local_state_comment=%s)",
+		s.ClusterStatus, ready, s.LocalStateComment)
+}
+
+// QueryGaleraStatus 读取 SHOW GLOBAL STATUS 中的 wsrep 状态
+func (d *GaleraDriver) QueryGaleraStatus(ctx context.Context, database *sql.DB) (GaleraStatus, error) {
+	var status GaleraStatus
+	rows, err := database.QueryContext(ctx, "SHOW GLOBAL STATUS WHERE Variable_name IN "+
+		"('wsrep_cluster_status', 'wsrep_ready', 'wsrep_cluster_size', 'wsrep_local_state', 'wsrep_local_state_comment')")
+	if err != nil {
+		return status, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return status, err
+		}
+		switch name {
+		case "wsrep_cluster_status":
+			status.ClusterStatus = value
+		case "wsrep_ready":
+			status.Ready = value == "ON"
+		case "wsrep_cluster_size":
+			status.ClusterSize, _ = strconv.Atoi(value)
+		case "wsrep_local_state":
+			status.LocalState, _ = strconv.Atoi(value)
+		case "wsrep_local_state_comment":
+			status.LocalStateComment = value
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return status, err
+	}
+	if status.ClusterStatus == "" {
+		return status, fmt.Errorf("galera: 未读取到 wsrep 状态，节点可能未启用 Galera 复制")
+	}
+	return status, nil
+}
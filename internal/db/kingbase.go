@@ -0,0 +1,21 @@
+package db
+
+// KingbaseDriver 人大金仓（KingbaseES）驱动实现
+// KingbaseES V8R6 起兼容 PostgreSQL 客户端协议，使用 lib/pq（注册为 postgres）连接，无需厂商驱动
+type KingbaseDriver struct{}
+
+func (d *KingbaseDriver) DriverName() string {
+	return "postgres"
+}
+
+func (d *KingbaseDriver) DefaultQuery() string {
+	return "SELECT 1"
+}
+
+// SafetySessionInit 只读事务，锁等待限制为 1 秒
+func (d *KingbaseDriver) SafetySessionInit() []string {
+	return []string{
+		"SET default_transaction_read_only = on",
+		"SET lock_timeout = '1s'",
+	}
+}
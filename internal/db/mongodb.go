@@ -0,0 +1,134 @@
+package db
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"github.com/imkerbos/db-probe/internal/config"
+)
+
+// MongoDBDriver MongoDB 探测驱动
+// Ping 阶段执行 hello 命令并识别节点角色，Query 阶段执行探测命令（默认 ping）
+type MongoDBDriver struct{}
+
+func (d *MongoDBDriver) DriverName() string {
+	return "mongodb"
+}
+
+// DefaultQuery MongoDB 的探测命令为命令名，在 admin 库上以 {<命令>: 1} 执行
+func (d *MongoDBDriver) DefaultQuery() string {
+	return "ping"
+}
+
+// NewClient 创建 MongoDB 客户端
+// 配置了 dsn 时直接使用连接串（可以是副本集 URI），否则直连 host:port 对应的单个节点
+func (d *MongoDBDriver) NewClient(dbCfg *config.DBConfig) (Client, error) {
+	opts := options.Client().
+		SetAppName("db-probe").
+		SetConnectTimeout(5 * time.Second).
+		SetMaxPoolSize(2)
+	if dbCfg.DSN != "" {
+		opts.ApplyURI(dbCfg.DSN)
+	} else {
+		opts.SetHosts([]string{net.JoinHostPort(dbCfg.Host, strconv.Itoa(dbCfg.Port))}).
+			SetDirect(true)
+		if dbCfg.User != "" {
+			opts.SetAuth(options.Credential{
+				AuthSource: "admin",
+				Username:   dbCfg.User,
+				Password:   dbCfg.Password,
+			})
+		}
+		if dbCfg.TLS {
+			opts.SetTLSConfig(&tls.Config{
+				ServerName:         dbCfg.Host,
+				InsecureSkipVerify: dbCfg.TLSSkipVerify,
+			})
+		}
+	}
+
+	// Connect 只校验参数、启动后台拓扑监控，不会阻塞等待连接建立
+	client, err := mongo.Connect(opts)
+	if err != nil {
+		return nil, fmt.Errorf("创建 MongoDB 客户端失败: %w", err)
+	}
+	query := dbCfg.Query
+	if query == "" {
+		query = d.DefaultQuery()
+	}
+	return &mongoClient{client: client, command: query}, nil
+}
+
+// mongoClient MongoDB 探测客户端，连接池和重连由官方驱动管理
+type mongoClient struct {
+	client  *mongo.Client
+	command string
+
+	mu   sync.RWMutex
+	role string // 最近一次 hello 识别出的节点角色
+}
+
+// helloReply hello 命令返回中与角色相关的字段
+type helloReply struct {
+	IsWritablePrimary bool   `bson:"isWritablePrimary"`
+	Secondary         bool   `bson:"secondary"`
+	ArbiterOnly       bool   `bson:"arbiterOnly"`
+	SetName           string `bson:"setName"`
+	Msg               string `bson:"msg"`
+}
+
+// role 根据 hello 返回识别节点角色：primary、secondary、arbiter、mongos、standalone
+func (r *helloReply) role() string {
+	switch {
+	case r.Msg == "isdbgrid":
+		return "mongos"
+	case r.SetName == "":
+		return "standalone"
+	case r.IsWritablePrimary:
+		return "primary"
+	case r.Secondary:
+		return "secondary"
+	case r.ArbiterOnly:
+		return "arbiter"
+	default:
+		return ""
+	}
+}
+
+func (c *mongoClient) Ping(ctx context.Context) error {
+	var reply helloReply
+	err := c.client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&reply)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.role = reply.role()
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *mongoClient) Query(ctx context.Context) error {
+	return c.client.Database("admin").RunCommand(ctx, bson.D{{Key: c.command, Value: 1}}).Err()
+}
+
+func (c *mongoClient) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return c.client.Disconnect(ctx)
+}
+
+// Role 返回最近一次 Ping 识别出的节点角色，尚未识别时返回空字符串
+func (c *mongoClient) Role() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.role
+}
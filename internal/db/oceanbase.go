@@ -0,0 +1,23 @@
+package db
+
+// OceanBaseDriver OceanBase（MySQL 模式）驱动实现，使用 MySQL 协议
+// 不复用 MySQLDriver 的运行时长查询：OceanBase 不支持 SHOW GLOBAL STATUS LIKE 'Uptime'
+type OceanBaseDriver struct{}
+
+func (d *OceanBaseDriver) DriverName() string {
+	return "mysql"
+}
+
+// DefaultQuery 经过租户的 SQL 引擎执行，租户不可用（如内存超限、无主）时会失败
+func (d *OceanBaseDriver) DefaultQuery() string {
+	return "SELECT 1 FROM DUAL"
+}
+
+// SafetySessionInit 只读事务，行锁等待限制为 1 秒（ob_trx_lock_timeout 单位为微秒）
+// OceanBase 接受但忽略 innodb_lock_wait_timeout，因此使用 OceanBase 自己的系统变量
+func (d *OceanBaseDriver) SafetySessionInit() []string {
+	return []string{
+		"SET SESSION TRANSACTION READ ONLY",
+		"SET SESSION ob_trx_lock_timeout = 1000000",
+	}
+}
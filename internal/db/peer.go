@@ -0,0 +1,33 @@
+package db
+
+import (
+	"context"
+	"net"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// PeerNetwork 开启 peer_check 的 MySQL 协议目标在 DSN 中使用的网络名称（如 user:pass@db-probe-tcp(host:3306)/）
+// 与 tcp 相同，但拨号时记录实际连接的对端地址，用于和 DNS 当前的解析结果比较
+const PeerNetwork = "db-probe-tcp"
+
+// peerKey Connector.Connect 放入 context 的 Connector
+type peerKey struct{}
+
+func init() {
+	mysql.RegisterDialContext(PeerNetwork, DialPeer)
+}
+
+// DialPeer 建立 TCP 连接，并把对端地址记录到发起连接的 Connector 上（见 Connector.Peer）
+// 由驱动在 Connector.Connect 传入的 context 下调用，不是经 Connector 发起的连接不记录
+func DialPeer(ctx context.Context, addr string) (net.Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := ctx.Value(peerKey{}).(*Connector); ok {
+		c.setPeer(conn.RemoteAddr())
+	}
+	return conn, nil
+}
@@ -0,0 +1,221 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+)
+
+// RedisDriver Redis 探测驱动
+// 使用精简的 RESP 客户端：Ping 阶段建立连接、AUTH 并发送 PING，Query 阶段执行可选的探测命令
+type RedisDriver struct{}
+
+func (d *RedisDriver) DriverName() string {
+	return "redis"
+}
+
+// DefaultQuery Redis 默认只执行 PING，可通过 query 配置额外的探测命令（如 GET __probe__）
+func (d *RedisDriver) DefaultQuery() string {
+	return ""
+}
+
+func (d *RedisDriver) NewClient(dbCfg *config.DBConfig) (Client, error) {
+	c := &redisClient{
+		addr:     net.JoinHostPort(dbCfg.Host, strconv.Itoa(dbCfg.Port)),
+		user:     dbCfg.User,
+		password: dbCfg.Password,
+		command:  strings.Fields(dbCfg.Query),
+	}
+	if dbCfg.TLS {
+		c.tlsConfig = &tls.Config{
+			ServerName:         dbCfg.Host,
+			InsecureSkipVerify: dbCfg.TLSSkipVerify,
+		}
+	}
+	return c, nil
+}
+
+// redisClient Redis 探测客户端
+// 与 SQL 连接池一样复用同一条连接，连接出错后关闭，下次 Ping 重新建立连接并认证
+type redisClient struct {
+	addr      string
+	tlsConfig *tls.Config
+	user      string
+	password  string
+	command   []string // 可选的探测命令，为空时 Query 不做任何操作
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+func (c *redisClient) Ping(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		if err := c.connect(ctx); err != nil {
+			return err
+		}
+	}
+	if _, err := c.do(ctx, "PING"); err != nil {
+		c.reset()
+		return err
+	}
+	return nil
+}
+
+func (c *redisClient) Query(ctx context.Context) error {
+	if len(c.command) == 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return fmt.Errorf("执行探测命令失败: 连接未建立")
+	}
+	if _, err := c.do(ctx, c.command...); err != nil {
+		var replyErr redisError
+		if !errors.As(err, &replyErr) {
+			// 网络错误后连接状态未知，下次重新建立
+			c.reset()
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *redisClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		err := c.conn.Close()
+		c.conn = nil
+		c.rd = nil
+		return err
+	}
+	return nil
+}
+
+// connect 建立连接并认证，调用方需持有 c.mu
+func (c *redisClient) connect(ctx context.Context) error {
+	var conn net.Conn
+	var err error
+	if c.tlsConfig != nil {
+		dialer := &tls.Dialer{Config: c.tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", c.addr)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", c.addr)
+	}
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	c.rd = bufio.NewReader(conn)
+
+	if c.password != "" {
+		args := []string{"AUTH", c.password}
+		if c.user != "" {
+			// Redis 6+ ACL：AUTH username password
+			args = []string{"AUTH", c.user, c.password}
+		}
+		if _, err := c.do(ctx, args...); err != nil {
+			c.reset()
+			return err
+		}
+	}
+	return nil
+}
+
+// reset 关闭当前连接，调用方需持有 c.mu
+func (c *redisClient) reset() {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.conn = nil
+	c.rd = nil
+}
+
+// do 发送一条命令并读取回复，调用方需持有 c.mu
+func (c *redisClient) do(ctx context.Context, args ...string) (string, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetDeadline(deadline)
+	} else {
+		c.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return "", err
+	}
+	return readRedisReply(c.rd)
+}
+
+// redisError Redis 返回的错误回复（如 WRONGPASS、NOAUTH），连接本身仍然可用
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// readRedisReply 读取一条 RESP 回复，数组回复只校验格式、不返回内容
+func readRedisReply(rd *bufio.Reader) (string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return "", fmt.Errorf("redis: 无效的回复")
+	}
+
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return "", redisError(line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("redis: 无效的回复 %q", line)
+		}
+		if n < 0 {
+			return "", nil // nil bulk string，例如 GET 不存在的 key
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return "", err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("redis: 无效的回复 %q", line)
+		}
+		for i := 0; i < n; i++ {
+			if _, err := readRedisReply(rd); err != nil {
+				return "", err
+			}
+		}
+		return "", nil
+	default:
+		return "", fmt.Errorf("redis: 无效的回复 %q", line)
+	}
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// RoleDetector 支持查询节点实际角色的驱动（开启 role_detection 的目标每轮探测查询一次）
+// 故障切换后配置中的 role 往往没有及时更新，prober 把识别出的角色导出为 effective_role，与配置的 role 对比
+type RoleDetector interface {
+	// DetectRole 返回节点当前的角色（如 primary、replica、standby）
+	DetectRole(ctx context.Context, database *sql.DB) (string, error)
+}
+
+// DetectRole 根据 read_only 判断角色：开启 read_only 为 replica，否则为 primary
+// 主从切换工具（如 MHA、Orchestrator）切换时会同时切换 read_only
+func (d *MySQLDriver) DetectRole(ctx context.Context, database *sql.DB) (string, error) {
+	var readOnly int
+	if err := database.QueryRowContext(ctx, "SELECT @@global.read_only").Scan(&readOnly); err != nil {
+		return "", err
+	}
+	if readOnly != 0 {
+		return "replica", nil
+	}
+	return "primary", nil
+}
+
+// DetectRole 读取 v$database.database_role（需要 v$database 的查询权限）
+// PRIMARY 为 primary，PHYSICAL/LOGICAL/SNAPSHOT STANDBY 为 standby
+func (d *OracleDriver) DetectRole(ctx context.Context, database *sql.DB) (string, error) {
+	var role string
+	if err := database.QueryRowContext(ctx, "SELECT database_role FROM v$database").Scan(&role); err != nil {
+		return "", err
+	}
+	return normalizeRole(role), nil
+}
+
+// DetectRole 读取 V$INSTANCE.MODE$（需要动态视图的查询权限）
+// PRIMARY 为 primary，STANDBY 为 standby，未配置主备的 NORMAL 实例视为 primary
+func (d *DMDriver) DetectRole(ctx context.Context, database *sql.DB) (string, error) {
+	var mode string
+	if err := database.QueryRowContext(ctx, "SELECT MODE$ FROM V$INSTANCE").Scan(&mode); err != nil {
+		return "", err
+	}
+	if strings.EqualFold(strings.TrimSpace(mode), "NORMAL") {
+		return "primary", nil
+	}
+	return normalizeRole(mode), nil
+}
+
+// normalizeRole 把数据库返回的角色名转为小写的 label 取值，各类 STANDBY 统一为 standby
+func normalizeRole(role string) string {
+	role = strings.ToLower(strings.TrimSpace(role))
+	if strings.HasSuffix(role, "standby") {
+		return "standby"
+	}
+	return strings.ReplaceAll(role, " ", "_")
+}
+
+// NodeRoleQuerier 节点角色随故障切换自动变化的集群驱动（如 Aurora 的 writer/reader）
+// 每轮探测 Ping 成功后查询一次，prober 使用查询到的角色作为 role label，连接到的实例变化时记录故障切换
+type NodeRoleQuerier interface {
+	// QueryNodeRole 返回当前连接到的实例的角色和实例标识
+	QueryNodeRole(ctx context.Context, database *sql.DB) (role, instance string, err error)
+}
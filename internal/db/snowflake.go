@@ -0,0 +1,56 @@
+package db
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+)
+
+// SnowflakeDriver Snowflake 驱动实现
+// 驱动（github.com/snowflakedb/gosnowflake，注册为 snowflake）不在默认构建中，需要使用 -tags snowflake 构建
+type SnowflakeDriver struct{}
+
+func (d *SnowflakeDriver) DriverName() string {
+	return "snowflake"
+}
+
+// DefaultQuery SELECT 1 由云服务层直接返回，不需要运行中的仓库，不会唤醒已挂起的仓库产生计费
+func (d *SnowflakeDriver) DefaultQuery() string {
+	return "SELECT 1"
+}
+
+// SnowflakePrivateKey 读取 key-pair 认证的私钥文件，返回驱动 privateKey 参数需要的格式（PKCS#8 DER 的 base64 URL 编码）
+// 支持未加密的 PKCS#8（BEGIN PRIVATE KEY）和 PKCS#1（BEGIN RSA PRIVATE KEY）PEM 文件
+func SnowflakePrivateKey(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("读取私钥文件失败: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return "", fmt.Errorf("私钥文件不是 PEM 格式: %s", path)
+	}
+	var der []byte
+	switch block.Type {
+	case "PRIVATE KEY":
+		if _, err := x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+			return "", fmt.Errorf("解析 PKCS#8 私钥失败: %w", err)
+		}
+		der = block.Bytes
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return "", fmt.Errorf("解析 PKCS#1 私钥失败: %w", err)
+		}
+		if der, err = x509.MarshalPKCS8PrivateKey(key); err != nil {
+			return "", fmt.Errorf("转换私钥格式失败: %w", err)
+		}
+	case "ENCRYPTED PRIVATE KEY":
+		return "", fmt.Errorf("不支持加密的私钥，请使用 openssl pkcs8 -topk8 -nocrypt 转换为未加密的 PKCS#8 格式: %s", path)
+	default:
+		return "", fmt.Errorf("不支持的私钥类型 %s: %s", block.Type, path)
+	}
+	return base64.URLEncoding.EncodeToString(der), nil
+}
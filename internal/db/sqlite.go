@@ -0,0 +1,55 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SQLiteDriver SQLite 驱动实现，用于探测边缘设备上的本地数据库文件
+// 驱动（modernc.org/sqlite，纯 Go 实现，注册为 sqlite）不在默认构建中，需要使用 -tags sqlite 构建
+type SQLiteDriver struct{}
+
+func (d *SQLiteDriver) DriverName() string {
+	return "sqlite"
+}
+
+func (d *SQLiteDriver) DefaultQuery() string {
+	return "SELECT 1"
+}
+
+// SafetySessionInit 禁止写入，数据库被应用的写事务锁住时最多等待 1 秒（默认不等待，直接返回 SQLITE_BUSY）
+func (d *SQLiteDriver) SafetySessionInit() []string {
+	return []string{
+		"PRAGMA query_only = ON",
+		"PRAGMA busy_timeout = 1000",
+	}
+}
+
+// CheckQueryResult 探测 SQL 为 PRAGMA integrity_check 或 quick_check 时，结果必须为 ok
+// 检查发现问题时第一行为第一个问题的描述
+func (d *SQLiteDriver) CheckQueryResult(query string, result interface{}) error {
+	pragma := strings.ToLower(strings.Join(strings.Fields(query), " "))
+	if !strings.HasPrefix(pragma, "pragma integrity_check") && !strings.HasPrefix(pragma, "pragma quick_check") {
+		return nil
+	}
+	var value string
+	switch v := result.(type) {
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		value = fmt.Sprint(v)
+	}
+	if value != "ok" {
+		return fmt.Errorf("sqlite: 完整性检查失败: %s", value)
+	}
+	return nil
+}
+
+// SQLiteDSN 构造以只读方式打开数据库文件的 URI（文件不存在时不会创建）
+// 路径中的 %、?、# 在 URI 中有特殊含义，需要转义
+func SQLiteDSN(path string) string {
+	escaped := strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23").Replace(path)
+	return "file:" + escaped + "?mode=ro"
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+)
+
+// bannerMaxBytes 读取 banner 的最大字节数
+const bannerMaxBytes = 4096
+
+// TCPDriver 纯 TCP 端口探测驱动
+// 用于暂时无法认证的数据存储：只检查 host:port 能否建立连接（可选 TLS 握手和 banner 匹配）
+type TCPDriver struct{}
+
+func (d *TCPDriver) DriverName() string {
+	return "tcp"
+}
+
+// DefaultQuery TCP 目标没有探测 SQL，Query 阶段用于 banner 匹配
+func (d *TCPDriver) DefaultQuery() string {
+	return ""
+}
+
+func (d *TCPDriver) NewClient(dbCfg *config.DBConfig) (Client, error) {
+	c := &tcpClient{
+		addr: net.JoinHostPort(dbCfg.Host, strconv.Itoa(dbCfg.Port)),
+	}
+	if dbCfg.TLS {
+		c.tlsConfig = &tls.Config{
+			ServerName:         dbCfg.Host,
+			InsecureSkipVerify: dbCfg.TLSSkipVerify,
+		}
+	}
+	if dbCfg.Banner != "" {
+		re, err := regexp.Compile(dbCfg.Banner)
+		if err != nil {
+			return nil, fmt.Errorf("banner 不是合法的正则表达式: %w", err)
+		}
+		c.banner = re
+	}
+	return c, nil
+}
+
+// tcpClient TCP 探测客户端
+// Ping 建立 TCP 连接（及 TLS 握手）；配置了 banner 时连接保留给 Query 读取 banner，否则立即关闭
+type tcpClient struct {
+	addr      string
+	tlsConfig *tls.Config
+	banner    *regexp.Regexp
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (c *tcpClient) Ping(ctx context.Context) error {
+	var conn net.Conn
+	var err error
+	if c.tlsConfig != nil {
+		dialer := &tls.Dialer{Config: c.tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", c.addr)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", c.addr)
+	}
+	if err != nil {
+		return err
+	}
+
+	if c.banner == nil {
+		return conn.Close()
+	}
+
+	c.mu.Lock()
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.conn = conn
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *tcpClient) Query(ctx context.Context) error {
+	if c.banner == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	conn := c.conn
+	c.conn = nil
+	c.mu.Unlock()
+	if conn == nil {
+		return fmt.Errorf("读取 banner 失败: 连接未建立")
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetReadDeadline(deadline)
+	} else {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	}
+
+	// banner 可能分多个 TCP 包到达，持续读取直到匹配、读满或超时
+	buf := make([]byte, 0, bannerMaxBytes)
+	chunk := make([]byte, 512)
+	for len(buf) < bannerMaxBytes {
+		n, err := conn.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if c.banner.Match(buf) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("banner 不匹配 %q (已读取 %q): %w", c.banner.String(), buf, err)
+		}
+	}
+	return fmt.Errorf("banner 不匹配 %q (已读取 %q)", c.banner.String(), buf)
+}
+
+func (c *tcpClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		err := c.conn.Close()
+		c.conn = nil
+		return err
+	}
+	return nil
+}
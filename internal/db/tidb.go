@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// TiDBStatus TiDB 状态端口 /status 返回中用到的字段
+type TiDBStatus struct {
+	Connections int    `json:"connections"`
+	Version     string `json:"version"`
+	GitHash     string `json:"git_hash"`
+}
+
+// TiDBStatusURL 返回 TiDB 状态端口（默认 10080）的 /status 地址
+func TiDBStatusURL(host string, port int) string {
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(port)) + "/status"
+}
+
+// QueryTiDBStatus 请求 TiDB 状态端口的 /status
+// 状态端口由独立的 HTTP 服务提供，不经过 SQL 层：SQL 探测失败而状态端口正常说明进程存活、SQL 层过载或阻塞
+func QueryTiDBStatus(ctx context.Context, client *http.Client, url string) (TiDBStatus, error) {
+	var status TiDBStatus
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return status, err
+	}
+	req.Header.Set("User-Agent", "db-probe")
+	resp, err := client.Do(req)
+	if err != nil {
+		return status, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err != nil {
+		return status, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg := strings.TrimSpace(string(body))
+		if len(msg) > 256 {
+			msg = msg[:256]
+		}
+		return status, fmt.Errorf("tidb: 状态端口返回 HTTP %d: %s", resp.StatusCode, msg)
+	}
+	if err := json.Unmarshal(body, &status); err != nil {
+		return status, fmt.Errorf("tidb: 解析 /status 失败: %w", err)
+	}
+	return status, nil
+}
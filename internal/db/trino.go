@@ -0,0 +1,209 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+)
+
+// TrinoDriver Trino/Presto coordinator 探测驱动（REST 客户端协议）
+// Ping 阶段请求 /v1/info 检查 coordinator 是否可用，Query 阶段通过 /v1/statement 提交探测查询并跟随 nextUri 直到结束，
+// 从查询的 stats 中读取排队时间和执行时间，用于区分 coordinator 过载（排队时间长）和不可用
+type TrinoDriver struct{}
+
+func (d *TrinoDriver) DriverName() string {
+	return "trino"
+}
+
+func (d *TrinoDriver) DefaultQuery() string {
+	return "SELECT 1"
+}
+
+// NewClient 创建 Trino 客户端
+// 配置了 dsn 时作为 coordinator 地址（如 https://trino.example.com:8443），否则使用 host:port，开启 tls 时为 https
+// 配置 password 时使用 Basic 认证（Trino 要求 HTTPS），否则只通过请求头传递用户名
+func (d *TrinoDriver) NewClient(dbCfg *config.DBConfig) (Client, error) {
+	baseURL := strings.TrimRight(dbCfg.DSN, "/")
+	if baseURL == "" {
+		scheme := "http"
+		if dbCfg.TLS {
+			scheme = "https"
+		}
+		baseURL = scheme + "://" + net.JoinHostPort(dbCfg.Host, strconv.Itoa(dbCfg.Port))
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: dbCfg.TLSSkipVerify}
+	transport.MaxIdleConnsPerHost = 1
+
+	query := dbCfg.Query
+	if query == "" {
+		query = d.DefaultQuery()
+	}
+	return &trinoClient{
+		http:     &http.Client{Transport: transport},
+		baseURL:  baseURL,
+		query:    query,
+		user:     dbCfg.User,
+		password: dbCfg.Password,
+	}, nil
+}
+
+// trinoClient Trino 探测客户端，超时由探测的 context 控制
+type trinoClient struct {
+	http     *http.Client
+	baseURL  string
+	query    string
+	user     string
+	password string
+
+	mu        sync.RWMutex
+	queued    time.Duration // 最近一次查询的排队时间
+	execution time.Duration // 最近一次查询的执行时间（总耗时减去排队时间）
+	hasPhases bool
+}
+
+// trinoInfo /v1/info 返回中用到的字段
+type trinoInfo struct {
+	Starting    bool `json:"starting"`
+	Coordinator bool `json:"coordinator"`
+}
+
+// trinoResults /v1/statement 及 nextUri 返回中用到的字段
+type trinoResults struct {
+	ID      string `json:"id"`
+	NextURI string `json:"nextUri"`
+	Stats   struct {
+		State             string `json:"state"`
+		QueuedTimeMillis  int64  `json:"queuedTimeMillis"`
+		ElapsedTimeMillis int64  `json:"elapsedTimeMillis"`
+	} `json:"stats"`
+	Error *struct {
+		Message   string `json:"message"`
+		ErrorCode int    `json:"errorCode"`
+		ErrorName string `json:"errorName"`
+		ErrorType string `json:"errorType"`
+	} `json:"error"`
+}
+
+// Ping 请求 /v1/info；连接到 worker 或 coordinator 仍在启动时视为失败
+func (c *trinoClient) Ping(ctx context.Context) error {
+	body, err := c.do(ctx, http.MethodGet, c.baseURL+"/v1/info", nil)
+	if err != nil {
+		return err
+	}
+	var info trinoInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		return fmt.Errorf("trino: 解析 /v1/info 失败: %w", err)
+	}
+	if info.Starting {
+		return fmt.Errorf("trino: SERVER_STARTING_UP: coordinator 正在启动")
+	}
+	if !info.Coordinator {
+		return fmt.Errorf("trino: 连接的节点不是 coordinator")
+	}
+	return nil
+}
+
+// Query 提交探测查询并跟随 nextUri 直到查询结束，记录排队时间和执行时间
+// 探测超时（context 取消）时取消查询，避免过载的 coordinator 上堆积排队的探测查询
+func (c *trinoClient) Query(ctx context.Context) error {
+	body, err := c.do(ctx, http.MethodPost, c.baseURL+"/v1/statement", []byte(c.query))
+	if err != nil {
+		return err
+	}
+	for {
+		var results trinoResults
+		if err := json.Unmarshal(body, &results); err != nil {
+			return fmt.Errorf("trino: 解析查询结果失败: %w", err)
+		}
+		c.recordPhases(results.Stats.QueuedTimeMillis, results.Stats.ElapsedTimeMillis)
+		if results.Error != nil {
+			return fmt.Errorf("trino: %s (%d): %s", results.Error.ErrorName, results.Error.ErrorCode, results.Error.Message)
+		}
+		if results.NextURI == "" {
+			if results.Stats.State == "FAILED" {
+				return fmt.Errorf("trino: 查询 %s 失败", results.ID)
+			}
+			return nil
+		}
+		if body, err = c.do(ctx, http.MethodGet, results.NextURI, nil); err != nil {
+			c.cancel(results.NextURI)
+			return err
+		}
+	}
+}
+
+// cancel 尽力取消查询（DELETE nextUri），不影响探测结果
+func (c *trinoClient) cancel(nextURI string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	c.do(ctx, http.MethodDelete, nextURI, nil)
+}
+
+func (c *trinoClient) recordPhases(queuedMillis, elapsedMillis int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queued = time.Duration(queuedMillis) * time.Millisecond
+	c.execution = time.Duration(max(elapsedMillis-queuedMillis, 0)) * time.Millisecond
+	c.hasPhases = true
+}
+
+// QueryPhases 返回最近一次查询的排队时间和执行时间
+func (c *trinoClient) QueryPhases() (queued, execution time.Duration, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.queued, c.execution, c.hasPhases
+}
+
+func (c *trinoClient) Close() error {
+	c.http.CloseIdleConnections()
+	return nil
+}
+
+// do 发送请求，非 2xx 响应返回带状态码和响应片段的错误
+// 同时发送 X-Trino-* 和 X-Presto-* 请求头，兼容 Trino 和 PrestoDB（不认识的请求头会被忽略）
+func (c *trinoClient) do(ctx context.Context, method, url string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "db-probe")
+	for _, prefix := range []string{"X-Trino-", "X-Presto-"} {
+		req.Header.Set(prefix+"User", c.user)
+		req.Header.Set(prefix+"Source", "db-probe")
+	}
+	if c.password != "" {
+		req.SetBasicAuth(c.user, c.password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// 探测查询的结果很小，限制读取大小，避免自定义查询返回大量数据
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		msg := strings.TrimSpace(string(data))
+		if len(msg) > 256 {
+			msg = msg[:256]
+		}
+		return nil, fmt.Errorf("trino: %s %s 返回 HTTP %d: %s", method, req.URL.Path, resp.StatusCode, msg)
+	}
+	return data, nil
+}
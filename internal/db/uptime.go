@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// UptimeQuerier 支持查询数据库实例运行时长的驱动
+// prober 定期查询运行时长，运行时长变小说明实例发生了重启
+type UptimeQuerier interface {
+	// QueryUptime 返回数据库实例已运行的秒数
+	QueryUptime(ctx context.Context, database *sql.DB) (float64, error)
+}
+
+// QueryUptime 读取 SHOW GLOBAL STATUS 中的 Uptime
+func (d *MySQLDriver) QueryUptime(ctx context.Context, database *sql.DB) (float64, error) {
+	var name string
+	var uptime float64
+	err := database.QueryRowContext(ctx, "SHOW GLOBAL STATUS LIKE 'Uptime'").Scan(&name, &uptime)
+	return uptime, err
+}
+
+// QueryUptime 根据 v$instance.startup_time 计算运行时长（需要 v$instance 的查询权限）
+func (d *OracleDriver) QueryUptime(ctx context.Context, database *sql.DB) (float64, error) {
+	var uptime float64
+	err := database.QueryRowContext(ctx, "SELECT (SYSDATE - startup_time) * 86400 FROM v$instance").Scan(&uptime)
+	return uptime, err
+}
+
+// QueryUptime 根据 sys.dm_os_sys_info.sqlserver_start_time 计算运行时长（需要 VIEW SERVER STATE 权限）
+func (d *MSSQLDriver) QueryUptime(ctx context.Context, database *sql.DB) (float64, error) {
+	var uptime float64
+	err := database.QueryRowContext(ctx, "SELECT DATEDIFF(SECOND, sqlserver_start_time, SYSDATETIME()) FROM sys.dm_os_sys_info").Scan(&uptime)
+	return uptime, err
+}
+
+// QueryUptime 根据 pg_postmaster_start_time() 计算运行时长
+func (d *AuroraPostgresDriver) QueryUptime(ctx context.Context, database *sql.DB) (float64, error) {
+	var uptime float64
+	err := database.QueryRowContext(ctx, "SELECT EXTRACT(EPOCH FROM now() - pg_postmaster_start_time())").Scan(&uptime)
+	return uptime, err
+}
@@ -0,0 +1,455 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/internal/metrics"
+	"github.com/imkerbos/db-probe/internal/prober"
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+const (
+	// kvWatchTimeout 单次监听（etcd watch、Consul blocking query）的时长，到期后重新读取前缀下的全部键
+	kvWatchTimeout = 5 * time.Minute
+	// kvRequestTimeout 读取前缀下全部键的超时时间
+	kvRequestTimeout = 10 * time.Second
+	// kvMinRetryInterval、kvMaxRetryInterval 读取或监听失败后的重试间隔，每次失败翻倍
+	kvMinRetryInterval = 5 * time.Second
+	kvMaxRetryInterval = time.Minute
+)
+
+// KVInventory 从 etcd 或 Consul KV 前缀发现目标
+// 前缀下的每个键生成一个目标，启动时读取全部键，之后监听前缀的变化，键增删改后立即同步；
+// 目标来源（source）和 db_probe_discovery_* 指标的 source label 为 backend（etcd 或 consul）
+type KVInventory struct {
+	cfg     config.KVDiscoveryConfig
+	address string
+	client  *http.Client
+	probe   *prober.Prober
+	watcher kvWatcher
+
+	// failing 读取是否处于持续失败状态，只在进入和恢复时输出日志
+	failing bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// kvWatcher 读取前缀下的全部键值：首次调用立即返回，之后阻塞到前缀下的内容变化或 kvWatchTimeout 到期再返回
+type kvWatcher interface {
+	next(ctx context.Context) (map[string][]byte, error)
+}
+
+// NewKV 创建 etcd、Consul KV 目标发现，不立即连接
+func NewKV(cfg config.KVDiscoveryConfig, probe *prober.Prober) (*KVInventory, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.TLSSkipVerify}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 discovery.kv.ca_file 失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("discovery.kv.ca_file 中没有有效的证书: %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &KVInventory{
+		cfg:     cfg,
+		address: strings.TrimSuffix(cfg.Address, "/"),
+		client:  &http.Client{Transport: transport},
+		probe:   probe,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	if cfg.Backend == "etcd" {
+		s.watcher = &etcdWatcher{s: s}
+	} else {
+		s.watcher = &consulWatcher{s: s}
+	}
+	return s, nil
+}
+
+// Start 启动读取和监听循环
+func (s *KVInventory) Start() {
+	logger.L().Infow("KV 目标发现已启用",
+		"backend", s.cfg.Backend,
+		"address", s.address,
+		"prefix", s.cfg.Prefix,
+	)
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop 停止读取和监听，已发现的目标由探针继续管理，必须在探针停止之前调用
+func (s *KVInventory) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *KVInventory) run() {
+	defer s.wg.Done()
+
+	retry := kvMinRetryInterval
+	for s.ctx.Err() == nil {
+		entries, err := s.watcher.next(s.ctx)
+		if s.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			metrics.RecordDiscoveryFailure(s.cfg.Backend)
+			if !s.failing {
+				s.failing = true
+				logger.L().Warnw("读取 KV 目标失败，保留上一次同步的目标，稍后重试",
+					"backend", s.cfg.Backend,
+					"prefix", s.cfg.Prefix,
+					"error", err,
+				)
+			}
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-time.After(retry):
+			}
+			retry = min(retry*2, kvMaxRetryInterval)
+			continue
+		}
+		retry = kvMinRetryInterval
+		if s.failing {
+			s.failing = false
+			logger.L().Infow("读取 KV 目标恢复", "backend", s.cfg.Backend, "prefix", s.cfg.Prefix)
+		}
+		s.sync(entries)
+	}
+}
+
+// sync 按键名顺序把每个键解析为目标并对齐探测目标，不合法的键跳过并输出告警日志
+func (s *KVInventory) sync(entries map[string][]byte) {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var dbCfgs []config.DBConfig
+	for _, key := range keys {
+		dbCfg, err := s.targetFromKey(key, entries[key])
+		if err != nil {
+			logger.L().Warnw("KV 中的目标不合法，跳过", "backend", s.cfg.Backend, "key", key, "error", err)
+			continue
+		}
+		dbCfgs = append(dbCfgs, dbCfg)
+	}
+
+	result := s.probe.SyncTargets(s.cfg.Backend, dbCfgs)
+	metrics.SetDiscoveryTargets(s.cfg.Backend, len(dbCfgs)-len(result.Skipped))
+	if result.Changed() {
+		logger.L().Infow("KV 目标已同步",
+			"source", s.cfg.Backend,
+			"added", result.Added,
+			"updated", result.Updated,
+			"removed", result.Removed,
+			"skipped", result.Skipped,
+		)
+	}
+}
+
+// targetFromKey 解析单个键的目标配置，未配置 name 时使用去掉前缀和扩展名的键名
+func (s *KVInventory) targetFromKey(key string, value []byte) (config.DBConfig, error) {
+	dbCfg, err := config.DecodeDatabase(key, value, s.cfg.Template)
+	if err != nil {
+		return dbCfg, err
+	}
+	if dbCfg.Name == "" {
+		name := strings.TrimPrefix(strings.TrimPrefix(key, s.cfg.Prefix), "/")
+		switch path.Ext(name) {
+		case ".yaml", ".yml", ".json":
+			name = strings.TrimSuffix(name, path.Ext(name))
+		}
+		dbCfg.Name = name
+	}
+	// 运行时读取凭据的目标由 secrets 包在启动时加入，KV 中的目标不支持
+	if dbCfg.ExternalCredentials() {
+		return dbCfg, fmt.Errorf("KV 中的目标不支持 secret_ref、user_ref、password_ref、dsn_ref，请使用 password 或 password_file")
+	}
+	if err := config.ValidateDatabase("discovery.kv["+key+"]", &dbCfg); err != nil {
+		return dbCfg, err
+	}
+	return dbCfg, nil
+}
+
+// do 发起请求，返回非 2xx 响应时把响应内容转换为错误（404 除外，Consul 前缀下没有键时返回 404）
+func (s *KVInventory) do(req *http.Request) (*http.Response, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("%s 返回 %s: %s", s.cfg.Backend, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+// isEntryKey 是否为目标键：跳过前缀本身、目录占位键（以 / 结尾）和空值
+func (s *KVInventory) isEntryKey(key string, value []byte) bool {
+	return key != s.cfg.Prefix && !strings.HasSuffix(key, "/") && len(bytes.TrimSpace(value)) > 0
+}
+
+// consulWatcher 通过 Consul blocking query 监听前缀：带上一次响应的 X-Consul-Index，内容变化或等待超时后返回
+type consulWatcher struct {
+	s     *KVInventory
+	index uint64
+}
+
+func (w *consulWatcher) next(ctx context.Context) (map[string][]byte, error) {
+	query := url.Values{}
+	query.Set("recurse", "true")
+	timeout := kvRequestTimeout
+	if w.index > 0 {
+		query.Set("index", strconv.FormatUint(w.index, 10))
+		query.Set("wait", fmt.Sprintf("%ds", int(kvWatchTimeout.Seconds())))
+		// 服务端最多等待 wait 再加上一点随机时间，客户端多留一些余量
+		timeout = kvWatchTimeout + time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	target := url.URL{Path: "/v1/kv/" + strings.TrimPrefix(w.s.cfg.Prefix, "/"), RawQuery: query.Encode()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.s.address+target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if w.s.cfg.Token != "" {
+		req.Header.Set("X-Consul-Token", w.s.cfg.Token)
+	}
+	resp, err := w.s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	entries := make(map[string][]byte)
+	if resp.StatusCode == http.StatusOK {
+		var pairs []struct {
+			Key   string `json:"Key"`
+			Value []byte `json:"Value"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&pairs); err != nil {
+			return nil, fmt.Errorf("解析 Consul KV 响应失败: %w", err)
+		}
+		for _, pair := range pairs {
+			if w.s.isEntryKey(pair.Key, pair.Value) {
+				entries[pair.Key] = pair.Value
+			}
+		}
+	}
+	// 前缀下没有键时返回 404，同样带有 X-Consul-Index；index 变小（如 Consul 数据恢复）时从头开始
+	index, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if index < w.index {
+		index = 0
+	}
+	w.index = index
+	return entries, nil
+}
+
+// etcdWatcher 通过 etcd v3 的 HTTP 网关（/v3/kv/range、/v3/watch）读取和监听前缀
+// 首次调用读取前缀下的全部键，之后从上一次读取的 revision 开始 watch，收到变化事件或 watch 超时后重新读取全部键
+type etcdWatcher struct {
+	s        *KVInventory
+	revision int64
+	token    string // 开启认证时 /v3/auth/authenticate 返回的 token
+}
+
+func (w *etcdWatcher) next(ctx context.Context) (map[string][]byte, error) {
+	if w.revision > 0 {
+		if err := w.watch(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return w.rangePrefix(ctx)
+}
+
+// keyRange 前缀对应的 key、range_end（[]byte 由 encoding/json 编码为 etcd 网关要求的 base64）
+// range_end 为前缀最后一个不是 0xff 的字节加一，全部为 0xff 时为 \x00（表示到最后一个键）
+func (w *etcdWatcher) keyRange() map[string]interface{} {
+	prefix := []byte(w.s.cfg.Prefix)
+	end := []byte{0}
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] < 0xff {
+			end = bytes.Clone(prefix[:i+1])
+			end[i]++
+			break
+		}
+	}
+	return map[string]interface{}{"key": prefix, "range_end": end}
+}
+
+// rangePrefix 读取前缀下的全部键，记录响应的 revision
+func (w *etcdWatcher) rangePrefix(ctx context.Context) (map[string][]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, kvRequestTimeout)
+	defer cancel()
+	resp, err := w.post(ctx, "/v3/kv/range", w.keyRange())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Header struct {
+			Revision string `json:"revision"`
+		} `json:"header"`
+		KVs []struct {
+			Key   []byte `json:"key"`
+			Value []byte `json:"value"`
+		} `json:"kvs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析 etcd 响应失败: %w", err)
+	}
+	revision, err := strconv.ParseInt(result.Header.Revision, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("etcd 响应中的 revision 不合法: %q", result.Header.Revision)
+	}
+	w.revision = revision
+
+	entries := make(map[string][]byte, len(result.KVs))
+	for _, kv := range result.KVs {
+		if key := string(kv.Key); w.s.isEntryKey(key, kv.Value) {
+			entries[key] = kv.Value
+		}
+	}
+	return entries, nil
+}
+
+// watch 从上一次读取的 revision 之后开始 watch 前缀，收到变化事件或 kvWatchTimeout 到期时返回 nil
+// revision 已被压缩（compact）时同样返回 nil，由调用方重新读取全部键
+func (w *etcdWatcher) watch(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, kvWatchTimeout)
+	defer cancel()
+	createRequest := w.keyRange()
+	createRequest["start_revision"] = strconv.FormatInt(w.revision+1, 10)
+	resp, err := w.post(ctx, "/v3/watch", map[string]interface{}{"create_request": createRequest})
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var message struct {
+			Result struct {
+				Canceled        bool              `json:"canceled"`
+				CompactRevision string            `json:"compact_revision"`
+				Events          []json.RawMessage `json:"events"`
+			} `json:"result"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := decoder.Decode(&message); err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return nil
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("watch etcd 中断: %w", err)
+		}
+		if message.Error != nil {
+			return fmt.Errorf("watch etcd 出错: %s", message.Error.Message)
+		}
+		if len(message.Result.Events) > 0 || message.Result.Canceled {
+			return nil
+		}
+		if message.Result.CompactRevision != "" && message.Result.CompactRevision != "0" {
+			return nil
+		}
+	}
+}
+
+// post 向 etcd HTTP 网关发送 JSON 请求，配置了 username 时带上认证 token，token 失效（401）后下一次请求重新认证
+func (w *etcdWatcher) post(ctx context.Context, api string, body interface{}) (*http.Response, error) {
+	if w.s.cfg.Username != "" && w.token == "" {
+		if err := w.authenticate(ctx); err != nil {
+			return nil, err
+		}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.s.address+api, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.token != "" {
+		req.Header.Set("Authorization", w.token)
+	}
+	resp, err := w.s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized {
+			w.token = ""
+		}
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("etcd %s 返回 %s: %s", api, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// authenticate 使用 username、password 换取 token
+func (w *etcdWatcher) authenticate(ctx context.Context) error {
+	data, err := json.Marshal(map[string]string{"name": w.s.cfg.Username, "password": w.s.cfg.Password})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.s.address+"/v3/auth/authenticate", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.s.do(req)
+	if err != nil {
+		return fmt.Errorf("etcd 认证失败: %w", err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || result.Token == "" {
+		return fmt.Errorf("etcd 认证失败: 响应中没有 token")
+	}
+	w.token = result.Token
+	return nil
+}
@@ -0,0 +1,247 @@
+// Package discovery 从外部清单定期发现探测目标
+// 发现的目标通过 prober.SyncTargets 与正在探测的目标对齐：新增的目标开始探测，消失的目标停止探测，配置变化的目标重建
+// 同步失败（清单库不可用、查询出错）时保留上一次同步的目标，避免清单故障导致探测目标全部消失
+package discovery
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/internal/metrics"
+	"github.com/imkerbos/db-probe/internal/prober"
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+// SQLSource SQL 清单发现的来源名称，用于目标的 source 字段和 db_probe_discovery_* 指标的 source label
+const SQLSource = "sql"
+
+// requiredColumns 清单查询结果必须包含的列
+var requiredColumns = []string{"name", "type", "host", "port"}
+
+// SQLInventory 从 SQL 清单库（如 CMDB）发现目标
+// 每个同步间隔执行一次 query，结果中的每一行按 template 生成一个目标
+type SQLInventory struct {
+	cfg   config.SQLDiscoveryConfig
+	db    *sql.DB
+	probe *prober.Prober
+
+	// failing 同步是否处于持续失败状态，只在进入和恢复时输出日志，避免每个间隔重复告警
+	failing bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewSQL 创建 SQL 清单发现，只打开连接池，不立即连接清单库
+func NewSQL(cfg config.SQLDiscoveryConfig, probe *prober.Prober) (*SQLInventory, error) {
+	database, err := sql.Open(cfg.Driver, cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("打开清单库连接失败: %w", err)
+	}
+	// 清单查询频率很低，只保留一条连接
+	database.SetMaxOpenConns(1)
+	database.SetMaxIdleConns(1)
+	database.SetConnMaxIdleTime(cfg.Interval + time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &SQLInventory{
+		cfg:    cfg,
+		db:     database,
+		probe:  probe,
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+// Start 启动同步循环，启动后立即同步一次
+func (s *SQLInventory) Start() {
+	logger.L().Infow("SQL 清单目标发现已启用",
+		"driver", s.cfg.Driver,
+		"interval", s.cfg.Interval,
+	)
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop 停止同步循环并关闭清单库连接，已发现的目标由探针继续管理，必须在探针停止之前调用
+func (s *SQLInventory) Stop() {
+	s.cancel()
+	s.wg.Wait()
+	s.db.Close()
+}
+
+func (s *SQLInventory) run() {
+	defer s.wg.Done()
+
+	s.sync()
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.sync()
+		}
+	}
+}
+
+// sync 查询清单并对齐探测目标，查询失败时不改动已有目标
+func (s *SQLInventory) sync() {
+	dbCfgs, err := s.fetch()
+	if err != nil {
+		metrics.RecordDiscoveryFailure(SQLSource)
+		if !s.failing {
+			s.failing = true
+			logger.L().Warnw("查询目标清单失败，保留上一次同步的目标，将在下一个间隔重试",
+				"driver", s.cfg.Driver,
+				"error", err,
+			)
+		}
+		return
+	}
+	if s.failing {
+		s.failing = false
+		logger.L().Infow("查询目标清单恢复", "driver", s.cfg.Driver)
+	}
+
+	result := s.probe.SyncTargets(SQLSource, dbCfgs)
+	metrics.SetDiscoveryTargets(SQLSource, len(dbCfgs)-len(result.Skipped))
+	if result.Changed() {
+		logger.L().Infow("目标清单已同步",
+			"source", SQLSource,
+			"added", result.Added,
+			"updated", result.Updated,
+			"removed", result.Removed,
+			"skipped", result.Skipped,
+		)
+	}
+}
+
+// fetch 执行清单查询，返回校验通过的目标配置
+// 单行数据不合法时跳过该行并输出告警日志，不影响其他目标
+func (s *SQLInventory) fetch() ([]config.DBConfig, error) {
+	ctx, cancel := context.WithTimeout(s.ctx, s.cfg.Timeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, s.cfg.Query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	for i, column := range columns {
+		columns[i] = strings.ToLower(column)
+	}
+	for _, required := range requiredColumns {
+		if !containsColumn(columns, required) {
+			return nil, fmt.Errorf("清单查询结果缺少 %s 列（需要 %s 列）", required, strings.Join(requiredColumns, "、"))
+		}
+	}
+
+	var dbCfgs []config.DBConfig
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(columns))
+		for i, column := range columns {
+			row[column] = strings.TrimSpace(values[i].String)
+		}
+		dbCfg, err := s.targetFromRow(row)
+		if err != nil {
+			logger.L().Warnw("清单中的目标不合法，跳过", "db_name", row["name"], "error", err)
+			continue
+		}
+		dbCfgs = append(dbCfgs, dbCfg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return dbCfgs, nil
+}
+
+// targetFromRow 按 template 生成目标配置，行中的非空值覆盖 template
+// labels 列为 JSON 对象（如 {"role": "primary"}），与 template 的 labels 合并
+func (s *SQLInventory) targetFromRow(row map[string]string) (config.DBConfig, error) {
+	if row["name"] == "" {
+		return config.DBConfig{}, fmt.Errorf("name 不能为空")
+	}
+	dbCfg := s.cfg.Template
+	dbCfg.Name = row["name"]
+	dbCfg.Type = row["type"]
+	dbCfg.Host = row["host"]
+	port, err := strconv.Atoi(row["port"])
+	if err != nil {
+		return dbCfg, fmt.Errorf("port 不是合法的端口号: %s", row["port"])
+	}
+	dbCfg.Port = port
+
+	for _, field := range []struct {
+		column string
+		value  *string
+	}{
+		{"project", &dbCfg.Project},
+		{"env", &dbCfg.Env},
+		{"owner", &dbCfg.Owner},
+		{"team", &dbCfg.Team},
+		{"oncall", &dbCfg.Oncall},
+		{"runbook_url", &dbCfg.RunbookURL},
+		{"zone", &dbCfg.Zone},
+	} {
+		if v := row[field.column]; v != "" {
+			*field.value = v
+		}
+	}
+
+	// 复制 template 的 labels，各目标之间不能共用同一个 map
+	labels := make(map[string]string, len(s.cfg.Template.Labels))
+	for k, v := range s.cfg.Template.Labels {
+		labels[k] = v
+	}
+	if raw := row["labels"]; raw != "" {
+		var rowLabels map[string]string
+		if err := json.Unmarshal([]byte(raw), &rowLabels); err != nil {
+			return dbCfg, fmt.Errorf("labels 不是合法的 JSON 对象: %w", err)
+		}
+		for k, v := range rowLabels {
+			labels[k] = v
+		}
+	}
+	dbCfg.Labels = nil
+	if len(labels) > 0 {
+		dbCfg.Labels = labels
+	}
+
+	if err := config.ValidateDatabase("discovery.sql["+dbCfg.Name+"]", &dbCfg); err != nil {
+		return dbCfg, err
+	}
+	return dbCfg, nil
+}
+
+func containsColumn(columns []string, name string) bool {
+	for _, column := range columns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,344 @@
+package localstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxPoints 单个序列最多返回的点数，与 Prometheus 的限制一致
+	maxPoints = 11000
+	// defaultLookback 某个时间点往前查找最近样本的最长时间，与 Prometheus 的默认值一致
+	defaultLookback = 5 * time.Minute
+)
+
+// matcher 序列选择器中的一个 label 匹配条件
+type matcher struct {
+	name  string
+	op    string // =、!=、=~、!~
+	value string
+	re    *regexp.Regexp
+}
+
+func (m *matcher) matches(labels []label) bool {
+	value := ""
+	for _, l := range labels {
+		if l.name == m.name {
+			value = l.value
+			break
+		}
+	}
+	switch m.op {
+	case "=":
+		return value == m.value
+	case "!=":
+		return value != m.value
+	case "=~":
+		return m.re.MatchString(value)
+	default:
+		return !m.re.MatchString(value)
+	}
+}
+
+// parseSelector 解析序列选择器：metric_name{label="value", label=~"regex", ...}
+// 指标名和花括号部分都可以省略其一，但至少需要一个不匹配空字符串的条件（与 Prometheus 一致）
+func parseSelector(query string) ([]matcher, error) {
+	query = strings.TrimSpace(query)
+	var matchers []matcher
+	name := query
+	rest := ""
+	if i := strings.IndexByte(query, '{'); i >= 0 {
+		name, rest = strings.TrimSpace(query[:i]), query[i+1:]
+		var ok bool
+		if rest, ok = strings.CutSuffix(strings.TrimSpace(rest), "}"); !ok {
+			return nil, fmt.Errorf("选择器缺少 }")
+		}
+	}
+	if name != "" {
+		if !metricName.MatchString(name) {
+			return nil, fmt.Errorf("只支持序列选择器（如 db_probe_up{env=\"prod\"}），不支持 PromQL 函数和运算: %s", query)
+		}
+		matchers = append(matchers, matcher{name: "__name__", op: "=", value: name})
+	}
+
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		loc := labelMatcher.FindStringSubmatchIndex(rest)
+		if loc == nil {
+			return nil, fmt.Errorf("无法解析的 label 匹配条件: %s", rest)
+		}
+		m := matcher{name: rest[loc[2]:loc[3]], op: rest[loc[4]:loc[5]]}
+		value, err := strconv.Unquote(rest[loc[6]:loc[7]])
+		if err != nil {
+			return nil, fmt.Errorf("label %s 的值不是合法的字符串: %s", m.name, rest[loc[6]:loc[7]])
+		}
+		m.value = value
+		if m.op == "=~" || m.op == "!~" {
+			if m.re, err = regexp.Compile("^(?:" + value + ")$"); err != nil {
+				return nil, fmt.Errorf("label %s 的正则表达式不合法: %w", m.name, err)
+			}
+		}
+		matchers = append(matchers, m)
+		rest = strings.TrimSpace(rest[loc[1]:])
+		if rest != "" && rest[0] != ',' {
+			return nil, fmt.Errorf("label 匹配条件之间需要以逗号分隔: %s", rest)
+		}
+		rest = strings.TrimPrefix(rest, ",")
+	}
+
+	for i := range matchers {
+		if !matchers[i].matches(nil) {
+			return matchers, nil
+		}
+	}
+	return nil, fmt.Errorf("选择器至少需要一个不匹配空值的条件（如指标名）: %s", query)
+}
+
+var (
+	metricName   = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelMatcher = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_]*)\s*(=~|!~|!=|=)\s*("(?:[^"\\]|\\.)*")`)
+)
+
+// series 查询结果中的一个序列
+type series struct {
+	labels []label
+	ts     []int64
+	values []float64
+}
+
+// QueryRangeHandler 处理 /api/v1/query_range，参数和返回格式与 Prometheus HTTP API 相同
+// query 只支持序列选择器；每个 step 时间点取之前 5 分钟（采样间隔较长时为两个间隔）内最近的一个样本
+func (s *Store) QueryRangeHandler(w http.ResponseWriter, r *http.Request) {
+	matchers, err := parseSelector(r.FormValue("query"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	start, err := parseTime(r.FormValue("start"))
+	if err != nil {
+		writeError(w, fmt.Errorf("start 参数不合法: %w", err))
+		return
+	}
+	end, err := parseTime(r.FormValue("end"))
+	if err != nil {
+		writeError(w, fmt.Errorf("end 参数不合法: %w", err))
+		return
+	}
+	step, err := parseStep(r.FormValue("step"))
+	if err != nil {
+		writeError(w, fmt.Errorf("step 参数不合法: %w", err))
+		return
+	}
+	if end.Before(start) {
+		writeError(w, fmt.Errorf("end 不能早于 start"))
+		return
+	}
+	if end.Sub(start)/step >= maxPoints {
+		writeError(w, fmt.Errorf("查询的点数超过 %d，请增大 step 或缩小时间范围", maxPoints))
+		return
+	}
+
+	lookback := max(defaultLookback, 2*s.cfg.Interval)
+	result, err := s.selectSeries(matchers, start.Add(-lookback).UnixMilli(), end.UnixMilli())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	type matrixSeries struct {
+		Metric map[string]string `json:"metric"`
+		Values [][2]interface{}  `json:"values"`
+	}
+	matrix := make([]matrixSeries, 0, len(result))
+	stepMs, lookbackMs := step.Milliseconds(), lookback.Milliseconds()
+	for _, ser := range result {
+		var values [][2]interface{}
+		i := 0
+		for t := start.UnixMilli(); t <= end.UnixMilli(); t += stepMs {
+			for i < len(ser.ts) && ser.ts[i] <= t {
+				i++
+			}
+			// i-1 为 t 之前（含 t）最近的样本
+			if i == 0 || t-ser.ts[i-1] > lookbackMs {
+				continue
+			}
+			values = append(values, [2]interface{}{float64(t) / 1000, formatValue(ser.values[i-1])})
+		}
+		if len(values) == 0 {
+			continue
+		}
+		metric := make(map[string]string, len(ser.labels))
+		for _, l := range ser.labels {
+			metric[l.name] = l.value
+		}
+		matrix = append(matrix, matrixSeries{Metric: metric, Values: values})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"resultType": "matrix",
+			"result":     matrix,
+		},
+	})
+}
+
+// availabilityMetric 计算可用率使用的指标
+const availabilityMetric = "db_probe_up"
+
+// Availability 按 db_name 汇总 [start, end] 内 db_probe_up 的样本，返回每个目标值为 1 的样本占比
+// 同一目标的多个序列（如 db_ip 变化后的新序列）合并计算；非 0、1 的样本（如 stale_behavior: nan 时的 NaN）不计入，
+// 时间范围内没有样本的目标不在结果中；metrics 中没有 db_probe_up 时返回错误
+func (s *Store) Availability(start, end time.Time) (map[string]float64, error) {
+	if !s.metrics[availabilityMetric] {
+		return nil, fmt.Errorf("local_storage.metrics 中没有 %s，无法计算可用率", availabilityMetric)
+	}
+	result, err := s.selectSeries([]matcher{{name: "__name__", op: "=", value: availabilityMetric}}, start.UnixMilli(), end.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	up := make(map[string]int)
+	total := make(map[string]int)
+	for _, ser := range result {
+		name := ""
+		for _, l := range ser.labels {
+			if l.name == "db_name" {
+				name = l.value
+				break
+			}
+		}
+		for _, v := range ser.values {
+			switch v {
+			case 1:
+				up[name]++
+				total[name]++
+			case 0:
+				total[name]++
+			}
+		}
+	}
+	availability := make(map[string]float64, len(total))
+	for name, n := range total {
+		availability[name] = float64(up[name]) / float64(n)
+	}
+	return availability, nil
+}
+
+// selectSeries 读取时间范围 [from, to]（毫秒）涉及的段文件，返回匹配的序列及其样本（按时间排序）
+func (s *Store) selectSeries(matchers []matcher, from, to int64) ([]*series, error) {
+	days, err := s.segmentDays()
+	if err != nil {
+		return nil, err
+	}
+	fromDay := time.UnixMilli(from).UTC().Format(dayLayout)
+	toDay := time.UnixMilli(to).UTC().Format(dayLayout)
+
+	byKey := make(map[string]*series)
+	var ordered []*series
+	for _, day := range days {
+		if day < fromDay || day > toDay {
+			continue
+		}
+		// 段内序列 ID 到查询结果的映射，不匹配的序列不在其中
+		selected := make(map[uint64]*series)
+		_, err := scanSegment(filepath.Join(s.cfg.Path, day+segmentSuffix), func(typ byte, payload []byte) error {
+			switch typ {
+			case recordSeries:
+				id, labels, err := decodeSeries(payload)
+				if err != nil {
+					return err
+				}
+				for i := range matchers {
+					if !matchers[i].matches(labels) {
+						return nil
+					}
+				}
+				key := seriesKey(labels)
+				ser := byKey[key]
+				if ser == nil {
+					ser = &series{labels: labels}
+					byKey[key] = ser
+					ordered = append(ordered, ser)
+				}
+				selected[id] = ser
+			case recordSamples:
+				if len(selected) == 0 {
+					return nil
+				}
+				if ts, ok := sampleTime(payload); !ok || ts < from || ts > to {
+					return nil
+				}
+				return decodeSamples(payload, func(ts int64, id uint64, value float64) {
+					if ser := selected[id]; ser != nil {
+						ser.ts = append(ser.ts, ts)
+						ser.values = append(ser.values, value)
+					}
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("读取段文件 %s 失败: %w", day+segmentSuffix, err)
+		}
+	}
+	return ordered, nil
+}
+
+// parseTime 解析 Unix 时间戳（秒，可带小数）或 RFC3339 时间
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, fmt.Errorf("不能为空")
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9)), nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// parseStep 解析秒数（可带小数）或 Go duration（如 30s、1m）
+func parseStep(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("不能为空")
+	}
+	var step time.Duration
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		step = time.Duration(f * float64(time.Second))
+	} else if step, err = time.ParseDuration(s); err != nil {
+		return 0, err
+	}
+	if step < time.Millisecond {
+		return 0, fmt.Errorf("必须大于 0")
+	}
+	return step, nil
+}
+
+// formatValue 与 Prometheus HTTP API 一致的样本值格式
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":    "error",
+		"errorType": "bad_data",
+		"error":     err.Error(),
+	})
+}
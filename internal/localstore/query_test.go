@@ -0,0 +1,358 @@
+package localstore
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []matcher // 只比较 name、op、value
+		err   bool
+	}{
+		{query: "db_probe_up", want: []matcher{{name: "__name__", op: "=", value: "db_probe_up"}}},
+		{query: "  db_probe_up{}  ", want: []matcher{{name: "__name__", op: "=", value: "db_probe_up"}}},
+		{
+			query: `db_probe_up{env="prod", db_name=~"mysql-.*",zone!="b" , role!~"replica|standby"}`,
+			want: []matcher{
+				{name: "__name__", op: "=", value: "db_probe_up"},
+				{name: "env", op: "=", value: "prod"},
+				{name: "db_name", op: "=~", value: "mysql-.*"},
+				{name: "zone", op: "!=", value: "b"},
+				{name: "role", op: "!~", value: "replica|standby"},
+			},
+		},
+		{query: `{__name__="db_probe_up"}`, want: []matcher{{name: "__name__", op: "=", value: "db_probe_up"}}},
+		{query: `{env="prod",}`, want: []matcher{{name: "env", op: "=", value: "prod"}}},
+		{query: `{msg="say \"hi\"\n"}`, want: []matcher{{name: "msg", op: "=", value: "say \"hi\"\n"}}},
+		{query: `db:recorded_rule`, want: []matcher{{name: "__name__", op: "=", value: "db:recorded_rule"}}},
+		{query: "", err: true},
+		{query: "{}", err: true},
+		{query: `{env=""}`, err: true},
+		{query: `{env=~".*"}`, err: true},
+		{query: `{env!="prod"}`, err: true},
+		{query: `rate(db_probe_up[5m])`, err: true},
+		{query: `db_probe_up + 1`, err: true},
+		{query: `db_probe_up{env="prod"`, err: true},
+		{query: `db_probe_up{env=prod}`, err: true},
+		{query: `db_probe_up{env=~"("}`, err: true},
+		{query: `db_probe_up{1env="prod"}`, err: true},
+		{query: `db_probe_up{env="prod" zone="a"}`, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			matchers, err := parseSelector(tt.query)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("parseSelector(%q) = %v, want error", tt.query, matchers)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSelector(%q): %v", tt.query, err)
+			}
+			got := make([]matcher, len(matchers))
+			for i, m := range matchers {
+				got[i] = matcher{name: m.name, op: m.op, value: m.value}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSelector(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcherRegexAnchored(t *testing.T) {
+	matchers, err := parseSelector(`{db_name=~"mysql"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matchers[0].matches([]label{{"db_name", "mysql-prod"}}) {
+		t.Error("regex matcher should be anchored like Prometheus")
+	}
+	if !matchers[0].matches([]label{{"db_name", "mysql"}}) {
+		t.Error("regex matcher should match the full value")
+	}
+}
+
+// testStore 本地存储和一个可以修改值的 db_probe_up 序列
+type testStore struct {
+	*Store
+	registry *prometheus.Registry
+	up       *prometheus.GaugeVec
+}
+
+func newTestStore(t *testing.T, interval time.Duration) *testStore {
+	t.Helper()
+	registry := prometheus.NewRegistry()
+	up := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "db_probe_up"}, []string{"db_name"})
+	registry.MustRegister(up)
+	store, err := New(config.LocalStorageConfig{
+		Path:      t.TempDir(),
+		Retention: 24 * time.Hour,
+		Interval:  interval,
+		Metrics:   []string{"db_probe_up"},
+	}, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if store.segment != nil {
+			store.segment.close()
+		}
+	})
+	return &testStore{Store: store, registry: registry, up: up}
+}
+
+// record 在 at 时刻写入一次采样
+func (s *testStore) record(t *testing.T, at time.Time, values map[string]float64) {
+	t.Helper()
+	s.up.Reset()
+	for name, value := range values {
+		s.up.WithLabelValues(name).Set(value)
+	}
+	families, err := s.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.write(at, families); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type queryResult struct {
+	Status    string `json:"status"`
+	Error     string `json:"error"`
+	ErrorType string `json:"errorType"`
+	Data      struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Values [][2]any          `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+func (s *testStore) query(t *testing.T, query string, start, end time.Time, step string) (int, queryResult) {
+	t.Helper()
+	params := url.Values{
+		"query": {query},
+		"start": {strconv.FormatInt(start.Unix(), 10)},
+		"end":   {end.Format(time.RFC3339)},
+		"step":  {step},
+	}
+	rec := httptest.NewRecorder()
+	s.QueryRangeHandler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/query_range?"+params.Encode(), nil))
+	var result queryResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, result
+}
+
+// points 把序列的点转换为 相对 base 的秒数 -> 值
+func points(t *testing.T, values [][2]any, base time.Time) map[int]string {
+	t.Helper()
+	out := make(map[int]string, len(values))
+	for _, v := range values {
+		ts, ok := v[0].(float64)
+		if !ok {
+			t.Fatalf("timestamp %v is not a number", v[0])
+		}
+		out[int(ts)-int(base.Unix())] = v[1].(string)
+	}
+	return out
+}
+
+func TestQueryRangeStep(t *testing.T) {
+	s := newTestStore(t, 15*time.Second)
+	base := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	s.record(t, base, map[string]float64{"a": 1, "b": 0})
+	s.record(t, base.Add(15*time.Second), map[string]float64{"a": 0, "b": 1})
+	s.record(t, base.Add(30*time.Second), map[string]float64{"a": 1})
+
+	tests := []struct {
+		name  string
+		query string
+		start time.Duration
+		end   time.Duration
+		step  string
+		want  map[string]map[int]string
+	}{
+		{
+			name:  "step 10s takes latest sample at or before each point",
+			query: `db_probe_up{db_name="a"}`,
+			start: 0, end: 40 * time.Second, step: "10",
+			want: map[string]map[int]string{"a": {0: "1", 10: "1", 20: "0", 30: "1", 40: "1"}},
+		},
+		{
+			name:  "duration step",
+			query: `db_probe_up`,
+			start: 0, end: 30 * time.Second, step: "15s",
+			want: map[string]map[int]string{
+				"a": {0: "1", 15: "0", 30: "1"},
+				// b 在最后一次采样中消失，lookback 内仍返回上一次的值
+				"b": {0: "0", 15: "1", 30: "1"},
+			},
+		},
+		{
+			name:  "fractional step",
+			query: `db_probe_up{db_name="b"}`,
+			start: 0, end: 15 * time.Second, step: "7.5",
+			want: map[string]map[int]string{"b": {0: "0", 7: "0", 15: "1"}},
+		},
+		{
+			name:  "points before the first sample are omitted",
+			query: `db_probe_up{db_name="a"}`,
+			start: -20 * time.Second, end: 0, step: "10s",
+			want: map[string]map[int]string{"a": {0: "1"}},
+		},
+		{
+			name:  "no matching series",
+			query: `db_probe_up{db_name="c"}`,
+			start: 0, end: 30 * time.Second, step: "15s",
+			want: map[string]map[int]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, result := s.query(t, tt.query, base.Add(tt.start), base.Add(tt.end), tt.step)
+			if code != http.StatusOK || result.Status != "success" || result.Data.ResultType != "matrix" {
+				t.Fatalf("code = %d, result = %+v", code, result)
+			}
+			got := make(map[string]map[int]string)
+			for _, ser := range result.Data.Result {
+				if ser.Metric["__name__"] != "db_probe_up" {
+					t.Errorf("metric = %v, want __name__ label", ser.Metric)
+				}
+				got[ser.Metric["db_name"]] = points(t, ser.Values, base)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("result = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryRangeLookback(t *testing.T) {
+	base := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+
+	t.Run("default 5m", func(t *testing.T) {
+		s := newTestStore(t, 15*time.Second)
+		s.record(t, base, map[string]float64{"a": 1})
+		s.record(t, base.Add(10*time.Minute), map[string]float64{"a": 0})
+		_, result := s.query(t, "db_probe_up", base, base.Add(10*time.Minute), "60")
+		if len(result.Data.Result) != 1 {
+			t.Fatalf("result = %+v", result)
+		}
+		// 5 分钟（含）以内取上一个样本，之后到下一个样本之间没有值
+		want := map[int]string{0: "1", 60: "1", 120: "1", 180: "1", 240: "1", 300: "1", 600: "0"}
+		if got := points(t, result.Data.Result[0].Values, base); !reflect.DeepEqual(got, want) {
+			t.Errorf("points = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("two intervals when longer than 5m", func(t *testing.T) {
+		s := newTestStore(t, 4*time.Minute)
+		s.record(t, base, map[string]float64{"a": 1})
+		_, result := s.query(t, "db_probe_up", base, base.Add(10*time.Minute), "1m")
+		if len(result.Data.Result) != 1 {
+			t.Fatalf("result = %+v", result)
+		}
+		got := points(t, result.Data.Result[0].Values, base)
+		if len(got) != 9 || got[480] != "1" {
+			t.Errorf("points = %v, want 0..480s with lookback 8m", got)
+		}
+	})
+
+	t.Run("sample before start within lookback", func(t *testing.T) {
+		s := newTestStore(t, 15*time.Second)
+		s.record(t, base, map[string]float64{"a": 1})
+		_, result := s.query(t, "db_probe_up", base.Add(2*time.Minute), base.Add(2*time.Minute), "15s")
+		if len(result.Data.Result) != 1 || len(result.Data.Result[0].Values) != 1 {
+			t.Errorf("result = %+v, want the sample 2m before start", result)
+		}
+	})
+
+	t.Run("across day segments", func(t *testing.T) {
+		s := newTestStore(t, 15*time.Second)
+		midnight := time.Date(2026, 1, 6, 0, 0, 0, 0, time.UTC)
+		s.record(t, midnight.Add(-10*time.Second), map[string]float64{"a": 1})
+		s.record(t, midnight.Add(5*time.Second), map[string]float64{"a": 0})
+		days, _ := s.segmentDays()
+		if !reflect.DeepEqual(days, []string{"2026-01-05", "2026-01-06"}) {
+			t.Fatalf("segment days = %v", days)
+		}
+		_, result := s.query(t, "db_probe_up", midnight, midnight.Add(10*time.Second), "5s")
+		if len(result.Data.Result) != 1 {
+			t.Fatalf("result = %+v", result)
+		}
+		want := map[int]string{0: "1", 5: "0", 10: "0"}
+		if got := points(t, result.Data.Result[0].Values, midnight); !reflect.DeepEqual(got, want) {
+			t.Errorf("points = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestQueryRangeErrors(t *testing.T) {
+	s := newTestStore(t, 15*time.Second)
+	base := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		query string
+		start time.Time
+		end   time.Time
+		step  string
+	}{
+		{"bad selector", "sum(db_probe_up)", base, base.Add(time.Minute), "15s"},
+		{"zero step", "db_probe_up", base, base.Add(time.Minute), "0"},
+		{"negative step", "db_probe_up", base, base.Add(time.Minute), "-15s"},
+		{"bad step", "db_probe_up", base, base.Add(time.Minute), "fast"},
+		{"end before start", "db_probe_up", base, base.Add(-time.Minute), "15s"},
+		{"too many points", "db_probe_up", base, base.Add(maxPoints * time.Second), "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, result := s.query(t, tt.query, tt.start, tt.end, tt.step)
+			if code != http.StatusBadRequest || result.Status != "error" || result.ErrorType != "bad_data" || result.Error == "" {
+				t.Errorf("code = %d, result = %+v; want 400 bad_data", code, result)
+			}
+		})
+	}
+}
+
+func TestAvailability(t *testing.T) {
+	s := newTestStore(t, 15*time.Second)
+	base := time.Date(2026, 1, 5, 23, 59, 30, 0, time.UTC)
+	// 跨越 UTC 零点（两个段文件），b 有一次 NaN（不计入），c 只在窗口之外有样本
+	s.record(t, base.Add(-time.Hour), map[string]float64{"c": 1})
+	s.record(t, base, map[string]float64{"a": 1, "b": 0})
+	s.record(t, base.Add(15*time.Second), map[string]float64{"a": 1, "b": math.NaN()})
+	s.record(t, base.Add(30*time.Second), map[string]float64{"a": 0, "b": 1})
+	s.record(t, base.Add(45*time.Second), map[string]float64{"a": 1, "b": 1})
+
+	got, err := s.Availability(base, base.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{"a": 0.75, "b": 2.0 / 3}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Availability = %v, want %v", got, want)
+	}
+
+	s.metrics = map[string]bool{"db_probe_duration_seconds": true}
+	if _, err := s.Availability(base, base.Add(time.Minute)); err == nil {
+		t.Error("expected error without db_probe_up in metrics")
+	}
+}
@@ -0,0 +1,273 @@
+package localstore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+)
+
+// 段文件由连续的记录组成，每条记录的格式为：
+//
+//	类型(1 字节) | 内容长度(uvarint) | 内容 | CRC32(4 字节，覆盖类型和内容)
+//
+// 序列记录（recordSeries）：序列 ID(uvarint) | label 数(uvarint) | 每个 label 的 name、value（uvarint 长度 + 字节）
+// 样本记录（recordSamples）：时间戳毫秒(varint) | 样本数(uvarint) | 每个样本的序列 ID(uvarint) + 值(8 字节)
+//
+// 序列 ID 只在单个段文件内有效，序列第一次出现在某个段文件中时先写入序列记录
+// 进程崩溃时最后一条记录可能不完整，读取时遇到长度或校验和不正确的记录即停止，重新打开时截断
+const (
+	recordSeries  byte = 1
+	recordSamples byte = 2
+)
+
+// maxRecordSize 单条记录内容的上限，长度字段损坏时避免分配过大的内存
+const maxRecordSize = 64 << 20
+
+var errCorrupted = errors.New("记录不完整或已损坏")
+
+// label 时间序列的一个 label
+type label struct {
+	name  string
+	value string
+}
+
+// sample 一个序列在某一时刻的值
+type sample struct {
+	id    uint64
+	value float64
+}
+
+// segmentWriter 追加写入一个段文件
+type segmentWriter struct {
+	day  string
+	file *os.File
+	w    *bufio.Writer
+	// ids 序列 key 到段内序列 ID 的映射
+	ids  map[string]uint64
+	next uint64
+	buf  []byte
+}
+
+// openSegment 打开段文件用于追加；文件已存在时读取已有的序列，并截断末尾不完整的记录
+func openSegment(path, day string) (*segmentWriter, error) {
+	s := &segmentWriter{day: day, ids: make(map[string]uint64), next: 1}
+
+	valid, err := scanSegment(path, func(typ byte, payload []byte) error {
+		if typ != recordSeries {
+			return nil
+		}
+		id, labels, err := decodeSeries(payload)
+		if err != nil {
+			return err
+		}
+		s.ids[seriesKey(labels)] = id
+		if id >= s.next {
+			s.next = id + 1
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Truncate(valid); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(valid, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	s.file = file
+	s.w = bufio.NewWriterSize(file, 64*1024)
+	return s, nil
+}
+
+// seriesID 返回序列在段内的 ID，第一次出现时写入序列记录
+func (s *segmentWriter) seriesID(key string, labels []label) (uint64, error) {
+	if id, ok := s.ids[key]; ok {
+		return id, nil
+	}
+	id := s.next
+	s.buf = binary.AppendUvarint(s.buf[:0], id)
+	s.buf = binary.AppendUvarint(s.buf, uint64(len(labels)))
+	for _, l := range labels {
+		s.buf = appendString(s.buf, l.name)
+		s.buf = appendString(s.buf, l.value)
+	}
+	if err := s.writeRecord(recordSeries, s.buf); err != nil {
+		return 0, err
+	}
+	s.ids[key] = id
+	s.next++
+	return id, nil
+}
+
+// appendSamples 写入一次采样的所有样本并刷到文件，查询可以立即读到
+func (s *segmentWriter) appendSamples(ts int64, samples []sample) error {
+	s.buf = binary.AppendVarint(s.buf[:0], ts)
+	s.buf = binary.AppendUvarint(s.buf, uint64(len(samples)))
+	for _, smp := range samples {
+		s.buf = binary.AppendUvarint(s.buf, smp.id)
+		s.buf = binary.LittleEndian.AppendUint64(s.buf, math.Float64bits(smp.value))
+	}
+	if err := s.writeRecord(recordSamples, s.buf); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+func (s *segmentWriter) writeRecord(typ byte, payload []byte) error {
+	var header [1 + binary.MaxVarintLen64]byte
+	header[0] = typ
+	n := binary.PutUvarint(header[1:], uint64(len(payload)))
+	crc := crc32.NewIEEE()
+	crc.Write(header[:1])
+	crc.Write(payload)
+	if _, err := s.w.Write(header[:1+n]); err != nil {
+		return err
+	}
+	if _, err := s.w.Write(payload); err != nil {
+		return err
+	}
+	return binary.Write(s.w, binary.LittleEndian, crc.Sum32())
+}
+
+func (s *segmentWriter) close() error {
+	err := s.w.Flush()
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// scanSegment 按顺序读取段文件中的记录，返回最后一条完整记录之后的偏移
+// 遇到不完整或损坏的记录时停止（视为文件末尾）；fn 返回错误时停止并返回该错误
+func scanSegment(path string, fn func(typ byte, payload []byte) error) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	r := bufio.NewReaderSize(file, 256*1024)
+	var offset int64
+	var payload []byte
+	for {
+		typ, err := r.ReadByte()
+		if err != nil {
+			return offset, nil
+		}
+		size, err := binary.ReadUvarint(r)
+		if err != nil || size > maxRecordSize {
+			return offset, nil
+		}
+		if cap(payload) < int(size) {
+			payload = make([]byte, size)
+		}
+		payload = payload[:size]
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return offset, nil
+		}
+		var sum uint32
+		if err := binary.Read(r, binary.LittleEndian, &sum); err != nil {
+			return offset, nil
+		}
+		crc := crc32.NewIEEE()
+		crc.Write([]byte{typ})
+		crc.Write(payload)
+		if crc.Sum32() != sum {
+			return offset, nil
+		}
+		if err := fn(typ, payload); err != nil {
+			return offset, err
+		}
+		offset += 1 + int64(uvarintLen(size)) + int64(size) + 4
+	}
+}
+
+func decodeSeries(payload []byte) (uint64, []label, error) {
+	id, n := binary.Uvarint(payload)
+	if n <= 0 {
+		return 0, nil, errCorrupted
+	}
+	payload = payload[n:]
+	count, n := binary.Uvarint(payload)
+	if n <= 0 || count > uint64(len(payload)) {
+		return 0, nil, errCorrupted
+	}
+	payload = payload[n:]
+	labels := make([]label, 0, count)
+	for i := uint64(0); i < count; i++ {
+		var name, value string
+		var ok bool
+		if name, payload, ok = readString(payload); !ok {
+			return 0, nil, errCorrupted
+		}
+		if value, payload, ok = readString(payload); !ok {
+			return 0, nil, errCorrupted
+		}
+		labels = append(labels, label{name: name, value: value})
+	}
+	return id, labels, nil
+}
+
+// decodeSamples 解析样本记录，fn 对每个样本调用一次
+func decodeSamples(payload []byte, fn func(ts int64, id uint64, value float64)) error {
+	ts, n := binary.Varint(payload)
+	if n <= 0 {
+		return errCorrupted
+	}
+	payload = payload[n:]
+	count, n := binary.Uvarint(payload)
+	if n <= 0 {
+		return errCorrupted
+	}
+	payload = payload[n:]
+	for i := uint64(0); i < count; i++ {
+		id, n := binary.Uvarint(payload)
+		if n <= 0 || len(payload) < n+8 {
+			return errCorrupted
+		}
+		value := math.Float64frombits(binary.LittleEndian.Uint64(payload[n:]))
+		payload = payload[n+8:]
+		fn(ts, id, value)
+	}
+	return nil
+}
+
+// sampleTime 读取样本记录的时间戳，不在查询范围内的记录无需解析样本
+func sampleTime(payload []byte) (int64, bool) {
+	ts, n := binary.Varint(payload)
+	return ts, n > 0
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func readString(payload []byte) (string, []byte, bool) {
+	size, n := binary.Uvarint(payload)
+	if n <= 0 || uint64(len(payload)-n) < size {
+		return "", nil, false
+	}
+	return string(payload[n : n+int(size)]), payload[n+int(size):], true
+}
+
+func uvarintLen(v uint64) int {
+	n := 1
+	for v >= 0x80 {
+		v >>= 7
+		n++
+	}
+	return n
+}
@@ -0,0 +1,221 @@
+package localstore
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// scannedSegment 段文件中读取到的序列和样本
+type scannedSegment struct {
+	series  map[uint64][]label
+	samples []scannedSample
+}
+
+type scannedSample struct {
+	ts    int64
+	id    uint64
+	value float64
+}
+
+func readSegment(t *testing.T, path string) (scannedSegment, int64) {
+	t.Helper()
+	seg := scannedSegment{series: make(map[uint64][]label)}
+	valid, err := scanSegment(path, func(typ byte, payload []byte) error {
+		switch typ {
+		case recordSeries:
+			id, labels, err := decodeSeries(payload)
+			if err != nil {
+				return err
+			}
+			seg.series[id] = labels
+		case recordSamples:
+			return decodeSamples(payload, func(ts int64, id uint64, value float64) {
+				seg.samples = append(seg.samples, scannedSample{ts: ts, id: id, value: value})
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("scanSegment: %v", err)
+	}
+	return seg, valid
+}
+
+var (
+	testSeriesUp   = []label{{"__name__", "db_probe_up"}, {"db_name", "mysql-prod"}}
+	testSeriesDown = []label{{"__name__", "db_probe_up"}, {"db_name", "pg-prod"}}
+)
+
+// writeTestSegment 写入两个序列、两次采样，返回关闭后的文件长度
+func writeTestSegment(t *testing.T, path string) int64 {
+	t.Helper()
+	w, err := openSegment(path, "2026-01-05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	up, err := w.seriesID(seriesKey(testSeriesUp), testSeriesUp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	down, err := w.seriesID(seriesKey(testSeriesDown), testSeriesDown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.appendSamples(1000, []sample{{up, 1}, {down, 0}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.appendSamples(16000, []sample{{up, 1}, {down, math.Inf(1)}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.close(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
+
+func TestSegmentRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "2026-01-05.seg")
+	size := writeTestSegment(t, path)
+
+	seg, valid := readSegment(t, path)
+	if valid != size {
+		t.Errorf("valid offset = %d, want file size %d", valid, size)
+	}
+	wantSeries := map[uint64][]label{1: testSeriesUp, 2: testSeriesDown}
+	if !reflect.DeepEqual(seg.series, wantSeries) {
+		t.Errorf("series = %v, want %v", seg.series, wantSeries)
+	}
+	wantSamples := []scannedSample{{1000, 1, 1}, {1000, 2, 0}, {16000, 1, 1}, {16000, 2, math.Inf(1)}}
+	if !reflect.DeepEqual(seg.samples, wantSamples) {
+		t.Errorf("samples = %v, want %v", seg.samples, wantSamples)
+	}
+
+	// 重新打开时沿用已有的序列 ID，新序列的 ID 接着分配，已有的序列不重复写入
+	w, err := openSegment(path, "2026-01-05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := w.seriesID(seriesKey(testSeriesDown), testSeriesDown); id != 2 {
+		t.Errorf("existing series id = %d, want 2", id)
+	}
+	extra := []label{{"__name__", "db_probe_up"}, {"db_name", "oracle-prod"}}
+	id, err := w.seriesID(seriesKey(extra), extra)
+	if err != nil || id != 3 {
+		t.Errorf("new series id = %d, %v; want 3", id, err)
+	}
+	if err := w.appendSamples(31000, []sample{{2, 1}, {3, 0.5}}); err != nil {
+		t.Fatal(err)
+	}
+	w.close()
+
+	seg, _ = readSegment(t, path)
+	if len(seg.series) != 3 || !reflect.DeepEqual(seg.series[3], extra) {
+		t.Errorf("series after reopen = %v", seg.series)
+	}
+	if got := seg.samples[len(seg.samples)-2:]; !reflect.DeepEqual(got, []scannedSample{{31000, 2, 1}, {31000, 3, 0.5}}) {
+		t.Errorf("appended samples = %v", got)
+	}
+}
+
+func TestSegmentTornTail(t *testing.T) {
+	// 一条完整的样本记录，作为不完整尾部的来源
+	recordPath := filepath.Join(t.TempDir(), "record.seg")
+	w, err := openSegment(recordPath, "2026-01-05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.appendSamples(46000, []sample{{1, 42}}); err != nil {
+		t.Fatal(err)
+	}
+	w.close()
+	record, err := os.ReadFile(recordPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	badCRC := append([]byte(nil), record...)
+	badCRC[len(badCRC)-1] ^= 0xff
+	badPayload := append([]byte(nil), record...)
+	badPayload[3] ^= 0xff
+
+	tests := []struct {
+		name string
+		tail []byte
+	}{
+		{name: "type only", tail: record[:1]},
+		{name: "partial length", tail: []byte{recordSamples, 0x80}},
+		{name: "partial payload", tail: record[:len(record)-6]},
+		{name: "missing crc", tail: record[:len(record)-4]},
+		{name: "partial crc", tail: record[:len(record)-1]},
+		{name: "bad crc", tail: badCRC},
+		{name: "corrupted payload", tail: badPayload},
+		{name: "oversized length", tail: []byte{recordSamples, 0xff, 0xff, 0xff, 0xff, 0x0f}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "2026-01-05.seg")
+			size := writeTestSegment(t, path)
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Write(tt.tail)
+			f.Close()
+
+			// 读取时忽略不完整的尾部
+			seg, valid := readSegment(t, path)
+			if valid != size || len(seg.samples) != 4 {
+				t.Fatalf("valid = %d (want %d), samples = %d (want 4)", valid, size, len(seg.samples))
+			}
+
+			// 重新打开时截断尾部，之后追加的记录可以正常读到
+			w, err := openSegment(path, "2026-01-05")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info, _ := os.Stat(path); info.Size() != size {
+				t.Errorf("size after reopen = %d, want %d", info.Size(), size)
+			}
+			if err := w.appendSamples(46000, []sample{{1, 7}}); err != nil {
+				t.Fatal(err)
+			}
+			w.close()
+			seg, _ = readSegment(t, path)
+			if len(seg.samples) != 5 || seg.samples[4] != (scannedSample{46000, 1, 7}) {
+				t.Errorf("samples after append = %v", seg.samples)
+			}
+		})
+	}
+}
+
+func TestDecodeCorruptedRecords(t *testing.T) {
+	for _, payload := range [][]byte{
+		nil,
+		{0x01},                  // 缺少 label 数
+		{0x01, 0x05},            // label 数超过剩余长度
+		{0x01, 0x01, 0x03, 'a'}, // name 长度超过剩余长度
+		{0x01, 0x01, 0x01, 'a'}, // 缺少 value
+	} {
+		if _, _, err := decodeSeries(payload); err == nil {
+			t.Errorf("decodeSeries(%x) succeeded, want error", payload)
+		}
+	}
+	for _, payload := range [][]byte{
+		nil,
+		{0x02},             // 缺少样本数
+		{0x02, 0x01},       // 缺少样本
+		{0x02, 0x01, 0x01}, // 缺少值
+		append([]byte{0x02, 0x01, 0x01}, bytes.Repeat([]byte{0}, 7)...),
+	} {
+		if err := decodeSamples(payload, func(int64, uint64, float64) {}); err == nil {
+			t.Errorf("decodeSamples(%x) succeeded, want error", payload)
+		}
+	}
+}
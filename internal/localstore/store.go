@@ -0,0 +1,241 @@
+// Package localstore 内置的轻量本地时序存储，适用于没有任何 Prometheus 的离线站点
+// 每个采样间隔从指标注册表采集一次配置的指标，追加到按天（UTC）切分的段文件中，保留 retention 时长；
+// 通过 /api/v1/query_range 按 Prometheus HTTP API 的格式查询，只支持简单的序列选择器（如 db_probe_up{env="prod"}），不支持 PromQL 函数和运算
+// 每次采样写入后立即刷到文件（不 fsync），进程崩溃时最多丢失最后一次采样
+package localstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	// segmentSuffix 段文件后缀，文件名为 UTC 日期，如 2026-01-05.seg
+	segmentSuffix = ".seg"
+	dayLayout     = "2006-01-02"
+	// cleanupInterval 检查过期段文件的间隔
+	cleanupInterval = time.Hour
+)
+
+// Store 本地时序存储
+type Store struct {
+	cfg      config.LocalStorageConfig
+	gatherer prometheus.Gatherer
+	metrics  map[string]bool
+
+	// segment 当天的段文件，只在采样 goroutine 中访问
+	segment *segmentWriter
+	samples []sample
+	// failing 写入是否处于持续失败状态（如磁盘已满），只在进入和恢复时输出日志
+	failing bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New 创建存储目录，gatherer 通常为 prometheus.DefaultGatherer
+func New(cfg config.LocalStorageConfig, gatherer prometheus.Gatherer) (*Store, error) {
+	if err := os.MkdirAll(cfg.Path, 0o755); err != nil {
+		return nil, fmt.Errorf("创建本地存储目录失败: %w", err)
+	}
+	names := make(map[string]bool, len(cfg.Metrics))
+	for _, name := range cfg.Metrics {
+		names[name] = true
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Store{
+		cfg:      cfg,
+		gatherer: gatherer,
+		metrics:  names,
+		ctx:      ctx,
+		cancel:   cancel,
+	}, nil
+}
+
+// Start 启动采样循环
+func (s *Store) Start() {
+	logger.L().Infow("本地时序存储已启用",
+		"path", s.cfg.Path,
+		"retention", s.cfg.Retention,
+		"interval", s.cfg.Interval,
+		"metrics", s.cfg.Metrics,
+	)
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop 停止采样并关闭当天的段文件
+func (s *Store) Stop() {
+	s.cancel()
+	s.wg.Wait()
+	if s.segment != nil {
+		if err := s.segment.close(); err != nil {
+			logger.L().Warnw("关闭本地存储段文件失败", "day", s.segment.day, "error", err)
+		}
+	}
+}
+
+func (s *Store) run() {
+	defer s.wg.Done()
+
+	s.cleanup(time.Now())
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	cleanup := time.NewTicker(cleanupInterval)
+	defer cleanup.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.collect(now)
+		case now := <-cleanup.C:
+			s.cleanup(now)
+		}
+	}
+}
+
+// collect 采集一次配置的指标并追加到当天的段文件
+func (s *Store) collect(now time.Time) {
+	families, err := s.gatherer.Gather()
+	if err != nil {
+		// Gather 出错时仍会返回能采集到的指标，照常写入
+		logger.L().Warnw("本地存储采集指标出错", "error", err)
+	}
+	if err := s.write(now, families); err != nil {
+		if !s.failing {
+			s.failing = true
+			logger.L().Errorw("本地存储写入失败，本次采样丢失，将在下一个间隔重试", "path", s.cfg.Path, "error", err)
+		}
+		// 关闭段文件，下一次写入时重新打开并截断写了一半的记录
+		if s.segment != nil {
+			s.segment.close()
+			s.segment = nil
+		}
+		return
+	}
+	if s.failing {
+		s.failing = false
+		logger.L().Infow("本地存储写入恢复", "path", s.cfg.Path)
+	}
+}
+
+func (s *Store) write(now time.Time, families []*dto.MetricFamily) error {
+	day := now.UTC().Format(dayLayout)
+	if s.segment == nil || s.segment.day != day {
+		if s.segment != nil {
+			if err := s.segment.close(); err != nil {
+				logger.L().Warnw("关闭本地存储段文件失败", "day", s.segment.day, "error", err)
+			}
+			s.segment = nil
+		}
+		segment, err := openSegment(filepath.Join(s.cfg.Path, day+segmentSuffix), day)
+		if err != nil {
+			return err
+		}
+		s.segment = segment
+	}
+
+	s.samples = s.samples[:0]
+	for _, mf := range families {
+		name := mf.GetName()
+		if !s.metrics[name] {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			var value float64
+			switch mf.GetType() {
+			case dto.MetricType_GAUGE:
+				value = m.GetGauge().GetValue()
+			case dto.MetricType_COUNTER:
+				value = m.GetCounter().GetValue()
+			case dto.MetricType_UNTYPED:
+				value = m.GetUntyped().GetValue()
+			default:
+				continue // Histogram、Summary 不存储
+			}
+			labels := make([]label, 0, len(m.GetLabel())+1)
+			labels = append(labels, label{name: "__name__", value: name})
+			for _, lp := range m.GetLabel() {
+				labels = append(labels, label{name: lp.GetName(), value: lp.GetValue()})
+			}
+			sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+			id, err := s.segment.seriesID(seriesKey(labels), labels)
+			if err != nil {
+				return err
+			}
+			s.samples = append(s.samples, sample{id: id, value: value})
+		}
+	}
+	if len(s.samples) == 0 {
+		return nil
+	}
+	return s.segment.appendSamples(now.UnixMilli(), s.samples)
+}
+
+// cleanup 删除整天都超出保留时长的段文件
+func (s *Store) cleanup(now time.Time) {
+	days, err := s.segmentDays()
+	if err != nil {
+		logger.L().Warnw("读取本地存储目录失败", "path", s.cfg.Path, "error", err)
+		return
+	}
+	cutoff := now.Add(-s.cfg.Retention)
+	for _, day := range days {
+		start, _ := time.Parse(dayLayout, day)
+		if !start.Add(24 * time.Hour).Before(cutoff) {
+			continue
+		}
+		path := filepath.Join(s.cfg.Path, day+segmentSuffix)
+		if err := os.Remove(path); err != nil {
+			logger.L().Warnw("删除过期的本地存储段文件失败", "file", path, "error", err)
+			continue
+		}
+		logger.L().Infow("已删除过期的本地存储段文件", "file", path)
+	}
+}
+
+// segmentDays 返回存储目录中所有段文件的日期，按时间排序
+func (s *Store) segmentDays() ([]string, error) {
+	entries, err := os.ReadDir(s.cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	var days []string
+	for _, entry := range entries {
+		day, ok := strings.CutSuffix(entry.Name(), segmentSuffix)
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		if _, err := time.Parse(dayLayout, day); err != nil {
+			continue
+		}
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	return days, nil
+}
+
+// seriesKey 序列的唯一标识，labels 需要已按名称排序
+func seriesKey(labels []label) string {
+	var b strings.Builder
+	for _, l := range labels {
+		b.WriteString(l.name)
+		b.WriteByte(0xff)
+		b.WriteString(l.value)
+		b.WriteByte(0xff)
+	}
+	return b.String()
+}
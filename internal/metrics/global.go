@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// globalGatherer 在 gatherer 采集到的每条时间序列上追加全局 label（global_labels）
+type globalGatherer struct {
+	gatherer prometheus.Gatherer
+	labels   []*dto.LabelPair
+}
+
+// WithGlobalLabels 返回追加了全局 label 的 gatherer，未配置全局 label 时直接返回 gatherer
+// 全局 label 不能与目标的 label 维度（project、db_name 等）同名；与其他指标自身的 label 同名时以指标自身的值为准
+func WithGlobalLabels(gatherer prometheus.Gatherer, labels map[string]string) (prometheus.Gatherer, error) {
+	if len(labels) == 0 {
+		return gatherer, nil
+	}
+	g := &globalGatherer{gatherer: gatherer}
+	for name, value := range labels {
+		for _, reserved := range targetLabelNames {
+			if name == reserved {
+				return nil, fmt.Errorf("global_labels 不能使用目标的 label 名称: %s", name)
+			}
+		}
+		g.labels = append(g.labels, &dto.LabelPair{Name: &name, Value: &value})
+	}
+	sort.Slice(g.labels, func(i, j int) bool { return g.labels[i].GetName() < g.labels[j].GetName() })
+	return g, nil
+}
+
+// Gather 采集指标并追加全局 label，保持每条时间序列的 label 按名称排序
+// 注册表每次采集都会生成新的 dto 对象，可以直接修改
+func (g *globalGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	for _, family := range families {
+		for _, m := range family.Metric {
+			appended := false
+			for _, global := range g.labels {
+				if !hasLabel(m, global.GetName()) {
+					m.Label = append(m.Label, global)
+					appended = true
+				}
+			}
+			if appended {
+				sort.Slice(m.Label, func(i, j int) bool { return m.Label[i].GetName() < m.Label[j].GetName() })
+			}
+		}
+	}
+	return families, err
+}
+
+// hasLabel 时间序列是否已有该名称的 label
+func hasLabel(m *dto.Metric, name string) bool {
+	for _, l := range m.Label {
+		if l.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
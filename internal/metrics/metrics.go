@@ -1,10 +1,11 @@
 // Package metrics 定义和注册所有 Prometheus 指标
-// 提供 13 个指标用于监控数据库可用性、延迟、失败统计等
-// 所有指标都包含统一的 label 维度：project、env、db_name、db_type、db_host、db_ip、role
-// 提供便捷的更新函数来更新指标值
+// 提供 55 个指标用于监控数据库可用性、延迟、失败统计等
+// 所有指标都包含统一的 label 维度：project、env、db_name、db_type、db_host、db_ip、role、zone、same_zone、schema
+// 每个目标通过 TargetMetrics 缓存已解析 labels 的子指标，探测时直接更新
 package metrics
 
 import (
+	"math"
 	"time"
 
 	"github.com/imkerbos/db-probe/internal/config"
@@ -51,19 +52,209 @@ var (
 
 	// DBProbeQueryFailuresTotal SQL 查询失败总次数（Counter）
 	DBProbeQueryFailuresTotal *prometheus.CounterVec
+
+	// DBProbeFailuresByClassTotal 按失败阶段和严重级别统计的失败次数（Counter）
+	DBProbeFailuresByClassTotal *prometheus.CounterVec
+
+	// DBProbeRetriesTotal 按失败阶段统计的重试决策次数（Counter）
+	// decision=retried 表示已重试，decision=suppressed 表示错误不可重试而放弃重试
+	DBProbeRetriesTotal *prometheus.CounterVec
+
+	// DBProbeAttemptsTotal 按步骤（ping、query）和结果统计的尝试次数（Counter），包括本轮探测内的重试
+	DBProbeAttemptsTotal *prometheus.CounterVec
+
+	// DBProbeAuthLockoutProtected 目标是否处于账号锁定保护 (1=是, 0=否)
+	// 连续认证失败达到阈值后进入保护，降低或停止探测以避免数据库锁定探测账号
+	DBProbeAuthLockoutProtected *prometheus.GaugeVec
+
+	// DBProbeTestFire 目标是否处于故障演练 (1=是, 0=否)
+	// 演练期间 db_probe_up 等指标为故障状态，告警规则可据此区分演练和真实故障
+	DBProbeTestFire *prometheus.GaugeVec
+
+	// DBProbeTargetPaused 目标是否被手动暂停探测 (1=暂停, 0=正常)
+	// 计划维护期间暂停探测，告警规则可据此屏蔽该目标；暂停时可以选择不导出 db_probe_up
+	DBProbeTargetPaused *prometheus.GaugeVec
+
+	// DBProbeStale 目标的指标是否已过期 (1=过期, 0=正常)
+	// 超过 stale_after_intervals 个探测间隔没有完成探测（探测卡住、调度过载）时置 1，其他指标停留在最后一次探测的值
+	DBProbeStale *prometheus.GaugeVec
+
+	// DBProbeConnectionReused 最近一次探测是否复用了连接池中的已有连接 (1=复用, 0=新建连接)
+	DBProbeConnectionReused *prometheus.GaugeVec
+
+	// DBProbeLockWaitsTotal 探测语句因锁等待超时而失败的次数（Counter）
+	DBProbeLockWaitsTotal *prometheus.CounterVec
+
+	// DBProbeDetectionLatencySeconds 故障检测延迟（Histogram）：从故障开始（首次失败探测）到状态变化事件分发的耗时
+	DBProbeDetectionLatencySeconds *prometheus.HistogramVec
+
+	// DBProbeZoneDurationSeconds 按区域对（探针所在区域、目标所在区域）统计的成功探测耗时（Histogram）
+	// 不带目标 label，同一区域对的所有目标共用，作为该区域对的延迟基线，跨区域探测不再与同区域共用阈值
+	DBProbeZoneDurationSeconds *prometheus.HistogramVec
+
+	// DBProbePingLatencySeconds Ping 耗时分布（Histogram），outcome=success|failure 区分成功和失败
+	// 失败多为耗时等于 probe_timeout 的超时，与成功的耗时放在一起会掩盖真实的 p99
+	DBProbePingLatencySeconds *prometheus.HistogramVec
+
+	// DBProbeQueryLatencySeconds SQL 查询耗时分布（Histogram），同 DBProbePingLatencySeconds
+	DBProbeQueryLatencySeconds *prometheus.HistogramVec
+
+	// DBProbeServerUptimeSeconds 数据库实例已运行的秒数（首次查询成功后才会出现）
+	DBProbeServerUptimeSeconds *prometheus.GaugeVec
+
+	// DBProbeServerRestartsTotal 检测到的数据库实例重启次数（Counter）
+	DBProbeServerRestartsTotal *prometheus.CounterVec
+
+	// DBProbeQueryCompatible 探测 SQL 是否与数据库当前版本兼容 (1=兼容, 0=不兼容)，开启 query_compat_check 且检查完成后才会出现
+	DBProbeQueryCompatible *prometheus.GaugeVec
+
+	// DBProbeQueryValue 探测 SQL 返回的第一列解析出的数值（无法解析或为 NULL 时不导出）
+	// 时间类型转换为 Unix 时间戳（秒），例如 SELECT MAX(ts) FROM heartbeat 可以用来计算复制延迟
+	DBProbeQueryValue *prometheus.GaugeVec
+
+	// DBProbeGaleraClusterSize Galera 节点所在组件的节点数（wsrep_cluster_size，仅 mariadb-galera 类型）
+	DBProbeGaleraClusterSize *prometheus.GaugeVec
+
+	// DBProbeGaleraLocalState Galera 节点的本地状态（wsrep_local_state：1=Joining 2=Donor/Desynced 3=Joined 4=Synced）
+	DBProbeGaleraLocalState *prometheus.GaugeVec
+
+	// DBProbeClusterNodes 集群节点总数（开启 cluster_check 的目标）
+	DBProbeClusterNodes *prometheus.GaugeVec
+
+	// DBProbeClusterLiveNodes 集群存活节点数（开启 cluster_check 的目标）
+	DBProbeClusterLiveNodes *prometheus.GaugeVec
+
+	// DBProbeDorisNodes Doris/StarRocks 的 FE、BE 节点数（node_type=frontend|backend，开启 cluster_check 的 doris 目标）
+	DBProbeDorisNodes *prometheus.GaugeVec
+
+	// DBProbeDorisAliveNodes Doris/StarRocks 的 FE、BE 存活节点数（SHOW FRONTENDS/BACKENDS 中 Alive 为 true）
+	DBProbeDorisAliveNodes *prometheus.GaugeVec
+
+	// DBProbeClusterHealth 集群健康状态（如 Elasticsearch 的 green/yellow/red），当前状态对应的 status 为 1，其余为 0
+	DBProbeClusterHealth *prometheus.GaugeVec
+
+	// DBProbeQueryQueuedSeconds 探测查询在服务端排队的时间（秒），只有能区分排队和执行的目标（如 Trino）才会导出
+	DBProbeQueryQueuedSeconds *prometheus.GaugeVec
+
+	// DBProbeQueryExecutionSeconds 探测查询在服务端执行的时间（秒，不含排队），同 DBProbeQueryQueuedSeconds
+	DBProbeQueryExecutionSeconds *prometheus.GaugeVec
+
+	// DBProbeFileSizeBytes 本地数据库文件的大小（字节，仅 sqlite 类型），file=db 为数据库文件，wal 为 WAL 文件（存在时）
+	DBProbeFileSizeBytes *prometheus.GaugeVec
+
+	// DBProbePeerMismatch 连接实际连接的对端地址是否不在 host 当前的 DNS 解析结果中 (1=不一致, 0=一致)，仅开启 peer_check 的目标
+	DBProbePeerMismatch *prometheus.GaugeVec
+
+	// DBProbeStatusUp 数据库状态端口是否可用 (1=可用, 0=不可用)，仅配置了 status_port 的 tidb 目标
+	// 状态端口不经过 SQL 层，与 db_probe_up 对比可以区分 SQL 层过载和进程退出
+	DBProbeStatusUp *prometheus.GaugeVec
+
+	// DBProbeServerConnections 状态端口报告的当前客户端连接数
+	DBProbeServerConnections *prometheus.GaugeVec
+
+	// DBProbeServerVersionInfo 状态端口报告的服务端版本，当前版本对应的 version 为 1，版本变化时删除旧版本的序列
+	DBProbeServerVersionInfo *prometheus.GaugeVec
+
+	// DBProbeEffectiveRole 识别出的节点实际角色（开启 role_detection 的目标），当前角色对应的 effective_role 为 1
+	// 角色变化时删除旧角色的序列，role label 仍为配置的角色，便于与实际角色对比
+	DBProbeEffectiveRole *prometheus.GaugeVec
+
+	// DBProbeRoleMismatch 实际角色与配置的 role 是否不一致 (1=不一致, 0=一致)，未配置 role 的目标为 0
+	DBProbeRoleMismatch *prometheus.GaugeVec
+
+	// DBProbeStatementsTotal 按类型统计的对数据库执行的语句数（Counter）
+	// kind=probe 为探测语句，session_init 为会话初始化语句，optional 为可选检查（如运行时长查询）
+	DBProbeStatementsTotal *prometheus.CounterVec
+
+	// DBProbeStatementBudgetExceeded 最近一小时执行的语句数是否达到预算 (1=是, 0=否)
+	// 达到预算后跳过可选检查，探测语句不受影响
+	DBProbeStatementBudgetExceeded *prometheus.GaugeVec
+
+	// DBProbeConfigGeneration 当前生效的配置版本号，启动时为 1，每次成功热加载后加 1
+	DBProbeConfigGeneration prometheus.Gauge
+
+	// DBProbeConfigReloadSuccessTimestamp 最近一次成功加载配置的时间戳（启动时的首次加载也计入）
+	DBProbeConfigReloadSuccessTimestamp prometheus.Gauge
+
+	// DBProbeConfigReloadsFailedTotal 重新加载配置失败（读取或校验失败，继续使用当前配置）的次数（Counter）
+	DBProbeConfigReloadsFailedTotal prometheus.Counter
+
+	// DBProbeFeatureEnabled 实验功能是否生效 (1=生效, 0=未开启或当前版本不支持)，label 为 feature
+	DBProbeFeatureEnabled *prometheus.GaugeVec
+
+	// DBProbeRemoteWriteSamplesTotal remote write 推送的样本数（Counter）
+	// result=sent 为推送成功，dropped 为缓冲已满或远端拒绝而丢弃
+	DBProbeRemoteWriteSamplesTotal *prometheus.CounterVec
+
+	// DBProbeRemoteWritePendingBatches remote write 等待推送的批次数
+	DBProbeRemoteWritePendingBatches prometheus.Gauge
+
+	// DBProbeDiscoveryTargets 各目标发现来源当前提供的目标数
+	DBProbeDiscoveryTargets *prometheus.GaugeVec
+
+	// DBProbeDiscoveryFailuresTotal 各目标发现来源同步失败的次数（Counter），失败时保留上一次同步的目标
+	DBProbeDiscoveryFailuresTotal *prometheus.CounterVec
+
+	// DBProbeCredentialsRotatedTotal 各目标凭据变化（轮换）后使用新凭据重建连接的次数（Counter）
+	// source=file 为凭据文件，kubernetes_secret、secret_store 同 db_probe_discovery_* 的 source
+	DBProbeCredentialsRotatedTotal *prometheus.CounterVec
+
+	// DBProbeDNSIPChangesTotal 重新解析 host 后目标地址（db_ip）变化的次数（Counter），
+	// 只带 db_name、db_host，不随 db_ip 变化而重新计数
+	DBProbeDNSIPChangesTotal *prometheus.CounterVec
+
+	// DBProbeRemoteConfigFetchesTotal 轮询远端配置（config_url）的次数，按结果分类（Counter）
+	// result=updated 为配置变化，not_modified 为没有变化（304 或内容相同），error 为请求失败
+	DBProbeRemoteConfigFetchesTotal *prometheus.CounterVec
+
+	// DBProbeNotifyQueueDepth 各通知渠道磁盘队列中等待发送的通知数
+	DBProbeNotifyQueueDepth *prometheus.GaugeVec
+
+	// DBProbeNotifyDeadLetters 各通知渠道死信目录中的通知数
+	DBProbeNotifyDeadLetters *prometheus.GaugeVec
+
+	// DBProbeNotifyDeliveriesTotal 各通知渠道的发送结果（Counter）
+	// result=sent 发送成功，retried 发送失败等待重试，dead_lettered 移入死信目录，dropped 写入队列失败
+	DBProbeNotifyDeliveriesTotal *prometheus.CounterVec
 )
 
+// HealthStatuses db_probe_cluster_health 的 status 取值
+var HealthStatuses = []string{"green", "yellow", "red"}
+
+// AttemptSteps db_probe_attempts_total 的 step 取值
+var AttemptSteps = []string{"ping", "query"}
+
+// StatementKinds db_probe_statements_total 的 kind 取值
+var StatementKinds = []string{"probe", "session_init", "optional"}
+
+// latencyBuckets Ping 和 SQL 查询耗时分布的 bucket：0.5ms 到约 8s，覆盖同区域到跨地域的耗时以及常见的 probe_timeout
+var latencyBuckets = prometheus.ExponentialBuckets(0.0005, 2, 15)
+
+// infoLabelNames db_probe_target_info 额外的 label 维度
+var infoLabelNames = []string{
+	"target_id",
+	"runbook_url",
+	"owner",
+	"team",
+	"oncall",
+}
+
+// targetLabelNames 所有目标指标统一的 label 维度
+var targetLabelNames = []string{
+	"project",
+	"env",
+	"db_name",
+	"db_type",
+	"db_host",
+	"db_ip",
+	"role",
+	"zone",
+	"same_zone",
+	"schema",
+}
+
 func init() {
-	// 统一的 label 维度
-	labelNames := []string{
-		"project",
-		"env",
-		"db_name",
-		"db_type",
-		"db_host",
-		"db_ip",
-		"role",
-	}
+	labelNames := targetLabelNames
 
 	DBProbeUp = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -89,12 +280,13 @@ func init() {
 		labelNames,
 	)
 
+	// target_info 在统一 label 之外携带目标的静态元信息
 	DBProbeTargetInfo = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "db_probe_target_info",
 			Help: "Database target information (static labels)",
 		},
-		labelNames,
+		append(labelNames, infoLabelNames...),
 	)
 
 	DBProbePingUp = promauto.NewGaugeVec(
@@ -168,10 +360,499 @@ func init() {
 		},
 		labelNames,
 	)
+
+	DBProbeFailuresByClassTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "db_probe_failures_by_class_total",
+			Help: "Total number of database probe failures by failure stage and severity",
+		},
+		append(labelNames, "stage", "severity"),
+	)
+
+	DBProbeRetriesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "db_probe_retries_total",
+			Help: "Total number of in-probe retry decisions by failure stage (decision=retried|suppressed)",
+		},
+		append(labelNames, "stage", "decision"),
+	)
+
+	DBProbeAttemptsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "db_probe_attempts_total",
+			Help: "Total number of ping/query attempts including in-probe retries (outcome=success|failure)",
+		},
+		append(labelNames, "step", "outcome"),
+	)
+
+	DBProbeAuthLockoutProtected = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_auth_lockout_protected",
+			Help: "Whether the target is in account lockout protection after repeated authentication failures (1=protected, 0=normal)",
+		},
+		labelNames,
+	)
+
+	DBProbeTestFire = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_test_fire",
+			Help: "Whether the target is marked down by a failure-injection drill (1=drill, 0=normal)",
+		},
+		labelNames,
+	)
+
+	DBProbeTargetPaused = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_target_paused",
+			Help: "Whether probing of the target is paused manually, e.g. during planned maintenance (1=paused, 0=normal)",
+		},
+		labelNames,
+	)
+
+	DBProbeStale = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_stale",
+			Help: "Whether the target has not completed a probe within stale_after_intervals probe intervals, so its other metrics are stale (1=stale, 0=fresh)",
+		},
+		labelNames,
+	)
+
+	DBProbeConnectionReused = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_connection_reused",
+			Help: "Whether the last probe reused an existing pooled connection (1=reused, 0=opened a new connection)",
+		},
+		labelNames,
+	)
+
+	DBProbeLockWaitsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "db_probe_lock_waits_total",
+			Help: "Total number of probes that failed because of a lock wait timeout (blocked by DDL or long transactions)",
+		},
+		labelNames,
+	)
+
+	DBProbeDetectionLatencySeconds = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "db_probe_detection_latency_seconds",
+			Help: "Time from the start of an outage (first failed probe) until the state change event was dispatched",
+			// 覆盖从亚秒级（单次探测即判定）到数分钟（超时、重试叠加）的检测延迟
+			Buckets: []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 30, 60, 120, 300},
+		},
+		labelNames,
+	)
+
+	DBProbeZoneDurationSeconds = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "db_probe_zone_duration_seconds",
+			Help: "Duration of successful probes by zone pair (probe zone, target zone), used as per zone pair latency baseline",
+			// 覆盖同区域（亚毫秒到数毫秒）到跨区域、跨地域（数十到数百毫秒）的探测耗时
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 15),
+		},
+		[]string{"probe_zone", "zone", "same_zone"},
+	)
+
+	DBProbePingLatencySeconds = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "db_probe_ping_latency_seconds",
+			Help:    "Distribution of ping duration by outcome (success|failure), failed pings are usually timeouts at probe_timeout",
+			Buckets: latencyBuckets,
+		},
+		append(labelNames, "outcome"),
+	)
+
+	DBProbeQueryLatencySeconds = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "db_probe_query_latency_seconds",
+			Help:    "Distribution of probe query duration by outcome (success|failure), failed queries are usually timeouts at probe_timeout",
+			Buckets: latencyBuckets,
+		},
+		append(labelNames, "outcome"),
+	)
+
+	DBProbeServerUptimeSeconds = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_server_uptime_seconds",
+			Help: "Database server uptime in seconds as reported by the server",
+		},
+		labelNames,
+	)
+
+	DBProbeQueryCompatible = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_query_compatible",
+			Help: "Whether the probe query parses and executes on the current server version (1=compatible, 0=incompatible), checked at startup and after server restarts",
+		},
+		labelNames,
+	)
+
+	DBProbeServerRestartsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "db_probe_server_restarts_total",
+			Help: "Total number of database server restarts detected from decreasing uptime",
+		},
+		labelNames,
+	)
+
+	DBProbeQueryValue = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_query_value",
+			Help: "Numeric value parsed from the first column returned by the probe query (timestamps as Unix seconds)",
+		},
+		labelNames,
+	)
+
+	DBProbeGaleraClusterSize = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_galera_cluster_size",
+			Help: "Number of nodes in the Galera cluster component the node belongs to (wsrep_cluster_size)",
+		},
+		labelNames,
+	)
+
+	DBProbeGaleraLocalState = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_galera_local_state",
+			Help: "Galera local node state (wsrep_local_state: 1=Joining 2=Donor/Desynced 3=Joined 4=Synced)",
+		},
+		labelNames,
+	)
+
+	DBProbeClusterNodes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_cluster_nodes",
+			Help: "Number of nodes in the cluster as reported by the probed node",
+		},
+		labelNames,
+	)
+
+	DBProbeClusterLiveNodes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_cluster_live_nodes",
+			Help: "Number of live nodes in the cluster as reported by the probed node",
+		},
+		labelNames,
+	)
+
+	DBProbeDorisNodes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_doris_nodes",
+			Help: "Number of Doris/StarRocks frontend or backend nodes (node_type=frontend|backend)",
+		},
+		append(labelNames, "node_type"),
+	)
+
+	DBProbeDorisAliveNodes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_doris_alive_nodes",
+			Help: "Number of alive Doris/StarRocks frontend or backend nodes (node_type=frontend|backend)",
+		},
+		append(labelNames, "node_type"),
+	)
+
+	DBProbeClusterHealth = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_cluster_health",
+			Help: "Cluster health status reported by the probed cluster (1 for the current status)",
+		},
+		append(labelNames, "status"),
+	)
+
+	DBProbeQueryQueuedSeconds = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_query_queued_seconds",
+			Help: "Time the last probe query spent queued on the server in seconds (e.g. Trino resource groups)",
+		},
+		labelNames,
+	)
+
+	DBProbeQueryExecutionSeconds = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_query_execution_seconds",
+			Help: "Time the last probe query spent executing on the server in seconds, excluding queue time",
+		},
+		labelNames,
+	)
+
+	DBProbeFileSizeBytes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_file_size_bytes",
+			Help: "Size of the local database file in bytes (file=db|wal, sqlite targets only)",
+		},
+		append(labelNames, "file"),
+	)
+
+	DBProbePeerMismatch = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_peer_mismatch",
+			Help: "Whether the peer address of the pooled connection is missing from the current DNS answer for the host (1=mismatch, 0=match)",
+		},
+		labelNames,
+	)
+
+	DBProbeStatusUp = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_status_up",
+			Help: "Whether the database status port responded (1=up, 0=down), independent of the SQL layer",
+		},
+		labelNames,
+	)
+
+	DBProbeServerConnections = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_server_connections",
+			Help: "Number of client connections reported by the database status port",
+		},
+		labelNames,
+	)
+
+	DBProbeServerVersionInfo = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_server_version_info",
+			Help: "Server version reported by the database status port (1 for the current version)",
+		},
+		append(labelNames, "version"),
+	)
+
+	DBProbeEffectiveRole = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_effective_role",
+			Help: "Role detected on the node (1 for the current effective_role), compared with the configured role label",
+		},
+		append(labelNames, "effective_role"),
+	)
+
+	DBProbeRoleMismatch = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_role_mismatch",
+			Help: "Whether the detected role differs from the configured role label (1=mismatch, 0=match)",
+		},
+		labelNames,
+	)
+
+	DBProbeStatementsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "db_probe_statements_total",
+			Help: "Total number of statements executed against the database by kind (kind=probe|session_init|optional)",
+		},
+		append(labelNames, "kind"),
+	)
+
+	DBProbeStatementBudgetExceeded = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_statement_budget_exceeded",
+			Help: "Whether the statements executed in the last hour reached the statement budget (1=yes, 0=no)",
+		},
+		labelNames,
+	)
+
+	DBProbeConfigGeneration = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "db_probe_config_generation",
+			Help: "Generation number of the currently applied configuration",
+		},
+	)
+
+	DBProbeConfigReloadSuccessTimestamp = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "db_probe_config_reload_success_timestamp",
+			Help: "Unix timestamp of the last successful configuration load, including the initial load at startup",
+		},
+	)
+
+	DBProbeConfigReloadsFailedTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "db_probe_config_reloads_failed_total",
+			Help: "Total number of configuration reloads that failed to read or validate; the current configuration stays in effect",
+		},
+	)
+
+	DBProbeFeatureEnabled = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_feature_enabled",
+			Help: "Whether an experimental feature is in effect (1=enabled, 0=disabled or not supported by this version)",
+		},
+		[]string{"feature"},
+	)
+
+	DBProbeRemoteWriteSamplesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "db_probe_remote_write_samples_total",
+			Help: "Total number of samples handled by remote write, by result (sent, dropped)",
+		},
+		[]string{"result"},
+	)
+
+	DBProbeRemoteWritePendingBatches = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "db_probe_remote_write_pending_batches",
+			Help: "Number of remote write batches waiting to be sent",
+		},
+	)
+
+	DBProbeDiscoveryTargets = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_discovery_targets",
+			Help: "Number of targets currently provided by each discovery source",
+		},
+		[]string{"source"},
+	)
+
+	DBProbeNotifyQueueDepth = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_notify_queue_depth",
+			Help: "Number of notifications waiting in the on-disk queue by channel",
+		},
+		[]string{"channel"},
+	)
+
+	DBProbeNotifyDeadLetters = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "db_probe_notify_dead_letters",
+			Help: "Number of notifications in the dead-letter directory by channel",
+		},
+		[]string{"channel"},
+	)
+
+	DBProbeNotifyDeliveriesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "db_probe_notify_deliveries_total",
+			Help: "Total number of notification delivery outcomes by channel (result=sent|retried|dead_lettered|dropped)",
+		},
+		[]string{"channel", "result"},
+	)
+
+	DBProbeDiscoveryFailuresTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "db_probe_discovery_failures_total",
+			Help: "Total number of failed target discovery syncs by source",
+		},
+		[]string{"source"},
+	)
+
+	DBProbeCredentialsRotatedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "db_probe_credentials_rotated_total",
+			Help: "Total number of credential changes picked up without restart, by target and credential source",
+		},
+		[]string{"db_name", "source"},
+	)
+
+	DBProbeDNSIPChangesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "db_probe_dns_ip_changes_total",
+			Help: "Total number of times re-resolving a target host returned a different IP",
+		},
+		[]string{"db_name", "db_host"},
+	)
+
+	DBProbeRemoteConfigFetchesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "db_probe_remote_config_fetches_total",
+			Help: "Total number of remote config polls, by result (updated, not_modified, error)",
+		},
+		[]string{"result"},
+	)
+}
+
+// SetFeatures 导出各实验功能是否生效，features 变更需要重启，只在启动时设置一次
+func SetFeatures(states map[string]bool) {
+	for name, enabled := range states {
+		DBProbeFeatureEnabled.WithLabelValues(name).Set(boolToFloat64(enabled))
+	}
+}
+
+// SetConfigGeneration 设置当前生效的配置版本号
+func SetConfigGeneration(generation uint64) {
+	DBProbeConfigGeneration.Set(float64(generation))
+}
+
+// RecordConfigReload 记录一次配置加载的结果：成功时更新时间戳，失败时累加失败次数
+func RecordConfigReload(success bool) {
+	if success {
+		DBProbeConfigReloadSuccessTimestamp.SetToCurrentTime()
+		return
+	}
+	DBProbeConfigReloadsFailedTotal.Inc()
+}
+
+// RecordRemoteWriteSamples 记录 remote write 推送成功（sent）或丢弃（dropped）的样本数
+func RecordRemoteWriteSamples(result string, n int) {
+	DBProbeRemoteWriteSamplesTotal.WithLabelValues(result).Add(float64(n))
+}
+
+// SetRemoteWritePending 设置 remote write 等待推送的批次数
+func SetRemoteWritePending(n int) {
+	DBProbeRemoteWritePendingBatches.Set(float64(n))
+}
+
+// SetDiscoveryTargets 设置目标发现来源当前提供的目标数
+func SetDiscoveryTargets(source string, n int) {
+	DBProbeDiscoveryTargets.WithLabelValues(source).Set(float64(n))
+}
+
+// RecordDiscoveryFailure 记录一次目标发现同步失败
+func RecordDiscoveryFailure(source string) {
+	DBProbeDiscoveryFailuresTotal.WithLabelValues(source).Inc()
+}
+
+// RecordCredentialRotation 记录一次目标凭据轮换
+func RecordCredentialRotation(dbName, source string) {
+	DBProbeCredentialsRotatedTotal.WithLabelValues(dbName, source).Inc()
+}
+
+// RecordDNSIPChange 记录一次目标地址变化（重新解析 host 得到了不同的 IP）
+func RecordDNSIPChange(dbName, host string) {
+	DBProbeDNSIPChangesTotal.WithLabelValues(dbName, host).Inc()
+}
+
+// RecordRemoteConfigFetch 记录一次远端配置轮询结果
+func RecordRemoteConfigFetch(result string) {
+	DBProbeRemoteConfigFetchesTotal.WithLabelValues(result).Inc()
+}
+
+// SetNotifyQueue 设置通知渠道等待发送的通知数和死信数
+func SetNotifyQueue(channel string, pending, dead int) {
+	DBProbeNotifyQueueDepth.WithLabelValues(channel).Set(float64(pending))
+	DBProbeNotifyDeadLetters.WithLabelValues(channel).Set(float64(dead))
 }
 
-// NewLabels 构造 Prometheus labels
-func NewLabels(dbCfg *config.DBConfig, ip string) prometheus.Labels {
+// RecordNotifyDelivery 记录一次通知发送结果
+func RecordNotifyDelivery(channel, result string) {
+	DBProbeNotifyDeliveriesTotal.WithLabelValues(channel, result).Inc()
+}
+
+// probeZone 探针所在的区域（全局 zone 配置），由 SetProbeZone 在创建目标之前设置
+var probeZone string
+
+// SetProbeZone 设置探针所在的区域，影响之后创建的目标的 same_zone label 和区域对延迟基线
+func SetProbeZone(zone string) {
+	probeZone = zone
+}
+
+// lowMemory 是否为低内存模式，由 SetLowMemory 在创建目标之前设置
+var lowMemory bool
+
+// SetLowMemory 设置低内存模式，之后创建的目标不再创建延迟分布（Histogram）子指标
+// 每个 Histogram 子指标有十几个桶，是每个目标占用内存和导出时间序列最多的部分
+func SetLowMemory(enabled bool) {
+	lowMemory = enabled
+}
+
+// SameZone 探针与目标是否位于同一区域（true/false），任意一方未配置区域时为空
+func SameZone(targetZone string) string {
+	if probeZone == "" || targetZone == "" {
+		return ""
+	}
+	if probeZone == targetZone {
+		return "true"
+	}
+	return "false"
+}
+
+// NewLabels 构造 Prometheus labels，schema 为按 schemas 展开的目标探测的库名（其他目标为空）
+func NewLabels(dbCfg *config.DBConfig, ip, schema string) prometheus.Labels {
 	labels := prometheus.Labels{
 		"project": dbCfg.Project,
 		"env":     dbCfg.Env,
@@ -180,6 +861,10 @@ func NewLabels(dbCfg *config.DBConfig, ip string) prometheus.Labels {
 		"db_host": dbCfg.Host,
 		"db_ip":   ip,
 		"role":    "",
+		"zone":    dbCfg.Zone,
+		// 同区域与跨区域的探测耗时差异很大，告警阈值可以按 same_zone 区分
+		"same_zone": SameZone(dbCfg.Zone),
+		"schema":    schema,
 	}
 
 	// 从 dbCfg.Labels 中提取 role（如果存在）
@@ -194,58 +879,496 @@ func NewLabels(dbCfg *config.DBConfig, ip string) prometheus.Labels {
 	return labels
 }
 
-// UpdateProbeResult 更新探测结果
-func UpdateProbeResult(labels prometheus.Labels, up bool, durationSeconds float64) {
-	timestamp := float64(time.Now().Unix())
+// NewInfoLabels 构造 db_probe_target_info 额外的 labels（目标静态元信息）
+func NewInfoLabels(dbCfg *config.DBConfig) prometheus.Labels {
+	return prometheus.Labels{
+		"target_id":   dbCfg.ID,
+		"runbook_url": dbCfg.RunbookURL,
+		"owner":       dbCfg.Owner,
+		"team":        dbCfg.Team,
+		"oncall":      dbCfg.Oncall,
+	}
+}
+
+// TargetMetrics 单个目标的指标集合
+// 在目标创建时通过 With(labels) 解析出各指标的子 collector 并缓存，
+// 探测热路径直接更新子 collector，避免每次更新都对 label map 做哈希和校验
+type TargetMetrics struct {
+	Up                prometheus.Gauge
+	Duration          prometheus.Gauge
+	LastTimestamp     prometheus.Gauge
+	PingUp            prometheus.Gauge
+	PingDuration      prometheus.Gauge
+	QueryUp           prometheus.Gauge
+	QueryDuration     prometheus.Gauge
+	Reconnects        prometheus.Counter
+	ReconnectDuration prometheus.Gauge
+	Failures          prometheus.Counter
+	PingFailures      prometheus.Counter
+	QueryFailures     prometheus.Counter
+	AuthProtected     prometheus.Gauge
+	TestFire          prometheus.Gauge
+	Paused            prometheus.Gauge
+	Stale             prometheus.Gauge
+	ConnReused        prometheus.Gauge
+	LockWaits         prometheus.Counter
+	ServerRestarts    prometheus.Counter
+	BudgetExceeded    prometheus.Gauge
+	DetectionLatency  prometheus.Observer
+	// PingLatency、QueryLatency 按 outcome 预先解析的耗时分布，下标 0 为 failure，1 为 success
+	PingLatency  [2]prometheus.Observer
+	QueryLatency [2]prometheus.Observer
+	// ZoneDuration 目标所在区域对的延迟基线，只记录成功的探测，不随目标删除
+	ZoneDuration prometheus.Observer
+	// FailuresByClass 已绑定目标 labels，只剩 stage、severity 两个维度
+	FailuresByClass *prometheus.CounterVec
+	// Retries 已绑定目标 labels，只剩 stage、decision 两个维度
+	Retries *prometheus.CounterVec
+	// Statements 按 kind 预先创建的语句计数器，探测热路径上不再对 label 做哈希
+	Statements map[string]prometheus.Counter
+	// Attempts 按 step 预先创建的尝试计数器
+	Attempts map[string]attemptCounters
+	// ServerUptime 已绑定全部 labels，首次设置时才创建子指标，
+	// 避免不支持或无权限查询运行时长的目标导出一个误导性的 0
+	ServerUptime *prometheus.GaugeVec
+	// QueryCompatible 同 ServerUptime，开启 query_compat_check 的目标检查完成后才导出
+	QueryCompatible *prometheus.GaugeVec
+	// QueryValue 同 ServerUptime，探测 SQL 的结果可以解析为数值时才导出
+	QueryValue *prometheus.GaugeVec
+	// GaleraClusterSize/GaleraLocalState 同 ServerUptime，只有 Galera 节点才会导出
+	GaleraClusterSize *prometheus.GaugeVec
+	GaleraLocalState  *prometheus.GaugeVec
+	// ClusterNodes/ClusterLiveNodes 同 ServerUptime，只有开启 cluster_check 的目标才会导出
+	ClusterNodes     *prometheus.GaugeVec
+	ClusterLiveNodes *prometheus.GaugeVec
+	// ClusterHealth 同 ServerUptime，只有能读取集群健康状态的目标（如 Elasticsearch）才会导出
+	ClusterHealth *prometheus.GaugeVec
+	// DorisNodes、DorisAliveNodes 同 ServerUptime，已绑定目标 labels，只剩 node_type 维度
+	DorisNodes      *prometheus.GaugeVec
+	DorisAliveNodes *prometheus.GaugeVec
+	// QueryQueued、QueryExecution 同 ServerUptime，只有能区分排队和执行时间的目标（如 Trino）才会导出
+	QueryQueued    *prometheus.GaugeVec
+	QueryExecution *prometheus.GaugeVec
+	// FileSize 同 ServerUptime，已绑定目标 labels，只剩 file 维度，只有 sqlite 目标才会导出
+	FileSize *prometheus.GaugeVec
+	// PeerMismatch 同 ServerUptime，只有开启 peer_check 的目标在建立连接并解析成功后才会导出
+	PeerMismatch *prometheus.GaugeVec
+	// StatusUp、ServerConnections、ServerVersion 同 ServerUptime，只有配置了 status_port 的目标才会导出
+	StatusUp          *prometheus.GaugeVec
+	ServerConnections *prometheus.GaugeVec
+	ServerVersion     *prometheus.GaugeVec
+	// EffectiveRole、RoleMismatch 同 ServerUptime，只有开启 role_detection 的目标才会导出
+	EffectiveRole *prometheus.GaugeVec
+	RoleMismatch  *prometheus.GaugeVec
+	// labels、upHidden 暂停探测并选择不导出 db_probe_up 时删除其时间序列，恢复时按 labels 重新创建（见 SetPaused）
+	labels   prometheus.Labels
+	upHidden bool
+}
+
+// NewTargetMetrics 为目标创建指标集合，并设置 target info（静态信息）
+// infoLabels 为只出现在 target_info 上的额外 labels（见 NewInfoLabels）
+// Counter 类型通过 Add(0) 初始化，这样即使值为 0 也会在 /metrics 中显示
+// 低内存模式下不创建 Histogram 子指标，对应的 Observer 为 nil，记录时跳过
+func NewTargetMetrics(labels, infoLabels prometheus.Labels) *TargetMetrics {
+	targetInfoLabels := make(prometheus.Labels, len(labels)+len(infoLabels))
+	for k, v := range labels {
+		targetInfoLabels[k] = v
+	}
+	for k, v := range infoLabels {
+		targetInfoLabels[k] = v
+	}
+	DBProbeTargetInfo.With(targetInfoLabels).Set(1)
+
+	m := &TargetMetrics{
+		Up:                DBProbeUp.With(labels),
+		Duration:          DBProbeDurationSeconds.With(labels),
+		LastTimestamp:     DBProbeLastTimestamp.With(labels),
+		PingUp:            DBProbePingUp.With(labels),
+		PingDuration:      DBProbePingDurationSeconds.With(labels),
+		QueryUp:           DBProbeQueryUp.With(labels),
+		QueryDuration:     DBProbeQueryDurationSeconds.With(labels),
+		Reconnects:        DBProbeConnectionReconnectsTotal.With(labels),
+		ReconnectDuration: DBProbeConnectionReconnectDurationSeconds.With(labels),
+		Failures:          DBProbeFailuresTotal.With(labels),
+		PingFailures:      DBProbePingFailuresTotal.With(labels),
+		QueryFailures:     DBProbeQueryFailuresTotal.With(labels),
+		AuthProtected:     DBProbeAuthLockoutProtected.With(labels),
+		TestFire:          DBProbeTestFire.With(labels),
+		Paused:            DBProbeTargetPaused.With(labels),
+		Stale:             DBProbeStale.With(labels),
+		ConnReused:        DBProbeConnectionReused.With(labels),
+		LockWaits:         DBProbeLockWaitsTotal.With(labels),
+		ServerRestarts:    DBProbeServerRestartsTotal.With(labels),
+		BudgetExceeded:    DBProbeStatementBudgetExceeded.With(labels),
+		FailuresByClass:   DBProbeFailuresByClassTotal.MustCurryWith(labels),
+		Retries:           DBProbeRetriesTotal.MustCurryWith(labels),
+		Statements:        make(map[string]prometheus.Counter, len(StatementKinds)),
+		Attempts:          make(map[string]attemptCounters, len(AttemptSteps)),
+		ServerUptime:      DBProbeServerUptimeSeconds.MustCurryWith(labels),
+		QueryCompatible:   DBProbeQueryCompatible.MustCurryWith(labels),
+		QueryValue:        DBProbeQueryValue.MustCurryWith(labels),
+		GaleraClusterSize: DBProbeGaleraClusterSize.MustCurryWith(labels),
+		GaleraLocalState:  DBProbeGaleraLocalState.MustCurryWith(labels),
+		ClusterNodes:      DBProbeClusterNodes.MustCurryWith(labels),
+		ClusterLiveNodes:  DBProbeClusterLiveNodes.MustCurryWith(labels),
+		ClusterHealth:     DBProbeClusterHealth.MustCurryWith(labels),
+		DorisNodes:        DBProbeDorisNodes.MustCurryWith(labels),
+		DorisAliveNodes:   DBProbeDorisAliveNodes.MustCurryWith(labels),
+		QueryQueued:       DBProbeQueryQueuedSeconds.MustCurryWith(labels),
+		QueryExecution:    DBProbeQueryExecutionSeconds.MustCurryWith(labels),
+		FileSize:          DBProbeFileSizeBytes.MustCurryWith(labels),
+		PeerMismatch:      DBProbePeerMismatch.MustCurryWith(labels),
+		StatusUp:          DBProbeStatusUp.MustCurryWith(labels),
+		ServerConnections: DBProbeServerConnections.MustCurryWith(labels),
+		ServerVersion:     DBProbeServerVersionInfo.MustCurryWith(labels),
+		EffectiveRole:     DBProbeEffectiveRole.MustCurryWith(labels),
+		RoleMismatch:      DBProbeRoleMismatch.MustCurryWith(labels),
+		labels:            labels,
+	}
+	if !lowMemory {
+		m.DetectionLatency = DBProbeDetectionLatencySeconds.With(labels)
+		m.PingLatency = outcomeObservers(DBProbePingLatencySeconds, labels)
+		m.QueryLatency = outcomeObservers(DBProbeQueryLatencySeconds, labels)
+		m.ZoneDuration = DBProbeZoneDurationSeconds.WithLabelValues(probeZone, labels["zone"], labels["same_zone"])
+	}
+	m.Failures.Add(0)
+	m.PingFailures.Add(0)
+	m.QueryFailures.Add(0)
+	m.Reconnects.Add(0)
+	m.LockWaits.Add(0)
+	m.ServerRestarts.Add(0)
+	m.AuthProtected.Set(0)
+	m.TestFire.Set(0)
+	m.Paused.Set(0)
+	m.Stale.Set(0)
+	m.BudgetExceeded.Set(0)
+	statements := DBProbeStatementsTotal.MustCurryWith(labels)
+	for _, kind := range StatementKinds {
+		m.Statements[kind] = statements.WithLabelValues(kind)
+		m.Statements[kind].Add(0)
+	}
+	attempts := DBProbeAttemptsTotal.MustCurryWith(labels)
+	for _, step := range AttemptSteps {
+		counters := attemptCounters{
+			success: attempts.WithLabelValues(step, "success"),
+			failure: attempts.WithLabelValues(step, "failure"),
+		}
+		counters.success.Add(0)
+		counters.failure.Add(0)
+		m.Attempts[step] = counters
+	}
+	return m
+}
 
-	DBProbeUp.With(labels).Set(boolToFloat64(up))
-	DBProbeDurationSeconds.With(labels).Set(durationSeconds)
-	DBProbeLastTimestamp.With(labels).Set(timestamp)
+// attemptCounters 单个步骤按结果预先创建的尝试计数器
+type attemptCounters struct {
+	success prometheus.Counter
+	failure prometheus.Counter
+}
+
+// DeleteTargetMetrics 删除目标在所有指标上的时间序列
+// 目标的 label 取值变化（如识别出的 role 变化）后，旧 labels 的序列不再更新，需要删除避免残留
+func DeleteTargetMetrics(labels prometheus.Labels) {
+	for _, vec := range []interface {
+		DeletePartialMatch(prometheus.Labels) int
+	}{
+		DBProbeUp,
+		DBProbeDurationSeconds,
+		DBProbeLastTimestamp,
+		DBProbeTargetInfo,
+		DBProbePingUp,
+		DBProbePingDurationSeconds,
+		DBProbeQueryUp,
+		DBProbeQueryDurationSeconds,
+		DBProbeConnectionReconnectsTotal,
+		DBProbeConnectionReconnectDurationSeconds,
+		DBProbeFailuresTotal,
+		DBProbePingFailuresTotal,
+		DBProbeQueryFailuresTotal,
+		DBProbeFailuresByClassTotal,
+		DBProbeRetriesTotal,
+		DBProbeAttemptsTotal,
+		DBProbeAuthLockoutProtected,
+		DBProbeTestFire,
+		DBProbeTargetPaused,
+		DBProbeStale,
+		DBProbeConnectionReused,
+		DBProbeLockWaitsTotal,
+		DBProbeDetectionLatencySeconds,
+		DBProbePingLatencySeconds,
+		DBProbeQueryLatencySeconds,
+		DBProbeServerUptimeSeconds,
+		DBProbeServerRestartsTotal,
+		DBProbeQueryCompatible,
+		DBProbeQueryValue,
+		DBProbeGaleraClusterSize,
+		DBProbeGaleraLocalState,
+		DBProbeClusterNodes,
+		DBProbeClusterLiveNodes,
+		DBProbeClusterHealth,
+		DBProbeDorisNodes,
+		DBProbeDorisAliveNodes,
+		DBProbeQueryQueuedSeconds,
+		DBProbeQueryExecutionSeconds,
+		DBProbeFileSizeBytes,
+		DBProbePeerMismatch,
+		DBProbeStatusUp,
+		DBProbeServerConnections,
+		DBProbeServerVersionInfo,
+		DBProbeEffectiveRole,
+		DBProbeRoleMismatch,
+		DBProbeStatementsTotal,
+		DBProbeStatementBudgetExceeded,
+	} {
+		vec.DeletePartialMatch(labels)
+	}
+}
+
+// UpdateProbeResult 更新探测结果，成功的探测同时计入区域对延迟基线
+// 失败探测的耗时多为超时，计入会拉高基线
+func (m *TargetMetrics) UpdateProbeResult(up bool, durationSeconds float64) {
+	m.Up.Set(boolToFloat64(up))
+	m.Duration.Set(durationSeconds)
+	m.LastTimestamp.Set(float64(time.Now().Unix()))
+	if up && m.ZoneDuration != nil {
+		m.ZoneDuration.Observe(durationSeconds)
+	}
 }
 
 // UpdatePingResult 更新 Ping 操作结果
-func UpdatePingResult(labels prometheus.Labels, success bool, durationSeconds float64) {
-	DBProbePingUp.With(labels).Set(boolToFloat64(success))
-	DBProbePingDurationSeconds.With(labels).Set(durationSeconds)
+func (m *TargetMetrics) UpdatePingResult(success bool, durationSeconds float64) {
+	m.PingUp.Set(boolToFloat64(success))
+	m.PingDuration.Set(durationSeconds)
+	if observer := m.PingLatency[outcomeIndex(success)]; observer != nil {
+		observer.Observe(durationSeconds)
+	}
 }
 
 // UpdateQueryResult 更新 SQL 查询结果
-func UpdateQueryResult(labels prometheus.Labels, success bool, durationSeconds float64) {
-	DBProbeQueryUp.With(labels).Set(boolToFloat64(success))
-	DBProbeQueryDurationSeconds.With(labels).Set(durationSeconds)
+func (m *TargetMetrics) UpdateQueryResult(success bool, durationSeconds float64) {
+	m.QueryUp.Set(boolToFloat64(success))
+	m.QueryDuration.Set(durationSeconds)
+	if observer := m.QueryLatency[outcomeIndex(success)]; observer != nil {
+		observer.Observe(durationSeconds)
+	}
+}
+
+// outcomeObservers 预先解析 outcome=failure、success 两个子指标，下标与 outcomeIndex 对应
+func outcomeObservers(vec *prometheus.HistogramVec, labels prometheus.Labels) [2]prometheus.Observer {
+	curried := vec.MustCurryWith(labels)
+	return [2]prometheus.Observer{
+		curried.WithLabelValues("failure"),
+		curried.WithLabelValues("success"),
+	}
+}
+
+// outcomeIndex 探测结果在 outcomeObservers 中的下标
+func outcomeIndex(success bool) int {
+	if success {
+		return 1
+	}
+	return 0
 }
 
 // RecordReconnect 记录连接重连
-func RecordReconnect(labels prometheus.Labels, durationSeconds float64) {
-	DBProbeConnectionReconnectsTotal.With(labels).Inc()
-	DBProbeConnectionReconnectDurationSeconds.With(labels).Set(durationSeconds)
+func (m *TargetMetrics) RecordReconnect(durationSeconds float64) {
+	m.Reconnects.Inc()
+	m.ReconnectDuration.Set(durationSeconds)
 }
 
 // RecordFailure 记录探测失败
-func RecordFailure(labels prometheus.Labels) {
-	DBProbeFailuresTotal.With(labels).Inc()
+func (m *TargetMetrics) RecordFailure() {
+	m.Failures.Inc()
 }
 
 // RecordPingFailure 记录 Ping 失败
-func RecordPingFailure(labels prometheus.Labels) {
-	DBProbePingFailuresTotal.With(labels).Inc()
+func (m *TargetMetrics) RecordPingFailure() {
+	m.PingFailures.Inc()
 }
 
 // RecordQueryFailure 记录 SQL 查询失败
-func RecordQueryFailure(labels prometheus.Labels) {
-	DBProbeQueryFailuresTotal.With(labels).Inc()
+func (m *TargetMetrics) RecordQueryFailure() {
+	m.QueryFailures.Inc()
+}
+
+// RecordFailureClass 按失败阶段和严重级别记录失败
+func (m *TargetMetrics) RecordFailureClass(stage, severity string) {
+	m.FailuresByClass.WithLabelValues(stage, severity).Inc()
+}
+
+// RecordRetry 记录一次重试决策（retried 或 suppressed）
+func (m *TargetMetrics) RecordRetry(stage, decision string) {
+	m.Retries.WithLabelValues(stage, decision).Inc()
+}
+
+// SetServerUptime 更新数据库实例运行时长
+func (m *TargetMetrics) SetServerUptime(seconds float64) {
+	m.ServerUptime.WithLabelValues().Set(seconds)
+}
+
+// SetQueryCompatible 更新探测 SQL 兼容性检查的结果
+func (m *TargetMetrics) SetQueryCompatible(compatible bool) {
+	m.QueryCompatible.WithLabelValues().Set(boolToFloat64(compatible))
+}
+
+// SetQueryValue 更新探测 SQL 结果解析出的数值，ok 为 false（结果为 NULL 或非数值）时删除该指标
+func (m *TargetMetrics) SetQueryValue(value float64, ok bool) {
+	if !ok {
+		m.QueryValue.DeleteLabelValues()
+		return
+	}
+	m.QueryValue.WithLabelValues().Set(value)
+}
+
+// SetGaleraStatus 更新 Galera 节点的集群规模和本地状态
+func (m *TargetMetrics) SetGaleraStatus(clusterSize, localState int) {
+	m.GaleraClusterSize.WithLabelValues().Set(float64(clusterSize))
+	m.GaleraLocalState.WithLabelValues().Set(float64(localState))
+}
+
+// SetClusterNodes 更新集群节点总数和存活节点数
+func (m *TargetMetrics) SetClusterNodes(total, live int) {
+	m.ClusterNodes.WithLabelValues().Set(float64(total))
+	m.ClusterLiveNodes.WithLabelValues().Set(float64(live))
+}
+
+// SetDorisNodes 设置 Doris/StarRocks 的 FE、BE 节点数和存活节点数
+func (m *TargetMetrics) SetDorisNodes(frontends, aliveFrontends, backends, aliveBackends int) {
+	m.DorisNodes.WithLabelValues("frontend").Set(float64(frontends))
+	m.DorisAliveNodes.WithLabelValues("frontend").Set(float64(aliveFrontends))
+	m.DorisNodes.WithLabelValues("backend").Set(float64(backends))
+	m.DorisAliveNodes.WithLabelValues("backend").Set(float64(aliveBackends))
+}
+
+// SetClusterHealth 设置集群健康状态，当前状态为 1，其余状态为 0
+func (m *TargetMetrics) SetClusterHealth(status string) {
+	for _, s := range HealthStatuses {
+		m.ClusterHealth.WithLabelValues(s).Set(boolToFloat64(s == status))
+	}
+}
+
+// SetQueryPhases 设置探测查询的排队时间和执行时间（秒）
+func (m *TargetMetrics) SetQueryPhases(queuedSeconds, executionSeconds float64) {
+	m.QueryQueued.WithLabelValues().Set(queuedSeconds)
+	m.QueryExecution.WithLabelValues().Set(executionSeconds)
+}
+
+// SetPeerMismatch 设置连接的对端地址是否不在 DNS 当前的解析结果中
+func (m *TargetMetrics) SetPeerMismatch(mismatch bool) {
+	m.PeerMismatch.WithLabelValues().Set(boolToFloat64(mismatch))
+}
+
+// SetStatusDown 状态端口请求失败，连接数和版本保留最近一次的值
+func (m *TargetMetrics) SetStatusDown() {
+	m.StatusUp.WithLabelValues().Set(0)
+}
+
+// SetStatus 设置状态端口读取到的连接数和版本，版本变化时删除旧版本的序列
+func (m *TargetMetrics) SetStatus(previousVersion, version string, connections int) {
+	m.StatusUp.WithLabelValues().Set(1)
+	m.ServerConnections.WithLabelValues().Set(float64(connections))
+	if previousVersion != "" && previousVersion != version {
+		m.ServerVersion.DeleteLabelValues(previousVersion)
+	}
+	m.ServerVersion.WithLabelValues(version).Set(1)
+}
+
+// SetFileSize 设置本地数据库文件的大小，ok 为 false（文件不存在或无法读取）时删除该文件的序列
+func (m *TargetMetrics) SetFileSize(file string, bytes int64, ok bool) {
+	if !ok {
+		m.FileSize.DeleteLabelValues(file)
+		return
+	}
+	m.FileSize.WithLabelValues(file).Set(float64(bytes))
+}
+
+// SetEffectiveRole 设置识别出的实际角色和与配置的 role 是否不一致，角色变化时删除旧角色的序列
+func (m *TargetMetrics) SetEffectiveRole(previous, role string, mismatch bool) {
+	if previous != "" && previous != role {
+		m.EffectiveRole.DeleteLabelValues(previous)
+	}
+	m.EffectiveRole.WithLabelValues(role).Set(1)
+	m.RoleMismatch.WithLabelValues().Set(boolToFloat64(mismatch))
 }
 
-// SetTargetInfo 设置目标信息（静态信息，只需设置一次）
-func SetTargetInfo(labels prometheus.Labels) {
-	DBProbeTargetInfo.With(labels).Set(1)
+// RecordServerRestart 记录一次检测到的实例重启
+func (m *TargetMetrics) RecordServerRestart() {
+	m.ServerRestarts.Inc()
+}
+
+// ObserveDetectionLatency 记录一次故障检测延迟
+func (m *TargetMetrics) ObserveDetectionLatency(seconds float64) {
+	if m.DetectionLatency != nil {
+		m.DetectionLatency.Observe(seconds)
+	}
+}
+
+// RecordLockWait 记录一次锁等待超时
+func (m *TargetMetrics) RecordLockWait() {
+	m.LockWaits.Inc()
+}
+
+// SetConnectionReused 更新最近一次探测的连接复用情况
+func (m *TargetMetrics) SetConnectionReused(reused bool) {
+	m.ConnReused.Set(boolToFloat64(reused))
+}
+
+// SetAuthProtected 更新账号锁定保护状态
+func (m *TargetMetrics) SetAuthProtected(protected bool) {
+	m.AuthProtected.Set(boolToFloat64(protected))
+}
+
+// SetTestFire 更新故障演练状态
+func (m *TargetMetrics) SetTestFire(active bool) {
+	m.TestFire.Set(boolToFloat64(active))
+}
+
+// SetPaused 更新暂停状态；暂停且 hideUp 为 true 时删除 db_probe_up 的时间序列，恢复后重新创建，由本轮探测结果覆盖
+// 与探测互斥调用（在 probeMu 保护下），探测不会更新已删除的 db_probe_up
+func (m *TargetMetrics) SetPaused(paused, hideUp bool) {
+	m.Paused.Set(boolToFloat64(paused))
+	switch {
+	case paused && hideUp && !m.upHidden:
+		DBProbeUp.Delete(m.labels)
+		m.upHidden = true
+	case !paused && m.upHidden:
+		m.Up = DBProbeUp.With(m.labels)
+		m.upHidden = false
+	}
+}
+
+// SetStale 更新指标是否过期；过期时按 behavior 处理 db_probe_up：down 置 0，nan 置 NaN（未知），mark 保持不变
+// 恢复时由下一次探测结果覆盖 db_probe_up
+func (m *TargetMetrics) SetStale(stale bool, behavior string) {
+	m.Stale.Set(boolToFloat64(stale))
+	if !stale {
+		return
+	}
+	switch behavior {
+	case "down":
+		m.Up.Set(0)
+	case "nan":
+		m.Up.Set(math.NaN())
+	}
+}
+
+// RecordAttempt 记录一次 Ping 或查询的尝试（step 为 AttemptSteps 之一）
+func (m *TargetMetrics) RecordAttempt(step string, success bool) {
+	if success {
+		m.Attempts[step].success.Inc()
+	} else {
+		m.Attempts[step].failure.Inc()
+	}
+}
+
+// RecordStatements 记录对数据库执行的语句数
+func (m *TargetMetrics) RecordStatements(kind string, n int) {
+	m.Statements[kind].Add(float64(n))
+}
 
-	// 初始化 Counter 类型指标，确保即使值为 0 也会显示
-	// Counter 类型需要通过 Add(0) 来初始化，这样即使值为 0 也会在 /metrics 中显示
-	DBProbeFailuresTotal.With(labels).Add(0)
-	DBProbePingFailuresTotal.With(labels).Add(0)
-	DBProbeQueryFailuresTotal.With(labels).Add(0)
-	DBProbeConnectionReconnectsTotal.With(labels).Add(0)
+// SetBudgetExceeded 更新语句预算状态
+func (m *TargetMetrics) SetBudgetExceeded(exceeded bool) {
+	m.BudgetExceeded.Set(boolToFloat64(exceeded))
 }
 
 func boolToFloat64(b bool) float64 {
@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/imkerbos/db-probe/internal/config"
+)
+
+func newBenchLabels() map[string]string {
+	return NewLabels(&config.DBConfig{
+		Name:    "bench",
+		Type:    "mysql",
+		Host:    "127.0.0.1",
+		Project: "bench",
+		Env:     "bench",
+		Labels:  map[string]string{"role": "master"},
+	}, "127.0.0.1", "")
+}
+
+// BenchmarkUpdateMetrics 模拟一次成功探测的全部指标更新
+func BenchmarkUpdateMetrics(b *testing.B) {
+	m := NewTargetMetrics(newBenchLabels(), NewInfoLabels(&config.DBConfig{}))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.UpdatePingResult(true, 0.001)
+		m.UpdateQueryResult(true, 0.001)
+		m.UpdateProbeResult(true, 0.002)
+	}
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// runtimeCollectors 当前注册的运行时收集器
+// 初始为 client_golang 默认注册表中已有的收集器（Unregister 按描述符匹配，新建的实例即可代表它们）
+var runtimeCollectors = []prometheus.Collector{
+	collectors.NewGoCollector(),
+	collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+}
+
+// ConfigureRuntimeCollectors 按 runtime_metrics 配置注册探针进程自身的运行时指标
+// 指标与探测指标在同一个注册表中，/metrics 和 remote write 一并输出，探针主机无需另外部署 node_exporter
+//
+//   - off：不输出 go_*、process_* 指标
+//   - basic（默认）：client_golang 默认的 Go 运行时指标（goroutine、GC 耗时、memstats）
+//     和进程指标（CPU 时间、RSS、打开的文件描述符数，仅 Linux）
+//   - full：在 basic 基础上输出 runtime/metrics 的全部指标（GC 暂停分布、调度延迟、各类内存占用等）
+func ConfigureRuntimeCollectors(mode string) {
+	for _, c := range runtimeCollectors {
+		prometheus.Unregister(c)
+	}
+	runtimeCollectors = nil
+
+	switch mode {
+	case "off":
+		return
+	case "full":
+		runtimeCollectors = append(runtimeCollectors, collectors.NewGoCollector(
+			collectors.WithGoCollectorRuntimeMetrics(collectors.MetricsAll),
+		))
+	default:
+		runtimeCollectors = append(runtimeCollectors, collectors.NewGoCollector())
+	}
+	runtimeCollectors = append(runtimeCollectors, collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	for _, c := range runtimeCollectors {
+		prometheus.MustRegister(c)
+	}
+}
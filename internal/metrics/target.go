@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// targetGatherer 只保留 gatherer 采集到的某个目标（db_name）的时间序列
+type targetGatherer struct {
+	gatherer prometheus.Gatherer
+	name     string
+}
+
+// ForTarget 返回只包含目标 name 的时间序列的 gatherer，没有该目标时间序列的指标整个省略
+// 用于 /probe 按目标返回指标，探针进程自身的指标（db_probe_runtime_*、go_* 等）不包含在内
+func ForTarget(gatherer prometheus.Gatherer, name string) prometheus.Gatherer {
+	return &targetGatherer{gatherer: gatherer, name: name}
+}
+
+// Gather 采集指标并过滤出 db_name 为目标名称的时间序列
+func (g *targetGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	filtered := families[:0]
+	for _, family := range families {
+		metrics := family.Metric[:0]
+		for _, m := range family.Metric {
+			if labelValue(m, "db_name") == g.name {
+				metrics = append(metrics, m)
+			}
+		}
+		if len(metrics) > 0 {
+			family.Metric = metrics
+			filtered = append(filtered, family)
+		}
+	}
+	return filtered, err
+}
+
+// labelValue 时间序列上该名称的 label 的值，没有该 label 时返回空字符串
+func labelValue(m *dto.Metric, name string) string {
+	for _, l := range m.Label {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/prober"
+)
+
+// payload 按渠道格式构造请求体
+// json 为原始状态变化事件；slack、wecom、dingtalk 为各自机器人的文本消息格式
+func (c *channel) payload(ev *prober.StateEvent) ([]byte, error) {
+	switch c.format() {
+	case "slack":
+		return json.Marshal(map[string]string{"text": text(ev)})
+	case "wecom", "dingtalk":
+		return json.Marshal(map[string]interface{}{
+			"msgtype": "text",
+			"text":    map[string]string{"content": text(ev)},
+		})
+	default:
+		return json.Marshal(ev)
+	}
+}
+
+// text 状态变化的文本描述，用于 chat 机器人
+func text(ev *prober.StateEvent) string {
+	var b strings.Builder
+	if ev.Test {
+		b.WriteString("[演练]")
+	}
+	if ev.Up {
+		fmt.Fprintf(&b, "[恢复] %s 探测恢复正常", ev.Target)
+	} else {
+		fmt.Fprintf(&b, "[故障] %s 探测失败", ev.Target)
+	}
+	fmt.Fprintf(&b, "\n类型: %s\n地址: %s", ev.Type, ev.Host)
+	if ev.IP != "" && ev.IP != ev.Host {
+		fmt.Fprintf(&b, " (%s)", ev.IP)
+	}
+	if ev.Schema != "" {
+		fmt.Fprintf(&b, "\nSchema: %s", ev.Schema)
+	}
+	if ev.Project != "" || ev.Env != "" {
+		fmt.Fprintf(&b, "\n项目: %s / %s", ev.Project, ev.Env)
+	}
+	if !ev.Up {
+		if ev.Stage != "" {
+			fmt.Fprintf(&b, "\n失败阶段: %s", ev.Stage)
+		}
+		if ev.Error != "" {
+			fmt.Fprintf(&b, "\n错误: %s", ev.Error)
+		}
+	}
+	if !ev.OutageStart.IsZero() {
+		fmt.Fprintf(&b, "\n故障开始: %s", ev.OutageStart.Format(time.DateTime))
+		if ev.Up {
+			fmt.Fprintf(&b, "\n故障时长: %s", ev.DetectedAt.Sub(ev.OutageStart).Round(time.Second))
+		}
+	}
+	fmt.Fprintf(&b, "\n检测时间: %s", ev.DetectedAt.Format(time.DateTime))
+	if ev.Owner != "" {
+		fmt.Fprintf(&b, "\n负责人: %s", ev.Owner)
+	}
+	if ev.Team != "" {
+		fmt.Fprintf(&b, "\n团队: %s", ev.Team)
+	}
+	if ev.Oncall != "" {
+		fmt.Fprintf(&b, "\n值班: %s", ev.Oncall)
+	}
+	if ev.RunbookURL != "" {
+		fmt.Fprintf(&b, "\n处理手册: %s", ev.RunbookURL)
+	}
+	return b.String()
+}
@@ -0,0 +1,450 @@
+// Package notify 把目标状态变化发送到 webhook/chat 机器人
+// 每条通知先写入磁盘队列（每个渠道一个目录，一条通知一个文件）再由后台按顺序发送，至少发送一次：
+// 发送失败按指数退避重试，webhook/chat 服务故障或探针重启期间通知保留在磁盘上，恢复后继续发送；
+// 超过 max_attempts 或被对端明确拒绝（4xx）的通知移入死信目录，把文件移回队列目录即可重新发送
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/internal/metrics"
+	"github.com/imkerbos/db-probe/internal/prober"
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+const (
+	// maxRetryInterval 指数退避的最长重试间隔
+	maxRetryInterval = 5 * time.Minute
+	// rescanInterval 队列为空时重新扫描队列目录的间隔，运维把死信移回队列目录后无需重启即可发送
+	rescanInterval = time.Minute
+	// deadDir 死信子目录名
+	deadDir = "dead"
+)
+
+// Notifier 状态变化通知器
+type Notifier struct {
+	cfg      config.NotifyConfig
+	channels []*channel
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// channel 单个通知渠道，拥有独立的队列目录和发送 goroutine，一个渠道故障不影响其他渠道
+type channel struct {
+	cfg     config.NotifyWebhook
+	dir     string
+	deadDir string
+	client  *http.Client
+	notify  *config.NotifyConfig
+
+	// seq 同一纳秒内写入多条通知时区分文件名
+	seq atomic.Uint64
+	// wake 有新通知写入队列时唤醒发送 goroutine
+	wake chan struct{}
+	// dueAt 发送循环预定的下一次发送时间（UnixNano），超过后仍有待发送的通知说明发送循环卡住（见 Health）
+	dueAt atomic.Int64
+}
+
+// ChannelStatus 通知渠道的队列状态，供 /health 使用
+type ChannelStatus struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Pending int    `json:"pending"` // 队列中待发送的通知数
+	// Overdue 发送循环超过预定时间多久仍没有发送（有待发送的通知时）
+	Overdue string `json:"overdue,omitempty"`
+}
+
+// message 队列中的通知
+type message struct {
+	Event      prober.StateEvent `json:"event"`
+	EnqueuedAt time.Time         `json:"enqueued_at"`
+	Attempts   int               `json:"attempts"`
+	LastError  string            `json:"last_error,omitempty"`
+}
+
+// permanentError 对端明确拒绝的请求，重试不会成功
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// New 创建队列目录，已有的未发送通知在 Start 后继续发送
+func New(cfg config.NotifyConfig) (*Notifier, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &Notifier{cfg: cfg, ctx: ctx, cancel: cancel}
+	for _, wh := range cfg.Webhooks {
+		c := &channel{
+			cfg:     wh,
+			dir:     filepath.Join(cfg.QueueDir, wh.Name),
+			deadDir: filepath.Join(cfg.QueueDir, wh.Name, deadDir),
+			client:  &http.Client{Timeout: cfg.Timeout},
+			notify:  &n.cfg,
+			wake:    make(chan struct{}, 1),
+		}
+		if err := os.MkdirAll(c.deadDir, 0o755); err != nil {
+			cancel()
+			return nil, fmt.Errorf("创建通知队列目录失败: %w", err)
+		}
+		n.channels = append(n.channels, c)
+	}
+	return n, nil
+}
+
+// Start 启动各渠道的发送循环
+func (n *Notifier) Start() {
+	for _, c := range n.channels {
+		pending, dead := c.refreshMetrics()
+		logger.L().Infow("状态变化通知已启用",
+			"channel", c.cfg.Name,
+			"format", c.format(),
+			"queue_dir", c.dir,
+			"pending", pending,
+			"dead_letters", dead,
+		)
+		c.dueAt.Store(time.Now().UnixNano())
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			c.run(n.ctx)
+		}()
+	}
+}
+
+// Stop 停止发送，未发送的通知保留在队列目录中，下次启动后继续发送
+func (n *Notifier) Stop() {
+	n.cancel()
+	n.wg.Wait()
+}
+
+// Handle 状态变化事件回调，通过 prober.Subscribe 注册
+// 通知同步写入各渠道的队列目录（不等待发送），写入后即使探针重启也不会丢失
+// 启动后首次探测即正常的目标不是真正的状态变化，不发送通知
+func (n *Notifier) Handle(ev prober.StateEvent) {
+	if ev.Initial && ev.Up {
+		return
+	}
+	for _, c := range n.channels {
+		if err := c.enqueue(ev); err != nil {
+			metrics.RecordNotifyDelivery(c.cfg.Name, "dropped")
+			logger.L().Errorw("写入通知队列失败，通知丢失",
+				"channel", c.cfg.Name,
+				"target", ev.Target,
+				"up", ev.Up,
+				"error", err,
+			)
+			continue
+		}
+		c.refreshMetrics()
+		select {
+		case c.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// enqueue 写入一条通知：先写临时文件再重命名，发送 goroutine 不会读到写了一半的文件
+// 文件名以写入时间开头，按文件名排序即为写入顺序
+func (c *channel) enqueue(ev prober.StateEvent) error {
+	msg := message{Event: ev, EnqueuedAt: time.Now()}
+	name := fmt.Sprintf("%020d-%06d.json", msg.EnqueuedAt.UnixNano(), c.seq.Add(1)%1000000)
+	return writeMessage(filepath.Join(c.dir, name), &msg)
+}
+
+func (c *channel) run(ctx context.Context) {
+	rescan := time.NewTicker(rescanInterval)
+	defer rescan.Stop()
+
+	// retryAt 队首通知的下一次重试时间，退避期间新通知的写入不会提前触发重试
+	var retryAt time.Time
+	for {
+		if !time.Now().Before(retryAt) {
+			retryAt = c.deliver(ctx)
+		}
+		if retryAt.IsZero() {
+			c.dueAt.Store(time.Now().Add(rescanInterval).UnixNano())
+		} else {
+			c.dueAt.Store(retryAt.UnixNano())
+		}
+
+		var timer *time.Timer
+		var retry <-chan time.Time
+		if !retryAt.IsZero() {
+			timer = time.NewTimer(time.Until(retryAt))
+			retry = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-c.wake:
+		case <-retry:
+		case <-rescan.C:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// deliver 按写入顺序发送队列中的通知，队首发送失败时停止并返回下一次重试的时间
+// 同一渠道严格按顺序发送，故障通知不会被其后的恢复通知超越；队列发送完毕时返回零值
+func (c *channel) deliver(ctx context.Context) time.Time {
+	defer c.refreshMetrics()
+	files, err := listMessages(c.dir)
+	if err != nil {
+		logger.L().Errorw("读取通知队列失败", "channel", c.cfg.Name, "error", err)
+		return time.Now().Add(c.notify.RetryInterval)
+	}
+
+	for _, file := range files {
+		if ctx.Err() != nil {
+			return time.Time{}
+		}
+		path := filepath.Join(c.dir, file)
+		msg, err := readMessage(path)
+		if err != nil {
+			logger.L().Errorw("通知文件无法解析，移入死信目录", "channel", c.cfg.Name, "file", file, "error", err)
+			c.deadLetter(file)
+			continue
+		}
+
+		c.dueAt.Store(time.Now().Add(c.notify.Timeout).UnixNano())
+		err = c.send(ctx, &msg.Event)
+		if err == nil {
+			metrics.RecordNotifyDelivery(c.cfg.Name, "sent")
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				// 删除失败时下一轮会重复发送，至少一次语义允许重复
+				logger.L().Warnw("删除已发送的通知失败", "channel", c.cfg.Name, "file", file, "error", err)
+			}
+			if msg.Attempts > 0 {
+				logger.L().Infow("通知重试发送成功",
+					"channel", c.cfg.Name,
+					"target", msg.Event.Target,
+					"attempts", msg.Attempts+1,
+					"delay", time.Since(msg.EnqueuedAt).Round(time.Second).String(),
+				)
+			}
+			continue
+		}
+		if ctx.Err() != nil {
+			// 停止时中断的发送不计入重试次数
+			return time.Time{}
+		}
+
+		msg.Attempts++
+		msg.LastError = err.Error()
+		var permanent *permanentError
+		if errors.As(err, &permanent) || msg.Attempts >= c.notify.MaxAttempts {
+			metrics.RecordNotifyDelivery(c.cfg.Name, "dead_lettered")
+			logger.L().Errorw("通知发送失败，已移入死信目录",
+				"channel", c.cfg.Name,
+				"target", msg.Event.Target,
+				"up", msg.Event.Up,
+				"attempts", msg.Attempts,
+				"error", err,
+			)
+			if err := writeMessage(path, msg); err != nil {
+				logger.L().Warnw("更新通知文件失败", "channel", c.cfg.Name, "file", file, "error", err)
+			}
+			c.deadLetter(file)
+			continue
+		}
+
+		metrics.RecordNotifyDelivery(c.cfg.Name, "retried")
+		delay := c.backoff(msg.Attempts)
+		logger.L().Warnw("通知发送失败，等待重试",
+			"channel", c.cfg.Name,
+			"target", msg.Event.Target,
+			"attempts", msg.Attempts,
+			"retry_in", delay.String(),
+			"error", err,
+		)
+		if err := writeMessage(path, msg); err != nil {
+			logger.L().Warnw("更新通知文件失败", "channel", c.cfg.Name, "file", file, "error", err)
+		}
+		return time.Now().Add(delay)
+	}
+	return time.Time{}
+}
+
+// Health 返回各渠道的队列状态：队列中有待发送的通知，而发送循环超过预定的发送时间 stallTimeout 仍没有发送时判定卡住
+// 退避等待重试属于正常情况，不判定为卡住
+func (n *Notifier) Health(now time.Time, stallTimeout time.Duration) []ChannelStatus {
+	statuses := make([]ChannelStatus, 0, len(n.channels))
+	for _, c := range n.channels {
+		files, _ := listMessages(c.dir)
+		status := ChannelStatus{Name: c.cfg.Name, OK: true, Pending: len(files)}
+		if overdue := now.Sub(time.Unix(0, c.dueAt.Load())); len(files) > 0 && overdue > 0 {
+			status.Overdue = overdue.Round(time.Second).String()
+			status.OK = overdue <= stallTimeout
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// backoff 第 attempts 次失败后的重试间隔：retry_interval 每次翻倍，最长 5m
+func (c *channel) backoff(attempts int) time.Duration {
+	delay := c.notify.RetryInterval
+	for i := 1; i < attempts && delay < maxRetryInterval; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryInterval)
+}
+
+// deadLetter 把通知移入死信目录
+func (c *channel) deadLetter(file string) {
+	if err := os.Rename(filepath.Join(c.dir, file), filepath.Join(c.deadDir, file)); err != nil {
+		logger.L().Errorw("移入死信目录失败", "channel", c.cfg.Name, "file", file, "error", err)
+	}
+}
+
+// refreshMetrics 按队列目录和死信目录中的文件数更新指标
+func (c *channel) refreshMetrics() (pending, dead int) {
+	files, _ := listMessages(c.dir)
+	deadFiles, _ := listMessages(c.deadDir)
+	metrics.SetNotifyQueue(c.cfg.Name, len(files), len(deadFiles))
+	return len(files), len(deadFiles)
+}
+
+func (c *channel) format() string {
+	if c.cfg.Format == "" {
+		return "json"
+	}
+	return c.cfg.Format
+}
+
+// send 发送一条通知
+// 2xx 为成功；408、429 和 5xx 可以重试，其余 4xx 说明地址或认证错误，重试不会成功
+// 企业微信、钉钉机器人出错时也返回 200，需要检查响应中的 errcode
+func (c *channel) send(ctx context.Context, ev *prober.StateEvent) error {
+	body, err := c.payload(ev)
+	if err != nil {
+		return &permanentError{err: err}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "db-probe")
+	for k, v := range c.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		// 错误信息中包含请求地址，机器人地址中的 key 不能输出到日志
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("%s 请求失败: %w", urlErr.Op, urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	decodeErr := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&result)
+	switch {
+	case resp.StatusCode/100 == 2:
+		if decodeErr == nil && result.ErrCode != 0 {
+			return fmt.Errorf("通知渠道返回错误: errcode=%d, errmsg=%s", result.ErrCode, result.ErrMsg)
+		}
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("通知渠道返回 HTTP %d", resp.StatusCode)
+	default:
+		return &permanentError{err: fmt.Errorf("通知渠道返回 HTTP %d", resp.StatusCode)}
+	}
+}
+
+// listMessages 返回目录中的通知文件名（按写入顺序），忽略临时文件和子目录
+func listMessages(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), ".json") {
+			files = append(files, entry.Name())
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func readMessage(path string) (*message, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// writeMessage 写入临时文件后重命名，替换已有文件时是原子的
+// 重命名前同步临时文件、重命名后同步所在目录，机器掉电后不会留下空文件或丢失已入队的通知
+func writeMessage(path string, msg *message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir 同步目录，使其中文件的创建、重命名落盘
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,85 @@
+package prober
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/imkerbos/db-probe/internal/config"
+)
+
+// defaultMaxAddresses probe_all_addresses 未配置 max_addresses 时最多探测的地址数
+const defaultMaxAddresses = 8
+
+// resolveIP 解析用于 db_ip label 的地址（支持 IP 地址和 DNS 域名）
+// 域名优先使用第一个 IPv4 地址，没有 IPv4 时使用第一个地址；解析失败时返回 host 本身
+func resolveIP(host string) string {
+	if host == "" {
+		return host
+	}
+	// 先检查是否是 IP 地址格式
+	if parsedIP := net.ParseIP(host); parsedIP != nil {
+		return parsedIP.String()
+	}
+	ip, err := lookupIP(host)
+	if err != nil {
+		return host
+	}
+	return ip
+}
+
+// lookupIP 解析域名，优先返回第一个 IPv4 地址，没有 IPv4 时返回第一个地址
+func lookupIP(host string) (string, error) {
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return "", err
+	}
+	if len(ips) == 0 {
+		return "", fmt.Errorf("没有解析到地址: %s", host)
+	}
+	// 优先使用 IPv4
+	for _, resolvedIP := range ips {
+		if resolvedIP.To4() != nil {
+			return resolvedIP.String(), nil
+		}
+	}
+	return ips[0].String(), nil
+}
+
+// resolveAddresses 解析域名的全部地址（IPv4 和 IPv6），去重后按解析器返回的顺序最多保留 max 个
+func resolveAddresses(host string, max int) ([]string, error) {
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(ips))
+	var addresses []string
+	for _, ip := range ips {
+		addr := ip.String()
+		if seen[addr] {
+			continue
+		}
+		seen[addr] = true
+		addresses = append(addresses, addr)
+		if len(addresses) == max {
+			break
+		}
+	}
+	return addresses, nil
+}
+
+// targetAddresses 返回需要分别探测的地址
+// 未开启 probe_all_addresses、host 本身是 IP、解析失败或只解析出一个地址时返回 nil，按普通目标探测
+func targetAddresses(dbCfg *config.DBConfig) ([]string, error) {
+	if !dbCfg.ProbeAllAddresses || net.ParseIP(dbCfg.Host) != nil {
+		return nil, nil
+	}
+	max := dbCfg.MaxAddresses
+	if max == 0 {
+		max = defaultMaxAddresses
+	}
+	addresses, err := resolveAddresses(dbCfg.Host, max)
+	if err != nil || len(addresses) <= 1 {
+		return nil, err
+	}
+	return addresses, nil
+}
@@ -0,0 +1,69 @@
+package prober
+
+import (
+	"database/sql"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/internal/db"
+)
+
+// checkPool 可选检查的独立连接池
+// 使用克隆的 Connector，新建连接不计入探测连接池的复用判断，会话初始化语句仍计入开销
+type checkPool struct {
+	db        *sql.DB
+	connector *db.Connector
+	maxIdle   int
+}
+
+// newCheckPools 为配置了 check_pools 的检查创建独立连接池，connector 为探测连接池使用的 Connector
+// 非 database/sql 目标（connector 为 nil）不创建
+func newCheckPools(dbCfg *config.DBConfig, connector *db.Connector) map[string]*checkPool {
+	if connector == nil || len(dbCfg.CheckPools) == 0 {
+		return nil
+	}
+	pools := make(map[string]*checkPool, len(dbCfg.CheckPools))
+	for name, poolCfg := range dbCfg.CheckPools {
+		maxOpen := poolCfg.MaxOpenConns
+		if maxOpen == 0 {
+			maxOpen = 1
+		}
+		maxIdle := poolCfg.MaxIdleConns
+		if maxIdle == 0 {
+			maxIdle = 1
+		}
+		clone := connector.Clone()
+		maxIdle = min(maxIdle, maxOpen)
+		pools[name] = &checkPool{db: openPool(clone, maxOpen, maxIdle), connector: clone, maxIdle: maxIdle}
+	}
+	return pools
+}
+
+// checkDB 返回检查使用的连接池：配置了独立连接池时返回该连接池，否则与探测 SQL 共用 DB
+func (t *DBTarget) checkDB(check string) *sql.DB {
+	if pool := t.checkPools[check]; pool != nil {
+		return pool.db
+	}
+	return t.DB
+}
+
+// closeIdleConns 关闭探测连接池和所有独立连接池中的空闲连接，之后新建的连接重新解析地址（如 host 解析出的地址变化后）
+// 正在使用的连接不受影响，归还后按原来的空闲连接数保留
+func (t *DBTarget) closeIdleConns() {
+	if t.DB != nil {
+		t.DB.SetMaxIdleConns(0)
+		t.DB.SetMaxIdleConns(1)
+	}
+	for _, pool := range t.checkPools {
+		pool.db.SetMaxIdleConns(0)
+		pool.db.SetMaxIdleConns(pool.maxIdle)
+	}
+}
+
+// sessionInitStatements 返回探测连接池和所有独立连接池累计执行的会话初始化语句数
+func (t *DBTarget) sessionInitStatements() uint64 {
+	executed := t.connector.SessionInitStatements()
+	for _, pool := range t.checkPools {
+		executed += pool.connector.SessionInitStatements()
+	}
+	return executed
+}
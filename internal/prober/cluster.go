@@ -0,0 +1,101 @@
+package prober
+
+import (
+	"context"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/db"
+)
+
+// checkCluster 按 cluster_check_interval 查询集群节点存活情况（开启 cluster_check 的目标）
+// 属于可选检查：查询失败（如权限不足）只记录 Debug 日志，不影响探测结果；语句数达到预算时跳过
+// 存活节点数变化时输出日志，便于定位节点宕机或恢复的时间
+func (p *Prober) checkCluster(target *DBTarget) {
+	interval := p.config.ClusterCheckInterval
+	if !target.Config.ClusterCheck || interval <= 0 || target.DB == nil {
+		return
+	}
+	switch target.driver.(type) {
+	case db.ClusterChecker, db.DorisChecker:
+	default:
+		return
+	}
+	if !p.optionalCheckAllowed(target) {
+		return
+	}
+
+	now := time.Now()
+	target.mu.Lock()
+	if !target.clusterCheckedAt.IsZero() && now.Sub(target.clusterCheckedAt) < interval {
+		target.mu.Unlock()
+		return
+	}
+	target.clusterCheckedAt = now
+	target.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(p.ctx, p.probeTimeout(target.Config))
+	defer cancel()
+	switch checker := target.driver.(type) {
+	case db.ClusterChecker:
+		p.checkClusterNodes(ctx, target, checker)
+	case db.DorisChecker:
+		p.checkDorisNodes(ctx, target, checker)
+	}
+	target.recordSessionInit() // 查询可能新建了连接
+}
+
+// checkClusterNodes 查询集群节点总数和存活节点数（如 CockroachDB）
+func (p *Prober) checkClusterNodes(ctx context.Context, target *DBTarget, checker db.ClusterChecker) {
+	target.recordStatements("optional", 1)
+	total, live, err := checker.QueryClusterNodes(ctx, target.checkDB("cluster"))
+	if err != nil {
+		target.log.Debugw("查询集群节点存活情况失败", "error", err)
+		return
+	}
+
+	target.mu.Lock()
+	lastLive := target.lastLiveNodes
+	target.lastLiveNodes = live
+	target.mu.Unlock()
+	target.Metrics.SetClusterNodes(total, live)
+
+	switch {
+	case lastLive < 0 || live == lastLive:
+	case live < total:
+		target.log.Warnw("集群存活节点数变化，存在不可用节点", "live_nodes", live, "nodes", total, "previous_live_nodes", lastLive)
+	default:
+		target.log.Infow("集群存活节点数变化，所有节点均存活", "live_nodes", live, "nodes", total, "previous_live_nodes", lastLive)
+	}
+}
+
+// checkDorisNodes 查询 Doris/StarRocks 的 FE、BE 存活情况（SHOW FRONTENDS、SHOW BACKENDS 两条语句）
+// SELECT 1 由 FE 直接计算，BE 全部宕机时探测仍然成功，需要通过存活 BE 数发现
+func (p *Prober) checkDorisNodes(ctx context.Context, target *DBTarget, checker db.DorisChecker) {
+	target.recordStatements("optional", 2)
+	nodes, err := checker.QueryDorisNodes(ctx, target.checkDB("cluster"))
+	if err != nil {
+		target.log.Debugw("查询 FE/BE 节点存活情况失败", "error", err)
+		return
+	}
+
+	target.mu.Lock()
+	last := target.lastDorisNodes
+	target.lastDorisNodes = &nodes
+	target.mu.Unlock()
+	target.Metrics.SetDorisNodes(nodes.Frontends, nodes.AliveFrontends, nodes.Backends, nodes.AliveBackends)
+
+	switch {
+	case last == nil || *last == nodes:
+	case nodes.AliveFrontends < nodes.Frontends || nodes.AliveBackends < nodes.Backends:
+		target.log.Warnw("FE/BE 存活节点数变化，存在不可用节点",
+			"alive_frontends", nodes.AliveFrontends, "frontends", nodes.Frontends,
+			"alive_backends", nodes.AliveBackends, "backends", nodes.Backends,
+			"previous_alive_frontends", last.AliveFrontends, "previous_alive_backends", last.AliveBackends,
+		)
+	default:
+		target.log.Infow("FE/BE 存活节点数变化，所有节点均存活",
+			"frontends", nodes.Frontends, "backends", nodes.Backends,
+			"previous_alive_frontends", last.AliveFrontends, "previous_alive_backends", last.AliveBackends,
+		)
+	}
+}
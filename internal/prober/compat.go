@@ -0,0 +1,97 @@
+package prober
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/imkerbos/db-probe/internal/db"
+)
+
+// compatResult 最近一次探测 SQL 兼容性检查的结果，err 为 nil 表示兼容
+type compatResult struct {
+	version string
+	err     error
+}
+
+// checkQueryCompat 开启 query_compat_check 时检查探测 SQL 能否在目标当前的版本上解析和执行，并记录服务端版本
+// 目标创建后首次 Ping 成功时检查，检测到实例重启或版本变化（升级通常伴随重启）后重新检查；
+// 遇到超时、连接断开等连接错误时无法得出结论，下一轮探测再检查
+func (p *Prober) checkQueryCompat(target *DBTarget) {
+	if !p.config.QueryCompatCheck || target.DB == nil || target.query == "" || !p.optionalCheckAllowed(target) {
+		return
+	}
+	target.mu.Lock()
+	if target.compatChecked {
+		target.mu.Unlock()
+		return
+	}
+	target.compatChecked = true
+	previous := target.lastCompat
+	target.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(p.ctx, p.probeTimeout(target.Config))
+	defer cancel()
+	database := target.DB
+
+	var version string
+	if querier, ok := target.driver.(db.VersionQuerier); ok {
+		target.recordStatements("optional", 1)
+		v, err := querier.QueryVersion(ctx, database)
+		if err != nil {
+			// 版本只用于对照，查询失败（如权限不足）不影响兼容性检查
+			target.log.Debugw("查询数据库版本失败", "error", err)
+		}
+		version = v
+	}
+
+	err := target.explainQuery(ctx, database)
+	target.recordSessionInit() // 检查可能新建了连接
+	if err != nil && db.IsConnectionError(err) {
+		target.log.Debugw("检查探测 SQL 兼容性失败，下一轮探测重试", "error", err)
+		target.recheckQueryCompat()
+		return
+	}
+
+	result := &compatResult{version: version, err: err}
+	target.mu.Lock()
+	target.lastCompat = result
+	target.mu.Unlock()
+	target.Metrics.SetQueryCompatible(err == nil)
+
+	if err != nil {
+		target.log.Warnw("探测 SQL 与数据库当前版本不兼容",
+			"query", target.query,
+			"server_version", version,
+			"error", err,
+		)
+		return
+	}
+	if previous == nil || previous.err != nil || previous.version != version {
+		target.log.Infow("探测 SQL 兼容性检查通过", "query", target.query, "server_version", version)
+	}
+}
+
+// explainQuery 在一条连接上检查探测 SQL，按 schemas 展开的目标先切换到对应的库
+func (t *DBTarget) explainQuery(ctx context.Context, database *sql.DB) error {
+	conn, err := database.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if t.schema != "" {
+		t.recordStatements("optional", 1)
+		if _, err := conn.ExecContext(ctx, "USE `"+t.schema+"`"); err != nil {
+			return fmt.Errorf("切换到 schema %s 失败: %w", t.schema, err)
+		}
+	}
+	t.recordStatements("optional", 1)
+	return db.CheckQueryCompat(ctx, conn, t.driver, t.query)
+}
+
+// recheckQueryCompat 实例重启或版本变化后，下一轮探测重新检查探测 SQL 的兼容性
+func (t *DBTarget) recheckQueryCompat() {
+	t.mu.Lock()
+	t.compatChecked = false
+	t.mu.Unlock()
+}
@@ -0,0 +1,104 @@
+package prober
+
+import "time"
+
+// costWindow 最近一小时对数据库执行的语句数，按分钟分桶的滑动窗口
+type costWindow struct {
+	counts    [60]int
+	minutes   [60]int64 // 每个桶对应的 Unix 分钟数，不在最近一小时内的桶视为过期
+	exceeded  bool      // 是否已达到语句预算，达到后跳过可选检查
+	initStmts uint64    // 已计入的会话初始化语句数（Connector 的累计值）
+}
+
+func (w *costWindow) add(now time.Time, n int) {
+	minute := now.Unix() / 60
+	i := minute % 60
+	if w.minutes[i] != minute {
+		w.minutes[i] = minute
+		w.counts[i] = 0
+	}
+	w.counts[i] += n
+}
+
+// total 返回最近一小时的语句数
+func (w *costWindow) total(now time.Time) int {
+	minute := now.Unix() / 60
+	sum := 0
+	for i, count := range w.counts {
+		if minute-w.minutes[i] < 60 {
+			sum += count
+		}
+	}
+	return sum
+}
+
+// recordStatements 记录对数据库执行的语句数，kind 为 probe、session_init 或 optional
+func (t *DBTarget) recordStatements(kind string, n int) {
+	if n <= 0 {
+		return
+	}
+	t.mu.Lock()
+	t.cost.add(time.Now(), n)
+	t.mu.Unlock()
+	t.Metrics.RecordStatements(kind, n)
+}
+
+// recordSessionInit 把 Connector 新执行的会话初始化语句计入开销
+func (t *DBTarget) recordSessionInit() {
+	if t.connector == nil {
+		return
+	}
+	executed := t.sessionInitStatements()
+	t.mu.Lock()
+	n := int(executed - t.cost.initStmts)
+	t.cost.initStmts = executed
+	t.mu.Unlock()
+	t.recordStatements("session_init", n)
+}
+
+// statementBudget 返回目标每小时的语句预算，0 表示不限制
+func (p *Prober) statementBudget(target *DBTarget) int {
+	if target.Config.StatementBudget != nil {
+		return *target.Config.StatementBudget
+	}
+	return p.config.StatementBudget
+}
+
+// checkBudget 每轮探测后检查最近一小时的语句数是否达到预算，状态变化时输出日志并更新指标
+// 预算只限制可选检查（如运行时长查询），探测语句本身不受影响
+func (p *Prober) checkBudget(target *DBTarget) {
+	budget := p.statementBudget(target)
+	if budget <= 0 {
+		return
+	}
+
+	target.mu.Lock()
+	used := target.cost.total(time.Now())
+	exceeded := used >= budget
+	changed := exceeded != target.cost.exceeded
+	target.cost.exceeded = exceeded
+	target.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	target.Metrics.SetBudgetExceeded(exceeded)
+	if exceeded {
+		target.log.Warnw("最近一小时执行的语句数达到预算，跳过可选检查",
+			"statements_last_hour", used,
+			"statement_budget", budget,
+		)
+	} else {
+		target.log.Infow("最近一小时执行的语句数回落到预算以内，恢复可选检查",
+			"statements_last_hour", used,
+			"statement_budget", budget,
+		)
+	}
+}
+
+// optionalCheckAllowed 未达到语句预算时才执行可选检查
+func (p *Prober) optionalCheckAllowed(target *DBTarget) bool {
+	target.mu.RLock()
+	defer target.mu.RUnlock()
+	return !target.cost.exceeded
+}
@@ -0,0 +1,72 @@
+package prober
+
+import (
+	"net"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// refreshIP 重新解析目标的 host（开启 dns_refresh_interval 或 dns_refresh_on_failure 的目标），地址变化时更新 db_ip
+// 距离上次解析超过 dns_refresh_interval，或开启 dns_refresh_on_failure 且上一轮探测失败（故障演练除外）时解析；
+// host 本身是 IP 或为 probe_all_addresses 展开的目标时不解析，解析失败时保留原来的地址。
+// 地址变化后删除旧 labels 的序列，指标以新的 db_ip 重新创建（与 role 变化相同，计数器从 0 开始），
+// 并关闭连接池（包括 check_pools 的独立连接池）中的空闲连接，本轮探测连接新的地址（如 RDS 故障切换后域名指向新的实例）
+// 在本轮探测之前调用，新的指标由本轮探测结果填充；只在持有 probeMu 时调用，替换 Metrics 时持有 target.mu，与 API 的读取互斥
+func (p *Prober) refreshIP(target *DBTarget, now time.Time) {
+	cfg := target.Config
+	if target.resolvedAt.IsZero() || cfg.Host == "" || net.ParseIP(cfg.Host) != nil {
+		return
+	}
+	due := cfg.DNSRefreshInterval > 0 && now.Sub(target.resolvedAt) >= cfg.DNSRefreshInterval
+	if !due && cfg.DNSRefreshOnFailure {
+		target.mu.RLock()
+		due = target.lastUpStatus != nil && !*target.lastUpStatus && !target.testOutage
+		target.mu.RUnlock()
+	}
+	if !due {
+		return
+	}
+	target.resolvedAt = now
+
+	ip, err := lookupIP(cfg.Host)
+	if err != nil {
+		target.log.Debugw("重新解析 host 失败，保留原来的地址", "error", err)
+		return
+	}
+	if ip == target.IP {
+		return
+	}
+
+	oldLabels := target.Labels
+	newLabels := make(prometheus.Labels, len(oldLabels))
+	for k, v := range oldLabels {
+		newLabels[k] = v
+	}
+	newLabels["db_ip"] = ip
+
+	metrics.DeleteTargetMetrics(oldLabels)
+	newMetrics := metrics.NewTargetMetrics(newLabels, metrics.NewInfoLabels(cfg))
+
+	target.mu.Lock()
+	previous := target.IP
+	target.IP = ip
+	target.Labels = newLabels
+	target.Metrics = newMetrics
+	target.log = target.probeLogger()
+	protected := target.auth.protected
+	target.mu.Unlock()
+	newMetrics.SetAuthProtected(protected)
+
+	// 空闲连接仍连着旧地址，关闭后本轮探测和检查重新建连（其他类型的客户端每次建连时自行解析）
+	target.closeIdleConns()
+
+	// 创建目标时解析失败（db_ip 为 host 本身）不计为地址变化
+	if net.ParseIP(previous) == nil {
+		target.log.Infow("重新解析 host 得到地址", "previous_ip", previous)
+		return
+	}
+	metrics.RecordDNSIPChange(cfg.Name, cfg.Host)
+	target.log.Warnw("host 解析出的地址发生变化", "previous_ip", previous)
+}
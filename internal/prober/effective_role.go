@@ -0,0 +1,72 @@
+package prober
+
+import (
+	"context"
+
+	"github.com/imkerbos/db-probe/internal/db"
+)
+
+// detectRole 识别节点的实际角色并更新 db_probe_effective_role、db_probe_role_mismatch（开启 role_detection 的目标）
+// 能够自行识别角色的客户端（如 MongoDB）直接使用 Ping 识别出的角色，不额外执行语句；
+// SQL 类型每轮探测执行一次角色查询，属于可选检查，最近一小时的语句数达到预算时跳过
+// 查询失败（如权限不足）只记录 Debug 日志，保留上一次识别出的角色
+func (p *Prober) detectRole(target *DBTarget) {
+	if !target.Config.RoleDetection {
+		return
+	}
+
+	var role string
+	if reporter, ok := target.client.(db.RoleReporter); ok {
+		role = reporter.Role()
+	} else if detector, ok := target.driver.(db.RoleDetector); ok && target.DB != nil {
+		if !p.optionalCheckAllowed(target) {
+			return
+		}
+		ctx, cancel := context.WithTimeout(p.ctx, p.probeTimeout(target.Config))
+		defer cancel()
+		target.recordStatements("optional", 1)
+		var err error
+		role, err = detector.DetectRole(ctx, target.checkDB("role"))
+		target.recordSessionInit() // 查询可能新建了连接
+		if err != nil {
+			target.log.Debugw("识别节点实际角色失败", "error", err)
+			return
+		}
+	}
+	if role == "" {
+		return
+	}
+
+	configured := target.Config.Labels["role"]
+	mismatch := configured != "" && roleClass(configured) != roleClass(role)
+
+	target.mu.Lock()
+	previous, previousMismatch := target.effectiveRole, target.roleMismatch
+	target.effectiveRole, target.roleMismatch = role, mismatch
+	target.mu.Unlock()
+	target.Metrics.SetEffectiveRole(previous, role, mismatch)
+
+	switch {
+	case previous == "":
+		target.log.Infow("识别到节点实际角色", "effective_role", role, "configured_role", configured)
+	case previous != role:
+		target.log.Warnw("节点实际角色发生变化", "previous_effective_role", previous, "effective_role", role, "configured_role", configured)
+	}
+	if mismatch && !previousMismatch {
+		target.log.Warnw("节点实际角色与配置的 role 不一致，配置可能需要在故障切换后更新", "effective_role", role, "configured_role", configured)
+	} else if !mismatch && previousMismatch {
+		target.log.Infow("节点实际角色与配置的 role 恢复一致", "effective_role", role, "configured_role", configured)
+	}
+}
+
+// roleClass 把同义的角色名归为一类后再比较，配置中的 master/slave 与识别出的 primary/replica 不算不一致
+func roleClass(role string) string {
+	switch role {
+	case "primary", "master":
+		return "primary"
+	case "replica", "slave", "secondary", "standby":
+		return "replica"
+	default:
+		return role
+	}
+}
@@ -0,0 +1,104 @@
+package prober
+
+import (
+	"sort"
+	"time"
+)
+
+// ErrorSample 目标最近出现过的一种错误，相同错误信息只保留一条并累加次数
+type ErrorSample struct {
+	Message   string    `json:"message"`
+	Stage     string    `json:"stage,omitempty"`
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// TargetErrorSamples 一个目标（开启 probe_all_addresses 的目标为一个地址）的错误样本，最近出现的在前
+type TargetErrorSamples struct {
+	Name    string        `json:"name"`
+	IP      string        `json:"ip"`
+	Schema  string        `json:"schema,omitempty"`
+	Samples []ErrorSample `json:"samples"`
+}
+
+// errorSamples 目标最近出现过的不同错误，最多保留 error_samples 种，超过 error_samples_retention 没有再出现的错误被丢弃
+// 只记录 LastError 之外的历史，偶发的认证失败、超时、DNS 错误在恢复后仍然可以查到
+type errorSamples struct {
+	samples []ErrorSample
+}
+
+// add 记录一次错误，max 为保留的错误种数（0 表示不记录），retention 为 0 时不按时间丢弃
+func (s *errorSamples) add(message, stage string, now time.Time, max int, retention time.Duration) {
+	if max <= 0 {
+		s.samples = nil
+		return
+	}
+	s.expire(now, retention)
+	for i := range s.samples {
+		if s.samples[i].Message == message {
+			s.samples[i].Count++
+			s.samples[i].LastSeen = now
+			s.samples[i].Stage = stage
+			return
+		}
+	}
+	s.samples = append(s.samples, ErrorSample{Message: message, Stage: stage, Count: 1, FirstSeen: now, LastSeen: now})
+	if len(s.samples) > max {
+		// 丢弃最久没有出现的错误
+		oldest := 0
+		for i := range s.samples {
+			if s.samples[i].LastSeen.Before(s.samples[oldest].LastSeen) {
+				oldest = i
+			}
+		}
+		s.samples = append(s.samples[:oldest], s.samples[oldest+1:]...)
+	}
+}
+
+// expire 丢弃超过 retention 没有再出现的错误
+func (s *errorSamples) expire(now time.Time, retention time.Duration) {
+	if retention <= 0 {
+		return
+	}
+	kept := s.samples[:0]
+	for _, sample := range s.samples {
+		if now.Sub(sample.LastSeen) <= retention {
+			kept = append(kept, sample)
+		}
+	}
+	s.samples = kept
+}
+
+// list 返回未过期的错误样本副本，最近出现的在前
+func (s *errorSamples) list(now time.Time, retention time.Duration) []ErrorSample {
+	samples := make([]ErrorSample, 0, len(s.samples))
+	for _, sample := range s.samples {
+		if retention <= 0 || now.Sub(sample.LastSeen) <= retention {
+			samples = append(samples, sample)
+		}
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].LastSeen.After(samples[j].LastSeen) })
+	return samples
+}
+
+// ErrorSamples 返回指定名称（或 ID）的目标最近出现过的不同错误，目标不存在时返回错误
+func (p *Prober) ErrorSamples(name string) ([]TargetErrorSamples, error) {
+	targets, err := p.targetsNamed(name)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	result := make([]TargetErrorSamples, 0, len(targets))
+	for _, target := range targets {
+		target.mu.RLock()
+		result = append(result, TargetErrorSamples{
+			Name:    target.Config.Name,
+			IP:      target.IP,
+			Schema:  target.schema,
+			Samples: target.errorSamples.list(now, p.config.ErrorSamplesRetention),
+		})
+		target.mu.RUnlock()
+	}
+	return result, nil
+}
@@ -0,0 +1,81 @@
+package prober
+
+import (
+	"time"
+)
+
+// StateEvent 目标可用性状态变化事件
+// 事件从检测到状态变化开始携带各环节的时间戳，分发时据此统计故障检测延迟，
+// 下游（日志、通知等）可以用同一组时间戳还原故障从发生到通知的完整时间线
+type StateEvent struct {
+	Target  string `json:"target"`
+	Type    string `json:"type"`
+	Host    string `json:"host"`
+	IP      string `json:"ip"` // 目标地址，开启 probe_all_addresses 时区分同一目标的各个地址
+	Project string `json:"project"`
+	Env     string `json:"env"`
+	Up      bool   `json:"up"`
+	// Schema 按 schemas 展开的目标探测的库名
+	Schema string `json:"schema,omitempty"`
+	// Initial 是否为启动后的首次探测结果（此前没有状态，不是真正的状态变化）
+	Initial bool   `json:"initial,omitempty"`
+	Stage   string `json:"stage,omitempty"` // 失败阶段（恢复事件为空）
+	Error   string `json:"error,omitempty"`
+	// Test 是否由故障演练引起（见 FireTest），演练结束后的恢复事件同样为 true
+	Test bool `json:"test,omitempty"`
+	// OutageStart 故障开始时间，即本次故障首次失败探测的开始时间（恢复事件同样携带，便于计算故障时长）
+	OutageStart time.Time `json:"outage_start,omitzero"`
+	// DetectedAt 探测结束、判定状态发生变化的时间
+	DetectedAt time.Time `json:"detected_at"`
+	// DispatchedAt 事件分发给订阅者的时间
+	DispatchedAt time.Time `json:"dispatched_at"`
+	// Result 判定状态变化的那次探测的完整结果（见 ProbeResult）
+	Result *ProbeResult `json:"result,omitempty"`
+	// TargetID 目标的稳定标识，目标改名后保持不变，下游按它关联同一目标改名前后的事件
+	TargetID string `json:"target_id"`
+	// RunbookURL 处理手册链接：故障事件为当前错误对应的处理手册（错误分类规则优先，其次为目标配置），恢复事件为目标的处理手册
+	RunbookURL string `json:"runbook_url,omitempty"`
+	// Owner/Team/Oncall 目标归属信息，收到通知后无需再查 CMDB
+	Owner  string `json:"owner,omitempty"`
+	Team   string `json:"team,omitempty"`
+	Oncall string `json:"oncall,omitempty"`
+}
+
+// Subscribe 订阅目标状态变化事件
+// 回调在探测 goroutine 中同步执行，不能阻塞；需要耗时处理（如发送通知）时应自行异步化
+func (p *Prober) Subscribe(handler func(StateEvent)) {
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+	p.subscribers = append(p.subscribers, handler)
+}
+
+// recentEventsSize 内存中保留的最近状态变化事件数
+const recentEventsSize = 200
+
+// RecentEvents 返回最近的状态变化事件（最多 200 个，按时间从早到晚），用于支持包等排障场景
+// 需要完整的历史时请开启 changefeed
+func (p *Prober) RecentEvents() []StateEvent {
+	p.subMu.RLock()
+	defer p.subMu.RUnlock()
+	return append([]StateEvent(nil), p.recentEvents...)
+}
+
+// dispatchStateEvent 分发状态变化事件，并记录故障事件的检测延迟
+// 启动后首次探测即失败的目标无法知道故障实际开始的时间，故障演练也不是真实故障，均不计入检测延迟
+func (p *Prober) dispatchStateEvent(target *DBTarget, ev StateEvent) {
+	ev.DispatchedAt = time.Now()
+	if !ev.Up && !ev.Test && !ev.OutageStart.IsZero() {
+		target.Metrics.ObserveDetectionLatency(ev.DispatchedAt.Sub(ev.OutageStart).Seconds())
+	}
+
+	p.subMu.Lock()
+	subscribers := p.subscribers
+	p.recentEvents = append(p.recentEvents, ev)
+	if len(p.recentEvents) > recentEventsSize {
+		p.recentEvents = append(p.recentEvents[:0], p.recentEvents[len(p.recentEvents)-recentEventsSize:]...)
+	}
+	p.subMu.Unlock()
+	for _, handler := range subscribers {
+		handler(ev)
+	}
+}
@@ -0,0 +1,24 @@
+package prober
+
+import (
+	"os"
+)
+
+// updateFileSize 读取 sqlite 目标的数据库文件和 WAL 文件大小，更新 db_probe_file_size_bytes
+// 文件不存在或无法读取时删除对应的序列（WAL 文件只在 WAL 模式下有写入时存在），文件能否打开由探测本身体现
+func (p *Prober) updateFileSize(target *DBTarget) {
+	if target.Config.Type != "sqlite" {
+		return
+	}
+	for file, path := range map[string]string{
+		"db":  target.Config.Path,
+		"wal": target.Config.Path + "-wal",
+	} {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			target.Metrics.SetFileSize(file, 0, false)
+			continue
+		}
+		target.Metrics.SetFileSize(file, info.Size(), true)
+	}
+}
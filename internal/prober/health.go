@@ -0,0 +1,49 @@
+package prober
+
+import (
+	"github.com/imkerbos/db-probe/internal/db"
+)
+
+// updateQueryPhases 使用探测客户端读取到的排队时间和执行时间更新 db_probe_query_queued_seconds、db_probe_query_execution_seconds
+// 查询失败时服务端通常也返回了 stats（如排队超时），同样导出
+func (p *Prober) updateQueryPhases(target *DBTarget) {
+	reporter, ok := target.client.(db.QueryPhaseReporter)
+	if !ok {
+		return
+	}
+	queued, execution, ok := reporter.QueryPhases()
+	if !ok {
+		return
+	}
+	target.Metrics.SetQueryPhases(queued.Seconds(), execution.Seconds())
+}
+
+// updateHealth 使用探测客户端读取到的集群健康状态更新 db_probe_cluster_health
+// 降级状态（如 Elasticsearch 的 yellow）下探测仍然成功，只通过指标和日志体现；
+// 状态变化时输出日志：变为非 green 为 Warn，恢复 green 为 Info
+func (p *Prober) updateHealth(target *DBTarget) {
+	reporter, ok := target.client.(db.HealthReporter)
+	if !ok {
+		return
+	}
+	health := reporter.Health()
+	if health == "" {
+		return
+	}
+
+	target.mu.Lock()
+	previous := target.lastHealth
+	target.lastHealth = health
+	target.mu.Unlock()
+	target.Metrics.SetClusterHealth(health)
+
+	switch {
+	case health == previous:
+	case health == "green":
+		if previous != "" {
+			target.log.Infow("集群健康状态恢复", "cluster_health", health, "previous_cluster_health", previous)
+		}
+	default:
+		target.log.Warnw("集群健康状态变化", "cluster_health", health, "previous_cluster_health", previous)
+	}
+}
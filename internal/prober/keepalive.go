@@ -0,0 +1,62 @@
+package prober
+
+import (
+	"context"
+	"time"
+)
+
+// keepaliveTicker 配置了 keepalive_interval 的目标在两轮探测之间发送保活 Ping 的定时器，未配置时返回 nil（select 中永远不会触发）
+func keepaliveTicker(target *DBTarget) (<-chan time.Time, func()) {
+	if target.Config.KeepaliveInterval <= 0 {
+		return nil, func() {}
+	}
+	ticker := time.NewTicker(target.Config.KeepaliveInterval)
+	return ticker.C, ticker.Stop
+}
+
+// keepalive 对目标的空闲连接执行一次 Ping，保持 NAT、防火墙的连接状态
+// 距离上次探测不足半个 keepalive_interval（刚探测过，定时器与探测的时间点接近时不必再发送）、正在探测（含立即探测）、暂停、账号锁定保护、故障演练或超出语句预算时跳过；
+// Ping 失败只记录日志，不更新探测指标，由下一轮探测报告目标状态（database/sql 会丢弃失效的连接，下一轮探测重新建连）
+func (p *Prober) keepalive(target *DBTarget) {
+	if !target.probeMu.TryLock() {
+		return
+	}
+	defer target.probeMu.Unlock()
+
+	now := time.Now()
+	target.mu.RLock()
+	lastProbeAt := target.lastProbeAt
+	target.mu.RUnlock()
+	if now.Sub(lastProbeAt) < target.Config.KeepaliveInterval/2 {
+		return
+	}
+	if _, paused := p.pauseOf(target, now); paused {
+		return
+	}
+	if !p.authProbeAllowed(target, now) || target.testFireActive(now) || !p.optionalCheckAllowed(target) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(p.ctx, p.probeTimeout(target.Config))
+	defer cancel()
+	err := target.ping(ctx)
+	target.recordSessionInit() // 连接失效时 Ping 会新建连接
+	if err != nil {
+		target.log.Debugw("保活 Ping 失败", "error", err)
+	}
+}
+
+// dropConnections 开启 always_reconnect 的目标在探测前关闭已有的连接，本轮探测重新建连
+func (t *DBTarget) dropConnections() {
+	if !t.Config.AlwaysReconnect {
+		return
+	}
+	if t.client != nil {
+		// redis 客户端关闭连接后，下一次 Ping 重新连接
+		_ = t.client.Close()
+		return
+	}
+	// MaxIdleConns 设为 0 会关闭所有空闲连接，再恢复为 1 使本轮探测的 Ping 和探测 SQL 共用新建的连接
+	t.DB.SetMaxIdleConns(0)
+	t.DB.SetMaxIdleConns(1)
+}
@@ -0,0 +1,79 @@
+package prober
+
+import (
+	"time"
+)
+
+// authGuard 账号锁定保护状态
+// 数据库通常配置了登录失败锁定策略（如 Oracle FAILED_LOGIN_ATTEMPTS），探针按固定间隔
+// 使用错误密码反复登录，很快就会把探测账号锁死。连续认证失败达到 auth_failure_threshold 后，
+// 目标进入保护状态：探测间隔降为 auth_failure_backoff（为 0 时完全停止探测），
+// 直到探测成功（凭据已修复）或通过接口手动恢复
+type authGuard struct {
+	failures  int       // 连续认证失败次数
+	protected bool      // 是否处于保护状态
+	since     time.Time // 进入保护的时间
+	nextProbe time.Time // 保护期间下次允许探测的时间
+}
+
+// authProbeAllowed 判断目标本轮是否允许探测，未处于保护状态时总是允许
+func (p *Prober) authProbeAllowed(target *DBTarget, now time.Time) bool {
+	target.mu.RLock()
+	defer target.mu.RUnlock()
+
+	if !target.auth.protected {
+		return true
+	}
+	if p.config.AuthFailureBackoff <= 0 {
+		return false
+	}
+	return !now.Before(target.auth.nextProbe)
+}
+
+// updateAuthGuard 根据本轮探测结果更新保护状态，调用方需持有 target.mu 写锁
+// 返回本轮是否进入或退出了保护状态，由调用方在锁外输出日志和更新指标
+func (p *Prober) updateAuthGuard(target *DBTarget, err error, authFailure bool, now time.Time) (entered, left bool) {
+	g := &target.auth
+	switch {
+	case err == nil:
+		left = g.protected
+		*g = authGuard{}
+	case authFailure:
+		g.failures++
+		if g.protected {
+			g.nextProbe = now.Add(p.config.AuthFailureBackoff)
+		} else if threshold := p.config.AuthFailureThreshold; threshold > 0 && g.failures >= threshold {
+			g.protected = true
+			g.since = now
+			g.nextProbe = now.Add(p.config.AuthFailureBackoff)
+			entered = true
+		}
+	case !g.protected:
+		// 其他错误打断连续认证失败计数；已处于保护状态时保持不变，只有成功或手动恢复才能退出
+		g.failures = 0
+	default:
+		g.nextProbe = now.Add(p.config.AuthFailureBackoff)
+	}
+	return entered, left
+}
+
+// logAuthGuardChange 输出保护状态变化日志并更新指标
+func (p *Prober) logAuthGuardChange(target *DBTarget, entered, left bool) {
+	switch {
+	case entered:
+		target.Metrics.SetAuthProtected(true)
+		if p.config.AuthFailureBackoff > 0 {
+			target.log.Warnw("连续认证失败，进入账号锁定保护，降低探测频率",
+				"auth_failures", p.config.AuthFailureThreshold,
+				"backoff", p.config.AuthFailureBackoff,
+			)
+		} else {
+			target.log.Warnw("连续认证失败，进入账号锁定保护，停止探测直到手动恢复",
+				"auth_failures", p.config.AuthFailureThreshold,
+			)
+		}
+	case left:
+		target.Metrics.SetAuthProtected(false)
+		target.log.Infow("认证已恢复，退出账号锁定保护")
+	}
+}
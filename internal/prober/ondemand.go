@@ -0,0 +1,103 @@
+package prober
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ImmediateResult 立即探测的结果
+// Probed 为 false 表示本次没有探测，TargetInfo 仍为之前的状态：目标处于暂停或账号锁定保护，
+// 或者 Throttled 为 true，距离上一次探测完成不足 on_demand_min_interval，TargetInfo 为上一次探测的结果
+type ImmediateResult struct {
+	TargetInfo
+	Probed    bool `json:"probed"`
+	Throttled bool `json:"throttled,omitempty"`
+}
+
+// Fresh 结果是否反映目标当前的状态（本次实际探测，或为 on_demand_min_interval 内上一次探测的结果）
+func (r ImmediateResult) Fresh() bool {
+	return r.Probed || r.Throttled
+}
+
+// ProbeNow 立即对指定目标执行一次探测（不等待下一个探测周期），探测完成后返回结果
+// 开启 probe_all_addresses 的目标并发探测同名的所有地址；探测结果同样更新指标、分发状态变化事件
+// 每次探测都会登录数据库，同一目标按 on_demand_min_interval 限制频率：间隔内的请求（含并发的请求）共用上一次探测的结果
+// 目标不存在时返回错误
+func (p *Prober) ProbeNow(name string) ([]ImmediateResult, error) {
+	targets, err := p.targetsNamed(name)
+	if err != nil {
+		return nil, err
+	}
+	return p.probeTargets(targets, p.config.OnDemandMinInterval), nil
+}
+
+// targetsNamed 返回指定名称（或 ID）的所有目标（开启 probe_all_addresses 的目标每个地址一个），目标不存在时返回错误
+func (p *Prober) targetsNamed(name string) ([]*DBTarget, error) {
+	var targets []*DBTarget
+	for _, target := range p.snapshot() {
+		if target.Config.Name == name || target.Config.ID == name {
+			targets = append(targets, target)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("目标不存在: %s", name)
+	}
+	return targets, nil
+}
+
+// probeTargets 并发对目标各执行一次探测，全部完成后返回结果
+// minInterval 大于 0 时，距离上一次探测完成不足 minInterval 的目标不再探测（见 runProbeThrottled）；
+// 故障演练、恢复探测等改变了目标状态的操作传 0，总是探测
+func (p *Prober) probeTargets(targets []*DBTarget, minInterval time.Duration) []ImmediateResult {
+	results := make([]ImmediateResult, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i].Probed, results[i].Throttled = p.runProbeThrottled(target, minInterval)
+		}()
+	}
+	wg.Wait()
+
+	for i, target := range targets {
+		results[i].TargetInfo = p.targetInfo(target)
+	}
+	return results
+}
+
+// runProbe 在 probeMu 保护下执行一次探测，周期探测和立即探测不会对同一目标并发执行
+// 返回本次是否实际探测（暂停、账号锁定保护期间跳过）
+func (p *Prober) runProbe(target *DBTarget) bool {
+	probed, _ := p.runProbeThrottled(target, 0)
+	return probed
+}
+
+// runProbeThrottled 与 runProbe 相同，minInterval 大于 0 时距离上一次探测完成不足 minInterval 则不探测，throttled 为 true
+// 在持有 probeMu 之后判断，等待同一目标正在进行的探测的并发请求直接使用该次探测的结果
+func (p *Prober) runProbeThrottled(target *DBTarget, minInterval time.Duration) (probed, throttled bool) {
+	target.probeMu.Lock()
+	defer target.probeMu.Unlock()
+
+	now := time.Now()
+	// 暂停期间每轮重新设置指标，暂停后重建的目标（如重新加载配置）同样标记为暂停
+	if tp, paused := p.pauseOf(target, now); paused {
+		target.Metrics.SetPaused(true, tp.HideUp)
+		return false, false
+	}
+	if !p.authProbeAllowed(target, now) {
+		return false, false
+	}
+	if minInterval > 0 {
+		target.mu.RLock()
+		lastProbeAt := target.lastProbeAt
+		target.mu.RUnlock()
+		if !lastProbeAt.IsZero() && now.Sub(lastProbeAt) < minInterval {
+			return false, true
+		}
+	}
+	p.refreshIP(target, now)
+	p.probeOnce(target)
+	return true, false
+}
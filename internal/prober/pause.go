@@ -0,0 +1,118 @@
+package prober
+
+import (
+	"time"
+
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+// TargetPause 目标的暂停状态（见 Pause）
+// 暂停按目标名称记录，重新加载配置、目标发现重建目标后保持；开启 probe_all_addresses 的目标同名的各个地址一起暂停
+type TargetPause struct {
+	Target string    `json:"target"`
+	Since  time.Time `json:"since"`
+	// Until 到期后自动恢复探测，为空表示直到手动恢复
+	Until  *time.Time `json:"until,omitempty"`
+	HideUp bool       `json:"hide_up"`
+	Reason string     `json:"reason,omitempty"`
+}
+
+// active 暂停在 now 时是否仍然有效
+func (tp TargetPause) active(now time.Time) bool {
+	return tp.Until == nil || now.Before(*tp.Until)
+}
+
+// Pause 暂停目标的探测（如计划维护期间），duration 为 0 时直到手动恢复（见 Resume）
+// 暂停期间不访问数据库，db_probe_target_paused 为 1，过期判定和调度健康检查跳过该目标；
+// hideUp 为 true 时同时删除 db_probe_up 的时间序列，恢复探测后重新导出。目标不存在时返回错误
+func (p *Prober) Pause(name string, duration time.Duration, hideUp bool, reason string) (TargetPause, error) {
+	targets, err := p.targetsNamed(name)
+	if err != nil {
+		return TargetPause{}, err
+	}
+	now := time.Now()
+	tp := TargetPause{Target: targets[0].Config.Name, Since: now, HideUp: hideUp, Reason: reason}
+	if duration > 0 {
+		until := now.Add(duration)
+		tp.Until = &until
+	}
+
+	p.pauseMu.Lock()
+	if p.pauses == nil {
+		p.pauses = make(map[string]TargetPause)
+	}
+	p.pauses[tp.Target] = tp
+	p.pauseMu.Unlock()
+
+	// 等待正在进行的探测完成后再更新指标，避免探测结果覆盖暂停状态
+	for _, target := range targets {
+		target.probeMu.Lock()
+		target.Metrics.SetPaused(true, hideUp)
+		target.probeMu.Unlock()
+	}
+	logger.L().Infow("已暂停目标的探测", "db_name", tp.Target, "until", tp.Until, "hide_up", hideUp, "reason", reason)
+	return tp, nil
+}
+
+// pauseOf 返回目标最近一次的暂停及其在 now 时是否仍然有效，没有暂停过（或已手动恢复）时返回零值和 false
+// 已到期的暂停同样返回，过期判定据此从暂停结束时开始计算
+func (p *Prober) pauseOf(target *DBTarget, now time.Time) (TargetPause, bool) {
+	p.pauseMu.RLock()
+	defer p.pauseMu.RUnlock()
+	tp, ok := p.pauses[target.Config.Name]
+	return tp, ok && tp.active(now)
+}
+
+// ResumeResult 恢复目标探测的结果
+type ResumeResult struct {
+	Name string `json:"name"`
+	// Resumed 目标之前是否处于暂停或账号锁定保护
+	Resumed bool `json:"resumed"`
+	// Unpaused 解除了暂停，AuthLockoutCleared 解除了账号锁定保护
+	Unpaused           bool `json:"unpaused"`
+	AuthLockoutCleared bool `json:"auth_lockout_cleared"`
+}
+
+// Resume 恢复目标的探测，开启 probe_all_addresses 的目标同名的各个地址一起恢复
+// 默认只解除暂停：维护期间可能轮换了凭据，账号锁定保护保持不变，避免恢复后立即用错误的凭据登录；
+// clearAuthLockout 为 true 时同时解除账号锁定保护（运维确认凭据已修复、数据库侧已解锁账号）
+// 解除暂停时立即探测一次（仍处于账号锁定保护时按保护的间隔探测），db_probe_up 等指标不必等待下一个探测周期；
+// 只解除账号锁定保护时下一个探测周期恢复探测。目标不存在时返回错误
+func (p *Prober) Resume(name string, clearAuthLockout bool) (ResumeResult, error) {
+	targets, err := p.targetsNamed(name)
+	if err != nil {
+		return ResumeResult{}, err
+	}
+	result := ResumeResult{Name: targets[0].Config.Name}
+
+	p.pauseMu.Lock()
+	if tp, ok := p.pauses[result.Name]; ok {
+		result.Unpaused = tp.active(time.Now())
+		delete(p.pauses, result.Name)
+	}
+	p.pauseMu.Unlock()
+
+	for _, target := range targets {
+		if !clearAuthLockout {
+			break
+		}
+		target.mu.Lock()
+		wasProtected := target.auth.protected
+		target.auth = authGuard{}
+		targetMetrics := target.Metrics // 探测 goroutine 识别到角色、地址变化时会替换 Metrics
+		ip := target.IP
+		target.mu.Unlock()
+
+		if wasProtected {
+			result.AuthLockoutCleared = true
+			targetMetrics.SetAuthProtected(false)
+			logger.L().Infow("已手动解除账号锁定保护", "db_name", result.Name, "db_ip", ip)
+		}
+	}
+	if result.Unpaused {
+		logger.L().Infow("已恢复目标的探测", "db_name", result.Name)
+		p.probeTargets(targets, 0)
+	}
+	result.Resumed = result.Unpaused || result.AuthLockoutCleared
+	return result, nil
+}
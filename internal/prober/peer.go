@@ -0,0 +1,43 @@
+package prober
+
+import (
+	"net"
+	"slices"
+)
+
+// checkPeer 比较连接实际连接的对端 IP 与 host 当前的 DNS 解析结果，更新 db_probe_peer_mismatch（开启 peer_check 的目标）
+// 连接池中的连接在建立时解析 host，基于 DNS 的故障切换后，已建立的连接在 ConnMaxLifetime 到期前仍连着旧后端，
+// 此时对端 IP 不在新的解析结果中；尚未建立连接或解析失败时不更新。不一致出现和恢复时各输出一次日志
+// Aurora reader 端点每次解析随机返回一个 reader 实例，对端地址与解析结果不一致是正常现象，不比较
+func (p *Prober) checkPeer(target *DBTarget) {
+	if !target.Config.PeerCheck || target.connector == nil || target.endpoint == "reader" {
+		return
+	}
+	peer := target.connector.Peer()
+	if peer == "" {
+		return
+	}
+	resolved, err := net.LookupHost(target.Config.Host)
+	if err != nil {
+		target.log.Debugw("解析 host 失败，跳过对端地址比较", "peer", peer, "error", err)
+		return
+	}
+	mismatch := !slices.Contains(resolved, peer)
+
+	target.mu.Lock()
+	previous := target.peerMismatch
+	target.peer = peer
+	target.peerMismatch = mismatch
+	target.mu.Unlock()
+	target.Metrics.SetPeerMismatch(mismatch)
+
+	switch {
+	case mismatch && !previous:
+		target.log.Warnw("连接的对端地址不在 host 当前的 DNS 解析结果中，连接可能仍连着故障切换前的后端",
+			"peer", peer,
+			"resolved", resolved,
+		)
+	case !mismatch && previous:
+		target.log.Infow("连接的对端地址与 DNS 解析结果恢复一致", "peer", peer, "resolved", resolved)
+	}
+}
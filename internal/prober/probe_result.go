@@ -0,0 +1,92 @@
+package prober
+
+import (
+	"sort"
+	"time"
+)
+
+// ProbeResult 一次探测的完整结果：目标标识、各检查步骤的结果和耗时、失败阶段与错误分类
+// 是 /api/v1/results、/targets（last_result）和状态变化事件（通知、changefeed）共用的标准格式，各处不再各自拼装
+// JSON 字段名和 protobuf 字段编号（见 MarshalProbeResults）保持稳定：只增加字段，不修改或复用已有的名称和编号
+type ProbeResult struct {
+	Target TargetIdentity `json:"target"`
+	Up     bool           `json:"up"`
+	// StartedAt 探测开始时间，DurationSeconds 为总耗时（Ping + 查询，不含状态端口等附加检查）
+	StartedAt       time.Time `json:"started_at"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	// Ping、Query 各检查步骤的结果，Ping 失败时不执行查询，Query 为零值（JSON 中不输出）
+	Ping  CheckResult `json:"ping"`
+	Query CheckResult `json:"query,omitzero"`
+	// Error 失败时的错误分类，成功时为零值（JSON 中不输出）
+	Error ErrorClass `json:"error,omitzero"`
+	// Test 是否为故障演练（见 FireTest）
+	Test bool `json:"test,omitempty"`
+}
+
+// TargetIdentity 探测目标的标识，与指标的 label 维度一致
+type TargetIdentity struct {
+	// ID 目标的稳定标识（配置的 id 或由地址推导），目标改名后保持不变
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Project string `json:"project"`
+	Env     string `json:"env"`
+	Host    string `json:"host"`
+	IP      string `json:"ip"`
+	Role    string `json:"role,omitempty"`
+	// Schema 按 schemas 展开的目标探测的库名
+	Schema string `json:"schema,omitempty"`
+}
+
+// CheckResult 单个检查步骤的结果
+type CheckResult struct {
+	Success         bool    `json:"success"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// ErrorClass 探测失败的错误分类
+type ErrorClass struct {
+	Stage      string `json:"stage"`              // 失败阶段（内置分析或自定义错误分类规则）
+	Severity   string `json:"severity,omitempty"` // 严重级别
+	Message    string `json:"message"`            // 标注了失败阶段和连接参数的错误信息
+	Details    string `json:"details,omitempty"`  // 详细错误描述
+	RunbookURL string `json:"runbook_url,omitempty"`
+}
+
+// identity 目标的标识
+func (t *DBTarget) identity() TargetIdentity {
+	return TargetIdentity{
+		ID:      t.Config.ID,
+		Name:    t.Config.Name,
+		Type:    t.Config.Type,
+		Project: t.Config.Project,
+		Env:     t.Config.Env,
+		Host:    t.Config.Host,
+		IP:      t.IP,
+		Role:    t.Labels["role"],
+		Schema:  t.schema,
+	}
+}
+
+// LastResults 返回所有目标最近一次的探测结果，按目标名称、地址和 schema 排序，尚未完成首次探测的目标不返回
+func (p *Prober) LastResults() []ProbeResult {
+	var results []ProbeResult
+	for _, target := range p.snapshot() {
+		target.mu.RLock()
+		result := target.lastResult
+		target.mu.RUnlock()
+		if !result.StartedAt.IsZero() {
+			results = append(results, result)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Target.Name != results[j].Target.Name {
+			return results[i].Target.Name < results[j].Target.Name
+		}
+		if results[i].Target.IP != results[j].Target.IP {
+			return results[i].Target.IP < results[j].Target.IP
+		}
+		return results[i].Target.Schema < results[j].Target.Schema
+	})
+	return results
+}
@@ -0,0 +1,244 @@
+package prober
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ProbeResultContentType ProbeResultList protobuf 编码的 Content-Type
+const ProbeResultContentType = "application/x-protobuf; proto=dbprobe.ProbeResultList"
+
+// MarshalProbeResults 将探测结果编码为 ProbeResultList，直接按 protobuf 线格式编码，不依赖生成代码：
+//
+//	ProbeResultList { repeated ProbeResult results = 1; }
+//	ProbeResult     { TargetIdentity target = 1; bool up = 2; Timestamp started_at = 3; double duration_seconds = 4;
+//	                  CheckResult ping = 5; CheckResult query = 6; ErrorClass error = 7; bool test = 8; }
+//	TargetIdentity  { string name = 1; string type = 2; string project = 3; string env = 4;
+//	                  string host = 5; string ip = 6; string role = 7; string id = 8; string schema = 9; }
+//	CheckResult     { bool success = 1; double duration_seconds = 2; }
+//	ErrorClass      { string stage = 1; string severity = 2; string message = 3; string details = 4; string runbook_url = 5; }
+//	Timestamp       { int64 seconds = 1; int32 nanos = 2; }  // 与 google.protobuf.Timestamp 相同
+//
+// 与 proto3 一致，零值字段不编码
+func MarshalProbeResults(results []ProbeResult) []byte {
+	var buf, scratch []byte
+	for i := range results {
+		scratch = results[i].appendProto(scratch[:0])
+		buf = protowire.AppendTag(buf, 1, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, scratch)
+	}
+	return buf
+}
+
+// UnmarshalProbeResults 解码 MarshalProbeResults 编码的 ProbeResultList，忽略未知字段
+func UnmarshalProbeResults(b []byte) ([]ProbeResult, error) {
+	var results []ProbeResult
+	err := consumeFields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if num != 1 || typ != protowire.BytesType {
+			return nil
+		}
+		var r ProbeResult
+		if err := r.unmarshalProto(v); err != nil {
+			return err
+		}
+		results = append(results, r)
+		return nil
+	})
+	return results, err
+}
+
+func (r *ProbeResult) appendProto(b []byte) []byte {
+	b = appendMessage(b, 1, r.Target.appendProto(nil))
+	b = appendBool(b, 2, r.Up)
+	if !r.StartedAt.IsZero() {
+		var ts []byte
+		ts = appendVarint(ts, 1, uint64(r.StartedAt.Unix()))
+		ts = appendVarint(ts, 2, uint64(r.StartedAt.Nanosecond()))
+		b = appendMessage(b, 3, ts)
+	}
+	b = appendDouble(b, 4, r.DurationSeconds)
+	b = appendMessage(b, 5, r.Ping.appendProto(nil))
+	b = appendMessage(b, 6, r.Query.appendProto(nil))
+	b = appendMessage(b, 7, r.Error.appendProto(nil))
+	b = appendBool(b, 8, r.Test)
+	return b
+}
+
+func (r *ProbeResult) unmarshalProto(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		switch num {
+		case 1:
+			return r.Target.unmarshalProto(v)
+		case 2:
+			r.Up = decodeVarint(v) != 0
+		case 3:
+			var sec, nsec int64
+			err := consumeFields(v, func(num protowire.Number, _ protowire.Type, v []byte) error {
+				switch num {
+				case 1:
+					sec = int64(decodeVarint(v))
+				case 2:
+					nsec = int64(int32(decodeVarint(v)))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			r.StartedAt = time.Unix(sec, nsec)
+		case 4:
+			r.DurationSeconds = decodeDouble(v)
+		case 5:
+			return r.Ping.unmarshalProto(v)
+		case 6:
+			return r.Query.unmarshalProto(v)
+		case 7:
+			return r.Error.unmarshalProto(v)
+		case 8:
+			r.Test = decodeVarint(v) != 0
+		}
+		return nil
+	})
+}
+
+func (t *TargetIdentity) appendProto(b []byte) []byte {
+	b = appendString(b, 1, t.Name)
+	b = appendString(b, 2, t.Type)
+	b = appendString(b, 3, t.Project)
+	b = appendString(b, 4, t.Env)
+	b = appendString(b, 5, t.Host)
+	b = appendString(b, 6, t.IP)
+	b = appendString(b, 7, t.Role)
+	b = appendString(b, 8, t.ID)
+	b = appendString(b, 9, t.Schema)
+	return b
+}
+
+func (t *TargetIdentity) unmarshalProto(b []byte) error {
+	fields := [...]*string{1: &t.Name, 2: &t.Type, 3: &t.Project, 4: &t.Env, 5: &t.Host, 6: &t.IP, 7: &t.Role, 8: &t.ID, 9: &t.Schema}
+	return consumeStrings(b, fields[:])
+}
+
+func (c *CheckResult) appendProto(b []byte) []byte {
+	b = appendBool(b, 1, c.Success)
+	b = appendDouble(b, 2, c.DurationSeconds)
+	return b
+}
+
+func (c *CheckResult) unmarshalProto(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, _ protowire.Type, v []byte) error {
+		switch num {
+		case 1:
+			c.Success = decodeVarint(v) != 0
+		case 2:
+			c.DurationSeconds = decodeDouble(v)
+		}
+		return nil
+	})
+}
+
+func (e *ErrorClass) appendProto(b []byte) []byte {
+	b = appendString(b, 1, e.Stage)
+	b = appendString(b, 2, e.Severity)
+	b = appendString(b, 3, e.Message)
+	b = appendString(b, 4, e.Details)
+	b = appendString(b, 5, e.RunbookURL)
+	return b
+}
+
+func (e *ErrorClass) unmarshalProto(b []byte) error {
+	fields := [...]*string{1: &e.Stage, 2: &e.Severity, 3: &e.Message, 4: &e.Details, 5: &e.RunbookURL}
+	return consumeStrings(b, fields[:])
+}
+
+// appendMessage 追加嵌套消息，空消息（全部字段为零值）不编码
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	if len(msg) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendBool(b []byte, num protowire.Number, v bool) []byte {
+	if !v {
+		return b
+	}
+	return appendVarint(b, num, 1)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendDouble(b []byte, num protowire.Number, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
+
+// consumeFields 逐个解析消息的字段，fn 收到字段编号、类型和字段值的原始编码（varint、fixed64 为值本身，bytes 为去掉长度前缀的内容）
+func consumeFields(b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("解析 protobuf 字段失败: %w", protowire.ParseError(n))
+		}
+		b = b[n:]
+		m := protowire.ConsumeFieldValue(num, typ, b)
+		if m < 0 {
+			return fmt.Errorf("解析 protobuf 字段 %d 失败: %w", num, protowire.ParseError(m))
+		}
+		v := b[:m]
+		if typ == protowire.BytesType {
+			v, _ = protowire.ConsumeBytes(v)
+		}
+		if err := fn(num, typ, v); err != nil {
+			return err
+		}
+		b = b[m:]
+	}
+	return nil
+}
+
+// consumeStrings 解析只包含 string 字段的消息，fields 按字段编号索引，未知字段忽略
+func consumeStrings(b []byte, fields []*string) error {
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if int(num) >= len(fields) || fields[num] == nil {
+			return nil
+		}
+		if typ != protowire.BytesType {
+			return errors.New("protobuf 字段类型不是 string")
+		}
+		*fields[num] = string(v)
+		return nil
+	})
+}
+
+func decodeVarint(v []byte) uint64 {
+	x, _ := protowire.ConsumeVarint(v)
+	return x
+}
+
+func decodeDouble(v []byte) float64 {
+	x, _ := protowire.ConsumeFixed64(v)
+	return math.Float64frombits(x)
+}
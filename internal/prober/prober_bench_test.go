@@ -0,0 +1,128 @@
+package prober
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/internal/metrics"
+	"go.uber.org/zap"
+)
+
+// probeAllocBudget probeOnce 成功路径每次探测允许的最大分配次数
+// 修改探测热路径时请同步更新 README 中的分配预算说明
+const probeAllocBudget = 24
+
+// benchDriver 内存中的假驱动，Ping 和查询都立即成功，只用于测量探针自身开销
+type benchDriver struct{}
+
+func (benchDriver) Open(string) (driver.Conn, error) { return benchConn{}, nil }
+
+type benchConn struct{}
+
+func (benchConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (benchConn) Close() error                        { return nil }
+func (benchConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+func (benchConn) Ping(context.Context) error          { return nil }
+
+func (benchConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return &benchRows{}, nil
+}
+
+type benchRows struct{ done bool }
+
+func (*benchRows) Columns() []string { return []string{"1"} }
+func (*benchRows) Close() error      { return nil }
+
+func (r *benchRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+func init() {
+	sql.Register("probe-bench", benchDriver{})
+}
+
+// newBenchProber 创建使用假驱动的探针和目标，日志输出被丢弃
+func newBenchProber(tb testing.TB) (*Prober, *DBTarget) {
+	tb.Helper()
+
+	database, err := sql.Open("probe-bench", "")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { database.Close() })
+
+	dbCfg := &config.DBConfig{
+		Name:    "bench",
+		Type:    "mysql",
+		Host:    "127.0.0.1",
+		Port:    3306,
+		Project: "bench",
+		Env:     "bench",
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	tb.Cleanup(cancel)
+
+	p := &Prober{
+		config: &config.Config{
+			ProbeInterval: 2 * time.Second,
+			ProbeTimeout:  time.Second,
+		},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	labels := metrics.NewLabels(dbCfg, "127.0.0.1", "")
+	target := &DBTarget{
+		Config:  dbCfg,
+		DB:      database,
+		Labels:  labels,
+		Metrics: metrics.NewTargetMetrics(labels, metrics.NewInfoLabels(dbCfg)),
+		IP:      "127.0.0.1",
+		query:   "SELECT 1",
+		log:     zap.NewNop().Sugar(),
+	}
+	return p, target
+}
+
+func BenchmarkProbeOnce(b *testing.B) {
+	p, target := newBenchProber(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.probeOnce(target)
+	}
+}
+
+func BenchmarkProbeOnceParallel(b *testing.B) {
+	p, target := newBenchProber(b)
+	target.DB.SetMaxOpenConns(0)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			p.probeOnce(target)
+		}
+	})
+}
+
+// TestProbeOnceAllocBudget 防止探测热路径的分配次数回退
+func TestProbeOnceAllocBudget(t *testing.T) {
+	p, target := newBenchProber(t)
+	p.probeOnce(target) // 预热连接池
+
+	allocs := testing.AllocsPerRun(200, func() {
+		p.probeOnce(target)
+	})
+	if allocs > probeAllocBudget {
+		t.Fatalf("probeOnce 成功路径分配次数 %.0f 超出预算 %d", allocs, probeAllocBudget)
+	}
+}
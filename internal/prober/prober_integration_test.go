@@ -0,0 +1,212 @@
+//go:build integration
+
+package prober
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/microsoft/go-mssqldb"
+	_ "github.com/sijms/go-ora/v2"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/internal/db"
+	"github.com/imkerbos/db-probe/internal/testenv"
+)
+
+// newTestProber 使用单个目标创建探针
+func newTestProber(t *testing.T, dbCfg config.DBConfig) (*Prober, *DBTarget) {
+	t.Helper()
+
+	cfg := &config.Config{
+		ListenAddress: ":0",
+		ProbeInterval: 10 * time.Second,
+		ProbeTimeout:  5 * time.Second,
+		Databases:     []config.DBConfig{dbCfg},
+	}
+	if err := config.Validate(cfg); err != nil {
+		t.Fatalf("配置校验失败: %v", err)
+	}
+
+	p, err := NewProber(cfg)
+	if err != nil {
+		t.Fatalf("初始化探针失败: %v", err)
+	}
+	t.Cleanup(p.Stop)
+	return p, p.GetTargets()[0]
+}
+
+// probeUntilUp 反复探测直到成功，容器端口可达不代表数据库已完成初始化
+func probeUntilUp(t *testing.T, p *Prober, target *DBTarget, timeout time.Duration) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		p.probeOnce(target)
+		target.mu.RLock()
+		lastErr := target.LastError
+		target.mu.RUnlock()
+		if lastErr == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("探测一直失败: %v", lastErr)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func TestProbeEngines(t *testing.T) {
+	for _, name := range testenv.Engines() {
+		t.Run(name, func(t *testing.T) {
+			dbCfg := testenv.Require(t, name)
+			p, target := newTestProber(t, dbCfg)
+			probeUntilUp(t, p, target, 5*time.Minute)
+		})
+	}
+}
+
+// TestBuiltDSN 确保不提供 dsn 时，由 host/port/user/password 构造的 DSN 可用
+func TestBuiltDSN(t *testing.T) {
+	for _, name := range []string{"mysql", "oracle", "mssql"} {
+		t.Run(name, func(t *testing.T) {
+			dbCfg := testenv.Require(t, name)
+			if dbCfg.DSN != "" {
+				t.Fatalf("引擎 %s 应使用构造的 DSN", name)
+			}
+			p, target := newTestProber(t, dbCfg)
+			probeUntilUp(t, p, target, 5*time.Minute)
+		})
+	}
+}
+
+// TestAuthFailureStage 确保错误密码被归类为认证阶段失败
+func TestAuthFailureStage(t *testing.T) {
+	for _, name := range []string{"mysql", "oceanbase", "oracle", "mssql", "redis", "mongodb"} {
+		t.Run(name, func(t *testing.T) {
+			dbCfg := testenv.Require(t, name)
+
+			// 先确认数据库已就绪，避免把初始化中的错误当成认证错误
+			p, target := newTestProber(t, dbCfg)
+			probeUntilUp(t, p, target, 5*time.Minute)
+
+			dbCfg.Name += "-badpass"
+			dbCfg.Password = "wrong-password"
+			p, target = newTestProber(t, dbCfg)
+			p.probeOnce(target)
+
+			target.mu.RLock()
+			lastErr := target.LastError
+			target.mu.RUnlock()
+			if lastErr == nil {
+				t.Fatal("错误密码探测应失败")
+			}
+			if !strings.Contains(lastErr.Error(), "[认证阶段失败]") {
+				t.Fatalf("错误应归类为认证阶段，实际: %v", lastErr)
+			}
+		})
+	}
+}
+
+// TestMongoDBRole 确保未配置 role 时使用 hello 识别出的角色作为 role label
+func TestMongoDBRole(t *testing.T) {
+	dbCfg := testenv.Require(t, "mongodb")
+	p, target := newTestProber(t, dbCfg)
+	probeUntilUp(t, p, target, 5*time.Minute)
+
+	target.mu.RLock()
+	role := target.Labels["role"]
+	target.mu.RUnlock()
+	// 测试环境为单节点（非副本集）
+	if role != "standalone" {
+		t.Fatalf("role 应为 standalone，实际: %q", role)
+	}
+}
+
+// TestMySQLEffectiveRole 测试环境的 MySQL 未开启 read_only，应识别为 primary，与配置的 replica 不一致
+func TestMySQLEffectiveRole(t *testing.T) {
+	dbCfg := testenv.Require(t, "mysql")
+	dbCfg.RoleDetection = true
+	dbCfg.Labels = map[string]string{"role": "replica"}
+	p, target := newTestProber(t, dbCfg)
+	probeUntilUp(t, p, target, 3*time.Minute)
+
+	target.mu.RLock()
+	role, mismatch := target.effectiveRole, target.roleMismatch
+	target.mu.RUnlock()
+	if role != "primary" || !mismatch {
+		t.Fatalf("effective_role 应为 primary 且与配置不一致，实际: %q, mismatch=%v", role, mismatch)
+	}
+}
+
+// TestGaleraStatus 确保 Galera 节点的 wsrep 状态被导出
+func TestGaleraStatus(t *testing.T) {
+	dbCfg := testenv.Require(t, "mariadb-galera")
+	p, target := newTestProber(t, dbCfg)
+	probeUntilUp(t, p, target, 5*time.Minute)
+
+	status, err := (&db.GaleraDriver{}).QueryGaleraStatus(context.Background(), target.DB)
+	if err != nil {
+		t.Fatalf("读取 wsrep 状态失败: %v", err)
+	}
+	if !status.Healthy() || status.ClusterSize != 1 {
+		t.Fatalf("单节点集群应为 Primary 且节点数为 1，实际: %+v", status)
+	}
+}
+
+// TestCockroachDBCluster 确保开启 cluster_check 时导出集群节点存活情况
+func TestCockroachDBCluster(t *testing.T) {
+	dbCfg := testenv.Require(t, "cockroachdb")
+	p, target := newTestProber(t, dbCfg)
+	probeUntilUp(t, p, target, 2*time.Minute)
+
+	total, live, err := (&db.CockroachDBDriver{}).QueryClusterNodes(context.Background(), target.DB)
+	if err != nil {
+		t.Fatalf("查询集群节点失败: %v", err)
+	}
+	if total != 1 || live != 1 {
+		t.Fatalf("单节点集群应有 1 个存活节点，实际: total=%d live=%d", total, live)
+	}
+}
+
+// TestDorisNodes 确保能从 SHOW FRONTENDS/BACKENDS 统计 FE、BE 存活情况
+func TestDorisNodes(t *testing.T) {
+	dbCfg := testenv.Require(t, "doris")
+	p, target := newTestProber(t, dbCfg)
+	probeUntilUp(t, p, target, 3*time.Minute)
+
+	// BE 向 FE 注册并发送心跳需要一段时间
+	deadline := time.Now().Add(2 * time.Minute)
+	for {
+		nodes, err := (&db.DorisDriver{}).QueryDorisNodes(context.Background(), target.DB)
+		if err != nil {
+			t.Fatalf("查询 FE/BE 节点失败: %v", err)
+		}
+		if nodes.Frontends == 1 && nodes.AliveFrontends == 1 && nodes.Backends == 1 && nodes.AliveBackends == 1 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("all-in-one 集群应有 1 个存活 FE 和 1 个存活 BE，实际: %+v", nodes)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// TestTrinoQueryPhases 确保从查询 stats 中读取到排队时间和执行时间
+func TestTrinoQueryPhases(t *testing.T) {
+	dbCfg := testenv.Require(t, "trino")
+	p, target := newTestProber(t, dbCfg)
+	probeUntilUp(t, p, target, 3*time.Minute)
+
+	queued, execution, ok := target.client.(db.QueryPhaseReporter).QueryPhases()
+	if !ok {
+		t.Fatal("应读取到查询的排队时间和执行时间")
+	}
+	if queued < 0 || execution <= 0 {
+		t.Fatalf("排队时间应不小于 0、执行时间应大于 0，实际: queued=%v execution=%v", queued, execution)
+	}
+}
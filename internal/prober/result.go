@@ -0,0 +1,63 @@
+package prober
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxQueryResultLen /targets 中展示的探测结果最大长度
+const maxQueryResultLen = 256
+
+// queryResultValue 将探测 SQL 返回的第一列解析为数值
+// 整数、浮点数、布尔值和数值字符串（DECIMAL 等类型驱动以字符串返回）直接转换，
+// 时间转换为 Unix 时间戳（秒）；NULL 和无法解析的值返回 false
+func queryResultValue(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case int64:
+		return float64(val), true
+	case float64:
+		return val, true
+	case bool:
+		if val {
+			return 1, true
+		}
+		return 0, true
+	case time.Time:
+		return float64(val.UnixNano()) / 1e9, true
+	case []byte:
+		return parseNumeric(string(val))
+	case string:
+		return parseNumeric(val)
+	default:
+		return 0, false
+	}
+}
+
+func parseNumeric(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+// formatQueryResult 格式化探测 SQL 的返回值用于展示，过长时截断
+func formatQueryResult(v interface{}) string {
+	var s string
+	switch val := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		s = string(val)
+	case time.Time:
+		s = val.Format(time.RFC3339Nano)
+	default:
+		s = fmt.Sprint(val)
+	}
+	if len(s) > maxQueryResultLen {
+		s = s[:maxQueryResultLen] + "..."
+	}
+	return s
+}
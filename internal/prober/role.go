@@ -0,0 +1,86 @@
+package prober
+
+import (
+	"context"
+
+	"github.com/imkerbos/db-probe/internal/db"
+	"github.com/imkerbos/db-probe/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// updateRole 使用识别出的节点角色更新 role label
+// 角色来自探测客户端（如 MongoDB 的 primary/secondary）或驱动的节点角色查询（如 Aurora 的 writer/reader）
+// 只在 labels 中未配置 role 时生效；角色变化（如主从切换）后删除旧 labels 的序列，
+// 指标以新的 role 重新创建，计数器从 0 开始
+// 只在探测 goroutine 中调用，替换 Metrics 时持有 target.mu，与 API 的读取互斥
+func (p *Prober) updateRole(target *DBTarget) {
+	if _, configured := target.Config.Labels["role"]; configured {
+		return
+	}
+	var role string
+	if reporter, ok := target.client.(db.RoleReporter); ok {
+		role = reporter.Role()
+	} else if querier, ok := target.driver.(db.NodeRoleQuerier); ok && target.DB != nil {
+		role = p.queryNodeRole(target, querier)
+	}
+	if role == "" || role == target.Labels["role"] {
+		return
+	}
+
+	oldLabels := target.Labels
+	newLabels := make(prometheus.Labels, len(oldLabels))
+	for k, v := range oldLabels {
+		newLabels[k] = v
+	}
+	newLabels["role"] = role
+
+	metrics.DeleteTargetMetrics(oldLabels)
+	newMetrics := metrics.NewTargetMetrics(newLabels, metrics.NewInfoLabels(target.Config))
+
+	target.mu.Lock()
+	target.Labels = newLabels
+	target.Metrics = newMetrics
+	target.mu.Unlock()
+
+	if oldLabels["role"] == "" {
+		target.log.Infow("识别到节点角色", "role", role)
+	} else {
+		target.log.Warnw("节点角色发生变化", "previous_role", oldLabels["role"], "role", role)
+	}
+}
+
+// queryNodeRole 查询当前连接到的实例及其角色（如 Aurora），查询失败时返回空字符串，保留上一次的角色
+// 角色决定 role label，每轮探测都需要查询，计入探测语句，不受语句预算限制
+// 端点背后的实例变化时输出日志：writer 端点的实例变化说明发生了故障切换；
+// 同时清空上一次的运行时长，避免把连接到另一个实例误判为实例重启
+func (p *Prober) queryNodeRole(target *DBTarget, querier db.NodeRoleQuerier) string {
+	ctx, cancel := context.WithTimeout(p.ctx, p.probeTimeout(target.Config))
+	defer cancel()
+	target.recordStatements("probe", 1)
+	role, instance, err := querier.QueryNodeRole(ctx, target.DB)
+	if err != nil {
+		target.log.Debugw("查询节点角色失败", "error", err)
+		return ""
+	}
+
+	target.mu.Lock()
+	previous := target.nodeInstance
+	target.nodeInstance = instance
+	changed := previous != "" && previous != instance
+	if changed {
+		target.lastUptime = 0
+	}
+	target.mu.Unlock()
+
+	switch {
+	case previous == "":
+		target.log.Infow("识别到端点连接的实例", "endpoint", target.endpoint, "instance", instance, "role", role)
+	case changed && target.endpoint == "reader":
+		// reader 端点按 DNS 轮询分配实例，重建连接后连到另一个 reader 是正常现象
+		target.log.Infow("reader 端点连接的实例发生变化", "previous_instance", previous, "instance", instance, "role", role)
+	case changed:
+		target.log.Warnw("端点连接的实例发生变化，集群可能发生了故障切换",
+			"endpoint", target.endpoint, "previous_instance", previous, "instance", instance, "role", role)
+	}
+	return role
+}
@@ -0,0 +1,95 @@
+package prober
+
+import (
+	"regexp"
+
+	"github.com/imkerbos/db-probe/internal/config"
+)
+
+// defaultSeverity 未被自定义规则覆盖时的严重级别
+const defaultSeverity = "error"
+
+// fatalStages 重试没有意义的失败阶段
+// 认证失败重试只会加速触发数据库的账号锁定策略；SQL 执行错误（语法、权限等）、MongoDB 命令错误、
+// KingbaseES 数据库不存在和授权异常、Elasticsearch 权限不足和请求错误、Snowflake 账号和仓库错误、SQLite 文件无法打开和损坏重试结果不变
+// 其余阶段（TCP连接、协议握手、超时等）视为瞬时错误，允许在本轮探测内重试
+var fatalStages = map[string]bool{
+	"认证":              true,
+	"SQL执行":           true,
+	"MongoDB命令":       true,
+	"KingBase数据库":     true,
+	"KingBase授权":      true,
+	"Elasticsearch权限": true,
+	"Elasticsearch请求": true,
+	"Snowflake账号":     true,
+	"Snowflake仓库":     true,
+	"SQLite文件":        true,
+	"SQLite损坏":        true,
+}
+
+// errorRule 编译后的自定义错误分类规则
+type errorRule struct {
+	re         *regexp.Regexp
+	stage      string
+	severity   string
+	runbookURL string
+	retryable  *bool           // 为空表示按失败阶段判断
+	types      map[string]bool // 为空表示对所有数据库类型生效
+}
+
+// compileErrorRules 编译配置中的错误分类规则（配置校验阶段已确认正则合法）
+func compileErrorRules(rules []config.ErrorRule) ([]errorRule, error) {
+	compiled := make([]errorRule, 0, len(rules))
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		r := errorRule{
+			re:         re,
+			stage:      rule.Stage,
+			severity:   rule.Severity,
+			runbookURL: rule.RunbookURL,
+			retryable:  rule.Retryable,
+		}
+		if r.severity == "" {
+			r.severity = defaultSeverity
+		}
+		if len(rule.Types) > 0 {
+			r.types = make(map[string]bool, len(rule.Types))
+			for _, t := range rule.Types {
+				r.types[t] = true
+			}
+		}
+		compiled = append(compiled, r)
+	}
+	return compiled, nil
+}
+
+// matchErrorRule 按配置顺序查找第一条匹配错误信息的规则，未匹配返回 nil
+func (p *Prober) matchErrorRule(errMsg, dbType string) *errorRule {
+	for i := range p.errorRules {
+		rule := &p.errorRules[i]
+		if rule.types != nil && !rule.types[dbType] {
+			continue
+		}
+		if rule.re.MatchString(errMsg) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// classifyRetry 判断错误是否值得在本轮探测内重试，返回失败阶段和是否可重试
+// 自定义规则显式配置了 retryable 时以规则为准，否则按失败阶段判断
+func (p *Prober) classifyRetry(err error, dbType string) (stage string, retryable bool) {
+	stage, _ = analyzeError(err, dbType)
+	rule := p.matchErrorRule(err.Error(), dbType)
+	if rule != nil {
+		stage = rule.stage
+		if rule.retryable != nil {
+			return stage, *rule.retryable
+		}
+	}
+	return stage, !fatalStages[stage]
+}
@@ -0,0 +1,78 @@
+package prober
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"time"
+)
+
+// probeSchedule 单个目标的周期探测时间点：每个探测间隔内的固定偏移 phase，加上可选的随机延迟 jitter
+// 开启 probe_splay 时 phase 由目标 ID（及地址、schema）的哈希决定，大量目标的探测均匀分散在探测间隔内，
+// 不会每个间隔在同一毫秒集中发起连接；phase 与进程启动时间无关，重启、热加载后目标的探测时间点不变
+type probeSchedule struct {
+	interval time.Duration
+	phase    time.Duration
+	jitter   time.Duration
+	// splay 是否开启 probe_splay；initialMax 为首次探测前的最长等待，initialOffset 为超过时在该时长内按 ID 哈希分散的等待
+	splay         bool
+	initialMax    time.Duration
+	initialOffset time.Duration
+}
+
+// newProbeSchedule 创建目标的探测时间点，未开启 probe_splay 时以 start 为相位，与按固定间隔的定时器相同
+func (p *Prober) newProbeSchedule(target *DBTarget, start time.Time) *probeSchedule {
+	interval := p.probeInterval(target.Config)
+	s := &probeSchedule{
+		interval: interval,
+		phase:    time.Duration(start.UnixNano() % int64(interval)),
+		jitter:   min(p.config.ProbeJitter, interval/2),
+	}
+	if p.config.ProbeSplay {
+		s.splay = true
+		s.phase = splayOffset(target, interval)
+		if s.initialMax = p.config.ProbeSplayInitialDelay; s.initialMax > 0 {
+			s.initialOffset = splayOffset(target, s.initialMax)
+		}
+	}
+	return s
+}
+
+// firstDelay 首次探测前的等待时间：未开启 probe_splay 时立即探测；开启时等到目标的探测时间点，
+// 启动、热加载后的首次探测同样分散开，不在同一时刻集中建连。距离时间点超过 probe_splay_initial_delay 时
+// 改为在该时长内按 ID 哈希分散（探测间隔较长时不必等待一个完整间隔才有数据），之后回到目标的时间点周期探测
+func (s *probeSchedule) firstDelay(now time.Time) time.Duration {
+	if !s.splay || s.initialMax == 0 {
+		return 0
+	}
+	delay := s.nextDelay(now)
+	if delay > s.initialMax {
+		delay = s.initialOffset
+	}
+	return delay
+}
+
+// nextDelay 距离下一个探测时间点的等待时间：now 之后第一个相位为 phase 的时间点，加上 [0, jitter) 的随机延迟
+// 随机延迟不累积，每个探测间隔仍然只探测一次；探测耗时超过一个间隔时跳过错过的时间点
+func (s *probeSchedule) nextDelay(now time.Time) time.Duration {
+	n, iv := now.UnixNano(), int64(s.interval)
+	next := n - n%iv + int64(s.phase)
+	if next <= n {
+		next += iv
+	}
+	delay := time.Duration(next - n)
+	if s.jitter > 0 {
+		delay += rand.N(s.jitter)
+	}
+	return delay
+}
+
+// splayOffset 目标在探测间隔内的固定偏移，按 ID 的哈希计算（按地址、schema 展开的目标分别计算）
+func splayOffset(target *DBTarget, interval time.Duration) time.Duration {
+	key := target.Config.ID
+	if key == "" {
+		key = target.Config.Name
+	}
+	h := fnv.New64a()
+	h.Write([]byte(key + "\x00" + target.IP + "\x00" + target.schema))
+	return time.Duration(h.Sum64() % uint64(interval))
+}
@@ -0,0 +1,72 @@
+package prober
+
+import (
+	"testing"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+)
+
+func TestProbeScheduleFirstDelay(t *testing.T) {
+	// 探测间隔 30s 的整数倍时刻，目标的时间点为每个间隔的第 5s
+	base := time.Unix(1767600000, 0)
+	tests := []struct {
+		name  string
+		sched probeSchedule
+		now   time.Time
+		want  time.Duration
+	}{
+		{
+			name:  "splay off probes immediately",
+			sched: probeSchedule{interval: 30 * time.Second, phase: 5 * time.Second, initialMax: 10 * time.Second, initialOffset: 3 * time.Second},
+			now:   base,
+			want:  0,
+		},
+		{
+			name:  "initial delay 0 probes immediately",
+			sched: probeSchedule{interval: 30 * time.Second, phase: 5 * time.Second, splay: true},
+			now:   base,
+			want:  0,
+		},
+		{
+			name:  "waits for the time point within the limit",
+			sched: probeSchedule{interval: 30 * time.Second, phase: 5 * time.Second, splay: true, initialMax: 10 * time.Second, initialOffset: 3 * time.Second},
+			now:   base,
+			want:  5 * time.Second,
+		},
+		{
+			name:  "time point exactly at the limit",
+			sched: probeSchedule{interval: 30 * time.Second, phase: 15 * time.Second, splay: true, initialMax: 10 * time.Second, initialOffset: 3 * time.Second},
+			now:   base.Add(5 * time.Second),
+			want:  10 * time.Second,
+		},
+		{
+			name:  "spreads within the limit when the time point is further away",
+			sched: probeSchedule{interval: 30 * time.Second, phase: 5 * time.Second, splay: true, initialMax: 10 * time.Second, initialOffset: 3 * time.Second},
+			now:   base.Add(10 * time.Second),
+			want:  3 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.sched.firstDelay(tt.now); got != tt.want {
+				t.Errorf("firstDelay = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplayOffsetWithinInterval(t *testing.T) {
+	for _, name := range []string{"mysql-prod", "pg-prod", "oracle-prod", ""} {
+		target := &DBTarget{Config: &config.DBConfig{Name: name}}
+		for _, interval := range []time.Duration{time.Second, 10 * time.Second, 5 * time.Minute} {
+			offset := splayOffset(target, interval)
+			if offset < 0 || offset >= interval {
+				t.Errorf("splayOffset(%q, %v) = %v, outside [0, interval)", name, interval, offset)
+			}
+			if again := splayOffset(target, interval); again != offset {
+				t.Errorf("splayOffset(%q, %v) not stable: %v != %v", name, interval, offset, again)
+			}
+		}
+	}
+}
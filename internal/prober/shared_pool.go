@@ -0,0 +1,99 @@
+package prober
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/internal/db"
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+// sharedPools 连接串和会话初始化语句完全相同的目标共用的连接池
+// 同一实例上按 schema 或业务拆分出的多个逻辑目标（只有探测 SQL、labels 不同）共用一条连接，各自的指标仍然独立
+type sharedPools struct {
+	mu    sync.Mutex
+	pools map[string]*sharedPool
+}
+
+// sharedPool 多个目标共用的连接池，按引用计数在最后一个目标关闭时关闭
+type sharedPool struct {
+	owner     *sharedPools
+	key       string
+	db        *sql.DB
+	connector *db.Connector
+	targets   []string // 共用该连接池的目标名称
+}
+
+// shareConnection 目标是否与其他相同连接的目标共用连接池：目标的 share_connection 优先，未配置时使用全局 share_connections
+// 开启 always_reconnect 的目标每轮探测前关闭连接，不与其他目标共用
+func (p *Prober) shareConnection(dbCfg *config.DBConfig) bool {
+	if dbCfg.AlwaysReconnect {
+		return false
+	}
+	if dbCfg.ShareConnection != nil {
+		return *dbCfg.ShareConnection
+	}
+	return p.config.ShareConnections
+}
+
+// poolKey 共用连接池的判定条件：驱动、DSN（含地址、用户、密码和连接参数）和会话初始化语句都相同
+func poolKey(driverName, dsn string, sessionInit []string) string {
+	return driverName + "\x00" + dsn + "\x00" + strings.Join(sessionInit, "\x00")
+}
+
+// acquire 返回 key 对应的连接池，不存在时调用 open 创建
+func (s *sharedPools) acquire(key, name string, open func() (*sql.DB, *db.Connector, error)) (*sharedPool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if pool := s.pools[key]; pool != nil {
+		logger.L().Infow("目标与其他目标的连接相同，共用连接池",
+			"db_name", name,
+			"shared_with", pool.targets,
+		)
+		pool.targets = append(pool.targets, name)
+		return pool, nil
+	}
+
+	database, connector, err := open()
+	if err != nil {
+		return nil, err
+	}
+	if s.pools == nil {
+		s.pools = make(map[string]*sharedPool)
+	}
+	pool := &sharedPool{owner: s, key: key, db: database, connector: connector, targets: []string{name}}
+	s.pools[key] = pool
+	return pool, nil
+}
+
+// release 目标不再使用连接池，最后一个目标释放时关闭连接池
+func (sp *sharedPool) release(name string) {
+	s := sp.owner
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, target := range sp.targets {
+		if target == name {
+			sp.targets = append(sp.targets[:i], sp.targets[i+1:]...)
+			break
+		}
+	}
+	if len(sp.targets) == 0 {
+		delete(s.pools, sp.key)
+		sp.db.Close()
+	}
+}
+
+// sharedWith 返回与 name 共用连接池的其他目标
+func (sp *sharedPool) sharedWith(name string) []string {
+	sp.owner.mu.Lock()
+	defer sp.owner.mu.Unlock()
+	var others []string
+	for _, target := range sp.targets {
+		if target != name {
+			others = append(others, target)
+		}
+	}
+	return others
+}
@@ -0,0 +1,113 @@
+package prober
+
+import (
+	"time"
+)
+
+// staleLoop 每个（全局）探测间隔检查一次各目标是否超过 stale_after_intervals 个探测间隔没有完成探测
+// 探测卡住时探测循环无法自己更新指标，由独立的 goroutine 把指标标记为过期，避免 db_probe_up 停留在最后一次的值
+func (p *Prober) staleLoop() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.config.ProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case now := <-ticker.C:
+			p.checkStale(now)
+		}
+	}
+}
+
+// checkStale 把超过 stale_after_intervals 个探测间隔没有完成探测的目标标记为过期，配置了 probe_interval 的目标按自己的间隔计算
+// 尚未完成首次探测的目标还没有导出 db_probe_up，账号锁定保护期间的目标是有意降频或暂停探测，手动暂停的目标不探测，
+// scrape_only 的目标按 Prometheus 的抓取间隔探测，均不检查
+// 标记在 target.mu 保护下进行，与探测结束时清除标记互斥，不会把刚完成的探测结果标记为过期
+func (p *Prober) checkStale(now time.Time) {
+	for _, target := range p.snapshot() {
+		if target.Config.ScrapeOnly {
+			continue
+		}
+		tp, paused := p.pauseOf(target, now)
+		if paused {
+			continue
+		}
+		maxAge := time.Duration(p.config.StaleAfterIntervals) * p.probeInterval(target.Config)
+		target.mu.Lock()
+		lastProbeAt := target.lastProbeAt
+		since := lastProbeAt
+		if tp.Until != nil && tp.Until.After(since) {
+			since = *tp.Until // 暂停到期后等待下一个探测周期恢复探测
+		}
+		becameStale := !target.stale && !lastProbeAt.IsZero() && !target.auth.protected && now.Sub(since) > maxAge
+		if becameStale {
+			target.stale = true
+			target.Metrics.SetStale(true, p.config.StaleBehavior)
+		}
+		target.mu.Unlock()
+
+		if becameStale {
+			target.log.Warnw("目标长时间没有完成探测，指标已标记为过期",
+				"last_probe_time", lastProbeAt,
+				"stale_after", maxAge,
+				"stale_behavior", p.config.StaleBehavior,
+			)
+		}
+	}
+}
+
+// SchedulerStatus 探测调度状态，供 /health 使用
+type SchedulerStatus struct {
+	OK      bool `json:"ok"`
+	Targets int  `json:"targets"` // 参与检查的目标数（不含账号锁定保护中、暂停和 scrape_only 的目标）
+	Stalled int  `json:"stalled"` // 超过 stall_intervals 个探测间隔没有完成探测的目标数
+	// LastProbeTime 所有目标中最近一次完成探测的时间，尚未完成任何探测时为空
+	LastProbeTime *time.Time `json:"last_probe_time,omitempty"`
+}
+
+// SchedulerHealth 检查探测调度是否仍在运行：所有参与检查的目标都超过 stallIntervals 个探测间隔没有完成探测时判定调度停止
+// 单个目标卡住（如驱动调用没有响应）由 stale 指标体现，不影响探针整体健康；
+// 尚未完成首次探测的目标从探测循环启动时开始计算，账号锁定保护期间的目标是有意降频或暂停探测，手动暂停的目标不探测，
+// scrape_only 的目标不执行周期探测，均不参与检查
+func (p *Prober) SchedulerHealth(now time.Time, stallIntervals int) SchedulerStatus {
+	status := SchedulerStatus{OK: true}
+	if p.ctx.Err() != nil {
+		status.OK = false
+		return status
+	}
+
+	var latest time.Time
+	for _, target := range p.snapshot() {
+		if target.Config.ScrapeOnly {
+			continue
+		}
+		target.mu.RLock()
+		lastProbeAt, protected := target.lastProbeAt, target.auth.protected
+		target.mu.RUnlock()
+		if lastProbeAt.After(latest) {
+			latest = lastProbeAt
+		}
+		since := lastProbeAt
+		if since.IsZero() {
+			since = target.startedAt
+		}
+		tp, paused := p.pauseOf(target, now)
+		if tp.Until != nil && tp.Until.After(since) {
+			since = *tp.Until
+		}
+		if protected || paused || since.IsZero() {
+			continue
+		}
+		status.Targets++
+		if now.Sub(since) > time.Duration(stallIntervals)*p.probeInterval(target.Config) {
+			status.Stalled++
+		}
+	}
+	if !latest.IsZero() {
+		status.LastProbeTime = &latest
+	}
+	status.OK = status.Targets == 0 || status.Stalled < status.Targets
+	return status
+}
@@ -0,0 +1,86 @@
+package prober
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/internal/db"
+)
+
+// statusProbe 请求 TiDB 状态端口（status_port）的客户端
+type statusProbe struct {
+	client *http.Client
+	url    string
+}
+
+// statusResult 最近一次请求状态端口的结果，err 为 nil 表示状态端口可用
+// version、connections 为最近一次成功读取到的值，请求失败时保留
+type statusResult struct {
+	err         error
+	version     string
+	connections int
+}
+
+// newStatusProbe 创建状态端口客户端，dbCfg 为连接使用的配置（按地址探测时 host 为该地址）
+func newStatusProbe(dbCfg *config.DBConfig) *statusProbe {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 1
+	return &statusProbe{
+		client: &http.Client{Transport: transport},
+		url:    db.TiDBStatusURL(dbCfg.Host, dbCfg.StatusPort),
+	}
+}
+
+// checkStatusPort 请求状态端口，更新 db_probe_status_up、db_probe_server_connections、db_probe_server_version_info
+// 与 SQL 探测的结果无关，每轮都请求；状态端口可用性变化时输出日志：不可用为 Warn，恢复为 Info，版本变化为 Info
+func (p *Prober) checkStatusPort(target *DBTarget) {
+	if target.status == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(p.ctx, p.probeTimeout(target.Config))
+	defer cancel()
+	status, err := db.QueryTiDBStatus(ctx, target.status.client, target.status.url)
+
+	target.mu.Lock()
+	previous := target.lastStatus
+	result := &statusResult{err: err}
+	if previous != nil {
+		result.version, result.connections = previous.version, previous.connections
+	}
+	if err == nil {
+		result.version, result.connections = status.Version, status.Connections
+	}
+	target.lastStatus = result
+	target.mu.Unlock()
+
+	if err != nil {
+		target.Metrics.SetStatusDown()
+		if previous == nil || previous.err == nil {
+			target.log.Warnw("状态端口不可用", "status_url", target.status.url, "error", err)
+		}
+		return
+	}
+	previousVersion := ""
+	if previous != nil {
+		previousVersion = previous.version
+	}
+	target.Metrics.SetStatus(previousVersion, status.Version, status.Connections)
+	if previous != nil && previous.err != nil {
+		target.log.Infow("状态端口恢复", "status_url", target.status.url)
+	}
+	if previousVersion != "" && previousVersion != status.Version {
+		target.log.Infow("服务端版本变化", "version", status.Version, "previous_version", previousVersion)
+		target.recheckQueryCompat()
+	}
+}
+
+// statusUp 返回最近一次请求状态端口是否成功，未配置 status_port 或尚未请求时 ok 为 false
+func (t *DBTarget) statusUp() (up, ok bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.lastStatus == nil {
+		return false, false
+	}
+	return t.lastStatus.err == nil, true
+}
@@ -0,0 +1,135 @@
+package prober
+
+import (
+	"reflect"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/internal/metrics"
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+// SyncResult 一次 SyncTargets 的结果（目标名称）
+type SyncResult struct {
+	Added   []string
+	Updated []string
+	Removed []string
+	// Skipped 与其他来源（包括配置文件）的目标重名、在本次结果中重复或创建失败而跳过的目标
+	Skipped []string
+}
+
+// Changed 本次同步是否增删或更新了目标
+func (r *SyncResult) Changed() bool {
+	return len(r.Added) > 0 || len(r.Updated) > 0 || len(r.Removed) > 0
+}
+
+// SyncTargets 把来源 source 的目标对齐到 dbCfgs：新增的目标开始探测，消失的目标停止探测并删除指标，
+// 配置发生变化的目标重建（连接和指标重新创建）；配置未变化的目标不受影响，探测状态保持连续
+// 只管理同一来源的目标，与其他来源的目标重名时跳过，配置文件中的目标优先
+// 调用方需要预先校验 dbCfgs 中的每个配置（config.ValidateDatabase）
+func (p *Prober) SyncTargets(source string, dbCfgs []config.DBConfig) SyncResult {
+	p.syncMu.Lock()
+	defer p.syncMu.Unlock()
+
+	current := make(map[string][]*DBTarget)
+	others := make(map[string]bool)
+	for _, target := range p.snapshot() {
+		if target.source == source {
+			current[target.Config.Name] = append(current[target.Config.Name], target)
+		} else {
+			others[target.Config.Name] = true
+		}
+	}
+
+	var result SyncResult
+	desired := make(map[string]bool, len(dbCfgs))
+	for i := range dbCfgs {
+		dbCfg := dbCfgs[i]
+		if others[dbCfg.Name] || desired[dbCfg.Name] {
+			logger.L().Warnw("目标名称重复，跳过", "source", source, "db_name", dbCfg.Name)
+			result.Skipped = append(result.Skipped, dbCfg.Name)
+			continue
+		}
+		desired[dbCfg.Name] = true
+
+		old := current[dbCfg.Name]
+		if len(old) > 0 && reflect.DeepEqual(*old[0].Config, dbCfg) {
+			continue
+		}
+		// 先停止旧目标再创建新目标：labels 未变化时新旧目标共用同一组时间序列，删除旧序列不能发生在创建之后
+		if len(old) > 0 {
+			p.removeTargets(old)
+		}
+		targets, err := p.buildTargets(&dbCfg)
+		if err != nil {
+			logger.L().Errorw("创建目标失败", "source", source, "db_name", dbCfg.Name, "error", err)
+			result.Skipped = append(result.Skipped, dbCfg.Name)
+			if len(old) > 0 {
+				result.Removed = append(result.Removed, dbCfg.Name)
+			}
+			continue
+		}
+		p.addTargets(source, targets)
+		if len(old) > 0 {
+			result.Updated = append(result.Updated, dbCfg.Name)
+		} else {
+			result.Added = append(result.Added, dbCfg.Name)
+		}
+	}
+
+	for name, old := range current {
+		if !desired[name] {
+			p.removeTargets(old)
+			result.Removed = append(result.Removed, name)
+		}
+	}
+	return result
+}
+
+// addTargets 加入目标列表，探针已启动时立即开始探测
+func (p *Prober) addTargets(source string, targets []*DBTarget) {
+	p.targetsMu.Lock()
+	defer p.targetsMu.Unlock()
+	for _, target := range targets {
+		target.source = source
+		p.targets = append(p.targets, target)
+		if p.started {
+			p.startTarget(target)
+		}
+	}
+}
+
+// removeTargets 从目标列表中移除，等待探测循环和进行中的立即探测结束后关闭连接并删除指标
+func (p *Prober) removeTargets(targets []*DBTarget) {
+	removed := make(map[*DBTarget]bool, len(targets))
+	for _, target := range targets {
+		removed[target] = true
+	}
+	p.targetsMu.Lock()
+	kept := p.targets[:0]
+	for _, target := range p.targets {
+		if !removed[target] {
+			kept = append(kept, target)
+		}
+	}
+	// 清空尾部的引用，已移除的目标可以被回收
+	for i := len(kept); i < len(p.targets); i++ {
+		p.targets[i] = nil
+	}
+	p.targets = kept
+	p.targetsMu.Unlock()
+
+	for _, target := range targets {
+		if target.cancel != nil {
+			target.cancel()
+			<-target.done
+		}
+		target.probeMu.Lock()
+		target.close()
+		target.probeMu.Unlock()
+
+		target.mu.RLock()
+		labels := target.Labels
+		target.mu.RUnlock()
+		metrics.DeleteTargetMetrics(labels)
+	}
+}
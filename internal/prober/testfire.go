@@ -0,0 +1,87 @@
+package prober
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// testFireStage 故障演练的失败阶段
+const testFireStage = "故障演练"
+
+// errTestFire 故障演练期间探测不访问数据库，直接以该错误判定为失败
+var errTestFire = errors.New("目标被临时标记为故障（故障演练），未访问数据库")
+
+// TestFire 正在进行的故障演练
+type TestFire struct {
+	Target string    `json:"target"`
+	IP     string    `json:"ip"`
+	Until  time.Time `json:"until"`
+}
+
+// FireTest 把指定目标临时标记为故障，持续 duration，用于演练告警和通知链路
+// 演练期间探测不访问数据库，指标按故障更新（db_probe_test_fire 为 1），状态变化事件的 Test 为 true；
+// 到期后下一个探测周期恢复正常探测，恢复事件同样标记为演练
+// 标记后立即探测一次，不等待下一个探测周期，返回演练的结束时间和探测结果；目标不存在时返回错误
+func (p *Prober) FireTest(name string, duration time.Duration) (time.Time, []ImmediateResult, error) {
+	targets, err := p.targetsNamed(name)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	until := time.Now().Add(duration)
+	for _, target := range targets {
+		target.mu.Lock()
+		target.testFireUntil = until
+		target.mu.Unlock()
+	}
+	return until, p.probeTargets(targets, 0), nil
+}
+
+// CancelTest 提前结束指定目标的故障演练，并立即探测一次
+// 返回目标此前是否处于演练中；目标不存在时返回错误
+func (p *Prober) CancelTest(name string) (bool, []ImmediateResult, error) {
+	targets, err := p.targetsNamed(name)
+	if err != nil {
+		return false, nil, err
+	}
+	now := time.Now()
+	active := false
+	for _, target := range targets {
+		target.mu.Lock()
+		if now.Before(target.testFireUntil) {
+			active = true
+		}
+		target.testFireUntil = time.Time{}
+		target.mu.Unlock()
+	}
+	return active, p.probeTargets(targets, 0), nil
+}
+
+// ActiveTests 返回正在进行的故障演练，按目标名称排序
+func (p *Prober) ActiveTests() []TestFire {
+	now := time.Now()
+	var fires []TestFire
+	for _, target := range p.snapshot() {
+		target.mu.RLock()
+		until := target.testFireUntil
+		ip := target.IP // 开启 dns_refresh_interval 时探测 goroutine 会更新 IP
+		target.mu.RUnlock()
+		if now.Before(until) {
+			fires = append(fires, TestFire{Target: target.Config.Name, IP: ip, Until: until})
+		}
+	}
+	sort.Slice(fires, func(i, j int) bool {
+		if fires[i].Target != fires[j].Target {
+			return fires[i].Target < fires[j].Target
+		}
+		return fires[i].IP < fires[j].IP
+	})
+	return fires
+}
+
+// testFireActive 目标在 now 时是否处于故障演练
+func (t *DBTarget) testFireActive(now time.Time) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return now.Before(t.testFireUntil)
+}
@@ -0,0 +1,58 @@
+package prober
+
+import (
+	"context"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/db"
+)
+
+// checkUptime 按 uptime_interval 查询数据库实例运行时长
+// 运行时长比上一次查询的结果小，说明实例在两次查询之间发生了重启
+// 查询失败（如权限不足）只记录 Debug 日志，不影响探测结果
+// 运行时长查询属于可选检查，最近一小时的语句数达到预算时跳过
+func (p *Prober) checkUptime(target *DBTarget) {
+	interval := p.config.UptimeInterval
+	if interval <= 0 || target.DB == nil {
+		return
+	}
+	querier, ok := target.driver.(db.UptimeQuerier)
+	if !ok || !p.optionalCheckAllowed(target) {
+		return
+	}
+
+	now := time.Now()
+	target.mu.Lock()
+	if !target.uptimeCheckedAt.IsZero() && now.Sub(target.uptimeCheckedAt) < interval {
+		target.mu.Unlock()
+		return
+	}
+	target.uptimeCheckedAt = now
+	lastUptime := target.lastUptime
+	target.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(p.ctx, p.probeTimeout(target.Config))
+	defer cancel()
+	target.recordStatements("optional", 1)
+	uptime, err := querier.QueryUptime(ctx, target.checkDB("uptime"))
+	target.recordSessionInit() // 查询可能新建了连接
+	if err != nil {
+		target.log.Debugw("查询数据库实例运行时长失败", "error", err)
+		return
+	}
+
+	target.mu.Lock()
+	target.lastUptime = uptime
+	target.mu.Unlock()
+	target.Metrics.SetServerUptime(uptime)
+
+	if lastUptime > 0 && uptime < lastUptime {
+		target.Metrics.RecordServerRestart()
+		target.recheckQueryCompat() // 重启可能是升级，重新检查探测 SQL
+		target.log.Warnw("检测到数据库实例重启",
+			"previous_uptime_seconds", lastUptime,
+			"uptime_seconds", uptime,
+			"started_at", now.Add(-time.Duration(uptime*float64(time.Second))),
+		)
+	}
+}
@@ -0,0 +1,143 @@
+package remotewrite
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// label 时间序列的一个 label
+type label struct {
+	name  string
+	value string
+}
+
+// encodeWriteRequest 将采集到的指标编码为 remote write 1.0 的 WriteRequest（未压缩）
+// 只用到 WriteRequest/TimeSeries/Label/Sample 四个消息，直接按 protobuf 线格式编码，避免引入 prometheus/prometheus：
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+//
+// Histogram 和 Summary 按 Prometheus 抓取时的方式展开为 _bucket/_sum/_count 和 quantile 序列
+func encodeWriteRequest(families []*dto.MetricFamily, external []label, nowMs int64) ([]byte, int) {
+	e := &encoder{external: external}
+	for _, mf := range families {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			ts := nowMs
+			if m.TimestampMs != nil {
+				ts = m.GetTimestampMs()
+			}
+			base := make([]label, 0, len(m.GetLabel())+1)
+			for _, lp := range m.GetLabel() {
+				base = append(base, label{name: lp.GetName(), value: lp.GetValue()})
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				e.add(name, base, m.GetCounter().GetValue(), ts)
+			case dto.MetricType_GAUGE:
+				e.add(name, base, m.GetGauge().GetValue(), ts)
+			case dto.MetricType_UNTYPED:
+				e.add(name, base, m.GetUntyped().GetValue(), ts)
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					e.add(name, withLabel(base, "quantile", formatFloat(q.GetQuantile())), q.GetValue(), ts)
+				}
+				e.add(name+"_sum", base, s.GetSampleSum(), ts)
+				e.add(name+"_count", base, float64(s.GetSampleCount()), ts)
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					if math.IsInf(b.GetUpperBound(), 1) {
+						continue // +Inf 桶统一按 sample_count 输出
+					}
+					e.add(name+"_bucket", withLabel(base, "le", formatFloat(b.GetUpperBound())), float64(b.GetCumulativeCount()), ts)
+				}
+				e.add(name+"_bucket", withLabel(base, "le", "+Inf"), float64(h.GetSampleCount()), ts)
+				e.add(name+"_sum", base, h.GetSampleSum(), ts)
+				e.add(name+"_count", base, float64(h.GetSampleCount()), ts)
+			}
+		}
+	}
+	return e.buf, e.samples
+}
+
+// encoder 逐条追加 TimeSeries 的编码器，series/scratch 在序列之间复用
+type encoder struct {
+	external []label
+	buf      []byte
+	series   []byte
+	scratch  []byte
+	labels   []label
+	samples  int
+}
+
+// add 追加一条只有一个样本的时间序列
+// labels 按名称排序（remote write 协议要求），external label 不覆盖指标自身的同名 label
+func (e *encoder) add(name string, labels []label, value float64, ts int64) {
+	e.labels = append(e.labels[:0], label{name: "__name__", value: name})
+	e.labels = append(e.labels, labels...)
+	for _, ext := range e.external {
+		if !hasLabel(labels, ext.name) {
+			e.labels = append(e.labels, ext)
+		}
+	}
+	sort.Slice(e.labels, func(i, j int) bool { return e.labels[i].name < e.labels[j].name })
+
+	e.series = e.series[:0]
+	for _, l := range e.labels {
+		e.scratch = e.scratch[:0]
+		e.scratch = protowire.AppendTag(e.scratch, 1, protowire.BytesType)
+		e.scratch = protowire.AppendString(e.scratch, l.name)
+		e.scratch = protowire.AppendTag(e.scratch, 2, protowire.BytesType)
+		e.scratch = protowire.AppendString(e.scratch, l.value)
+		e.series = protowire.AppendTag(e.series, 1, protowire.BytesType)
+		e.series = protowire.AppendBytes(e.series, e.scratch)
+	}
+
+	e.scratch = e.scratch[:0]
+	e.scratch = protowire.AppendTag(e.scratch, 1, protowire.Fixed64Type)
+	e.scratch = protowire.AppendFixed64(e.scratch, math.Float64bits(value))
+	e.scratch = protowire.AppendTag(e.scratch, 2, protowire.VarintType)
+	e.scratch = protowire.AppendVarint(e.scratch, uint64(ts))
+	e.series = protowire.AppendTag(e.series, 2, protowire.BytesType)
+	e.series = protowire.AppendBytes(e.series, e.scratch)
+
+	e.buf = protowire.AppendTag(e.buf, 1, protowire.BytesType)
+	e.buf = protowire.AppendBytes(e.buf, e.series)
+	e.samples++
+}
+
+// withLabel 返回追加了一个 label 的副本，不修改 base
+func withLabel(base []label, name, value string) []label {
+	out := make([]label, len(base), len(base)+1)
+	copy(out, base)
+	return append(out, label{name: name, value: value})
+}
+
+func hasLabel(labels []label, name string) bool {
+	for _, l := range labels {
+		if l.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// formatFloat 与 Prometheus 文本格式一致的浮点数格式（如 0.005、+Inf）
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
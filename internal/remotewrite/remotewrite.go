@@ -0,0 +1,247 @@
+// Package remotewrite 通过 Prometheus remote write 协议主动推送探针指标
+// 适用于边缘站点没有 Prometheus 抓取的场景，可直接推送到 Grafana Cloud、Mimir、VictoriaMetrics 等
+// 不使用 WAL：每个推送间隔采集一次指标，编码后的批次在内存中有界缓冲，
+// 远端持续不可用时丢弃最旧的批次，保证内存占用有上限
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/internal/metrics"
+	"github.com/imkerbos/db-probe/pkg/logger"
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// batch 一次采集编码后的 WriteRequest（snappy 压缩）
+type batch struct {
+	body    []byte
+	samples int
+}
+
+// Writer remote write 推送器
+type Writer struct {
+	cfg      config.RemoteWriteConfig
+	gatherer prometheus.Gatherer
+	client   *http.Client
+	// external 附加到所有时间序列的 label，按名称排序
+	external []label
+
+	pending []batch
+	// failing 远端是否处于持续失败状态，只在进入和恢复时输出日志，避免每个间隔重复告警
+	failing bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New 创建 remote write 推送器，gatherer 通常为 prometheus.DefaultGatherer
+// external_labels 未配置 job、instance 时，默认使用 db-probe 和主机名，远端无需额外配置即可区分各站点的探针
+func New(cfg config.RemoteWriteConfig, gatherer prometheus.Gatherer) (*Writer, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.TLSSkipVerify}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 remote_write.ca_file 失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("remote_write.ca_file 中没有有效的证书: %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	external := map[string]string{"job": "db-probe"}
+	if hostname, err := os.Hostname(); err == nil {
+		external["instance"] = hostname
+	}
+	for name, value := range cfg.ExternalLabels {
+		external[name] = value
+	}
+	labels := make([]label, 0, len(external))
+	for name, value := range external {
+		labels = append(labels, label{name: name, value: value})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Writer{
+		cfg:      cfg,
+		gatherer: gatherer,
+		client:   &http.Client{Timeout: cfg.Timeout, Transport: transport},
+		external: labels,
+		ctx:      ctx,
+		cancel:   cancel,
+	}, nil
+}
+
+// Start 启动推送循环
+func (w *Writer) Start() {
+	logger.L().Infow("remote write 已启用",
+		"url", w.cfg.URL,
+		"interval", w.cfg.Interval,
+		"max_pending_batches", w.cfg.MaxPendingBatches,
+	)
+	w.wg.Add(1)
+	go w.run()
+}
+
+// Stop 停止推送循环，缓冲中尚未推送的批次被丢弃
+func (w *Writer) Stop() {
+	w.cancel()
+	w.wg.Wait()
+}
+
+func (w *Writer) run() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			w.collect()
+			w.flush()
+		}
+	}
+}
+
+// collect 采集一次指标并加入缓冲，缓冲已满时丢弃最旧的批次
+func (w *Writer) collect() {
+	families, err := w.gatherer.Gather()
+	if err != nil {
+		// Gather 出错时仍会返回能采集到的指标，照常推送
+		logger.L().Warnw("remote write 采集指标出错", "error", err)
+	}
+	body, samples := encodeWriteRequest(families, w.external, time.Now().UnixMilli())
+	if samples == 0 {
+		return
+	}
+	w.pending = append(w.pending, batch{body: snappy.Encode(nil, body), samples: samples})
+
+	if dropped := len(w.pending) - w.cfg.MaxPendingBatches; dropped > 0 {
+		droppedSamples := 0
+		for _, b := range w.pending[:dropped] {
+			droppedSamples += b.samples
+		}
+		w.pending = append(w.pending[:0], w.pending[dropped:]...)
+		metrics.RecordRemoteWriteSamples("dropped", droppedSamples)
+		logger.L().Warnw("remote write 缓冲已满，丢弃最旧的批次",
+			"dropped_batches", dropped,
+			"dropped_samples", droppedSamples,
+			"max_pending_batches", w.cfg.MaxPendingBatches,
+		)
+	}
+	metrics.SetRemoteWritePending(len(w.pending))
+}
+
+// flush 按采集顺序推送缓冲中的批次，遇到可重试的错误时停止，留到下一个间隔重试
+func (w *Writer) flush() {
+	defer func() { metrics.SetRemoteWritePending(len(w.pending)) }()
+
+	for len(w.pending) > 0 {
+		b := w.pending[0]
+		err := w.send(b.body)
+		if err == nil {
+			w.pending = w.pending[1:]
+			metrics.RecordRemoteWriteSamples("sent", b.samples)
+			if w.failing {
+				w.failing = false
+				logger.L().Infow("remote write 推送恢复", "url", w.cfg.URL, "pending_batches", len(w.pending))
+			}
+			continue
+		}
+
+		if rerr, ok := err.(*responseError); ok && !rerr.retryable() {
+			// 远端拒绝（如 400 样本格式错误、401 认证失败），重试不会成功，直接丢弃该批次
+			w.pending = w.pending[1:]
+			metrics.RecordRemoteWriteSamples("dropped", b.samples)
+			logger.L().Errorw("remote write 推送被拒绝，丢弃该批次",
+				"url", w.cfg.URL,
+				"samples", b.samples,
+				"error", err,
+			)
+			continue
+		}
+
+		if !w.failing {
+			w.failing = true
+			logger.L().Warnw("remote write 推送失败，将在下一个间隔重试",
+				"url", w.cfg.URL,
+				"pending_batches", len(w.pending),
+				"error", err,
+			)
+		}
+		return
+	}
+}
+
+// responseError 远端返回的非 2xx 响应
+type responseError struct {
+	status int
+	body   string
+}
+
+func (e *responseError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.status, e.body)
+}
+
+// retryable 5xx 和 429（限流）可重试，其余 4xx 为请求本身的问题
+func (e *responseError) retryable() bool {
+	return e.status >= 500 || e.status == http.StatusTooManyRequests
+}
+
+// send 推送一个批次
+func (w *Writer) send(body []byte) error {
+	ctx, cancel := context.WithTimeout(w.ctx, w.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, value := range w.cfg.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "db-probe")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if w.cfg.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", w.cfg.TenantID)
+	}
+	if w.cfg.BasicAuth.Username != "" {
+		req.SetBasicAuth(w.cfg.BasicAuth.Username, w.cfg.BasicAuth.Password)
+	} else if w.cfg.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+w.cfg.BearerToken)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &responseError{status: resp.StatusCode, body: strings.TrimSpace(string(msg))}
+}
@@ -0,0 +1,469 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+const (
+	// awsService Secrets Manager 在签名中的服务名
+	awsService = "secretsmanager"
+	// awsCredentialRefreshWindow 临时凭证在到期前多久刷新
+	awsCredentialRefreshWindow = 5 * time.Minute
+	// awsMetadataTimeout 访问 ECS/EC2 元数据服务的超时时间，不在 AWS 上运行时尽快失败
+	awsMetadataTimeout = 2 * time.Second
+)
+
+// AWSSecretsManager 从 AWS Secrets Manager 读取凭据（如 RDS 托管的轮换密码），路径为 secret 的 ARN 或名称
+// 请求使用 Signature V4 签名；访问凭证按环境变量、Web Identity（EKS IRSA）、ECS 任务角色、EC2 实例角色的顺序获取，临时凭证到期前自动刷新
+// Secrets Manager 中的凭据没有租约，按 refresh_interval 重新读取，轮换后读取到新密码
+type AWSSecretsManager struct {
+	region   string // 默认区域，ARN 中的区域优先
+	endpoint string // 可选，覆盖默认的 https://secretsmanager.<region>.amazonaws.com
+	client   *http.Client
+
+	// mu 保护 creds，临时凭证到期前重新获取
+	mu    sync.Mutex
+	creds awsCredentials
+}
+
+// awsCredentials AWS 访问凭证，Expires 为零值表示长期凭证
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expires         time.Time
+	source          string
+}
+
+// NewAWSSecretsManager 创建 AWS Secrets Manager 凭据读取，未配置 region 时使用 AWS_REGION、AWS_DEFAULT_REGION
+func NewAWSSecretsManager(cfg config.AWSConfig) (*AWSSecretsManager, error) {
+	region := envDefault(cfg.Region, "AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	a := &AWSSecretsManager{
+		region:   region,
+		endpoint: strings.TrimSuffix(cfg.Endpoint, "/"),
+		client:   &http.Client{},
+	}
+	logger.L().Infow("AWS Secrets Manager 凭据读取已配置",
+		"region", region,
+		"endpoint", a.endpoint,
+	)
+	return a, nil
+}
+
+// Read 读取 secret 的当前版本（AWSCURRENT），SecretString 为 JSON 对象时按字段展开（如 RDS 的 username、password），否则以 value 为 key
+func (a *AWSSecretsManager) Read(ctx context.Context, path string) (*Secret, error) {
+	region := a.region
+	// ARN 格式：arn:aws:secretsmanager:<region>:<account>:secret:<name>
+	if parts := strings.Split(path, ":"); len(parts) >= 7 && parts[0] == "arn" && parts[2] == awsService {
+		region = parts[3]
+	}
+	if region == "" {
+		return nil, fmt.Errorf("无法确定 AWS 区域，请使用 secret 的 ARN，或配置 secrets.aws.region、AWS_REGION 环境变量")
+	}
+	creds, err := a.credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := a.endpoint
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + region + ".amazonaws.com"
+	}
+	body, _ := json.Marshal(map[string]string{"SecretId": path, "VersionStage": "AWSCURRENT"})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signAWSRequest(req, body, creds, region, awsService, time.Now())
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求 AWS Secrets Manager 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Type     string `json:"__type"`
+			Message  string `json:"message"`
+			Message2 string `json:"Message"`
+		}
+		json.Unmarshal(raw, &apiErr)
+		errType := apiErr.Type[strings.LastIndex(apiErr.Type, "#")+1:]
+		return nil, fmt.Errorf("AWS Secrets Manager 返回 %s: %s %s", resp.Status, errType, apiErr.Message+apiErr.Message2)
+	}
+
+	var result struct {
+		SecretString *string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("解析 AWS Secrets Manager 响应失败: %w", err)
+	}
+	if result.SecretString == nil {
+		return nil, fmt.Errorf("secret %s 没有 SecretString（不支持二进制 secret）", path)
+	}
+	secret := &Secret{Data: make(map[string]string)}
+	var fields map[string]interface{}
+	if json.Unmarshal([]byte(*result.SecretString), &fields) != nil {
+		secret.Data["value"] = *result.SecretString
+		return secret, nil
+	}
+	for key, value := range fields {
+		switch value := value.(type) {
+		case string:
+			secret.Data[key] = value
+		case nil:
+		default:
+			raw, _ := json.Marshal(value)
+			secret.Data[key] = string(raw)
+		}
+	}
+	return secret, nil
+}
+
+// Renew Secrets Manager 中的凭据没有租约
+func (a *AWSSecretsManager) Renew(ctx context.Context, secret *Secret) (time.Duration, error) {
+	return 0, errNotRenewable
+}
+
+// credentials 返回访问凭证，临时凭证在到期前 awsCredentialRefreshWindow 重新获取
+func (a *AWSSecretsManager) credentials(ctx context.Context) (awsCredentials, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.creds.AccessKeyID != "" && (a.creds.Expires.IsZero() || time.Until(a.creds.Expires) > awsCredentialRefreshWindow) {
+		return a.creds, nil
+	}
+	creds, err := a.loadCredentials(ctx)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	if creds.source != a.creds.source {
+		logger.L().Infow("已获取 AWS 访问凭证", "source", creds.source, "temporary", !creds.Expires.IsZero())
+	}
+	a.creds = creds
+	return creds, nil
+}
+
+// loadCredentials 按环境变量、Web Identity、ECS 任务角色、EC2 实例角色的顺序获取访问凭证
+func (a *AWSSecretsManager) loadCredentials(ctx context.Context) (awsCredentials, error) {
+	if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+		return awsCredentials{AccessKeyID: id, SecretAccessKey: secret, SessionToken: os.Getenv("AWS_SESSION_TOKEN"), source: "env"}, nil
+	}
+	if role, tokenFile := os.Getenv("AWS_ROLE_ARN"), os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"); role != "" && tokenFile != "" {
+		return a.assumeRoleWithWebIdentity(ctx, role, tokenFile)
+	}
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); uri != "" {
+		return a.containerCredentials(ctx, "http://169.254.170.2"+uri)
+	}
+	if uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI"); uri != "" {
+		if err := checkContainerCredentialsURI(uri); err != nil {
+			return awsCredentials{}, err
+		}
+		return a.containerCredentials(ctx, uri)
+	}
+	creds, err := a.instanceCredentials(ctx)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("未找到 AWS 访问凭证（环境变量、Web Identity、ECS 任务角色均未配置，EC2 实例角色不可用: %v）", err)
+	}
+	return creds, nil
+}
+
+// assumeRoleWithWebIdentity 使用 Web Identity token（如 EKS IRSA 挂载的 ServiceAccount token）换取临时凭证，请求不需要签名
+func (a *AWSSecretsManager) assumeRoleWithWebIdentity(ctx context.Context, role, tokenFile string) (awsCredentials, error) {
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("读取 AWS Web Identity token 失败: %w", err)
+	}
+	endpoint := "https://sts.amazonaws.com"
+	if a.region != "" {
+		endpoint = "https://sts." + a.region + ".amazonaws.com"
+	}
+	query := url.Values{}
+	query.Set("Action", "AssumeRoleWithWebIdentity")
+	query.Set("Version", "2011-06-15")
+	query.Set("RoleArn", role)
+	session := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if session == "" {
+		session = "db-probe"
+	}
+	query.Set("RoleSessionName", session)
+	query.Set("WebIdentityToken", strings.TrimSpace(string(token)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(query.Encode()))
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("请求 AWS STS 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		var stsErr struct {
+			Code    string `xml:"Error>Code"`
+			Message string `xml:"Error>Message"`
+		}
+		xml.Unmarshal(raw, &stsErr)
+		return awsCredentials{}, fmt.Errorf("AWS STS AssumeRoleWithWebIdentity 返回 %s: %s %s", resp.Status, stsErr.Code, stsErr.Message)
+	}
+	var result struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal(raw, &result); err != nil {
+		return awsCredentials{}, fmt.Errorf("解析 AWS STS 响应失败: %w", err)
+	}
+	c := result.Credentials
+	return awsCredentials{AccessKeyID: c.AccessKeyID, SecretAccessKey: c.SecretAccessKey, SessionToken: c.SessionToken, Expires: c.Expiration, source: "web_identity"}, nil
+}
+
+// metadataCredentials ECS、EC2 元数据服务返回的临时凭证
+type metadataCredentials struct {
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+// containerCredentials 从 ECS 任务角色（或 EKS Pod Identity）的凭证地址获取临时凭证
+// 授权 token 优先读取 AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE（每次重新读取，EKS Pod Identity 会轮换该文件），其次为 AWS_CONTAINER_AUTHORIZATION_TOKEN
+func (a *AWSSecretsManager) containerCredentials(ctx context.Context, endpoint string) (awsCredentials, error) {
+	header := http.Header{}
+	token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if tokenFile := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); tokenFile != "" {
+		raw, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return awsCredentials{}, fmt.Errorf("读取 AWS 容器凭证授权 token 失败: %w", err)
+		}
+		token = strings.TrimSpace(string(raw))
+	}
+	if token != "" {
+		header.Set("Authorization", token)
+	}
+	var c metadataCredentials
+	if err := a.metadata(ctx, http.MethodGet, endpoint, header, &c); err != nil {
+		return awsCredentials{}, fmt.Errorf("获取 ECS 任务角色凭证失败: %w", err)
+	}
+	return awsCredentials{AccessKeyID: c.AccessKeyID, SecretAccessKey: c.SecretAccessKey, SessionToken: c.Token, Expires: c.Expiration, source: "ecs"}, nil
+}
+
+// checkContainerCredentialsURI 检查 AWS_CONTAINER_CREDENTIALS_FULL_URI，避免把授权 token 发送到任意地址
+// 与 AWS SDK 一致：https 地址不限制主机；http 地址只允许环回地址、ECS（169.254.170.2）和 EKS Pod Identity（169.254.170.23、fd00:ec2::23）的凭证地址
+func checkContainerCredentialsURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("AWS_CONTAINER_CREDENTIALS_FULL_URI 格式错误: %w", err)
+	}
+	switch u.Scheme {
+	case "https":
+		if u.Hostname() != "" {
+			return nil
+		}
+	case "http":
+		host := u.Hostname()
+		if host == "localhost" {
+			return nil
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			if ip.IsLoopback() || ip.Equal(net.ParseIP("169.254.170.2")) ||
+				ip.Equal(net.ParseIP("169.254.170.23")) || ip.Equal(net.ParseIP("fd00:ec2::23")) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("AWS_CONTAINER_CREDENTIALS_FULL_URI %q 不允许：http 地址只能是环回地址、ECS 或 EKS Pod Identity 的凭证地址", uri)
+}
+
+// instanceCredentials 通过 IMDSv2 获取 EC2 实例角色的临时凭证
+func (a *AWSSecretsManager) instanceCredentials(ctx context.Context) (awsCredentials, error) {
+	const imds = "http://169.254.169.254/latest"
+	var token string
+	header := http.Header{"X-Aws-Ec2-Metadata-Token-Ttl-Seconds": {"21600"}}
+	if err := a.metadata(ctx, http.MethodPut, imds+"/api/token", header, &token); err != nil {
+		return awsCredentials{}, err
+	}
+	header = http.Header{"X-Aws-Ec2-Metadata-Token": {token}}
+	var role string
+	if err := a.metadata(ctx, http.MethodGet, imds+"/meta-data/iam/security-credentials/", header, &role); err != nil {
+		return awsCredentials{}, err
+	}
+	role = strings.TrimSpace(strings.SplitN(role, "\n", 2)[0])
+	if role == "" {
+		return awsCredentials{}, fmt.Errorf("EC2 实例没有关联 IAM 角色")
+	}
+	var c metadataCredentials
+	if err := a.metadata(ctx, http.MethodGet, imds+"/meta-data/iam/security-credentials/"+role, header, &c); err != nil {
+		return awsCredentials{}, err
+	}
+	return awsCredentials{AccessKeyID: c.AccessKeyID, SecretAccessKey: c.SecretAccessKey, SessionToken: c.Token, Expires: c.Expiration, source: "ec2"}, nil
+}
+
+// metadata 请求元数据服务，out 为 *string 时返回原始内容，否则按 JSON 解析
+func (a *AWSSecretsManager) metadata(ctx context.Context, method, endpoint string, header http.Header, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, awsMetadataTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s 返回 %s", endpoint, resp.Status)
+	}
+	if s, ok := out.(*string); ok {
+		*s = string(raw)
+		return nil
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// signAWSRequest 按 Signature V4 为请求签名，body 为请求体，签名覆盖 Host 和请求中已设置的全部请求头
+// 规范请求按 AWS 的规则构造：查询参数按名称、值排序并重新编码，同名请求头的多个值以逗号连接，值中连续的空格合并为一个
+func signAWSRequest(req *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := []string{"host"}
+	for name := range req.Header {
+		headers = append(headers, strings.ToLower(name))
+	}
+	slices.Sort(headers)
+	var canonicalHeaders strings.Builder
+	for _, h := range headers {
+		values := req.Header.Values(h)
+		if h == "host" {
+			values = []string{req.URL.Host}
+		}
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.Join(trimmed, ",") + "\n")
+	}
+	signedHeaders := strings.Join(headers, ";")
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsCanonicalPath(req.URL),
+		awsCanonicalQuery(req.URL),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := awsSigningKey(creds.SecretAccessKey, date, region, service)
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+creds.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// awsSigningKey 按日期、区域、服务逐级派生签名密钥
+func awsSigningKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+// awsCanonicalPath 规范 URI：去掉 . 和 .. 路径段后逐段按 AWS 规则编码，空路径为 /
+func awsCanonicalPath(u *url.URL) string {
+	p := u.Path
+	if p == "" {
+		return "/"
+	}
+	clean := path.Clean(p)
+	if strings.HasSuffix(p, "/") && clean != "/" {
+		clean += "/"
+	}
+	segments := strings.Split(clean, "/")
+	for i, s := range segments {
+		segments[i] = awsURIEncode(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// awsCanonicalQuery 规范查询字符串：参数名和值按 AWS 规则编码后按名称、值排序
+func awsCanonicalQuery(u *url.URL) string {
+	var pairs [][2]string
+	for name, values := range u.Query() {
+		for _, v := range values {
+			pairs = append(pairs, [2]string{awsURIEncode(name), awsURIEncode(v)})
+		}
+	}
+	slices.SortFunc(pairs, func(a, b [2]string) int {
+		if c := strings.Compare(a[0], b[0]); c != 0 {
+			return c
+		}
+		return strings.Compare(a[1], b[1])
+	})
+	encoded := make([]string, len(pairs))
+	for i, pair := range pairs {
+		encoded[i] = pair[0] + "=" + pair[1]
+	}
+	return strings.Join(encoded, "&")
+}
+
+// awsURIEncode 除 A-Z、a-z、0-9、-、_、.、~ 以外的字节都编码为 %XX（大写十六进制）
+func awsURIEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,246 @@
+package secrets
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// AWS Signature V4 测试套件（aws-sig-v4-test-suite）和 IAM 文档示例使用的凭证与时间
+var (
+	sigV4TestCreds = awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	sigV4TestTime  = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+)
+
+func TestSignAWSRequest(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		url           string
+		service       string
+		contentType   string
+		body          string
+		signedHeaders string
+		signature     string
+	}{
+		{
+			name:          "get-vanilla",
+			method:        http.MethodGet,
+			url:           "https://example.amazonaws.com/",
+			service:       "service",
+			signedHeaders: "host;x-amz-date",
+			signature:     "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:          "get-vanilla-query-order-key-case",
+			method:        http.MethodGet,
+			url:           "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			service:       "service",
+			signedHeaders: "host;x-amz-date",
+			signature:     "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			name:          "post-vanilla",
+			method:        http.MethodPost,
+			url:           "https://example.amazonaws.com/",
+			service:       "service",
+			signedHeaders: "host;x-amz-date",
+			signature:     "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b",
+		},
+		{
+			name:          "post-x-www-form-urlencoded",
+			method:        http.MethodPost,
+			url:           "https://example.amazonaws.com/",
+			service:       "service",
+			contentType:   "application/x-www-form-urlencoded",
+			body:          "Param1=value1",
+			signedHeaders: "content-type;host;x-amz-date",
+			signature:     "ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+		},
+		{
+			name:          "iam-list-users",
+			method:        http.MethodGet,
+			url:           "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08",
+			service:       "iam",
+			contentType:   "application/x-www-form-urlencoded; charset=utf-8",
+			signedHeaders: "content-type;host;x-amz-date",
+			signature:     "5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			signAWSRequest(req, []byte(tt.body), sigV4TestCreds, "us-east-1", tt.service, sigV4TestTime)
+
+			want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/" + tt.service + "/aws4_request" +
+				", SignedHeaders=" + tt.signedHeaders + ", Signature=" + tt.signature
+			if got := req.Header.Get("Authorization"); got != want {
+				t.Errorf("Authorization =\n%s\nwant\n%s", got, want)
+			}
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("X-Amz-Date = %s", got)
+			}
+		})
+	}
+}
+
+func TestAWSSigningKey(t *testing.T) {
+	key := awsSigningKey(sigV4TestCreds.SecretAccessKey, "20150830", "us-east-1", "iam")
+	if got, want := hex.EncodeToString(key), "c4afb1cc5771d871763a393e44b703571b55cc28424d1a5e86da6ed3c154a4b9"; got != want {
+		t.Errorf("signing key = %s, want %s", got, want)
+	}
+}
+
+func TestSignAWSRequestCanonicalForm(t *testing.T) {
+	// 参数顺序、编码方式、请求头中的多余空格不同的两个请求，规范请求相同，签名也应相同
+	a, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/a/./b/../c?b=2&a=x%20y&a=1", nil)
+	a.Header.Set("X-Custom", "  one   two ")
+	a.Header.Add("X-Custom", "three")
+	b, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/a/c?a=1&a=x+y&b=2", nil)
+	b.Header.Set("X-Custom", "one two")
+	b.Header.Add("X-Custom", "three")
+	signAWSRequest(a, nil, sigV4TestCreds, "us-east-1", "service", sigV4TestTime)
+	signAWSRequest(b, nil, sigV4TestCreds, "us-east-1", "service", sigV4TestTime)
+	if a.Header.Get("Authorization") != b.Header.Get("Authorization") {
+		t.Errorf("equivalent requests signed differently:\n%s\n%s", a.Header.Get("Authorization"), b.Header.Get("Authorization"))
+	}
+	if got := a.Header.Values("X-Custom"); got[0] != "  one   two " {
+		t.Errorf("signing modified request header: %q", got)
+	}
+
+	if got, want := awsCanonicalQuery(a.URL), "a=1&a=x%20y&b=2"; got != want {
+		t.Errorf("canonical query = %s, want %s", got, want)
+	}
+	if got, want := awsCanonicalPath(a.URL), "/a/c"; got != want {
+		t.Errorf("canonical path = %s, want %s", got, want)
+	}
+}
+
+func TestCheckContainerCredentialsURI(t *testing.T) {
+	tests := []struct {
+		uri string
+		ok  bool
+	}{
+		{"http://127.0.0.1:8080/creds", true},
+		{"http://127.1.2.3/creds", true},
+		{"http://localhost/creds", true},
+		{"http://[::1]:8080/creds", true},
+		{"http://169.254.170.2/v2/credentials", true},
+		{"http://169.254.170.23/v1/credentials", true},
+		{"http://[fd00:ec2::23]/v1/credentials", true},
+		{"https://credentials.example.com/creds", true},
+		{"http://169.254.169.254/latest", false},
+		{"http://10.0.0.1/creds", false},
+		{"http://credentials.example.com/creds", false},
+		{"http://127.0.0.1.example.com/creds", false},
+		{"ftp://127.0.0.1/creds", false},
+		{"https:///creds", false},
+		{"://bad", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			err := checkContainerCredentialsURI(tt.uri)
+			if (err == nil) != tt.ok {
+				t.Errorf("checkContainerCredentialsURI(%q) error = %v, want ok %v", tt.uri, err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestContainerCredentialsAuthorizationToken(t *testing.T) {
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.Write([]byte(`{"AccessKeyId":"AKID","SecretAccessKey":"secret","Token":"session","Expiration":"2030-01-01T00:00:00Z"}`))
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	unsetAWSEnv(t)
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", server.URL+"/creds")
+	t.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN", "from-env")
+
+	tests := []struct {
+		name      string
+		tokenFile string
+		content   string
+		want      string
+	}{
+		{name: "env token", want: "from-env"},
+		{name: "token file preferred", tokenFile: tokenFile, content: "from-file\n", want: "from-file"},
+		{name: "token file re-read", tokenFile: tokenFile, content: "rotated", want: "rotated"},
+	}
+	a := &AWSSecretsManager{client: server.Client()}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.tokenFile != "" {
+				if err := os.WriteFile(tt.tokenFile, []byte(tt.content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE", tt.tokenFile)
+			}
+			creds, err := a.loadCredentials(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotAuth != tt.want {
+				t.Errorf("Authorization = %q, want %q", gotAuth, tt.want)
+			}
+			if creds.AccessKeyID != "AKID" || creds.SessionToken != "session" || creds.source != "ecs" {
+				t.Errorf("unexpected credentials %+v", creds)
+			}
+		})
+	}
+
+	t.Run("missing token file", func(t *testing.T) {
+		t.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE", filepath.Join(t.TempDir(), "missing"))
+		if _, err := a.loadCredentials(context.Background()); err == nil {
+			t.Error("expected error for missing token file")
+		}
+	})
+}
+
+func TestLoadCredentialsRejectsFullURI(t *testing.T) {
+	requested := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+	}))
+	defer server.Close()
+
+	unsetAWSEnv(t)
+	// 非环回地址的 http 凭证地址被拒绝，不发送请求（授权 token 不会泄露）
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", "http://10.0.0.1/creds")
+	t.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN", "secret-token")
+	a := &AWSSecretsManager{client: server.Client()}
+	if _, err := a.loadCredentials(context.Background()); err == nil {
+		t.Fatal("expected error for disallowed full URI")
+	}
+	if requested {
+		t.Error("credentials endpoint was requested")
+	}
+}
+
+// unsetAWSEnv 清除会影响凭证来源选择的环境变量，测试结束后恢复
+func unsetAWSEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{
+		"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN",
+		"AWS_ROLE_ARN", "AWS_WEB_IDENTITY_TOKEN_FILE",
+		"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "AWS_CONTAINER_CREDENTIALS_FULL_URI",
+		"AWS_CONTAINER_AUTHORIZATION_TOKEN", "AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE",
+	} {
+		t.Setenv(name, "")
+	}
+}
@@ -0,0 +1,450 @@
+// Package secrets 在运行时从外部密钥存储读取目标凭据，凭据不需要写入配置文件
+// 读取到凭据的目标通过 prober.SyncTargets 加入探测，凭据变化时目标重建（连接使用新的凭据）；
+// 读取失败或凭据不可用时保留上一次读取的凭据，避免密钥存储故障导致目标停止探测
+package secrets
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/internal/metrics"
+	"github.com/imkerbos/db-probe/internal/prober"
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+// KubernetesSource Kubernetes Secret 凭据的来源名称，用于目标的 source 字段和 db_probe_discovery_* 指标的 source label
+const KubernetesSource = "kubernetes_secret"
+
+const (
+	// serviceAccountDir Pod 中 ServiceAccount 的 token、CA 证书和命名空间的挂载目录
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	// watchTimeout 单次 watch 请求的时长，到期后重新读取 Secret 并建立新的 watch
+	watchTimeout = 5 * time.Minute
+	// requestTimeout 读取 Secret 的超时时间
+	requestTimeout = 10 * time.Second
+	// minRetryInterval、maxRetryInterval 读取或 watch 失败后的重试间隔，每次失败翻倍
+	minRetryInterval = 5 * time.Second
+	maxRetryInterval = time.Minute
+)
+
+// errWatchExpired watch 的 resourceVersion 已过期（410 Gone），需要重新读取 Secret
+var errWatchExpired = errors.New("watch 的 resourceVersion 已过期")
+
+// secretKey Secret 的命名空间和名称
+type secretKey struct {
+	namespace string
+	name      string
+}
+
+func (k secretKey) String() string {
+	return k.namespace + "/" + k.name
+}
+
+// secretObject Secret 对象中用到的字段，data 的值在 JSON 中为 base64 编码
+type secretObject struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Data map[string][]byte `json:"data"`
+}
+
+// KubernetesSecrets 从 Kubernetes Secret 读取配置了 secret_ref 的目标的凭据
+// 每个 Secret 一个 goroutine：先读取一次，再从读取到的 resourceVersion 开始 watch，Secret 变化后立即重新同步目标
+type KubernetesSecrets struct {
+	server string
+	token  string // token 文件路径，为空时不带认证信息（如经 kubectl proxy 访问）
+	client *http.Client
+	probe  *prober.Prober
+
+	// targets 配置了 secret_ref 的目标（命名空间已补全）
+	targets []config.DBConfig
+
+	// mu 保护以下字段，并串行化 resync
+	mu sync.Mutex
+	// data 最近一次读取到的 Secret 内容，Secret 被删除时保留
+	data map[secretKey]map[string][]byte
+	// resolved 各目标最近一次成功填入凭据的配置，凭据不可用时继续使用
+	resolved map[string]config.DBConfig
+	// failing 各 Secret 的读取是否处于持续失败状态，只在进入和恢复时输出日志
+	failing map[secretKey]bool
+	// missing 已输出过不存在日志的 Secret，每次重新读取时不再重复输出
+	missing map[secretKey]bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewKubernetes 创建 Kubernetes Secret 凭据读取，dbCfgs 中只处理配置了 secret_ref 的目标
+// 未配置 api_server 时使用 Pod 的 ServiceAccount 访问集群内的 API Server
+func NewKubernetes(cfg config.KubernetesConfig, dbCfgs []config.DBConfig, probe *prober.Prober) (*KubernetesSecrets, error) {
+	server := strings.TrimSuffix(cfg.APIServer, "/")
+	if server == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, fmt.Errorf("未在 Kubernetes 集群中运行（缺少 KUBERNETES_SERVICE_HOST/PORT 环境变量），请配置 kubernetes.api_server")
+		}
+		server = "https://" + net.JoinHostPort(host, port)
+	}
+
+	tokenFile := cfg.TokenFile
+	if tokenFile == "" && cfg.APIServer == "" {
+		tokenFile = serviceAccountDir + "/token"
+	}
+	caFile := cfg.CAFile
+	if caFile == "" && cfg.APIServer == "" {
+		caFile = serviceAccountDir + "/ca.crt"
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 Kubernetes CA 证书失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("Kubernetes CA 证书文件中没有合法的证书: %s", caFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	// 未指定命名空间的 secret_ref 使用探针所在的命名空间
+	defaultNamespace := ""
+	if raw, err := os.ReadFile(serviceAccountDir + "/namespace"); err == nil {
+		defaultNamespace = strings.TrimSpace(string(raw))
+	}
+	var targets []config.DBConfig
+	for _, dbCfg := range dbCfgs {
+		if dbCfg.SecretRef == nil {
+			continue
+		}
+		ref := *dbCfg.SecretRef
+		if ref.Namespace == "" {
+			if defaultNamespace == "" {
+				return nil, fmt.Errorf("目标 %s 的 secret_ref 未指定 namespace，且无法读取探针所在的命名空间", dbCfg.Name)
+			}
+			ref.Namespace = defaultNamespace
+		}
+		dbCfg.SecretRef = &ref
+		targets = append(targets, dbCfg)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &KubernetesSecrets{
+		server:   server,
+		token:    tokenFile,
+		client:   &http.Client{Transport: transport},
+		probe:    probe,
+		targets:  targets,
+		data:     make(map[secretKey]map[string][]byte),
+		resolved: make(map[string]config.DBConfig),
+		failing:  make(map[secretKey]bool),
+		missing:  make(map[secretKey]bool),
+		ctx:      ctx,
+		cancel:   cancel,
+	}, nil
+}
+
+// Start 为每个引用的 Secret 启动读取和 watch 循环
+func (k *KubernetesSecrets) Start() {
+	keys := make(map[secretKey]bool)
+	for _, dbCfg := range k.targets {
+		keys[secretKey{dbCfg.SecretRef.Namespace, dbCfg.SecretRef.Name}] = true
+	}
+	logger.L().Infow("Kubernetes Secret 凭据读取已启用",
+		"api_server", k.server,
+		"secrets", len(keys),
+		"targets", len(k.targets),
+	)
+	for key := range keys {
+		k.wg.Add(1)
+		go k.run(key)
+	}
+}
+
+// Stop 停止读取和 watch，已加入的目标由探针继续管理，必须在探针停止之前调用
+func (k *KubernetesSecrets) Stop() {
+	k.cancel()
+	k.wg.Wait()
+}
+
+func (k *KubernetesSecrets) run(key secretKey) {
+	defer k.wg.Done()
+
+	retry := minRetryInterval
+	for k.ctx.Err() == nil {
+		err := k.readAndWatch(key)
+		if k.ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errWatchExpired) {
+			continue
+		}
+		if err != nil {
+			metrics.RecordDiscoveryFailure(KubernetesSource)
+			k.setFailing(key, err)
+			select {
+			case <-k.ctx.Done():
+				return
+			case <-time.After(retry):
+			}
+			retry = min(retry*2, maxRetryInterval)
+			continue
+		}
+		retry = minRetryInterval
+	}
+}
+
+// readAndWatch 读取一次 Secret 并同步目标，然后 watch 到超时、连接断开或 resourceVersion 过期
+func (k *KubernetesSecrets) readAndWatch(key secretKey) error {
+	secret, err := k.get(key)
+	if err != nil {
+		return err
+	}
+	k.setFailing(key, nil)
+	resourceVersion := ""
+	if secret == nil {
+		k.mu.Lock()
+		if !k.missing[key] {
+			k.missing[key] = true
+			logger.L().Warnw("Kubernetes Secret 不存在，引用该 Secret 的目标在 Secret 创建后开始探测（已读取过的凭据继续使用）", "secret", key.String())
+		}
+		k.mu.Unlock()
+	} else {
+		resourceVersion = secret.Metadata.ResourceVersion
+		k.update(key, secret.Data)
+	}
+	return k.watch(key, resourceVersion)
+}
+
+// get 读取 Secret，不存在时返回 nil
+func (k *KubernetesSecrets) get(key secretKey) (*secretObject, error) {
+	ctx, cancel := context.WithTimeout(k.ctx, requestTimeout)
+	defer cancel()
+	resp, err := k.request(ctx, "/api/v1/namespaces/"+url.PathEscape(key.namespace)+"/secrets/"+url.PathEscape(key.name), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp)
+	}
+	var secret secretObject
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return nil, fmt.Errorf("解析 Secret 失败: %w", err)
+	}
+	return &secret, nil
+}
+
+// watch 从 resourceVersion 开始 watch Secret 的变化（为空时从当前状态开始，已存在的 Secret 以 ADDED 事件返回）
+// 服务端在 watchTimeout 后正常结束时返回 nil
+func (k *KubernetesSecrets) watch(key secretKey, resourceVersion string) error {
+	query := url.Values{}
+	query.Set("watch", "true")
+	query.Set("fieldSelector", "metadata.name="+key.name)
+	query.Set("timeoutSeconds", fmt.Sprint(int(watchTimeout.Seconds())))
+	if resourceVersion != "" {
+		query.Set("resourceVersion", resourceVersion)
+	}
+	// 服务端会在 timeoutSeconds 后结束 watch，客户端多留一些余量，避免连接异常挂起时无法恢复
+	ctx, cancel := context.WithTimeout(k.ctx, watchTimeout+time.Minute)
+	defer cancel()
+	resp, err := k.request(ctx, "/api/v1/namespaces/"+url.PathEscape(key.namespace)+"/secrets", query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return statusError(resp)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event struct {
+			Type   string          `json:"type"`
+			Object json.RawMessage `json:"object"`
+		}
+		if err := decoder.Decode(&event); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("watch Secret 中断: %w", err)
+		}
+		switch event.Type {
+		case "ADDED", "MODIFIED":
+			var secret secretObject
+			if err := json.Unmarshal(event.Object, &secret); err != nil {
+				return fmt.Errorf("解析 Secret 失败: %w", err)
+			}
+			k.update(key, secret.Data)
+		case "DELETED":
+			logger.L().Warnw("Kubernetes Secret 已删除，继续使用上一次读取的凭据", "secret", key.String())
+		case "ERROR":
+			var status struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			json.Unmarshal(event.Object, &status)
+			if status.Code == http.StatusGone {
+				return errWatchExpired
+			}
+			return fmt.Errorf("watch Secret 出错: %d %s", status.Code, status.Message)
+		}
+	}
+}
+
+// request 向 API Server 发起 GET 请求，token 每次请求时重新读取（ServiceAccount token 会定期轮换）
+func (k *KubernetesSecrets) request(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	target := k.server + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if k.token != "" {
+		token, err := os.ReadFile(k.token)
+		if err != nil {
+			return nil, fmt.Errorf("读取 Kubernetes token 失败: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	return k.client.Do(req)
+}
+
+// statusError 把 API Server 的错误响应转换为错误，403 时提示所需的 RBAC 权限
+func statusError(resp *http.Response) error {
+	var status struct {
+		Message string `json:"message"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if json.Unmarshal(body, &status) != nil || status.Message == "" {
+		status.Message = strings.TrimSpace(string(body))
+	}
+	if resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("无权读取 Secret，请为探针的 ServiceAccount 授予 secrets 的 get、watch 权限: %s", status.Message)
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("Kubernetes API 认证失败，请检查 token: %s", status.Message)
+	}
+	return fmt.Errorf("Kubernetes API 返回 %s: %s", resp.Status, status.Message)
+}
+
+// setFailing 记录 Secret 的读取状态，err 为 nil 表示读取成功
+func (k *KubernetesSecrets) setFailing(key secretKey, err error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err != nil {
+		if !k.failing[key] {
+			k.failing[key] = true
+			logger.L().Warnw("读取 Kubernetes Secret 失败，继续使用上一次读取的凭据，稍后重试", "secret", key.String(), "error", err)
+		}
+		return
+	}
+	if k.failing[key] {
+		k.failing[key] = false
+		logger.L().Infow("读取 Kubernetes Secret 恢复", "secret", key.String())
+	}
+}
+
+// update 保存 Secret 的内容并重新同步目标
+func (k *KubernetesSecrets) update(key secretKey, data map[string][]byte) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if data == nil {
+		data = map[string][]byte{}
+	}
+	k.missing[key] = false
+	k.data[key] = data
+	k.resync()
+}
+
+// resync 为每个目标填入凭据并对齐探测目标，调用方需要持有 mu
+// Secret 尚未读取到的目标不加入探测；凭据不完整（如缺少 key）的目标继续使用上一次的凭据
+func (k *KubernetesSecrets) resync() {
+	dbCfgs := make([]config.DBConfig, 0, len(k.targets))
+	for _, dbCfg := range k.targets {
+		key := secretKey{dbCfg.SecretRef.Namespace, dbCfg.SecretRef.Name}
+		data, ok := k.data[key]
+		if ok {
+			resolved, err := resolve(dbCfg, data)
+			if err == nil {
+				k.resolved[dbCfg.Name] = resolved
+			} else {
+				metrics.RecordDiscoveryFailure(KubernetesSource)
+				logger.L().Warnw("Kubernetes Secret 中的凭据不可用，继续使用上一次读取的凭据",
+					"db_name", dbCfg.Name,
+					"secret", key.String(),
+					"error", err,
+				)
+			}
+		}
+		if resolved, ok := k.resolved[dbCfg.Name]; ok {
+			dbCfgs = append(dbCfgs, resolved)
+		}
+	}
+
+	result := k.probe.SyncTargets(KubernetesSource, dbCfgs)
+	metrics.SetDiscoveryTargets(KubernetesSource, len(dbCfgs)-len(result.Skipped))
+	// 目标配置来自启动时的配置文件，只有凭据会变化，更新的目标即为凭据轮换的目标
+	for _, name := range result.Updated {
+		metrics.RecordCredentialRotation(name, KubernetesSource)
+	}
+	if result.Changed() {
+		logger.L().Infow("已按 Kubernetes Secret 更新目标凭据",
+			"source", KubernetesSource,
+			"added", result.Added,
+			"updated", result.Updated,
+			"removed", result.Removed,
+			"skipped", result.Skipped,
+		)
+	}
+}
+
+// resolve 把 Secret 中的值填入目标配置并校验，值末尾的换行（kubectl create secret --from-file 常见）被去掉
+func resolve(dbCfg config.DBConfig, data map[string][]byte) (config.DBConfig, error) {
+	ref := dbCfg.SecretRef
+	for _, field := range []struct {
+		key   string
+		value *string
+	}{
+		{ref.Key, &dbCfg.Password},
+		{ref.UserKey, &dbCfg.User},
+		{ref.DSNKey, &dbCfg.DSN},
+	} {
+		if field.key == "" {
+			continue
+		}
+		value, ok := data[field.key]
+		if !ok {
+			return dbCfg, fmt.Errorf("Secret 中没有 key %s", field.key)
+		}
+		*field.value = strings.TrimRight(string(value), "\r\n")
+	}
+
+	// 凭据已填入，按普通目标完整校验（secret_ref 与直接配置的字段互斥，校验时先去掉）
+	dbCfg.SecretRef = nil
+	if err := config.ValidateDatabase("databases["+dbCfg.Name+"]", &dbCfg); err != nil {
+		return dbCfg, err
+	}
+	dbCfg.SecretRef = ref
+	return dbCfg, nil
+}
@@ -0,0 +1,377 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/internal/metrics"
+	"github.com/imkerbos/db-probe/internal/prober"
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+// StoreSource 外部密钥存储凭据的来源名称，用于目标的 source 字段和 db_probe_discovery_* 指标的 source label
+const StoreSource = "secret_store"
+
+// errNotRenewable 凭据没有可以续期的租约
+var errNotRenewable = errors.New("凭据不支持续期")
+
+// Provider 外部密钥存储，按 user_ref、password_ref、dsn_ref 中的路径读取凭据
+type Provider interface {
+	// Read 读取路径下的全部 key
+	Read(ctx context.Context, path string) (*Secret, error)
+	// Renew 续期凭据的租约，返回续期后的剩余有效期；不支持续期时返回 errNotRenewable
+	Renew(ctx context.Context, secret *Secret) (time.Duration, error)
+}
+
+// Secret 从密钥存储读取到的一组凭据
+type Secret struct {
+	Data map[string]string
+	// LeaseID、LeaseDuration、Renewable 凭据的租约（如 Vault 数据库引擎签发的动态账号），没有租约时为零值
+	LeaseID       string
+	LeaseDuration time.Duration
+	Renewable     bool
+}
+
+// storeKey 密钥存储中的一个路径
+type storeKey struct {
+	provider string
+	path     string
+}
+
+func (k storeKey) String() string {
+	return k.provider + ":" + k.path
+}
+
+// storeTarget 配置了 *_ref 的目标及其解析后的引用
+type storeTarget struct {
+	cfg  config.DBConfig
+	refs []storeRef
+}
+
+// storeRef 引用的凭据及其填入的字段
+type storeRef struct {
+	ref   config.CredentialRef
+	value func(*config.DBConfig) *string
+}
+
+// Store 从外部密钥存储读取配置了 user_ref、password_ref、dsn_ref 的目标的凭据
+// 每个路径一个 goroutine：读取后同步目标；有可续期租约的凭据在租约到期前续期，续期失败或接近最大有效期时重新读取，
+// 没有租约的凭据每隔 refresh_interval 重新读取；凭据变化（轮换）的目标重建连接
+type Store struct {
+	providers map[string]Provider
+	refresh   time.Duration
+	probe     *prober.Prober
+	targets   []storeTarget
+
+	// mu 保护以下字段，并串行化 resync
+	mu sync.Mutex
+	// data 各路径最近一次读取到的凭据
+	data map[storeKey]map[string]string
+	// resolved 各目标最近一次成功填入凭据的配置，凭据不可用时继续使用
+	resolved map[string]config.DBConfig
+	// failing 各路径的读取是否处于持续失败状态，只在进入和恢复时输出日志
+	failing map[storeKey]bool
+
+	// wake 各路径的提前重新读取信号，目标认证失败时发送（密码可能已轮换）；targetKeys 各目标引用的路径
+	wake       map[storeKey]chan struct{}
+	targetKeys map[string][]storeKey
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewStore 创建外部密钥存储凭据读取，dbCfgs 中只处理配置了 *_ref 的目标，只创建被引用的密钥存储
+func NewStore(cfg config.SecretsConfig, dbCfgs []config.DBConfig, probe *prober.Prober) (*Store, error) {
+	s := &Store{
+		providers:  make(map[string]Provider),
+		refresh:    cfg.RefreshInterval,
+		probe:      probe,
+		data:       make(map[storeKey]map[string]string),
+		resolved:   make(map[string]config.DBConfig),
+		failing:    make(map[storeKey]bool),
+		wake:       make(map[storeKey]chan struct{}),
+		targetKeys: make(map[string][]storeKey),
+	}
+	for _, dbCfg := range dbCfgs {
+		if !dbCfg.HasCredentialRefs() {
+			continue
+		}
+		target := storeTarget{cfg: dbCfg}
+		for _, field := range []struct {
+			ref   string
+			value func(*config.DBConfig) *string
+		}{
+			{dbCfg.UserRef, func(db *config.DBConfig) *string { return &db.User }},
+			{dbCfg.PasswordRef, func(db *config.DBConfig) *string { return &db.Password }},
+			{dbCfg.DSNRef, func(db *config.DBConfig) *string { return &db.DSN }},
+		} {
+			if field.ref == "" {
+				continue
+			}
+			ref, err := config.ParseCredentialRef(field.ref)
+			if err != nil {
+				return nil, fmt.Errorf("目标 %s: %w", dbCfg.Name, err)
+			}
+			if _, ok := s.providers[ref.Provider]; !ok {
+				provider, err := newProvider(ref.Provider, cfg)
+				if err != nil {
+					return nil, err
+				}
+				s.providers[ref.Provider] = provider
+			}
+			target.refs = append(target.refs, storeRef{ref: ref, value: field.value})
+			key := storeKey{ref.Provider, ref.Path}
+			if _, ok := s.wake[key]; !ok {
+				s.wake[key] = make(chan struct{}, 1)
+			}
+			if !slices.Contains(s.targetKeys[dbCfg.Name], key) {
+				s.targetKeys[dbCfg.Name] = append(s.targetKeys[dbCfg.Name], key)
+			}
+		}
+		s.targets = append(s.targets, target)
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s, nil
+}
+
+// newProvider 按名称创建密钥存储
+func newProvider(name string, cfg config.SecretsConfig) (Provider, error) {
+	switch name {
+	case "vault":
+		return NewVault(cfg.Vault)
+	case "aws-sm":
+		return NewAWSSecretsManager(cfg.AWS)
+	}
+	return nil, fmt.Errorf("不支持的密钥存储: %s", name)
+}
+
+// Start 为每个引用的路径启动读取循环
+func (s *Store) Start() {
+	logger.L().Infow("外部密钥存储凭据读取已启用",
+		"paths", len(s.wake),
+		"targets", len(s.targets),
+		"refresh_interval", s.refresh,
+	)
+	for key := range s.wake {
+		s.wg.Add(1)
+		go s.run(key)
+	}
+}
+
+// Handle 处理目标状态变化事件：目标因认证失败变为不可用时立即重新读取它引用的凭据，
+// 密码在密钥存储中轮换后（如 RDS 自动轮换）不必等到下一次定期读取；在探测 goroutine 中调用，不阻塞
+func (s *Store) Handle(ev prober.StateEvent) {
+	if ev.Up || ev.Stage != "认证" {
+		return
+	}
+	for _, key := range s.targetKeys[ev.Target] {
+		select {
+		case s.wake[key] <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Stop 停止读取，已加入的目标由探针继续管理，必须在探针停止之前调用
+func (s *Store) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *Store) run(key storeKey) {
+	defer s.wg.Done()
+
+	provider := s.providers[key.provider]
+	retry := minRetryInterval
+	for s.ctx.Err() == nil {
+		ctx, cancel := context.WithTimeout(s.ctx, requestTimeout)
+		secret, err := provider.Read(ctx, key.path)
+		cancel()
+		if s.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			metrics.RecordDiscoveryFailure(StoreSource)
+			s.setFailing(key, err)
+			if !s.sleep(retry) {
+				return
+			}
+			retry = min(retry*2, maxRetryInterval)
+			continue
+		}
+		retry = minRetryInterval
+		s.setFailing(key, nil)
+		s.update(key, secret.Data)
+		// 读取之前收到的提前读取信号已经由本次读取满足
+		select {
+		case <-s.wake[key]:
+		default:
+		}
+		if !s.hold(key, provider, secret) {
+			return
+		}
+	}
+}
+
+// hold 在下一次读取之前保持凭据有效：可续期的租约在剩余 1/3 时续期，续期失败或续期后的有效期不足最初的 1/3（接近最大有效期）时返回，
+// 由调用方重新读取（动态凭据会得到新的账号密码，旧账号在租约到期前仍然可用）；没有租约的凭据等待 refresh_interval
+// 引用该路径的目标认证失败时立即返回；停止时返回 false
+func (s *Store) hold(key storeKey, provider Provider, secret *Secret) bool {
+	if !secret.Renewable || secret.LeaseDuration <= 0 {
+		wait := s.refresh
+		if secret.LeaseDuration > 0 {
+			wait = min(wait, secret.LeaseDuration*2/3)
+		}
+		_, stopped := s.wait(key, wait)
+		return !stopped
+	}
+
+	remaining := secret.LeaseDuration
+	for {
+		if woken, stopped := s.wait(key, remaining*2/3); stopped || woken {
+			return !stopped
+		}
+		ctx, cancel := context.WithTimeout(s.ctx, requestTimeout)
+		renewed, err := provider.Renew(ctx, secret)
+		cancel()
+		if s.ctx.Err() != nil {
+			return false
+		}
+		if err != nil {
+			metrics.RecordDiscoveryFailure(StoreSource)
+			logger.L().Warnw("续期凭据租约失败，重新读取凭据", "path", key.String(), "error", err)
+			return true
+		}
+		if renewed < secret.LeaseDuration/3 {
+			logger.L().Infow("凭据租约接近最大有效期，重新读取凭据", "path", key.String(), "remaining", renewed)
+			return true
+		}
+		remaining = renewed
+	}
+}
+
+// wait 等待 d，woken 表示提前收到了重新读取信号，stopped 表示已停止
+func (s *Store) wait(key storeKey, d time.Duration) (woken, stopped bool) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-s.ctx.Done():
+		return false, true
+	case <-timer.C:
+		return false, false
+	case <-s.wake[key]:
+		logger.L().Infow("引用该凭据的目标认证失败，立即重新读取凭据", "path", key.String())
+		return true, false
+	}
+}
+
+// sleep 等待 d，停止时返回 false
+func (s *Store) sleep(d time.Duration) bool {
+	select {
+	case <-s.ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// setFailing 记录路径的读取状态，err 为 nil 表示读取成功
+func (s *Store) setFailing(key storeKey, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		if !s.failing[key] {
+			s.failing[key] = true
+			logger.L().Warnw("读取外部密钥存储的凭据失败，继续使用上一次读取的凭据，稍后重试", "path", key.String(), "error", err)
+		}
+		return
+	}
+	if s.failing[key] {
+		s.failing[key] = false
+		logger.L().Infow("读取外部密钥存储的凭据恢复", "path", key.String())
+	}
+}
+
+// update 保存读取到的凭据并重新同步目标
+func (s *Store) update(key storeKey, data map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if data == nil {
+		data = map[string]string{}
+	}
+	s.data[key] = data
+	s.resync()
+}
+
+// resync 为每个目标填入凭据并对齐探测目标，调用方需要持有 mu
+// 引用的路径尚未全部读取到的目标不加入探测；凭据不完整（如缺少 key）的目标继续使用上一次的凭据
+func (s *Store) resync() {
+	dbCfgs := make([]config.DBConfig, 0, len(s.targets))
+	for _, target := range s.targets {
+		resolved, err := s.resolve(target)
+		switch {
+		case err == nil:
+			s.resolved[target.cfg.Name] = resolved
+		case !errors.Is(err, errNotLoaded):
+			metrics.RecordDiscoveryFailure(StoreSource)
+			logger.L().Warnw("外部密钥存储中的凭据不可用，继续使用上一次读取的凭据",
+				"db_name", target.cfg.Name,
+				"error", err,
+			)
+		}
+		if resolved, ok := s.resolved[target.cfg.Name]; ok {
+			dbCfgs = append(dbCfgs, resolved)
+		}
+	}
+
+	result := s.probe.SyncTargets(StoreSource, dbCfgs)
+	metrics.SetDiscoveryTargets(StoreSource, len(dbCfgs)-len(result.Skipped))
+	for _, name := range result.Updated {
+		metrics.RecordCredentialRotation(name, StoreSource)
+	}
+	if result.Changed() {
+		logger.L().Infow("已按外部密钥存储更新目标凭据",
+			"source", StoreSource,
+			"added", result.Added,
+			"updated", result.Updated,
+			"removed", result.Removed,
+			"skipped", result.Skipped,
+		)
+	}
+}
+
+// errNotLoaded 目标引用的路径尚未读取到
+var errNotLoaded = errors.New("凭据尚未读取")
+
+// resolve 把读取到的凭据填入目标配置并校验，值末尾的换行被去掉
+func (s *Store) resolve(target storeTarget) (config.DBConfig, error) {
+	dbCfg := target.cfg
+	for _, r := range target.refs {
+		key := storeKey{r.ref.Provider, r.ref.Path}
+		data, ok := s.data[key]
+		if !ok {
+			return dbCfg, errNotLoaded
+		}
+		value, ok := data[r.ref.Key]
+		if !ok {
+			return dbCfg, fmt.Errorf("%s 中没有 key %s", key, r.ref.Key)
+		}
+		*r.value(&dbCfg) = strings.TrimRight(value, "\r\n")
+	}
+
+	// 凭据已填入，按普通目标完整校验（*_ref 与直接配置的字段互斥，校验时先去掉）
+	userRef, passwordRef, dsnRef := dbCfg.UserRef, dbCfg.PasswordRef, dbCfg.DSNRef
+	dbCfg.UserRef, dbCfg.PasswordRef, dbCfg.DSNRef = "", "", ""
+	if err := config.ValidateDatabase("databases["+dbCfg.Name+"]", &dbCfg); err != nil {
+		return dbCfg, err
+	}
+	dbCfg.UserRef, dbCfg.PasswordRef, dbCfg.DSNRef = userRef, passwordRef, dsnRef
+	return dbCfg, nil
+}
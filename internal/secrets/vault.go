@@ -0,0 +1,335 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+	"github.com/imkerbos/db-probe/pkg/logger"
+)
+
+// Vault 从 HashiCorp Vault 读取凭据，支持 KV v1/v2 和签发动态账号的引擎（如 database/creds/<role>）
+// 认证使用 token（可续期的 token 在有效期剩余 1/3 时续期）或 Kubernetes 认证（token 接近过期时重新登录）
+type Vault struct {
+	address   string
+	namespace string
+	client    *http.Client
+
+	tokenFile string // token 文件，每次请求时重新读取
+	envToken  string // 未配置 token 文件时使用的 VAULT_TOKEN
+
+	kubernetesRole      string
+	kubernetesMount     string
+	kubernetesTokenFile string
+
+	// mu 保护以下字段，请求前检查 token 是否需要续期或重新登录
+	mu sync.Mutex
+	// token 当前使用的 token；使用 token 文件时为文件内容，文件变化后重新查询有效期
+	token string
+	// tokenTTL、tokenRenewable、tokenIssued token 的有效期、是否可续期及取得有效期的时间，有效期为 0 表示不过期
+	tokenTTL       time.Duration
+	tokenRenewable bool
+	tokenIssued    time.Time
+}
+
+// vaultResponse Vault API 响应中用到的字段
+type vaultResponse struct {
+	LeaseID       string                 `json:"lease_id"`
+	LeaseDuration int64                  `json:"lease_duration"`
+	Renewable     bool                   `json:"renewable"`
+	Data          map[string]interface{} `json:"data"`
+	Auth          *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int64  `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+// NewVault 创建 Vault 凭据读取，未配置的字段使用 VAULT_ADDR、VAULT_NAMESPACE、VAULT_TOKEN、VAULT_CACERT 环境变量
+func NewVault(cfg config.VaultConfig) (*Vault, error) {
+	address := strings.TrimSuffix(envDefault(cfg.Address, "VAULT_ADDR"), "/")
+	if address == "" {
+		return nil, fmt.Errorf("未配置 Vault 地址，请配置 secrets.vault.address 或 VAULT_ADDR 环境变量")
+	}
+	v := &Vault{
+		address:             address,
+		namespace:           envDefault(cfg.Namespace, "VAULT_NAMESPACE"),
+		tokenFile:           cfg.TokenFile,
+		kubernetesRole:      cfg.KubernetesRole,
+		kubernetesMount:     strings.Trim(cfg.KubernetesMount, "/"),
+		kubernetesTokenFile: cfg.KubernetesTokenFile,
+	}
+	if v.kubernetesMount == "" {
+		v.kubernetesMount = "kubernetes"
+	}
+	if v.kubernetesTokenFile == "" {
+		v.kubernetesTokenFile = serviceAccountDir + "/token"
+	}
+	if v.kubernetesRole == "" && v.tokenFile == "" {
+		v.envToken = os.Getenv("VAULT_TOKEN")
+		if v.envToken == "" {
+			return nil, fmt.Errorf("未配置 Vault 认证，请配置 secrets.vault.token_file、kubernetes_role 或 VAULT_TOKEN 环境变量")
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caFile := envDefault(cfg.CAFile, "VAULT_CACERT"); caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 Vault CA 证书失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("Vault CA 证书文件中没有合法的证书: %s", caFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	v.client = &http.Client{Transport: transport}
+
+	logger.L().Infow("Vault 凭据读取已配置",
+		"address", address,
+		"namespace", v.namespace,
+		"auth", v.authMethod(),
+	)
+	return v, nil
+}
+
+func envDefault(value, env string) string {
+	if value != "" {
+		return value
+	}
+	return os.Getenv(env)
+}
+
+func (v *Vault) authMethod() string {
+	switch {
+	case v.kubernetesRole != "":
+		return "kubernetes"
+	case v.tokenFile != "":
+		return "token_file"
+	}
+	return "token"
+}
+
+// Read 读取路径下的凭据，KV v2 的路径需要包含 data（如 secret/data/db/orders），返回值中已去掉 KV v2 的外层结构
+func (v *Vault) Read(ctx context.Context, path string) (*Secret, error) {
+	resp, err := v.request(ctx, http.MethodGet, "/v1/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	data := resp.Data
+	// KV v2 的响应为 {"data": {"data": {...}, "metadata": {...}}}
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"].(map[string]interface{}); ok {
+			data = inner
+		}
+	}
+	if data == nil {
+		return nil, fmt.Errorf("Vault 路径 %s 没有数据（KV v2 的路径需要包含 data，如 secret/data/db/orders）", path)
+	}
+	secret := &Secret{
+		Data:          make(map[string]string, len(data)),
+		LeaseID:       resp.LeaseID,
+		LeaseDuration: time.Duration(resp.LeaseDuration) * time.Second,
+		Renewable:     resp.Renewable && resp.LeaseID != "",
+	}
+	for key, value := range data {
+		switch value := value.(type) {
+		case string:
+			secret.Data[key] = value
+		case nil:
+		default:
+			raw, _ := json.Marshal(value)
+			secret.Data[key] = string(raw)
+		}
+	}
+	return secret, nil
+}
+
+// Renew 按最初的有效期续期租约，返回 Vault 实际给出的有效期（受 max_ttl 限制，接近最大有效期时会变短）
+func (v *Vault) Renew(ctx context.Context, secret *Secret) (time.Duration, error) {
+	if !secret.Renewable {
+		return 0, errNotRenewable
+	}
+	body := map[string]interface{}{
+		"lease_id":  secret.LeaseID,
+		"increment": int64(secret.LeaseDuration.Seconds()),
+	}
+	resp, err := v.request(ctx, http.MethodPut, "/v1/sys/leases/renew", body)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(resp.LeaseDuration) * time.Second, nil
+}
+
+// request 带 token 发起请求；使用 Kubernetes 认证时 token 失效（403）后重新登录并重试一次
+func (v *Vault) request(ctx context.Context, method, path string, body interface{}) (*vaultResponse, error) {
+	token, err := v.currentToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, status, err := v.do(ctx, method, path, token, body)
+	if status == http.StatusForbidden && v.kubernetesRole != "" {
+		v.mu.Lock()
+		v.token = ""
+		v.mu.Unlock()
+		if token, err = v.currentToken(ctx); err != nil {
+			return nil, err
+		}
+		resp, _, err = v.do(ctx, method, path, token, body)
+	}
+	return resp, err
+}
+
+// currentToken 返回可用的 token：按需登录、查询有效期，可续期的 token 在有效期剩余 1/3 时续期，
+// Kubernetes 认证得到的 token 在剩余 1/3 时重新登录
+func (v *Vault) currentToken(ctx context.Context) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.kubernetesRole != "" {
+		if v.token == "" || v.expiring() {
+			if err := v.login(ctx); err != nil {
+				return "", err
+			}
+		}
+		return v.token, nil
+	}
+
+	token := v.envToken
+	if v.tokenFile != "" {
+		raw, err := os.ReadFile(v.tokenFile)
+		if err != nil {
+			return "", fmt.Errorf("读取 Vault token 失败: %w", err)
+		}
+		token = strings.TrimSpace(string(raw))
+	}
+	if token != v.token {
+		v.token = token
+		if err := v.lookupSelf(ctx); err != nil {
+			v.token = ""
+			return "", err
+		}
+	}
+	if v.tokenRenewable && v.expiring() {
+		if err := v.renewSelf(ctx); err != nil {
+			// 续期失败时继续使用当前 token，到期前的下一次请求会再次尝试
+			logger.L().Warnw("续期 Vault token 失败", "error", err)
+		}
+	}
+	return v.token, nil
+}
+
+// expiring token 的有效期是否已过去 2/3，调用方需要持有 mu
+func (v *Vault) expiring() bool {
+	return v.tokenTTL > 0 && time.Since(v.tokenIssued) > v.tokenTTL*2/3
+}
+
+// login 使用 ServiceAccount token 以 Kubernetes 认证登录，调用方需要持有 mu
+func (v *Vault) login(ctx context.Context) error {
+	jwt, err := os.ReadFile(v.kubernetesTokenFile)
+	if err != nil {
+		return fmt.Errorf("读取 Kubernetes ServiceAccount token 失败: %w", err)
+	}
+	body := map[string]string{"role": v.kubernetesRole, "jwt": strings.TrimSpace(string(jwt))}
+	resp, _, err := v.do(ctx, http.MethodPost, "/v1/auth/"+v.kubernetesMount+"/login", "", body)
+	if err != nil {
+		return fmt.Errorf("Vault Kubernetes 认证登录失败: %w", err)
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return fmt.Errorf("Vault Kubernetes 认证登录失败: 响应中没有 token")
+	}
+	v.token = resp.Auth.ClientToken
+	v.tokenTTL = time.Duration(resp.Auth.LeaseDuration) * time.Second
+	v.tokenRenewable = resp.Auth.Renewable
+	v.tokenIssued = time.Now()
+	return nil
+}
+
+// lookupSelf 查询 token 的剩余有效期和是否可续期，调用方需要持有 mu
+func (v *Vault) lookupSelf(ctx context.Context) error {
+	resp, _, err := v.do(ctx, http.MethodGet, "/v1/auth/token/lookup-self", v.token, nil)
+	if err != nil {
+		return fmt.Errorf("查询 Vault token 失败: %w", err)
+	}
+	ttl, _ := resp.Data["ttl"].(float64)
+	renewable, _ := resp.Data["renewable"].(bool)
+	v.tokenTTL = time.Duration(ttl) * time.Second
+	v.tokenRenewable = renewable
+	v.tokenIssued = time.Now()
+	return nil
+}
+
+// renewSelf 续期当前 token，调用方需要持有 mu
+func (v *Vault) renewSelf(ctx context.Context) error {
+	resp, _, err := v.do(ctx, http.MethodPost, "/v1/auth/token/renew-self", v.token, map[string]string{})
+	if err != nil {
+		return err
+	}
+	if resp.Auth != nil {
+		v.tokenTTL = time.Duration(resp.Auth.LeaseDuration) * time.Second
+		v.tokenRenewable = resp.Auth.Renewable
+		v.tokenIssued = time.Now()
+	}
+	return nil
+}
+
+// do 发起一次 Vault API 请求，返回解析后的响应和 HTTP 状态码；错误信息中不包含 token 和凭据
+func (v *Vault) do(ctx context.Context, method, path, token string, body interface{}) (*vaultResponse, int, error) {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return nil, 0, err
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, v.address+path, reader)
+	if err != nil {
+		return nil, 0, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("请求 Vault 失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result vaultResponse
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &result); err != nil && resp.StatusCode/100 == 2 {
+			return nil, resp.StatusCode, fmt.Errorf("解析 Vault 响应失败: %w", err)
+		}
+	}
+	if resp.StatusCode/100 != 2 {
+		message := strings.Join(result.Errors, "; ")
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return nil, resp.StatusCode, fmt.Errorf("Vault 路径 %s 不存在", strings.TrimPrefix(path, "/v1/"))
+		case http.StatusForbidden:
+			return nil, resp.StatusCode, fmt.Errorf("Vault 拒绝访问 %s，请检查 token 的 policy: %s", strings.TrimPrefix(path, "/v1/"), message)
+		}
+		return nil, resp.StatusCode, fmt.Errorf("Vault 返回 %s: %s", resp.Status, message)
+	}
+	return &result, resp.StatusCode, nil
+}
@@ -0,0 +1,433 @@
+// Package testenv 提供端到端集成测试所需的数据库环境
+// 通过 docker-compose.test.yaml 启动 MySQL、MariaDB Galera、TiDB、OceanBase、StarRocks、Oracle XE、SQL Server、CockroachDB、Redis、MongoDB、Cassandra、Elasticsearch 等容器
+// 并为每种引擎提供连接参数和就绪检测，集成测试和下游 fork 都可以复用
+// 新增数据库引擎时，只需在 compose 文件中增加服务并调用 Register 注册
+package testenv
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/imkerbos/db-probe/internal/config"
+)
+
+// ComposeFile 集成测试 compose 文件（相对仓库根目录），下游 fork 可以覆盖
+var ComposeFile = "docker-compose.test.yaml"
+
+// Engine 集成测试数据库引擎定义
+type Engine struct {
+	// Name 引擎名称，同时也是 compose 服务名
+	Name string
+	// Type 对应 config.DBConfig.Type
+	Type string
+	// Port 映射到宿主机的端口
+	Port int
+	// Config 返回连接该引擎的数据库配置（host/port 已填好）
+	Config func(host string, port int) config.DBConfig
+	// StartTimeout 等待容器就绪的最长时间
+	StartTimeout time.Duration
+}
+
+var (
+	mu      sync.RWMutex
+	engines = make(map[string]Engine)
+)
+
+func init() {
+	Register(Engine{
+		Name: "mysql",
+		Type: "mysql",
+		Port: 13306,
+		Config: func(host string, port int) config.DBConfig {
+			return config.DBConfig{
+				Name:     "it-mysql",
+				Type:     "mysql",
+				Host:     host,
+				Port:     port,
+				User:     "root",
+				Password: "dbprobe",
+				Project:  "integration",
+				Env:      "test",
+			}
+		},
+		StartTimeout: 2 * time.Minute,
+	})
+
+	// 单节点 Galera 集群（wsrep_cluster_address=gcomm:// 自举），wsrep_cluster_status 为 Primary
+	Register(Engine{
+		Name: "mariadb-galera",
+		Type: "mariadb-galera",
+		Port: 13307,
+		Config: func(host string, port int) config.DBConfig {
+			return config.DBConfig{
+				Name:     "it-mariadb-galera",
+				Type:     "mariadb-galera",
+				Host:     host,
+				Port:     port,
+				User:     "root",
+				Password: "dbprobe",
+				Project:  "integration",
+				Env:      "test",
+			}
+		},
+		StartTimeout: 2 * time.Minute,
+	})
+
+	// TiDB 默认 root 无密码，只能通过自定义 DSN 连接
+	Register(Engine{
+		Name: "tidb",
+		Type: "tidb",
+		Port: 14000,
+		Config: func(host string, port int) config.DBConfig {
+			return config.DBConfig{
+				Name:    "it-tidb",
+				Type:    "tidb",
+				Host:    host,
+				Port:    port,
+				DSN:     fmt.Sprintf("root:@tcp(%s:%d)/?timeout=5s", host, port),
+				Project: "integration",
+				Env:     "test",
+			}
+		},
+		StartTimeout: 2 * time.Minute,
+	})
+
+	// OceanBase 社区版 mini 模式，业务租户为 test，首次启动需要数分钟
+	Register(Engine{
+		Name: "oceanbase",
+		Type: "oceanbase",
+		Port: 12881,
+		Config: func(host string, port int) config.DBConfig {
+			return config.DBConfig{
+				Name:     "it-oceanbase",
+				Type:     "oceanbase",
+				Host:     host,
+				Port:     port,
+				User:     "root",
+				Tenant:   "test",
+				Password: "dbprobe",
+				Project:  "integration",
+				Env:      "test",
+			}
+		},
+		StartTimeout: 10 * time.Minute,
+	})
+
+	// Oracle XE 启动较慢，首次初始化可能需要数分钟
+	Register(Engine{
+		Name: "oracle",
+		Type: "oracle",
+		Port: 11521,
+		Config: func(host string, port int) config.DBConfig {
+			return config.DBConfig{
+				Name:        "it-oracle",
+				Type:        "oracle",
+				Host:        host,
+				Port:        port,
+				User:        "system",
+				Password:    "dbprobe",
+				ServiceName: "XEPDB1",
+				Project:     "integration",
+				Env:         "test",
+			}
+		},
+		StartTimeout: 10 * time.Minute,
+	})
+
+	// SQL Server 要求 sa 密码满足复杂度策略
+	Register(Engine{
+		Name: "mssql",
+		Type: "mssql",
+		Port: 11433,
+		Config: func(host string, port int) config.DBConfig {
+			return config.DBConfig{
+				Name:     "it-mssql",
+				Type:     "mssql",
+				Host:     host,
+				Port:     port,
+				User:     "sa",
+				Password: "DbProbe#2024",
+				Project:  "integration",
+				Env:      "test",
+			}
+		},
+		StartTimeout: 3 * time.Minute,
+	})
+
+	// CockroachDB 单节点 insecure 模式，root 用户无需密码
+	Register(Engine{
+		Name: "cockroachdb",
+		Type: "cockroachdb",
+		Port: 16257,
+		Config: func(host string, port int) config.DBConfig {
+			return config.DBConfig{
+				Name:         "it-cockroachdb",
+				Type:         "cockroachdb",
+				Host:         host,
+				Port:         port,
+				User:         "root",
+				ClusterCheck: true,
+				Project:      "integration",
+				Env:          "test",
+			}
+		},
+		StartTimeout: 2 * time.Minute,
+	})
+
+	// StarRocks all-in-one 容器中 BE 在 FE 就绪后才注册，BE 存活前 SELECT 1 已经可以成功
+	Register(Engine{
+		Name: "doris",
+		Type: "doris",
+		Port: 19030,
+		Config: func(host string, port int) config.DBConfig {
+			return config.DBConfig{
+				Name:         "it-doris",
+				Type:         "doris",
+				Host:         host,
+				Port:         port,
+				User:         "root",
+				ClusterCheck: true,
+				Project:      "integration",
+				Env:          "test",
+			}
+		},
+		StartTimeout: 3 * time.Minute,
+	})
+
+	Register(Engine{
+		Name: "redis",
+		Type: "redis",
+		Port: 16379,
+		Config: func(host string, port int) config.DBConfig {
+			return config.DBConfig{
+				Name:     "it-redis",
+				Type:     "redis",
+				Host:     host,
+				Port:     port,
+				Password: "dbprobe",
+				Query:    "GET __probe__",
+				Project:  "integration",
+				Env:      "test",
+			}
+		},
+		StartTimeout: time.Minute,
+	})
+
+	Register(Engine{
+		Name: "mongodb",
+		Type: "mongodb",
+		Port: 17017,
+		Config: func(host string, port int) config.DBConfig {
+			return config.DBConfig{
+				Name:     "it-mongodb",
+				Type:     "mongodb",
+				Host:     host,
+				Port:     port,
+				User:     "root",
+				Password: "dbprobe",
+				Project:  "integration",
+				Env:      "test",
+			}
+		},
+		StartTimeout: 2 * time.Minute,
+	})
+
+	// Cassandra 启动较慢（gossip 和 system keyspace 初始化），CQL 端口就绪后才能建立会话
+	Register(Engine{
+		Name: "cassandra",
+		Type: "cassandra",
+		Port: 19042,
+		Config: func(host string, port int) config.DBConfig {
+			return config.DBConfig{
+				Name:       "it-cassandra",
+				Type:       "cassandra",
+				Host:       host,
+				Port:       port,
+				Datacenter: "datacenter1",
+				Project:    "integration",
+				Env:        "test",
+			}
+		},
+		StartTimeout: 4 * time.Minute,
+	})
+
+	Register(Engine{
+		Name: "elasticsearch",
+		Type: "elasticsearch",
+		Port: 19200,
+		Config: func(host string, port int) config.DBConfig {
+			return config.DBConfig{
+				Name:    "it-elasticsearch",
+				Type:    "elasticsearch",
+				Host:    host,
+				Port:    port,
+				Project: "integration",
+				Env:     "test",
+			}
+		},
+		StartTimeout: 2 * time.Minute,
+	})
+
+	// Trino 端口就绪后 coordinator 仍在启动（/v1/info 中 starting 为 true），由 probeUntilUp 等待
+	Register(Engine{
+		Name: "trino",
+		Type: "trino",
+		Port: 18080,
+		Config: func(host string, port int) config.DBConfig {
+			return config.DBConfig{
+				Name:    "it-trino",
+				Type:    "trino",
+				Host:    host,
+				Port:    port,
+				User:    "db-probe",
+				Project: "integration",
+				Env:     "test",
+			}
+		},
+		StartTimeout: 2 * time.Minute,
+	})
+}
+
+// Register 注册一个集成测试引擎，同名引擎会被覆盖
+func Register(e Engine) {
+	mu.Lock()
+	defer mu.Unlock()
+	engines[e.Name] = e
+}
+
+// Engines 返回所有已注册的引擎名称（按名称排序）
+func Engines() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup 根据名称获取引擎定义
+func Lookup(name string) (Engine, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	e, ok := engines[name]
+	return e, ok
+}
+
+// Enabled 返回本次测试启用的引擎
+// 通过环境变量 DB_PROBE_TEST_ENGINES 指定（逗号分隔），未设置时启用全部引擎
+func Enabled() []string {
+	raw := strings.TrimSpace(os.Getenv("DB_PROBE_TEST_ENGINES"))
+	if raw == "" {
+		return Engines()
+	}
+	var names []string
+	for _, name := range strings.Split(raw, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Host 返回容器所在主机，可通过 DB_PROBE_TEST_HOST 覆盖（默认 127.0.0.1）
+func Host() string {
+	if host := os.Getenv("DB_PROBE_TEST_HOST"); host != "" {
+		return host
+	}
+	return "127.0.0.1"
+}
+
+// DBConfig 返回指定引擎的数据库配置
+// 端口可通过 DB_PROBE_TEST_<NAME>_PORT 覆盖（名称中的 - 替换为 _，如 DB_PROBE_TEST_MARIADB_GALERA_PORT）
+func (e Engine) DBConfig() config.DBConfig {
+	port := e.Port
+	envName := strings.ToUpper(strings.ReplaceAll(e.Name, "-", "_"))
+	if v := os.Getenv("DB_PROBE_TEST_" + envName + "_PORT"); v != "" {
+		if p, err := strconv.Atoi(v); err == nil {
+			port = p
+		}
+	}
+	return e.Config(Host(), port)
+}
+
+// WaitReady 等待引擎端口可连接，超过 StartTimeout 返回错误
+func (e Engine) WaitReady(ctx context.Context) error {
+	cfg := e.DBConfig()
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+
+	ctx, cancel := context.WithTimeout(ctx, e.StartTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	for {
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("等待 %s 就绪超时 (%s): %w", e.Name, addr, err)
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// Require 返回指定引擎的数据库配置
+// 引擎未启用时跳过测试；已启用但不可达时测试失败，避免回归被跳过掩盖
+func Require(t testing.TB, name string) config.DBConfig {
+	t.Helper()
+
+	e, ok := Lookup(name)
+	if !ok {
+		t.Fatalf("未注册的集成测试引擎: %s", name)
+	}
+
+	enabled := false
+	for _, n := range Enabled() {
+		if n == name {
+			enabled = true
+			break
+		}
+	}
+	if !enabled {
+		t.Skipf("引擎 %s 未启用（DB_PROBE_TEST_ENGINES）", name)
+	}
+
+	if err := e.WaitReady(context.Background()); err != nil {
+		t.Fatalf("引擎 %s 不可用: %v", name, err)
+	}
+	return e.DBConfig()
+}
+
+// Up 使用 docker compose 启动指定引擎（为空时启动全部）
+func Up(ctx context.Context, names ...string) error {
+	args := append([]string{"compose", "-f", ComposeFile, "up", "-d"}, names...)
+	return run(ctx, args...)
+}
+
+// Down 停止并清理集成测试容器
+func Down(ctx context.Context) error {
+	return run(ctx, "compose", "-f", ComposeFile, "down", "-v")
+}
+
+func run(ctx context.Context, args ...string) error {
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("执行 docker %s 失败: %w", strings.Join(args, " "), err)
+	}
+	return nil
+}
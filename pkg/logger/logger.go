@@ -4,6 +4,8 @@
 package logger
 
 import (
+	"fmt"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -11,8 +13,14 @@ import (
 var (
 	globalLogger *zap.Logger
 	sugar        *zap.SugaredLogger
+
+	// atomicLevel 全局 logger 的日志级别，运行中可以通过 SetLevel 调整，无需重启
+	atomicLevel = zap.NewAtomicLevelAt(zapcore.InfoLevel)
 )
 
+// adjustableLevels 运行中可以切换的日志级别，按详细程度从高到低排列
+var adjustableLevels = []zapcore.Level{zapcore.DebugLevel, zapcore.InfoLevel, zapcore.WarnLevel, zapcore.ErrorLevel}
+
 // InitLogger 初始化全局 logger（始终使用 JSON 格式输出）
 func InitLogger() error {
 	var err error
@@ -27,11 +35,17 @@ func InitLogger() error {
 	config.EncoderConfig.CallerKey = "caller"
 	config.EncoderConfig.StacktraceKey = "stacktrace"
 	config.EncoderConfig.EncodeLevel = zapcore.LowercaseLevelEncoder
+	config.Level = atomicLevel
 
-	globalLogger, err = config.Build()
+	// 同时把日志保留在内存中，支持包（support bundle）附带最近的日志，不需要到节点上翻日志文件
+	recent := newRecentCore(zapcore.NewJSONEncoder(config.EncoderConfig), atomicLevel, recentLogSize)
+	globalLogger, err = config.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(core, recent)
+	}))
 	if err != nil {
 		return err
 	}
+	recentLogs = recent.buf
 
 	sugar = globalLogger.Sugar()
 	return nil
@@ -56,6 +70,59 @@ func Logger() *zap.Logger {
 	return globalLogger
 }
 
+// Level 返回当前的日志级别
+func Level() zapcore.Level {
+	return atomicLevel.Level()
+}
+
+// SetLevel 调整日志级别，只支持 debug、info、warn、error；msg 和 keysAndValues（附加调整前后的级别 from、to）记录为一条 Warn 日志
+func SetLevel(l zapcore.Level, msg string, keysAndValues ...interface{}) error {
+	for _, adjustable := range adjustableLevels {
+		if l == adjustable {
+			changeLevel(l, 2, msg, keysAndValues)
+			return nil
+		}
+	}
+	return fmt.Errorf("不支持的日志级别: %s（支持 debug、info、warn、error）", l)
+}
+
+// IncreaseVerbosity 日志级别调低一级（输出更多日志），已经是 debug 时不变，返回调整后的级别；msg 的含义与 SetLevel 相同
+func IncreaseVerbosity(msg string, keysAndValues ...interface{}) zapcore.Level {
+	return stepLevel(-1, msg, keysAndValues)
+}
+
+// DecreaseVerbosity 日志级别调高一级（输出更少日志），已经是 error 时不变，返回调整后的级别；msg 的含义与 SetLevel 相同
+func DecreaseVerbosity(msg string, keysAndValues ...interface{}) zapcore.Level {
+	return stepLevel(1, msg, keysAndValues)
+}
+
+// stepLevel 按 adjustableLevels 的顺序移动日志级别
+func stepLevel(step int, msg string, keysAndValues []interface{}) zapcore.Level {
+	current := atomicLevel.Level()
+	i := 0
+	for i < len(adjustableLevels)-1 && adjustableLevels[i] < current {
+		i++
+	}
+	i = min(max(i+step, 0), len(adjustableLevels)-1)
+	changeLevel(adjustableLevels[i], 3, msg, keysAndValues)
+	return adjustableLevels[i]
+}
+
+// changeLevel 调整日志级别并记录调整前后的级别：输出更少日志（如调整为 error）时先记录再调整，否则调整后再记录，
+// 这条日志在调整前后较详细的级别下写出，关闭 Warn 日志的调整也会留下记录；skip 为到调用方的栈帧数，日志的 caller 为发起调整的位置
+func changeLevel(l zapcore.Level, skip int, msg string, keysAndValues []interface{}) {
+	log := L().WithOptions(zap.AddCallerSkip(skip))
+	previous := atomicLevel.Level()
+	keysAndValues = append([]interface{}{"from", previous.String(), "to", l.String()}, keysAndValues...)
+	if l > previous {
+		log.Warnw(msg, keysAndValues...)
+		atomicLevel.SetLevel(l)
+		return
+	}
+	atomicLevel.SetLevel(l)
+	log.Warnw(msg, keysAndValues...)
+}
+
 // Sync 同步日志缓冲区
 func Sync() error {
 	if globalLogger != nil {
@@ -0,0 +1,92 @@
+package logger
+
+import (
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// recentLogSize 内存中保留的最近日志条数
+const recentLogSize = 2000
+
+// recentLogs 全局 logger 最近输出的日志，InitLogger 之前为 nil
+var recentLogs *logRing
+
+// Recent 返回最近输出的日志（JSON 格式，每条一行，按时间从早到晚），最多 2000 条；未初始化时返回 nil
+func Recent() []string {
+	if recentLogs == nil {
+		return nil
+	}
+	return recentLogs.lines()
+}
+
+// logRing 固定容量的日志环形缓冲区，写满后覆盖最早的日志
+type logRing struct {
+	mu    sync.Mutex
+	items []string
+	next  int
+	full  bool
+}
+
+func (r *logRing) add(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.items[r.next] = line
+	r.next = (r.next + 1) % len(r.items)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *logRing) lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]string(nil), r.items[:r.next]...)
+	}
+	return append(append([]string(nil), r.items[r.next:]...), r.items[:r.next]...)
+}
+
+// recentCore 把日志编码后写入环形缓冲区的 zapcore.Core，与输出到标准错误的 core 组合使用
+type recentCore struct {
+	zapcore.LevelEnabler
+	enc zapcore.Encoder
+	buf *logRing
+}
+
+func newRecentCore(enc zapcore.Encoder, level zapcore.LevelEnabler, size int) *recentCore {
+	return &recentCore{LevelEnabler: level, enc: enc, buf: &logRing{items: make([]string, size)}}
+}
+
+func (c *recentCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &recentCore{LevelEnabler: c.LevelEnabler, enc: c.enc.Clone(), buf: c.buf}
+	for _, field := range fields {
+		field.AddTo(clone.enc)
+	}
+	return clone
+}
+
+func (c *recentCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+	return ce
+}
+
+func (c *recentCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	line, err := c.enc.EncodeEntry(entry, fields)
+	if err != nil {
+		return err
+	}
+	s := line.String()
+	line.Free()
+	if n := len(s); n > 0 && s[n-1] == '\n' {
+		s = s[:n-1]
+	}
+	c.buf.add(s)
+	return nil
+}
+
+func (c *recentCore) Sync() error {
+	return nil
+}